@@ -0,0 +1,179 @@
+package metrics
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// AdminHandler returns an HTTP handler for inspecting and administering the
+// registry. A GET request responds with a JSON object of the report's tags and
+// every registered counter, float counter, gauge, and histogram, with its
+// type, metadata,
+// current value (a summary, for histograms), and the time it was last written
+// to, if known (see LastUpdated). A POST request resets the metrics named in
+// its reset form values and removes the metrics named in its remove form
+// values before responding. Metrics are named as they are listed, including
+// any prefix set with WithPrefix, e.g.:
+//
+//	curl -d reset=Requests -d remove=Conn.1.Bytes http://localhost:8080/debug/metrics/admin
+//
+// Resetting a counter or float counter sets it to zero and resetting a
+// histogram discards its recorded values. Gauges and counters with functions cannot be reset.
+func AdminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET", "HEAD":
+		case "POST":
+			if err := r.ParseForm(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			for _, n := range r.PostForm["reset"] {
+				resetMetric(n)
+			}
+
+			for _, n := range r.PostForm["remove"] {
+				removeMetric(n)
+			}
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(inspect())
+	})
+}
+
+type adminReport struct {
+	Tags    map[string]string `json:",omitempty"`
+	Metrics []adminMetric
+}
+
+type adminMetric struct {
+	Name    string
+	Type    string // counter, float counter, gauge, or histogram
+	Help    string `json:",omitempty"`
+	Unit    string `json:",omitempty"`
+	Value   interface{}
+	Updated *time.Time `json:",omitempty"`
+}
+
+// inspect returns a description of every registered metric, sorted by name.
+func inspect() adminReport {
+	r := Capture()
+	md := copyMetadata()
+
+	metrics := make([]adminMetric, 0, len(r.Counters)+len(r.FloatCounters)+len(r.Gauges)+len(r.Histograms))
+	add := func(name, typ string, v interface{}) {
+		m := adminMetric{
+			Name:  name,
+			Type:  typ,
+			Help:  md[name].Help,
+			Unit:  md[name].Unit,
+			Value: v,
+		}
+		if t, ok := LastUpdated(name); ok {
+			m.Updated = &t
+		}
+		metrics = append(metrics, m)
+	}
+
+	for n, v := range r.Counters {
+		add(n, "counter", v)
+	}
+
+	for n, v := range r.FloatCounters {
+		add(n, "float counter", v)
+	}
+
+	for n, v := range r.Gauges {
+		add(n, "gauge", v)
+	}
+
+	for n, s := range r.Histograms {
+		add(n, "histogram", s)
+	}
+
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].Name < metrics[j].Name
+	})
+
+	return adminReport{Tags: r.Tags, Metrics: metrics}
+}
+
+// resetMetric sets the counter or float counter with the given resolved name to
+// zero, or discards the values recorded by the histogram with the given
+// resolved name.
+func resetMetric(name string) {
+	cm.RLock()
+	v, ok := counters[name]
+	cm.RUnlock()
+
+	if ok {
+		atomic.StoreUint64(v, 0)
+	}
+
+	fcm.RLock()
+	f, ok := floatCounters[name]
+	fcm.RUnlock()
+
+	if ok {
+		atomic.StoreUint64(f, math.Float64bits(0))
+	}
+
+	hm.RLock()
+	h, ok := histograms[name]
+	hm.RUnlock()
+
+	if ok {
+		h.clear()
+	}
+}
+
+// removeMetric removes the counter, gauge, histogram, or sketch with the given
+// resolved name.
+func removeMetric(name string) {
+	hm.RLock()
+	h, ok := histograms[name]
+	sk, isSketch := sketches[name]
+	hm.RUnlock()
+
+	if ok {
+		h.Remove()
+		return
+	}
+
+	if isSketch {
+		sk.Remove()
+		return
+	}
+
+	removeCounter(name)
+	removeFloatCounter(name)
+	if removeGauge(name) {
+		notifyRemoved(name, "gauge")
+	}
+}
+
+// clear discards all of the histogram's recorded values and exemplars, and
+// starts a new window.
+func (h *Histogram) clear() {
+	h.rw.Lock()
+	defer h.rw.Unlock()
+
+	h.hist.clear()
+	if h.reservoir != nil {
+		h.reservoir.Clear()
+	}
+
+	h.m = nil
+	h.exemplars = nil
+	h.starts = []time.Time{now()}
+}
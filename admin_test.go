@@ -0,0 +1,124 @@
+package metrics_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+type adminMetric struct {
+	Name  string
+	Type  string
+	Help  string
+	Value json.RawMessage
+}
+
+func admin(t *testing.T, form url.Values) []adminMetric {
+	r := httptest.NewRequest("GET", "/debug/metrics/admin", nil)
+	if form != nil {
+		r = httptest.NewRequest("POST", "/debug/metrics/admin", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	w := httptest.NewRecorder()
+	metrics.AdminHandler().ServeHTTP(w, r)
+
+	var v struct {
+		Metrics []adminMetric
+	}
+	if err := json.NewDecoder(w.Body).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	return v.Metrics
+}
+
+func TestAdminHandler(t *testing.T) {
+	metricstest.Reset(t)
+
+	metrics.Counter("Requests").AddN(4)
+	metrics.Describe("Requests", metrics.Metadata{Help: "requests served"})
+	metrics.FloatCounter("Bytes").Add(1.5)
+	metrics.Gauge("Conns").Set(2)
+	h := metrics.NewHistogram("Latency", 1, 1000, 3)
+	h.RecordValue(10)
+
+	m := admin(t, nil)
+
+	var names, types []string
+	for _, v := range m {
+		names = append(names, v.Name)
+		types = append(types, v.Type)
+	}
+
+	if v, want := strings.Join(names, ","), "Bytes,Conns,Latency,Requests"; v != want {
+		t.Errorf("Names were %v, but expected %v", v, want)
+	}
+
+	if v, want := strings.Join(types, ","), "float counter,gauge,histogram,counter"; v != want {
+		t.Errorf("Types were %v, but expected %v", v, want)
+	}
+
+	if v, want := m[3].Help, "requests served"; v != want {
+		t.Errorf("Help was %q, but expected %q", v, want)
+	}
+
+	if v, want := string(m[3].Value), "4"; v != want {
+		t.Errorf("Value was %v, but expected %v", v, want)
+	}
+
+	if v, want := string(m[0].Value), "1.5"; v != want {
+		t.Errorf("Value was %v, but expected %v", v, want)
+	}
+
+	admin(t, url.Values{"reset": {"Requests", "Latency", "Bytes"}, "remove": {"Conns"}})
+
+	metricstest.AssertCounter(t, "Requests", 0)
+
+	if v, want := metrics.FloatCounter("Bytes").Value(), 0.0; v != want {
+		t.Errorf("Float counter was %v, but expected %v", v, want)
+	}
+
+	if v, want := h.TotalCount(), int64(0); v != want {
+		t.Errorf("Histogram count was %v, but expected %v", v, want)
+	}
+
+	if _, ok := metrics.Gauge("Conns").Value(); ok {
+		t.Error("Gauge was not removed")
+	}
+}
+
+func TestAdminHandlerPrefix(t *testing.T) {
+	metricstest.Reset(t)
+
+	metrics.Init(metrics.WithPrefix("api."))
+	defer metrics.Init(metrics.WithPrefix(""))
+
+	metrics.Counter("Requests").AddN(4)
+	metrics.Gauge("Conns").Set(2)
+	metrics.NewHistogram("Latency", 1, 1000, 3).RecordValue(10)
+
+	admin(t, url.Values{
+		"reset":  {"api.Requests"},
+		"remove": {"api.Conns", "api.Latency"},
+	})
+
+	values := make(map[string]string)
+	for _, m := range admin(t, nil) {
+		if strings.HasPrefix(m.Name, "api.") {
+			values[m.Name] = string(m.Value)
+		}
+	}
+
+	if v, want := len(values), 1; v != want {
+		t.Errorf("Metrics were %v, but expected only api.Requests", values)
+	}
+
+	if v, want := values["api.Requests"], "0"; v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+}
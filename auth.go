@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Access restricts access to the HTTP handlers this package provides (e.g.,
+// Handler, AdminHandler, or SilenceHandler), which can reveal operational
+// details or allow metrics to be reset.
+//
+// If Username or Tokens are set, requests must carry either matching basic
+// auth credentials or one of the bearer tokens. If Networks is set, requests
+// must also come from one of the networks.
+type Access struct {
+	// Username and Password, if Username is not empty, are the credentials
+	// accepted via basic auth.
+	Username, Password string
+
+	// Tokens are the tokens accepted via an "Authorization: Bearer" header.
+	Tokens []string
+
+	// Networks are the IP addresses (e.g., "127.0.0.1") and CIDR networks
+	// (e.g., "10.0.0.0/8") requests are allowed from. If empty, requests are
+	// allowed from any address.
+	Networks []string
+}
+
+// Wrap returns an HTTP handler which responds to requests which are allowed
+// access with the given handler, to requests from networks which are not
+// allowed with 403 Forbidden, and to requests without valid credentials with
+// 401 Unauthorized. It panics if any of the networks cannot be parsed.
+func (a Access) Wrap(h http.Handler) http.Handler {
+	nets := make([]*net.IPNet, 0, len(a.Networks))
+	for _, s := range a.Networks {
+		if !strings.Contains(s, "/") {
+			if strings.Contains(s, ":") {
+				s += "/128"
+			} else {
+				s += "/32"
+			}
+		}
+
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(nets) > 0 && !allowedAddr(nets, r.RemoteAddr) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		if (a.Username != "" || len(a.Tokens) > 0) && !a.authorized(r) {
+			if a.Username != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// authorized returns true if the request carries valid credentials.
+func (a Access) authorized(r *http.Request) bool {
+	if a.Username != "" {
+		if u, p, ok := r.BasicAuth(); ok && equal(u, a.Username) && equal(p, a.Password) {
+			return true
+		}
+	}
+
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		for _, t := range a.Tokens {
+			if equal(auth[7:], t) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// allowedAddr returns true if the host of the given address is in any of the
+// given networks.
+func allowedAddr(nets []*net.IPNet, addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// equal compares two strings in constant time.
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,44 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codahale/metrics"
+)
+
+func TestAccess(t *testing.T) {
+	h := metrics.Access{
+		Username: "admin",
+		Password: "secret",
+		Tokens:   []string{"t0k3n"},
+		Networks: []string{"10.0.0.0/8", "127.0.0.1"},
+	}.Wrap(metrics.Handler{})
+
+	tests := []struct {
+		addr   string
+		header func(r *http.Request)
+		status int
+	}{
+		{"127.0.0.1:1234", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"127.0.0.1:1234", func(r *http.Request) { r.SetBasicAuth("admin", "secret") }, http.StatusOK},
+		{"10.1.2.3:1234", func(r *http.Request) { r.SetBasicAuth("admin", "wrong") }, http.StatusUnauthorized},
+		{"10.1.2.3:1234", func(r *http.Request) { r.Header.Set("Authorization", "Bearer t0k3n") }, http.StatusOK},
+		{"10.1.2.3:1234", func(r *http.Request) { r.Header.Set("Authorization", "Bearer nope") }, http.StatusUnauthorized},
+		{"192.168.1.1:1234", func(r *http.Request) { r.SetBasicAuth("admin", "secret") }, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		r.RemoteAddr = test.addr
+		test.header(r)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if v, want := w.Code, test.status; v != want {
+			t.Errorf("Status for %s was %v, but expected %v", test.addr, v, want)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// An AzureMonitorReporter sends the values of metrics to Azure Monitor as
+// custom metrics of an Azure resource. Counters and float counters are sent as
+// their increase since the previous report, so the first report sends no
+// counters; gauges and
+// the quantiles of histograms (e.g., Latency.P99) are sent as single samples.
+//
+// Azure Monitor accepts one metric per request, so use the reporter's pipeline
+// to limit the metrics sent to those which are needed.
+type AzureMonitorReporter struct {
+	// Region is the Azure region of the resource (e.g., "eastus").
+	Region string
+
+	// ResourceID is the ID of the resource the metrics belong to (e.g.,
+	// "/subscriptions/.../resourceGroups/.../providers/Microsoft.Compute/virtualMachines/web1").
+	ResourceID string
+
+	// Namespace is the namespace of the custom metrics. If empty, "metrics"
+	// is used.
+	Namespace string
+
+	// Token returns an access token for https://monitoring.azure.com/ (e.g.,
+	// AzureManagedIdentity).
+	Token func() (string, error)
+
+	// Client, if not nil, is used to send requests. Otherwise,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	// URL, if not empty, overrides the regional ingestion endpoint (e.g.,
+	// for sovereign clouds). The resource ID and /metrics are appended to it.
+	URL string
+
+	// Pipeline is applied to each report before it is sent.
+	Pipeline Pipeline
+
+	m    sync.Mutex
+	prev Report
+}
+
+type azureMetric struct {
+	Time time.Time `json:"time"`
+	Data struct {
+		BaseData struct {
+			Metric    string        `json:"metric"`
+			Namespace string        `json:"namespace"`
+			Series    []azureSeries `json:"series"`
+		} `json:"baseData"`
+	} `json:"data"`
+}
+
+type azureSeries struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Sum   float64 `json:"sum"`
+	Count int     `json:"count"`
+}
+
+// Report sends the values of metrics to Azure Monitor. If any request fails,
+// the remaining metrics are still sent and the first error is returned.
+func (am *AzureMonitorReporter) Report() error {
+	am.m.Lock()
+	defer am.m.Unlock()
+
+	r := am.Pipeline.Apply(Capture())
+
+	token, err := am.Token()
+	if err != nil {
+		return err
+	}
+
+	url := am.URL
+	if url == "" {
+		url = "https://" + am.Region + ".monitoring.azure.com"
+	}
+	url += am.ResourceID + "/metrics"
+
+	ns := am.Namespace
+	if ns == "" {
+		ns = "metrics"
+	}
+
+	var first error
+	post := func(name string, v float64) {
+		var m azureMetric
+		m.Time = r.Time.UTC()
+		m.Data.BaseData.Metric = name
+		m.Data.BaseData.Namespace = ns
+		m.Data.BaseData.Series = []azureSeries{{Min: v, Max: v, Sum: v, Count: 1}}
+
+		b, err := json.Marshal(m)
+		if err == nil {
+			var req *http.Request
+			if req, err = http.NewRequest("POST", url, bytes.NewReader(b)); err == nil {
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("Authorization", "Bearer "+token)
+				err = send(am.Client, req)
+			}
+		}
+
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+
+	if !am.prev.Time.IsZero() {
+		d := r.DeltaSince(am.prev)
+		for _, n := range sortedKeys(d.Counters) {
+			post(n, float64(d.Counters[n]))
+		}
+
+		for _, n := range sortedKeys(d.FloatCounters) {
+			post(n, d.FloatCounters[n])
+		}
+	}
+
+	for _, n := range sortedKeys(r.Gauges) {
+		post(n, float64(r.Gauges[n]))
+	}
+
+	for _, n := range sortedKeys(r.Histograms) {
+		for _, q := range quantiles {
+			post(n+q.suffix, float64(r.Histograms[n].Quantiles[q.q]))
+		}
+	}
+
+	am.prev = r
+	return first
+}
+
+// ReportEvery sends the values of metrics once per the given interval until the
+// returned timer is stopped. Errors are counted by the Metrics.ExportErrors
+// counter.
+func (am *AzureMonitorReporter) ReportEvery(d time.Duration) Timer {
+	return flushEvery(d, "Metrics.ExportErrors", am.Report)
+}
+
+// AzureManagedIdentity returns a token function for AzureMonitorReporter which
+// fetches tokens for the virtual machine's or container's managed identity
+// from the Azure Instance Metadata Service. If clientID is not empty, the
+// user-assigned identity with that client ID is used. Tokens are cached until
+// five minutes before they expire.
+func AzureManagedIdentity(clientID string) func() (string, error) {
+	var (
+		m       sync.Mutex
+		token   string
+		expires time.Time
+	)
+
+	return func() (string, error) {
+		m.Lock()
+		defer m.Unlock()
+
+		if token != "" && time.Now().Before(expires.Add(-5*time.Minute)) {
+			return token, nil
+		}
+
+		url := "http://169.254.169.254/metadata/identity/oauth2/token" +
+			"?api-version=2018-02-01&resource=https://monitoring.azure.com/"
+		if clientID != "" {
+			url += "&client_id=" + clientID
+		}
+
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Metadata", "true")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+
+		var v struct {
+			AccessToken string `json:"access_token"`
+			ExpiresOn   string `json:"expires_on"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+			return "", err
+		}
+
+		if v.AccessToken == "" {
+			return "", errors.New("metrics: no managed identity token: " + resp.Status)
+		}
+
+		exp, err := strconv.ParseInt(v.ExpiresOn, 10, 64)
+		if err != nil {
+			return "", err
+		}
+
+		token, expires = v.AccessToken, time.Unix(exp, 0)
+		return token, nil
+	}
+}
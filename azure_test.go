@@ -0,0 +1,86 @@
+package metrics_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestAzureMonitorReporter(t *testing.T) {
+	metricstest.Reset(t)
+
+	type metric struct {
+		Data struct {
+			BaseData struct {
+				Metric    string
+				Namespace string
+				Series    []struct {
+					Sum   float64
+					Count int
+				}
+			}
+		}
+	}
+
+	var posted []metric
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v, want := r.URL.Path, "/subscriptions/s/vm/metrics"; v != want {
+			t.Errorf("Path was %q, but expected %q", v, want)
+		}
+
+		if v, want := r.Header.Get("Authorization"), "Bearer t0k3n"; v != want {
+			t.Errorf("Authorization was %q, but expected %q", v, want)
+		}
+
+		var m metric
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			t.Error(err)
+		}
+		posted = append(posted, m)
+	}))
+	defer srv.Close()
+
+	am := &metrics.AzureMonitorReporter{
+		URL:        srv.URL,
+		ResourceID: "/subscriptions/s/vm",
+		Namespace:  "app",
+		Token: func() (string, error) {
+			return "t0k3n", nil
+		},
+		Pipeline: metrics.Pipeline{
+			Filters: []metrics.Filter{metrics.Include("Conns", "Bytes")},
+		},
+	}
+
+	metrics.Gauge("Conns").Set(2)
+	if err := am.Report(); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := len(posted), 1; v != want {
+		t.Fatalf("Posted %v metrics, but expected %v", v, want)
+	}
+
+	d := posted[0].Data.BaseData
+	if d.Metric != "Conns" || d.Namespace != "app" || d.Series[0].Sum != 2 || d.Series[0].Count != 1 {
+		t.Errorf("Metric was %+v, but expected Conns/app/2/1", d)
+	}
+
+	metrics.FloatCounter("Bytes").Add(1.5)
+	if err := am.Report(); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := len(posted), 3; v != want {
+		t.Fatalf("Posted %v metrics, but expected %v", v, want)
+	}
+
+	d = posted[1].Data.BaseData
+	if d.Metric != "Bytes" || d.Series[0].Sum != 1.5 {
+		t.Errorf("Metric was %+v, but expected Bytes/app/1.5/1", d)
+	}
+}
@@ -0,0 +1,397 @@
+package metrics
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+var errMalformed = errors.New("metrics: malformed binary report")
+
+// MarshalBinary encodes the report in the compact protobuf encoding described
+// by metrics.proto, which is considerably cheaper to produce and parse than
+// JSON for large reports.
+func (r Report) MarshalBinary() ([]byte, error) {
+	var w protoWriter
+	w.varint(1, uint64(unixNano(r.Time)))
+
+	for _, n := range sortedKeys(r.Counters) {
+		w.message(2, func(e *protoWriter) {
+			e.string(1, n)
+			e.varint(2, r.Counters[n])
+		})
+	}
+
+	for _, n := range sortedKeys(r.Gauges) {
+		w.message(3, func(e *protoWriter) {
+			e.string(1, n)
+			e.zigzag(2, r.Gauges[n])
+		})
+	}
+
+	for _, n := range sortedKeys(r.Histograms) {
+		w.message(4, func(e *protoWriter) {
+			e.string(1, n)
+			e.message(2, r.Histograms[n].marshalProto)
+		})
+	}
+
+	w.stringMap(5, r.Tags)
+
+	for _, n := range sortedKeys(r.FloatCounters) {
+		w.message(6, func(e *protoWriter) {
+			e.string(1, n)
+			e.double(2, r.FloatCounters[n])
+		})
+	}
+
+	return w.b, nil
+}
+
+func (s HistogramSummary) marshalProto(w *protoWriter) {
+	w.varint(1, uint64(s.Count))
+	w.varint(2, uint64(s.Min))
+	w.varint(3, uint64(s.Max))
+	w.double(4, s.Mean)
+	w.double(5, s.StdDev)
+
+	qs := make([]float64, 0, len(s.Quantiles))
+	for q := range s.Quantiles {
+		qs = append(qs, q)
+	}
+	sort.Float64s(qs)
+
+	for _, q := range qs {
+		w.message(6, func(e *protoWriter) {
+			e.double(1, q)
+			e.varint(2, uint64(s.Quantiles[q]))
+			if ex, ok := s.Exemplars[q]; ok {
+				e.message(3, ex.marshalProto)
+			}
+		})
+	}
+
+	for _, b := range s.Buckets {
+		b := b
+		w.message(7, func(e *protoWriter) {
+			e.varint(1, uint64(b.UpperBound))
+			e.varint(2, uint64(b.Count))
+			if b.Exemplar != nil {
+				e.message(3, b.Exemplar.marshalProto)
+			}
+		})
+	}
+
+	w.varint(8, uint64(unixNano(s.Start)))
+	w.varint(9, uint64(unixNano(s.End)))
+}
+
+func (e Exemplar) marshalProto(w *protoWriter) {
+	w.varint(1, uint64(e.Value))
+	w.stringMap(2, e.Labels)
+	w.varint(3, uint64(unixNano(e.Time)))
+}
+
+// UnmarshalBinary decodes a report encoded with MarshalBinary.
+func (r *Report) UnmarshalBinary(b []byte) error {
+	*r = Report{
+		Counters:      make(map[string]uint64),
+		FloatCounters: make(map[string]float64),
+		Gauges:        make(map[string]int64),
+		Histograms:    make(map[string]HistogramSummary),
+		Tags:          make(map[string]string),
+	}
+
+	return readProto(b, func(field int, v protoValue) error {
+		switch field {
+		case 1:
+			r.Time = fromUnixNano(int64(v.n))
+		case 2:
+			var n string
+			var c uint64
+			err := readProto(v.b, func(field int, v protoValue) error {
+				switch field {
+				case 1:
+					n = string(v.b)
+				case 2:
+					c = v.n
+				}
+				return nil
+			})
+			r.Counters[n] = c
+			return err
+		case 3:
+			var n string
+			var g int64
+			err := readProto(v.b, func(field int, v protoValue) error {
+				switch field {
+				case 1:
+					n = string(v.b)
+				case 2:
+					g = int64(v.n>>1) ^ -int64(v.n&1)
+				}
+				return nil
+			})
+			r.Gauges[n] = g
+			return err
+		case 4:
+			var n string
+			var s HistogramSummary
+			err := readProto(v.b, func(field int, v protoValue) error {
+				switch field {
+				case 1:
+					n = string(v.b)
+				case 2:
+					return s.unmarshalProto(v.b)
+				}
+				return nil
+			})
+			r.Histograms[n] = s
+			return err
+		case 5:
+			return readStringMap(v.b, r.Tags)
+		case 6:
+			var n string
+			var c float64
+			err := readProto(v.b, func(field int, v protoValue) error {
+				switch field {
+				case 1:
+					n = string(v.b)
+				case 2:
+					c = math.Float64frombits(v.n)
+				}
+				return nil
+			})
+			r.FloatCounters[n] = c
+			return err
+		}
+		return nil
+	})
+}
+
+func (s *HistogramSummary) unmarshalProto(b []byte) error {
+	s.Quantiles = make(map[float64]int64)
+
+	return readProto(b, func(field int, v protoValue) error {
+		switch field {
+		case 1:
+			s.Count = int64(v.n)
+		case 2:
+			s.Min = int64(v.n)
+		case 3:
+			s.Max = int64(v.n)
+		case 4:
+			s.Mean = math.Float64frombits(v.n)
+		case 5:
+			s.StdDev = math.Float64frombits(v.n)
+		case 6:
+			var q float64
+			var qv int64
+			var ex *Exemplar
+			err := readProto(v.b, func(field int, v protoValue) error {
+				switch field {
+				case 1:
+					q = math.Float64frombits(v.n)
+				case 2:
+					qv = int64(v.n)
+				case 3:
+					ex = new(Exemplar)
+					return ex.unmarshalProto(v.b)
+				}
+				return nil
+			})
+			s.Quantiles[q] = qv
+			if ex != nil {
+				if s.Exemplars == nil {
+					s.Exemplars = make(map[float64]Exemplar)
+				}
+				s.Exemplars[q] = *ex
+			}
+			return err
+		case 7:
+			var bk Bucket
+			err := readProto(v.b, func(field int, v protoValue) error {
+				switch field {
+				case 1:
+					bk.UpperBound = int64(v.n)
+				case 2:
+					bk.Count = int64(v.n)
+				case 3:
+					bk.Exemplar = new(Exemplar)
+					return bk.Exemplar.unmarshalProto(v.b)
+				}
+				return nil
+			})
+			s.Buckets = append(s.Buckets, bk)
+			return err
+		case 8:
+			s.Start = fromUnixNano(int64(v.n))
+		case 9:
+			s.End = fromUnixNano(int64(v.n))
+		}
+		return nil
+	})
+}
+
+func (e *Exemplar) unmarshalProto(b []byte) error {
+	return readProto(b, func(field int, v protoValue) error {
+		switch field {
+		case 1:
+			e.Value = int64(v.n)
+		case 2:
+			if e.Labels == nil {
+				e.Labels = make(map[string]string)
+			}
+			return readStringMap(v.b, e.Labels)
+		case 3:
+			e.Time = fromUnixNano(int64(v.n))
+		}
+		return nil
+	})
+}
+
+// A protoWriter appends fields in the protobuf wire format. Fields with zero
+// values are omitted, as in proto3.
+type protoWriter struct {
+	b []byte
+}
+
+func (w *protoWriter) key(field, wireType int) {
+	w.uvarint(uint64(field<<3 | wireType))
+}
+
+func (w *protoWriter) uvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	w.b = append(w.b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func (w *protoWriter) varint(field int, v uint64) {
+	if v != 0 {
+		w.key(field, 0)
+		w.uvarint(v)
+	}
+}
+
+func (w *protoWriter) zigzag(field int, v int64) {
+	w.varint(field, uint64(v<<1)^uint64(v>>63))
+}
+
+func (w *protoWriter) double(field int, v float64) {
+	if v != 0 {
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+		w.key(field, 1)
+		w.b = append(w.b, buf[:]...)
+	}
+}
+
+func (w *protoWriter) float(field int, v float32) {
+	if v != 0 {
+		var buf [4]byte
+		binary.LittleEndian.PutUint32(buf[:], math.Float32bits(v))
+		w.key(field, 5)
+		w.b = append(w.b, buf[:]...)
+	}
+}
+
+func (w *protoWriter) string(field int, s string) {
+	w.key(field, 2)
+	w.uvarint(uint64(len(s)))
+	w.b = append(w.b, s...)
+}
+
+func (w *protoWriter) message(field int, f func(*protoWriter)) {
+	var e protoWriter
+	f(&e)
+	w.string(field, string(e.b))
+}
+
+func (w *protoWriter) stringMap(field int, m map[string]string) {
+	for _, k := range sortedKeys(m) {
+		w.message(field, func(e *protoWriter) {
+			e.string(1, k)
+			e.string(2, m[k])
+		})
+	}
+}
+
+// A protoValue is the value of a field: an integer for varint and fixed-width
+// fields, and bytes for length-delimited fields.
+type protoValue struct {
+	n uint64
+	b []byte
+}
+
+// readProto calls f with each field of the encoded message.
+func readProto(b []byte, f func(field int, v protoValue) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errMalformed
+		}
+		b = b[n:]
+
+		var v protoValue
+		switch key & 7 {
+		case 0:
+			if v.n, n = binary.Uvarint(b); n <= 0 {
+				return errMalformed
+			}
+			b = b[n:]
+		case 1:
+			if len(b) < 8 {
+				return errMalformed
+			}
+			v.n, b = binary.LittleEndian.Uint64(b), b[8:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || l > uint64(len(b)-n) {
+				return errMalformed
+			}
+			v.b, b = b[n:n+int(l)], b[n+int(l):]
+		case 5:
+			if len(b) < 4 {
+				return errMalformed
+			}
+			v.n, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		default:
+			return errMalformed
+		}
+
+		if err := f(int(key>>3), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readStringMap(b []byte, m map[string]string) error {
+	var k, v string
+	err := readProto(b, func(field int, pv protoValue) error {
+		switch field {
+		case 1:
+			k = string(pv.b)
+		case 2:
+			v = string(pv.b)
+		}
+		return nil
+	})
+	m[k] = v
+	return err
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
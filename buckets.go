@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"math/bits"
+
+	"github.com/codahale/hdrhistogram"
+)
+
+// A Bucket is a cumulative count of the values recorded by a histogram which
+// are no greater than an upper bound, in the style of a classic Prometheus
+// histogram. Unlike pre-computed quantiles, buckets from many processes can be
+// summed and aggregated server-side without loss.
+type Bucket struct {
+	UpperBound int64     // the largest value counted by the bucket
+	Count      int64     // the number of recorded values no greater than UpperBound
+	Exemplar   *Exemplar // the most recent exemplar in the bucket, if any
+}
+
+// buckets returns cumulative counts for each power-of-two range of values
+// between the histogram's lowest and highest trackable values. The set of
+// bounds depends only on the histogram's range, so it is stable across
+// windows. It must be called with h.rw held.
+func (h *Histogram) buckets(m *hdrhistogram.Histogram) []Bucket {
+	lo, hi := bucketIndex(m.LowestTrackableValue()), bucketIndex(m.HighestTrackableValue())
+
+	counts := make([]int64, hi-lo+1)
+	for _, bar := range m.Distribution() {
+		i := bucketIndex(bar.To)
+		if i < lo {
+			i = lo
+		} else if i > hi {
+			i = hi
+		}
+		counts[i-lo] += bar.Count
+	}
+
+	buckets := make([]Bucket, len(counts))
+	var total int64
+	for i, n := range counts {
+		total += n
+		buckets[i] = Bucket{
+			UpperBound: bucketBound(lo + i),
+			Count:      total,
+		}
+		if e, ok := h.exemplars[lo+i]; ok {
+			e := e
+			buckets[i].Exemplar = &e
+		}
+	}
+	return buckets
+}
+
+// bucketIndex returns the index of the power-of-two range containing v.
+func bucketIndex(v int64) int {
+	if v <= 0 {
+		return 0
+	}
+	return bits.Len64(uint64(v))
+}
+
+// bucketBound returns the largest value in the power-of-two range with the
+// given index.
+func bucketBound(i int) int64 {
+	if i == 0 {
+		return 0
+	}
+	return int64(uint64(1)<<uint(i) - 1)
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+// BuildInfoGauge is the name of the gauge published by SetBuildInfo. Its value
+// is always one; the build metadata is published as the report tags
+// build.version, build.commit, and build.date, and as labels in the Prometheus
+// and OpenMetrics formats.
+const BuildInfoGauge = "Build.Info"
+
+// SetBuildInfo publishes the version, commit, and date of the running build, so
+// that dashboards can correlate changes in behavior with deploys.
+func SetBuildInfo(version, commit, date string) {
+	bim.Lock()
+	buildInfo = map[string]string{
+		"version": version,
+		"commit":  commit,
+		"date":    date,
+	}
+	bim.Unlock()
+
+	Gauge(BuildInfoGauge).Set(1)
+	Describe(BuildInfoGauge, Metadata{Help: "Build metadata of the running binary."})
+}
+
+// DetectBuildInfo publishes the build metadata embedded in the binary by the Go
+// toolchain, as with SetBuildInfo: the main module's version, and the VCS
+// revision and commit time, if available. It returns false if the binary has
+// no build metadata.
+func DetectBuildInfo() bool {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return false
+	}
+
+	var commit, date string
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			commit = s.Value
+		case "vcs.time":
+			date = s.Value
+		}
+	}
+
+	SetBuildInfo(bi.Main.Version, commit, date)
+	return true
+}
+
+// copyBuildInfo returns the build metadata, if any.
+func copyBuildInfo() map[string]string {
+	bim.Lock()
+	defer bim.Unlock()
+
+	m := make(map[string]string, len(buildInfo))
+	for k, v := range buildInfo {
+		m[k] = v
+	}
+	return m
+}
+
+var (
+	buildInfo map[string]string
+	bim       sync.Mutex
+)
@@ -0,0 +1,31 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestSetBuildInfo(t *testing.T) {
+	metricstest.Reset(t)
+
+	metrics.SetBuildInfo("v1.2.3", "abc123", "2020-01-01T00:00:00Z")
+
+	r := metrics.Capture()
+
+	if v, want := r.Gauges[metrics.BuildInfoGauge], int64(1); v != want {
+		t.Errorf("Gauge was %v, but expected %v", v, want)
+	}
+
+	if v, want := r.Tags["build.version"], "v1.2.3"; v != want {
+		t.Errorf("Version was %q, but expected %q", v, want)
+	}
+
+	w := serve(metrics.Handler{}, "text/plain")
+	want := `Build_Info{commit="abc123",date="2020-01-01T00:00:00Z",version="v1.2.3"} 1` + "\n"
+	if !strings.Contains(w.Body.String(), want) {
+		t.Errorf("Response did not contain %q:\n%s", want, w.Body.String())
+	}
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import "reflect"
+
+// GaugeChannel publishes the number of elements queued in the given channel as
+// the <name>.Len gauge, its capacity as the <name>.Cap gauge, and the
+// percentage of its capacity in use as the <name>.Utilization gauge. It panics
+// if ch is not a channel.
+//
+// Use GaugeChannel to monitor the backpressure on a worker pool's queue.
+func GaugeChannel(name string, ch interface{}) {
+	v := reflect.ValueOf(ch)
+	if v.Kind() != reflect.Chan {
+		panic(name + " is not a channel")
+	}
+
+	Gauge(name + ".Len").SetFunc(func() int64 {
+		return int64(v.Len())
+	})
+	Gauge(name + ".Cap").SetFunc(func() int64 {
+		return int64(v.Cap())
+	})
+	Gauge(name + ".Utilization").SetFunc(func() int64 {
+		if v.Cap() == 0 {
+			return 0
+		}
+		return int64(v.Len() * 100 / v.Cap())
+	})
+}
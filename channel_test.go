@@ -0,0 +1,39 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/codahale/metrics"
+)
+
+func TestGaugeChannel(t *testing.T) {
+	metrics.Reset()
+
+	ch := make(chan int, 4)
+	ch <- 1
+	metrics.GaugeChannel("Queue", ch)
+
+	_, gauges := metrics.Snapshot()
+
+	if v, want := gauges["Queue.Len"], int64(1); v != want {
+		t.Errorf("Len was %v, but expected %v", v, want)
+	}
+
+	if v, want := gauges["Queue.Cap"], int64(4); v != want {
+		t.Errorf("Cap was %v, but expected %v", v, want)
+	}
+
+	if v, want := gauges["Queue.Utilization"], int64(25); v != want {
+		t.Errorf("Utilization was %v, but expected %v", v, want)
+	}
+}
+
+func TestGaugeChannelNotChannel(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic but got none")
+		}
+	}()
+
+	metrics.GaugeChannel("Queue", 1)
+}
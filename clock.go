@@ -0,0 +1,193 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A Clock tells the time and schedules function calls. Histogram windows are
+// rotated by the package's clock, which may be replaced in tests to control the
+// passage of time.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// AfterFunc calls f in its own goroutine after the duration elapses.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// A Timer is a pending function call scheduled by a Clock. Functions which
+// return a Timer for periodic calls (e.g., PushEvery and ReportEvery) panic if
+// the interval is not positive, as time.NewTicker does.
+type Timer interface {
+	// Stop prevents the call from happening, returning false if it has already
+	// happened or been stopped.
+	Stop() bool
+}
+
+// SystemClock is the default clock, which uses the time package.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// SetClock replaces the package's clock and reschedules all periodic work, such
+// as the rotation of histogram windows, using it.
+func SetClock(c Clock) {
+	tm.Lock()
+	clock.Store(clockHolder{c})
+	rs := make([]*repeater, 0, len(repeaters))
+	for r := range repeaters {
+		rs = append(rs, r)
+	}
+	tm.Unlock()
+
+	for _, r := range rs {
+		r.m.Lock()
+		if !r.stopped {
+			r.start(c)
+		}
+		r.m.Unlock()
+	}
+}
+
+// now returns the current time according to the package's clock.
+func now() time.Time {
+	return currentClock().Now()
+}
+
+// currentClock returns the package's clock without locking, since it is read
+// for every timestamp taken.
+func currentClock() Clock {
+	return clock.Load().(clockHolder).Clock
+}
+
+// a clockHolder wraps clocks so that clocks of different types can be stored
+// in the same atomic.Value.
+type clockHolder struct {
+	Clock
+}
+
+// repeat calls f every d, using the package's clock, until the returned timer
+// is stopped. It panics if d is not positive.
+func repeat(d time.Duration, f func()) Timer {
+	checkInterval(d)
+	return schedule(func(time.Time) time.Duration { return d }, f)
+}
+
+// repeatPhased calls f every d, at instants which are whole multiples of d
+// after phase, until the returned timer is stopped. Unlike repeat, the calls
+// do not drift later as time passes.
+func repeatPhased(d time.Duration, phase time.Time, f func()) Timer {
+	checkInterval(d)
+	return schedule(func(t time.Time) time.Duration {
+		since := t.Sub(phase) % d
+		if since < 0 {
+			since += d
+		}
+		return d - since
+	}, f)
+}
+
+// checkInterval panics if d is not positive, rather than letting a timer spin.
+func checkInterval(d time.Duration) {
+	if d <= 0 {
+		panic("interval must be positive")
+	}
+}
+
+// schedule repeatedly calls f, waiting between calls for the duration returned
+// by next given the current time, until the returned timer is stopped.
+func schedule(next func(now time.Time) time.Duration, f func()) Timer {
+	r := &repeater{next: next, f: f}
+
+	tm.Lock()
+	repeaters[r] = struct{}{}
+	c := currentClock()
+	tm.Unlock()
+
+	r.m.Lock()
+	r.start(c)
+	r.m.Unlock()
+
+	return r
+}
+
+type repeater struct {
+	next    func(now time.Time) time.Duration
+	f       func()
+	t       Timer
+	gen     int // incremented whenever t is replaced, invalidating old calls
+	stopped bool
+	m       sync.Mutex
+}
+
+// start schedules the next call using the given clock. It must be called with
+// r.m held.
+func (r *repeater) start(c Clock) {
+	if r.t != nil {
+		r.t.Stop()
+	}
+
+	r.gen++
+	gen := r.gen
+	r.t = c.AfterFunc(r.next(c.Now()), func() {
+		r.fire(gen)
+	})
+}
+
+func (r *repeater) fire(gen int) {
+	r.m.Lock()
+	current := gen == r.gen
+	r.m.Unlock()
+
+	if !current {
+		return
+	}
+
+	r.f()
+
+	c := currentClock()
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if gen == r.gen && !r.stopped {
+		r.start(c)
+	}
+}
+
+func (r *repeater) Stop() bool {
+	r.m.Lock()
+	stopped := r.stopped
+	r.stopped = true
+	r.gen++
+	if r.t != nil {
+		r.t.Stop()
+	}
+	r.m.Unlock()
+
+	tm.Lock()
+	delete(repeaters, r)
+	tm.Unlock()
+
+	return !stopped
+}
+
+var (
+	clock     atomic.Value // a clockHolder, replaced with tm held
+	repeaters = make(map[*repeater]struct{})
+	tm        sync.Mutex
+)
+
+func init() {
+	clock.Store(clockHolder{SystemClock})
+}
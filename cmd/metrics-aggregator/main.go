@@ -0,0 +1,137 @@
+// Command metrics-aggregator polls the metrics of many processes and serves a
+// single, merged report describing the fleet.
+//
+// Usage:
+//
+//	metrics-aggregator [flags] URL...
+//
+// Each URL should point to a process's metrics.Handler endpoint (e.g.,
+// http://10.0.0.1:8080/metrics) or, if -var is set, its expvar endpoint (e.g.,
+// http://10.0.0.1:8080/debug/vars). Counters are summed, gauges are merged
+// according to -gauges (sum, avg, max, or min), and histograms are merged
+// using their buckets. Expvars carry no buckets, so when polling them the
+// merged histograms of more than one process have counts, means, and extremes
+// but no quantiles. The merged report is served at the -listen address in
+// the formats metrics.Handler supports, along with an Aggregator.Up gauge of
+// the number of processes which responded to the latest poll.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/internal/aggregate"
+	"github.com/codahale/metrics/internal/vars"
+)
+
+func main() {
+	var (
+		listen   = flag.String("listen", ":8081", "the address to serve the merged report on")
+		interval = flag.Duration("interval", 10*time.Second, "the polling interval")
+		gauges   = flag.String("gauges", "sum", "how to merge gauges: sum, avg, max, or min")
+		name     = flag.String("var", "", "the name of the metrics expvar, if polling expvars")
+		timeout  = flag.Duration("timeout", 5*time.Second, "the HTTP request timeout")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] URL...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	mode := aggregate.Mode(*gauges)
+	switch mode {
+	case aggregate.Sum, aggregate.Avg, aggregate.Max, aggregate.Min:
+	default:
+		log.Fatalf("invalid gauge mode: %q", *gauges)
+	}
+
+	a := &aggregator{
+		urls:   flag.Args(),
+		name:   *name,
+		mode:   mode,
+		client: &http.Client{Timeout: *timeout},
+	}
+
+	a.poll()
+	go func() {
+		for range time.Tick(*interval) {
+			a.poll()
+		}
+	}()
+
+	http.Handle("/", metrics.Handler{Buckets: true, Source: a.report})
+	log.Fatal(http.ListenAndServe(*listen, nil))
+}
+
+type aggregator struct {
+	urls   []string
+	name   string
+	mode   aggregate.Mode
+	client *http.Client
+
+	m      sync.Mutex
+	merged metrics.Report
+}
+
+// poll fetches a report from each URL concurrently and merges those which were
+// fetched successfully.
+func (a *aggregator) poll() {
+	var (
+		wg      sync.WaitGroup
+		m       sync.Mutex
+		reports []metrics.Report
+	)
+
+	for _, url := range a.urls {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+
+			var r metrics.Report
+			var err error
+			if a.name != "" {
+				r, err = vars.Fetch(a.client, url, a.name)
+			} else {
+				r, err = vars.FetchReport(a.client, url)
+			}
+
+			if err != nil {
+				log.Print(err)
+				return
+			}
+
+			m.Lock()
+			reports = append(reports, r)
+			m.Unlock()
+		}(url)
+	}
+	wg.Wait()
+
+	merged := aggregate.Merge(reports, a.mode)
+	merged.Gauges["Aggregator.Up"] = int64(len(reports))
+	if merged.Time.IsZero() {
+		merged.Time = time.Now()
+	}
+
+	a.m.Lock()
+	a.merged = merged
+	a.m.Unlock()
+}
+
+func (a *aggregator) report() metrics.Report {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	return a.merged
+}
@@ -0,0 +1,292 @@
+// Command metrics-top polls the expvars of one or more processes and displays
+// their counters (with rates), gauges, and histogram quantiles in a
+// continuously updated table.
+//
+// Usage:
+//
+//	metrics-top [flags] URL...
+//
+// Each URL should point to a process's expvar endpoint (e.g.,
+// http://localhost:8080/debug/vars). While running, press n, v, or r to sort by
+// name, value, or rate, and q to quit.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/internal/vars"
+)
+
+func main() {
+	var (
+		interval = flag.Duration("interval", 2*time.Second, "the polling interval")
+		name     = flag.String("var", "metrics", "the name of the metrics expvar")
+		sortBy   = flag.String("sort", "name", "the initial sort order: name, value, or rate")
+		timeout  = flag.Duration("timeout", 5*time.Second, "the HTTP request timeout")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] URL...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	t := &top{
+		urls:   flag.Args(),
+		name:   *name,
+		sortBy: *sortBy,
+		client: &http.Client{Timeout: *timeout},
+		prev:   make(map[string]metrics.Report),
+	}
+
+	restore := rawMode()
+	defer restore()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+
+	keys := make(chan byte)
+	go readKeys(os.Stdin, keys)
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	t.poll()
+	t.render(os.Stdout)
+
+	for {
+		select {
+		case <-ticker.C:
+			t.poll()
+		case k := <-keys:
+			switch k {
+			case 'n':
+				t.sortBy = "name"
+			case 'v':
+				t.sortBy = "value"
+			case 'r':
+				t.sortBy = "rate"
+			case 'q':
+				return
+			}
+		case <-sigs:
+			return
+		}
+		t.render(os.Stdout)
+	}
+}
+
+type top struct {
+	urls   []string
+	name   string
+	sortBy string
+	client *http.Client
+
+	prev    map[string]metrics.Report
+	reports map[string]metrics.Report
+	deltas  map[string]metrics.Delta
+	errs    map[string]error
+}
+
+// poll fetches a report from each URL concurrently, computing rates against the
+// previous report from the same URL.
+func (t *top) poll() {
+	var (
+		wg sync.WaitGroup
+		m  sync.Mutex
+	)
+
+	t.reports = make(map[string]metrics.Report)
+	t.deltas = make(map[string]metrics.Delta)
+	t.errs = make(map[string]error)
+
+	for _, url := range t.urls {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+
+			r, err := vars.Fetch(t.client, url, t.name)
+
+			m.Lock()
+			defer m.Unlock()
+
+			if err != nil {
+				t.errs[url] = err
+				return
+			}
+
+			t.reports[url] = r
+			if prev, ok := t.prev[url]; ok {
+				t.deltas[url] = r.DeltaSince(prev)
+			}
+			t.prev[url] = r
+		}(url)
+	}
+	wg.Wait()
+}
+
+type row struct {
+	source string
+	name   string
+	value  float64
+	rate   float64
+	cols   []string
+}
+
+func (t *top) render(out io.Writer) {
+	var counters, gauges, hists []row
+
+	for url, r := range t.reports {
+		d := t.deltas[url]
+
+		for n, v := range r.Counters {
+			rate := "-"
+			if _, ok := d.Rates[n]; ok {
+				rate = fmt.Sprintf("%.2f", d.Rates[n])
+			}
+			counters = append(counters, row{
+				source: url,
+				name:   n,
+				value:  float64(v),
+				rate:   d.Rates[n],
+				cols:   []string{fmt.Sprint(v), rate},
+			})
+		}
+
+		for n, v := range r.Gauges {
+			gauges = append(gauges, row{
+				source: url,
+				name:   n,
+				value:  float64(v),
+				rate:   float64(v),
+				cols:   []string{fmt.Sprint(v)},
+			})
+		}
+
+		for n, s := range r.Histograms {
+			qs := make([]float64, 0, len(s.Quantiles))
+			for q := range s.Quantiles {
+				qs = append(qs, q)
+			}
+			sort.Float64s(qs)
+
+			var cols []string
+			for _, q := range qs {
+				cols = append(cols, fmt.Sprintf("P%v=%d", q, s.Quantiles[q]))
+			}
+
+			p99 := float64(s.Quantiles[99])
+			hists = append(hists, row{
+				source: url,
+				name:   n,
+				value:  p99,
+				rate:   p99,
+				cols:   cols,
+			})
+		}
+	}
+
+	// clear the screen and move the cursor to the top left
+	fmt.Fprint(out, "\x1b[H\x1b[2J")
+	fmt.Fprintf(out, "metrics-top  %s  sort: %s  (n)ame (v)alue (r)ate (q)uit\r\n\r\n",
+		time.Now().Format("15:04:05"), t.sortBy)
+
+	for url, err := range t.errs {
+		fmt.Fprintf(out, "error: %s: %v\r\n", url, err)
+	}
+
+	t.table(out, "COUNTERS", []string{"VALUE", "RATE/S"}, counters)
+	t.table(out, "GAUGES", []string{"VALUE"}, gauges)
+	t.table(out, "HISTOGRAMS", []string{"QUANTILES"}, hists)
+}
+
+func (t *top) table(out io.Writer, title string, headers []string, rows []row) {
+	if len(rows) == 0 {
+		return
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		switch {
+		case t.sortBy == "value" && a.value != b.value:
+			return a.value > b.value
+		case t.sortBy == "rate" && a.rate != b.rate:
+			return a.rate > b.rate
+		case a.name != b.name:
+			return a.name < b.name
+		}
+		return a.source < b.source
+	})
+
+	multi := len(t.urls) > 1
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	cols := []string{title}
+	if multi {
+		cols = append(cols, "SOURCE")
+	}
+	fmt.Fprintf(w, "%s\r\n", strings.Join(append(cols, headers...), "\t"))
+
+	for _, r := range rows {
+		cols := []string{r.name}
+		if multi {
+			cols = append(cols, r.source)
+		}
+		fmt.Fprintf(w, "%s\r\n", strings.Join(append(cols, r.cols...), "\t"))
+	}
+	w.Flush()
+	fmt.Fprint(out, "\r\n")
+}
+
+// rawMode puts the terminal into non-canonical mode so that single key presses
+// can be read, returning a function which restores the previous mode. If stdin
+// is not a terminal, it does nothing.
+func rawMode() func() {
+	saved, err := stty("-g")
+	if err != nil {
+		return func() {}
+	}
+
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return func() {}
+	}
+
+	return func() {
+		_, _ = stty(strings.TrimSpace(saved))
+	}
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+func readKeys(in io.Reader, keys chan<- byte) {
+	r := bufio.NewReader(in)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		keys <- b
+	}
+}
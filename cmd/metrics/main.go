@@ -0,0 +1,306 @@
+// Command metrics fetches the metrics published as expvars by a process and
+// prints them, optionally comparing them over time or between processes.
+//
+// Usage:
+//
+//	metrics get [-json] URL
+//	metrics watch [-interval d] URL
+//	metrics diff URL1 URL2
+//	metrics diff [-interval d] URL
+//
+// Each URL should point to a process's expvar endpoint (e.g.,
+// http://localhost:8080/debug/vars).
+//
+// The get command prints all counters, gauges, and histogram quantiles. The
+// watch command repeatedly prints the counters' deltas and rates. The diff
+// command compares the metrics of two processes, or of one process at two
+// points in time.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/internal/vars"
+)
+
+var (
+	name    = flag.String("var", "metrics", "the name of the metrics expvar")
+	timeout = flag.Duration("timeout", 5*time.Second, "the HTTP request timeout")
+	client  *http.Client
+)
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	client = &http.Client{Timeout: *timeout}
+
+	var err error
+	switch cmd, args := flag.Arg(0), flag.Args()[1:]; cmd {
+	case "get":
+		err = get(args)
+	case "watch":
+		err = watch(args)
+	case "diff":
+		err = diff(args)
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage:
+  %[1]s [flags] get [-json] URL
+  %[1]s [flags] watch [-interval d] URL
+  %[1]s [flags] diff URL1 URL2
+  %[1]s [flags] diff [-interval d] URL
+
+Flags:
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
+func get(args []string) error {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("get: expected one URL")
+	}
+
+	r, err := vars.Fetch(client, fs.Arg(0), *name)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Printf("%s\n", b)
+		return err
+	}
+
+	printReport(os.Stdout, r)
+	return nil
+}
+
+func watch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	interval := fs.Duration("interval", 10*time.Second, "the polling interval")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("watch: expected one URL")
+	}
+
+	prev, err := vars.Fetch(client, fs.Arg(0), *name)
+	if err != nil {
+		return err
+	}
+
+	for range time.Tick(*interval) {
+		r, err := vars.Fetch(client, fs.Arg(0), *name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+
+		fmt.Printf("--- %s\n", r.Time.Format(time.RFC3339))
+		printDelta(os.Stdout, r.DeltaSince(prev))
+		prev = r
+	}
+	return nil
+}
+
+func diff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	interval := fs.Duration("interval", 10*time.Second, "the time between samples of a single URL")
+	_ = fs.Parse(args)
+
+	var a, b metrics.Report
+	var err error
+
+	switch fs.NArg() {
+	case 1:
+		if a, err = vars.Fetch(client, fs.Arg(0), *name); err != nil {
+			return err
+		}
+		time.Sleep(*interval)
+		if b, err = vars.Fetch(client, fs.Arg(0), *name); err != nil {
+			return err
+		}
+		printDelta(os.Stdout, b.DeltaSince(a))
+		fmt.Println()
+	case 2:
+		if a, err = vars.Fetch(client, fs.Arg(0), *name); err != nil {
+			return err
+		}
+		if b, err = vars.Fetch(client, fs.Arg(1), *name); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("diff: expected one or two URLs")
+	}
+
+	printComparison(os.Stdout, a, b)
+	return nil
+}
+
+func printReport(out io.Writer, r metrics.Report) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	for _, n := range sortedKeys(r.Counters) {
+		fmt.Fprintf(w, "counter\t%s\t%d\n", n, r.Counters[n])
+	}
+
+	for _, n := range sortedKeys(r.Gauges) {
+		fmt.Fprintf(w, "gauge\t%s\t%d\n", n, r.Gauges[n])
+	}
+
+	for _, n := range sortedKeys(r.Histograms) {
+		s := r.Histograms[n]
+
+		qs := make([]float64, 0, len(s.Quantiles))
+		for q := range s.Quantiles {
+			qs = append(qs, q)
+		}
+		sort.Float64s(qs)
+
+		for _, q := range qs {
+			fmt.Fprintf(w, "histogram\t%s\tP%v=%d\n", n, q, s.Quantiles[q])
+		}
+	}
+}
+
+func printDelta(out io.Writer, d metrics.Delta) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintf(w, "COUNTER\tDELTA\tRATE/S\t(over %v)\n", d.Interval.Round(time.Millisecond))
+	for _, n := range sortedKeys(d.Counters) {
+		fmt.Fprintf(w, "%s\t%d\t%.2f\t\n", n, d.Counters[n], d.Rates[n])
+	}
+}
+
+func printComparison(out io.Writer, a, b metrics.Report) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "METRIC\tA\tB\tB-A")
+
+	counters := make(map[string]bool)
+	for n := range a.Counters {
+		counters[n] = true
+	}
+	for n := range b.Counters {
+		counters[n] = true
+	}
+
+	for _, n := range sortedKeys(counters) {
+		va, oka := a.Counters[n]
+		vb, okb := b.Counters[n]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", n, opt(int64(va), oka), opt(int64(vb), okb),
+			opt(int64(vb)-int64(va), oka && okb))
+	}
+
+	gauges := make(map[string]bool)
+	for n := range a.Gauges {
+		gauges[n] = true
+	}
+	for n := range b.Gauges {
+		gauges[n] = true
+	}
+
+	for _, n := range sortedKeys(gauges) {
+		va, oka := a.Gauges[n]
+		vb, okb := b.Gauges[n]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", n, opt(va, oka), opt(vb, okb), opt(vb-va, oka && okb))
+	}
+
+	hists := make(map[string]bool)
+	for n := range a.Histograms {
+		hists[n] = true
+	}
+	for n := range b.Histograms {
+		hists[n] = true
+	}
+
+	for _, n := range sortedKeys(hists) {
+		sa, oka := a.Histograms[n]
+		sb, okb := b.Histograms[n]
+
+		qs := make(map[float64]bool)
+		for q := range sa.Quantiles {
+			qs[q] = true
+		}
+		for q := range sb.Quantiles {
+			qs[q] = true
+		}
+
+		sorted := make([]float64, 0, len(qs))
+		for q := range qs {
+			sorted = append(sorted, q)
+		}
+		sort.Float64s(sorted)
+
+		for _, q := range sorted {
+			va, vb := sa.Quantiles[q], sb.Quantiles[q]
+			fmt.Fprintf(w, "%s.P%v\t%s\t%s\t%s\n", n, q, opt(va, oka), opt(vb, okb), opt(vb-va, oka && okb))
+		}
+	}
+}
+
+func opt(v int64, ok bool) string {
+	if !ok {
+		return "-"
+	}
+	return fmt.Sprint(v)
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]uint64:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]int64:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]metrics.HistogramSummary:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]bool:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,165 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net"
+	"os"
+	"time"
+)
+
+// A CollectdWriter sends the values of metrics to a collectd server (or any
+// other receiver of the collectd binary network protocol) over UDP, for
+// environments which aggregate metrics with collectd.
+//
+// Each metric is sent as a value of the writer's plugin, with the metric's name
+// as the type instance: counters as derive values, and gauges and the quantiles
+// of histograms (e.g., Latency.P99) as gauge values. Since derive values are
+// integers, float counters are sent as gauge values of their cumulative totals.
+type CollectdWriter struct {
+	// Addr is the address of the collectd server (e.g., "collectd:25826").
+	Addr string
+
+	// Host is the host the values are reported for. If empty, the hostname
+	// is used.
+	Host string
+
+	// Plugin is the plugin the values are reported for. If empty, "metrics"
+	// is used.
+	Plugin string
+
+	// Interval is the interval at which values are sent, which collectd uses
+	// to detect missing values. If zero, ten seconds is used. PushEvery sets
+	// it to its own interval.
+	Interval time.Duration
+
+	// Pipeline is applied to each report before it is sent.
+	Pipeline Pipeline
+}
+
+// collectd part types and value types, from collectd's network.h.
+const (
+	collectdHost           = 0x0000
+	collectdPlugin         = 0x0002
+	collectdType           = 0x0004
+	collectdTypeInstance   = 0x0005
+	collectdValues         = 0x0006
+	collectdTimeHR         = 0x0008
+	collectdIntervalHR     = 0x0009
+	collectdValueGauge     = 1
+	collectdValueDerive    = 2
+	collectdMaxPacketBytes = 1452
+)
+
+// Push sends the current value of each metric to the server.
+func (cw CollectdWriter) Push() error {
+	r := cw.Pipeline.Apply(Capture())
+
+	host := cw.Host
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+
+	plugin := cw.Plugin
+	if plugin == "" {
+		plugin = "metrics"
+	}
+
+	interval := cw.Interval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	conn, err := net.Dial("udp", cw.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var header bytes.Buffer
+	collectdString(&header, collectdHost, host)
+	collectdNumber(&header, collectdTimeHR, collectdTime(r.Time.UnixNano()))
+	collectdNumber(&header, collectdIntervalHR, collectdTime(int64(interval)))
+	collectdString(&header, collectdPlugin, plugin)
+
+	var packet, rec bytes.Buffer
+	packet.Write(header.Bytes())
+
+	send := func(typ, instance string, kind byte, v uint64) {
+		rec.Reset()
+		collectdString(&rec, collectdType, typ)
+		collectdString(&rec, collectdTypeInstance, instance)
+
+		// header, value count, value kind, value
+		_ = binary.Write(&rec, binary.BigEndian, [3]uint16{collectdValues, 15, 1})
+		rec.WriteByte(kind)
+		if kind == collectdValueGauge {
+			_ = binary.Write(&rec, binary.LittleEndian, v)
+		} else {
+			_ = binary.Write(&rec, binary.BigEndian, v)
+		}
+
+		if packet.Len()+rec.Len() > collectdMaxPacketBytes && packet.Len() > header.Len() {
+			if _, werr := conn.Write(packet.Bytes()); werr != nil && err == nil {
+				err = werr
+			}
+			packet.Reset()
+			packet.Write(header.Bytes())
+		}
+		packet.Write(rec.Bytes())
+	}
+
+	for _, n := range sortedKeys(r.Counters) {
+		send("derive", n, collectdValueDerive, r.Counters[n])
+	}
+
+	for _, n := range sortedKeys(r.FloatCounters) {
+		send("gauge", n, collectdValueGauge, math.Float64bits(r.FloatCounters[n]))
+	}
+
+	for _, n := range sortedKeys(r.Gauges) {
+		send("gauge", n, collectdValueGauge, math.Float64bits(float64(r.Gauges[n])))
+	}
+
+	for _, n := range sortedKeys(r.Histograms) {
+		for _, q := range quantiles {
+			v := float64(r.Histograms[n].Quantiles[q.q])
+			send("gauge", n+q.suffix, collectdValueGauge, math.Float64bits(v))
+		}
+	}
+
+	if packet.Len() > header.Len() {
+		if _, werr := conn.Write(packet.Bytes()); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+// PushEvery sends the values of metrics once per the given interval until the
+// returned timer is stopped. Errors are counted by the Metrics.ExportErrors
+// counter.
+func (cw CollectdWriter) PushEvery(d time.Duration) Timer {
+	cw.Interval = d
+	return flushEvery(d, "Metrics.ExportErrors", cw.Push)
+}
+
+// collectdString writes a string part.
+func collectdString(b *bytes.Buffer, typ uint16, s string) {
+	_ = binary.Write(b, binary.BigEndian, [2]uint16{typ, uint16(4 + len(s) + 1)})
+	b.WriteString(s)
+	b.WriteByte(0)
+}
+
+// collectdNumber writes a numeric part.
+func collectdNumber(b *bytes.Buffer, typ uint16, v uint64) {
+	_ = binary.Write(b, binary.BigEndian, [2]uint16{typ, 12})
+	_ = binary.Write(b, binary.BigEndian, v)
+}
+
+// collectdTime converts nanoseconds to collectd's high-resolution time, in
+// units of 2^-30 seconds.
+func collectdTime(ns int64) uint64 {
+	return uint64(ns/1e9)<<30 | uint64(ns%1e9)<<30/1e9
+}
@@ -0,0 +1,71 @@
+package metrics_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net"
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestCollectdWriter(t *testing.T) {
+	metricstest.Reset(t)
+
+	metrics.Counter("Requests").AddN(3)
+	metrics.FloatCounter("Bytes").Add(1.5)
+	metrics.Gauge("Conns").Set(-2)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	cw := metrics.CollectdWriter{
+		Addr: conn.LocalAddr().String(),
+		Host: "web1",
+	}
+	if err := cw.Push(); err != nil {
+		t.Fatal(err)
+	}
+
+	b := make([]byte, 1452)
+	n, _, err := conn.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = b[:n]
+
+	// parse the packet into a list of string parts and values
+	var strs []string
+	var values []float64
+	for len(b) >= 4 {
+		typ, l := binary.BigEndian.Uint16(b), int(binary.BigEndian.Uint16(b[2:]))
+		part := b[4:l]
+		switch typ {
+		case 0x0000, 0x0002, 0x0004, 0x0005:
+			strs = append(strs, string(bytes.TrimRight(part, "\x00")))
+		case 0x0006:
+			if part[2] == 1 {
+				values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(part[3:])))
+			} else {
+				values = append(values, float64(binary.BigEndian.Uint64(part[3:])))
+			}
+		}
+		b = b[l:]
+	}
+
+	want := []string{"web1", "metrics", "derive", "Requests", "gauge", "Bytes", "gauge", "Conns"}
+	for i, s := range want {
+		if i >= len(strs) || strs[i] != s {
+			t.Fatalf("Parts were %v, but expected %v", strs, want)
+		}
+	}
+
+	if len(values) < 3 || values[0] != 3 || values[1] != 1.5 || values[2] != -2 {
+		t.Errorf("Values were %v, but expected [3 1.5 -2 ...]", values)
+	}
+}
@@ -0,0 +1,99 @@
+package metrics
+
+import "fmt"
+
+// A CollectorFunc emits the values of a dynamic set of counters and gauges
+// each time a snapshot is taken (e.g., one gauge per mounted disk or per Kafka
+// partition), so that families of metrics whose members come and go need not
+// be registered and removed as they change.
+type CollectorFunc func(e *Emitter)
+
+// An Emitter receives the values emitted by a CollectorFunc.
+type Emitter struct {
+	counters map[string]uint64
+	gauges   map[string]int64
+}
+
+// Counter emits the value of a counter.
+func (e *Emitter) Counter(name string, v uint64) {
+	if name, ok := resolve(name); ok && !isSilenced(name) {
+		e.counters[name] = v
+	}
+}
+
+// Gauge emits the value of a gauge.
+func (e *Emitter) Gauge(name string, v int64) {
+	if name, ok := resolve(name); ok && !isSilenced(name) {
+		e.gauges[name] = v
+	}
+}
+
+// AddCollector registers a collector with the given name, replacing any
+// collector already registered with it. Collectors are called without holding
+// the registry's locks, after counter and gauge functions and before derived
+// gauges, which may use their values. Registered counters and gauges take
+// precedence over emitted values with the same names. If a collector panics,
+// its values are omitted from the snapshot and the Metrics.GaugeErrors counter
+// is incremented.
+func AddCollector(name string, f CollectorFunc) {
+	gm.Lock()
+	defer gm.Unlock()
+
+	collectors[name] = f
+}
+
+// RemoveCollector removes the collector with the given name.
+func RemoveCollector(name string) {
+	gm.Lock()
+	defer gm.Unlock()
+
+	delete(collectors, name)
+}
+
+// collect calls the registered collectors, adding the values they emit to the
+// given maps.
+func collect(c map[string]uint64, g map[string]int64) {
+	gm.RLock()
+	fs := make(map[string]CollectorFunc, len(collectors))
+	for n, f := range collectors {
+		fs[n] = f
+	}
+	gm.RUnlock()
+
+	for name, f := range fs {
+		e := &Emitter{
+			counters: make(map[string]uint64),
+			gauges:   make(map[string]int64),
+		}
+
+		if err := e.call(f); err != nil {
+			countError("Metrics.GaugeErrors", name, err)
+			continue
+		}
+
+		for n, v := range e.counters {
+			if _, ok := c[n]; !ok {
+				c[n] = v
+			}
+		}
+
+		for n, v := range e.gauges {
+			if _, ok := g[n]; !ok {
+				g[n] = v
+			}
+		}
+	}
+}
+
+func (e *Emitter) call(f CollectorFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	f(e)
+	return nil
+}
+
+var collectors = make(map[string]CollectorFunc) // guarded by gm
@@ -0,0 +1,57 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestCollector(t *testing.T) {
+	metricstest.Reset(t)
+
+	partitions := map[string]int64{"0": 10, "1": 20}
+	metrics.AddCollector("Kafka", func(e *metrics.Emitter) {
+		for p, lag := range partitions {
+			e.Gauge("Kafka.Lag."+p, lag)
+		}
+		e.Counter("Kafka.Messages", 100)
+		e.Gauge("Queue", 1)
+	})
+	metrics.Gauge("Queue").Set(5)
+	metrics.Derive("Kafka.TotalLag", func(_ map[string]uint64, g map[string]int64) int64 {
+		return g["Kafka.Lag.0"] + g["Kafka.Lag.1"]
+	})
+
+	metricstest.AssertGauge(t, "Kafka.Lag.0", 10)
+	metricstest.AssertGauge(t, "Kafka.Lag.1", 20)
+	metricstest.AssertGauge(t, "Kafka.TotalLag", 30)
+	metricstest.AssertCounter(t, "Kafka.Messages", 100)
+
+	// registered metrics take precedence
+	metricstest.AssertGauge(t, "Queue", 5)
+
+	delete(partitions, "1")
+	if _, ok := metricstest.CollectGauges(t)["Kafka.Lag.1"]; ok {
+		t.Error("Gauge for a removed partition was emitted")
+	}
+
+	metrics.RemoveCollector("Kafka")
+	if _, ok := metricstest.CollectGauges(t)["Kafka.Lag.0"]; ok {
+		t.Error("Gauge was emitted after the collector was removed")
+	}
+}
+
+func TestCollectorPanic(t *testing.T) {
+	metricstest.Reset(t)
+
+	metrics.AddCollector("Broken", func(e *metrics.Emitter) {
+		e.Gauge("Partial", 1)
+		panic("oops")
+	})
+
+	if _, ok := metricstest.CollectGauges(t)["Partial"]; ok {
+		t.Error("Values from a panicking collector were emitted")
+	}
+	metricstest.AssertCounter(t, "Metrics.GaugeErrors", 1)
+}
@@ -0,0 +1,328 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConfigEnv is the environment variable from which ConfigFromEnv reads a
+// configuration, either as a JSON object, as a YAML document, or as the path
+// of a JSON or YAML file.
+const ConfigEnv = "METRICS_CONFIG"
+
+// A Config describes the setup of the package declaratively, so that services
+// can configure tags, filters, exporters, and periodic work from a file or the
+// environment rather than each writing their own wiring code. In JSON and
+// YAML, fields are named as in Go and durations are strings (e.g., "10s"):
+//
+//	Tags: {service: api}
+//	DefaultTags: true
+//	Exclude: ["Debug.*"]
+//	Collectors:
+//	  runtime: {Contention: {BlockRate: 10000, MutexFraction: 100}}
+//	  sysstats: {Mounts: ["/"]}
+//	Exporters:
+//	  - {Type: remotewrite, URL: "http://mimir:9009/api/v1/push", Interval: 15s}
+//	  - {Type: riemann, Addr: "riemann:5555", Interval: 10s}
+//
+// Only the subset of YAML needed for configuration is supported: block and
+// flow mappings and sequences, comments, and plain and quoted scalars.
+type Config struct {
+	// Tags are added with SetTag.
+	Tags map[string]string
+
+	// DefaultTags, if true, adds the host and pid tags with SetDefaultTags.
+	DefaultTags bool
+
+	// Self, if true, publishes metrics about the package with
+	// InstrumentSelf.
+	Self bool
+
+	// Silence are patterns of metrics to silence with Silence.
+	Silence []string
+
+	// Include and Exclude are patterns (see Include and Exclude) applied by
+	// every exporter, before the exporter's own patterns.
+	Include, Exclude []string
+
+	// Collectors configures collector packages by name (see RegisterConfig),
+	// e.g. "runtime" for the runtime package and "sysstats" for the
+	// sysstats package, which must be imported by the program.
+	Collectors map[string]json.RawMessage
+
+	// History, if not nil, enables history with EnableHistory.
+	History *HistoryConfig
+
+	// Checkpoint, if not nil, periodically saves a checkpoint.
+	Checkpoint *CheckpointConfig
+
+	// Exporters are started by Configure.
+	Exporters []ExporterConfig
+}
+
+// A HistoryConfig configures the retention of recent reports.
+type HistoryConfig struct {
+	Size     int    // the number of reports retained
+	Interval string // the interval at which reports are captured
+}
+
+// A CheckpointConfig configures a Checkpointer.
+type CheckpointConfig struct {
+	Path       string // the checkpoint file
+	Histograms bool   // whether histograms are saved
+	Restore    bool   // whether the checkpoint is restored by Configure
+	Interval   string // the interval at which checkpoints are saved
+}
+
+// An ExporterConfig configures an exporter which sends reports periodically.
+// Type is one of "collectd", "newrelic", "remotewrite", "riemann", "syslog",
+// or "zabbix", and determines which of the other fields are used, as with the
+// fields of the same names on CollectdWriter, NewRelicReporter,
+// RemoteWriter, RiemannReporter, SyslogReporter, and ZabbixSender.
+type ExporterConfig struct {
+	Type     string // the type of exporter
+	Interval string // the interval at which reports are sent
+
+	Network string            // syslog
+	Addr    string            // collectd, riemann, syslog, zabbix
+	URL     string            // newrelic, remotewrite
+	Host    string            // collectd, riemann, zabbix
+	APIKey  string            // newrelic
+	Header  map[string]string // remotewrite
+	Buckets bool              // remotewrite
+
+	// Include and Exclude are patterns applied after the global ones, so that
+	// a metric is exported only if it is included by both.
+	Include, Exclude []string
+
+	// Tags are added to each report sent by the exporter.
+	Tags map[string]string
+}
+
+// LoadConfig reads a configuration from a YAML file, if its extension is .yaml
+// or .yml, and otherwise from a JSON file. Unknown fields are rejected, so that
+// misspelled settings are not silently ignored.
+func LoadConfig(path string) (Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		return parseYAMLConfig(b)
+	default:
+		return parseConfig(b)
+	}
+}
+
+// ConfigFromEnv reads a configuration from the METRICS_CONFIG environment
+// variable, which is either a JSON object, a YAML document of more than one
+// line, or the path of a JSON or YAML file. If the variable is not set, it
+// returns an empty configuration.
+func ConfigFromEnv() (Config, error) {
+	v := strings.TrimSpace(os.Getenv(ConfigEnv))
+	switch {
+	case v == "":
+		return Config{}, nil
+	case strings.HasPrefix(v, "{"):
+		return parseConfig([]byte(v))
+	case strings.Contains(v, "\n"):
+		return parseYAMLConfig([]byte(v))
+	default:
+		return LoadConfig(v)
+	}
+}
+
+func parseYAMLConfig(b []byte) (Config, error) {
+	j, err := yamlToJSON(b)
+	if err != nil {
+		return Config{}, fmt.Errorf("metrics: invalid config: %w", err)
+	}
+	return parseConfig(j)
+}
+
+func parseConfig(b []byte) (Config, error) {
+	var c Config
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&c); err != nil {
+		return Config{}, fmt.Errorf("metrics: invalid config: %w", err)
+	}
+	return c, nil
+}
+
+// A ConfigFunc validates the settings of a collector package given in a
+// Config's Collectors, and returns the function which starts its collection.
+// The settings are JSON, or null if none were given.
+type ConfigFunc func(settings json.RawMessage) (start func(), err error)
+
+// RegisterConfig registers the ConfigFunc of the collector package with the
+// given name, replacing any already registered with it. Collector packages
+// call it from an init function, so that importing them makes them
+// configurable.
+func RegisterConfig(name string, f ConfigFunc) {
+	configm.Lock()
+	defer configm.Unlock()
+
+	configFuncs[name] = f
+}
+
+var (
+	configm     sync.Mutex
+	configFuncs = make(map[string]ConfigFunc) // guarded by configm
+)
+
+// Configure applies the configuration, starting its collectors, exporters,
+// history, and checkpoints. The configuration is validated before anything is
+// applied, so an invalid configuration has no effect. Use Shutdown to flush and
+// stop the exporters and checkpoints before the process exits.
+func Configure(c Config) error {
+	var start []func()
+
+	for _, e := range c.Exporters {
+		d, err := configInterval("exporter "+e.Type, e.Interval)
+		if err != nil {
+			return err
+		}
+
+		// separate filters, so that the exporter's patterns narrow the global
+		// ones rather than widening them
+		p := Pipeline{Tags: e.Tags}
+		for _, inc := range [][]string{c.Include, e.Include} {
+			if len(inc) > 0 {
+				p.Filters = append(p.Filters, Include(inc...))
+			}
+		}
+		for _, exc := range [][]string{c.Exclude, e.Exclude} {
+			if len(exc) > 0 {
+				p.Filters = append(p.Filters, Exclude(exc...))
+			}
+		}
+
+		every, err := configExporter(e, p)
+		if err != nil {
+			return err
+		}
+		start = append(start, func() { every(d) })
+	}
+
+	names := make([]string, 0, len(c.Collectors))
+	for n := range c.Collectors {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		configm.Lock()
+		f, ok := configFuncs[n]
+		configm.Unlock()
+		if !ok {
+			return fmt.Errorf("metrics: unknown collector %q (is its package imported?)", n)
+		}
+
+		s, err := f(c.Collectors[n])
+		if err != nil {
+			return fmt.Errorf("metrics: invalid settings for collector %s: %w", n, err)
+		}
+		start = append(start, s)
+	}
+
+	if h := c.History; h != nil {
+		d, err := configInterval("history", h.Interval)
+		if err != nil {
+			return err
+		}
+		if h.Size <= 0 {
+			return fmt.Errorf("metrics: invalid history size %d", h.Size)
+		}
+		start = append(start, func() { EnableHistory(h.Size, d) })
+	}
+
+	if cp := c.Checkpoint; cp != nil {
+		d, err := configInterval("checkpoint", cp.Interval)
+		if err != nil {
+			return err
+		}
+		if cp.Path == "" {
+			return fmt.Errorf("metrics: checkpoint has no path")
+		}
+
+		ck := Checkpointer{Path: cp.Path, Histograms: cp.Histograms}
+		if cp.Restore {
+			if err := ck.Restore(); err != nil {
+				return err
+			}
+		}
+		start = append(start, func() { ck.SaveEvery(d) })
+	}
+
+	for k, v := range c.Tags {
+		SetTag(k, v)
+	}
+	if c.DefaultTags {
+		SetDefaultTags()
+	}
+	for _, p := range c.Silence {
+		Silence(p)
+	}
+	if c.Self {
+		InstrumentSelf()
+	}
+
+	for _, f := range start {
+		f()
+	}
+	return nil
+}
+
+// configExporter returns the function which starts the configured exporter.
+func configExporter(e ExporterConfig, p Pipeline) (func(time.Duration) Timer, error) {
+	required := map[string]string{
+		"collectd":    e.Addr,
+		"newrelic":    e.APIKey,
+		"remotewrite": e.URL,
+		"riemann":     e.Addr,
+		"zabbix":      e.Addr,
+	}
+	if v, ok := required[e.Type]; ok && v == "" {
+		return nil, fmt.Errorf("metrics: exporter %s is missing its address, URL, or API key", e.Type)
+	}
+
+	switch e.Type {
+	case "collectd":
+		return CollectdWriter{Addr: e.Addr, Host: e.Host, Pipeline: p}.PushEvery, nil
+	case "newrelic":
+		return (&NewRelicReporter{APIKey: e.APIKey, URL: e.URL, Pipeline: p}).ReportEvery, nil
+	case "remotewrite":
+		header := make(http.Header, len(e.Header))
+		for k, v := range e.Header {
+			header.Set(k, v)
+		}
+		return RemoteWriter{URL: e.URL, Header: header, Buckets: e.Buckets, Pipeline: p}.PushEvery, nil
+	case "riemann":
+		return RiemannReporter{Addr: e.Addr, Host: e.Host, Pipeline: p}.ReportEvery, nil
+	case "syslog":
+		return SyslogReporter{Network: e.Network, Addr: e.Addr, Pipeline: p}.ReportEvery, nil
+	case "zabbix":
+		return ZabbixSender{Addr: e.Addr, Host: e.Host, Pipeline: p}.PushEvery, nil
+	default:
+		return nil, fmt.Errorf("metrics: unknown exporter type %q", e.Type)
+	}
+}
+
+func configInterval(what, s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("metrics: invalid interval %q for %s", s, what)
+	}
+	return d, nil
+}
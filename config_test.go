@@ -0,0 +1,253 @@
+package metrics_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/internal/snappy"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestConfigure(t *testing.T) {
+	metricstest.Reset(t)
+	defer metrics.RemoveTag("service")
+	defer metrics.DisableHistory()
+
+	requests := make(chan *http.Request, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+	}))
+	defer srv.Close()
+
+	t.Setenv(metrics.ConfigEnv, `{
+		"Tags": {"service": "api"},
+		"History": {"Size": 10, "Interval": "1m"},
+		"Exporters": [
+			{"Type": "remotewrite", "URL": "`+srv.URL+`", "Interval": "1h", "Header": {"X-Scope-OrgID": "acme"}}
+		]
+	}`)
+
+	c, err := metrics.ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := metrics.Configure(c); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := metrics.Capture().Tags["service"], "api"; v != want {
+		t.Errorf("Tag was %q, but expected %q", v, want)
+	}
+
+	// Shutdown flushes the configured exporter
+	if err := metrics.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case r := <-requests:
+		if v, want := r.Header.Get("X-Scope-OrgID"), "acme"; v != want {
+			t.Errorf("Header was %q, but expected %q", v, want)
+		}
+	default:
+		t.Error("Exporter did not send a report")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	if err := os.WriteFile(path, []byte(`{"Silence": ["Debug.*"], "Exporters": [{"Type": "zabbix", "Addr": "zabbix:10051", "Interval": "10s"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := metrics.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.Silence) != 1 || len(c.Exporters) != 1 || c.Exporters[0].Addr != "zabbix:10051" {
+		t.Errorf("Config was %+v", c)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"Exporter": []}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := metrics.LoadConfig(path); err == nil || !strings.Contains(err.Error(), "Exporter") {
+		t.Errorf("Error was %v, but expected an unknown field", err)
+	}
+}
+
+func TestLoadConfigYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.yaml")
+	if err := os.WriteFile(path, []byte(`
+# metrics for the API
+Tags: {service: api, "team": 'core # infra'}
+DefaultTags: true
+Exclude: ["Debug.*"]
+Collectors:
+  runtime: {Contention: {BlockRate: 10000, MutexFraction: 100}}
+Exporters:
+- Type: remotewrite
+  URL: "http://mimir:9009/api/v1/push"  # the cluster's Mimir
+  Interval: 15s
+  Include:
+    - HTTP.*
+- {Type: riemann, Addr: "riemann:5555", Interval: 10s}
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := metrics.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Tags["service"] != "api" || c.Tags["team"] != "core # infra" || !c.DefaultTags {
+		t.Errorf("Config was %+v", c)
+	}
+
+	if v, want := string(c.Collectors["runtime"]), `{"Contention":{"BlockRate":10000,"MutexFraction":100}}`; v != want {
+		t.Errorf("Collector settings were %s, but expected %s", v, want)
+	}
+
+	if len(c.Exporters) != 2 ||
+		c.Exporters[0].URL != "http://mimir:9009/api/v1/push" ||
+		c.Exporters[0].Interval != "15s" ||
+		len(c.Exporters[0].Include) != 1 ||
+		c.Exporters[1].Addr != "riemann:5555" {
+		t.Errorf("Exporters were %+v", c.Exporters)
+	}
+
+	if err := os.WriteFile(path, []byte("Tags:\n  service: api\n Self: true\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := metrics.LoadConfig(path); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("Error was %v, but expected an indentation error on line 3", err)
+	}
+}
+
+func TestConfigFromEnvYAML(t *testing.T) {
+	t.Setenv(metrics.ConfigEnv, "Silence:\n  - Debug.*\nSelf: true")
+
+	c, err := metrics.ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.Silence) != 1 || c.Silence[0] != "Debug.*" || !c.Self {
+		t.Errorf("Config was %+v", c)
+	}
+}
+
+func TestConfigureCollectors(t *testing.T) {
+	metricstest.Reset(t)
+
+	var settings string
+	metrics.RegisterConfig("test", func(s json.RawMessage) (func(), error) {
+		if bytes.Contains(s, []byte("bad")) {
+			return nil, errors.New("bad settings")
+		}
+		return func() { settings = string(s) }, nil
+	})
+
+	if err := metrics.Configure(metrics.Config{
+		Collectors: map[string]json.RawMessage{"test": json.RawMessage(`{"Depth":1}`)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := settings, `{"Depth":1}`; v != want {
+		t.Errorf("Settings were %s, but expected %s", v, want)
+	}
+
+	for _, c := range []map[string]json.RawMessage{
+		{"test": json.RawMessage(`"bad"`)},
+		{"carrier-pigeon": nil},
+	} {
+		if err := metrics.Configure(metrics.Config{Collectors: c}); err == nil {
+			t.Errorf("Collectors %s were accepted", c)
+		}
+	}
+}
+
+func TestConfigureIncludeNarrows(t *testing.T) {
+	metricstest.Reset(t)
+
+	metrics.Counter("HTTP.Requests").Add()
+	metrics.Counter("HTTP.Errors").Add()
+	metrics.Counter("DB.Requests").Add()
+
+	bodies := make(chan []byte, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		b, err = snappy.Decode(b)
+		if err != nil {
+			t.Error(err)
+		}
+		bodies <- b
+	}))
+	defer srv.Close()
+
+	if err := metrics.Configure(metrics.Config{
+		Include: []string{"HTTP.*"},
+		Exporters: []metrics.ExporterConfig{
+			{Type: "remotewrite", URL: srv.URL, Interval: "1h", Include: []string{"*.Requests"}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := metrics.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	body := <-bodies
+	if !bytes.Contains(body, []byte("HTTP_Requests")) {
+		t.Error("HTTP.Requests was not exported")
+	}
+
+	for _, s := range []string{"HTTP_Errors", "DB_Requests"} {
+		if bytes.Contains(body, []byte(s)) {
+			t.Errorf("%s was exported", s)
+		}
+	}
+}
+
+func TestConfigureInvalid(t *testing.T) {
+	metricstest.Reset(t)
+
+	for _, c := range []metrics.Config{
+		{Exporters: []metrics.ExporterConfig{{Type: "carrier-pigeon", Interval: "1m"}}},
+		{Exporters: []metrics.ExporterConfig{{Type: "riemann", Addr: "riemann:5555"}}},
+		{Exporters: []metrics.ExporterConfig{{Type: "remotewrite", Interval: "1m"}}},
+		{History: &metrics.HistoryConfig{Interval: "1m"}},
+		{Checkpoint: &metrics.CheckpointConfig{Interval: "1m"}},
+	} {
+		c.Tags = map[string]string{"applied": "yes"}
+		if err := metrics.Configure(c); err == nil {
+			t.Errorf("Config %+v was accepted", c)
+		}
+	}
+
+	if _, ok := metrics.Capture().Tags["applied"]; ok {
+		t.Error("Invalid config was partially applied")
+	}
+}
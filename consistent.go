@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// SetConsistentSnapshots makes Capture read counters, float counters, and
+// histograms at a single logical point in time, so that values computed from
+// several of them (e.g., a request counter and a latency histogram) are not
+// skewed by updates which happen while the report is captured.
+//
+// While enabled, every counter increment and histogram recording takes a
+// shared lock which Capture holds exclusively while it reads them, so enable
+// it only if the skew matters more than the cost of the lock. Gauges, counter
+// functions, and derived gauges are evaluated before that point, as they may
+// themselves update metrics.
+func SetConsistentSnapshots(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&consistent, 1)
+	} else {
+		atomic.StoreInt32(&consistent, 0)
+	}
+}
+
+// beginUpdate takes the shared consistency lock if consistent snapshots are
+// enabled, returning true if it did. It must be paired with endUpdate and
+// held only for the update itself, not while calling other code.
+func beginUpdate() bool {
+	if atomic.LoadInt32(&consistent) == 0 {
+		return false
+	}
+	xm.RLock()
+	return true
+}
+
+// endUpdate releases the shared consistency lock if beginUpdate took it.
+func endUpdate(locked bool) {
+	if locked {
+		xm.RUnlock()
+	}
+}
+
+// beginSnapshot takes the exclusive consistency lock if consistent snapshots
+// are enabled, returning true if it did.
+func beginSnapshot() bool {
+	if atomic.LoadInt32(&consistent) == 0 {
+		return false
+	}
+	xm.Lock()
+	return true
+}
+
+// endSnapshot releases the exclusive consistency lock if beginSnapshot took
+// it.
+func endSnapshot(locked bool) {
+	if locked {
+		xm.Unlock()
+	}
+}
+
+// rereadCounters reads the values of all counters into the given map, except
+// for counter functions, which keep the values they returned.
+func rereadCounters(c map[string]uint64) {
+	cm.RLock()
+	defer cm.RUnlock()
+
+	t := now()
+	for n, v := range counters {
+		if _, ok := counterFuncs[n]; ok {
+			continue
+		}
+		if !isSilenced(n) && !isStale(n, t) {
+			c[n] = atomic.LoadUint64(v)
+		}
+	}
+}
+
+var (
+	consistent int32
+	xm         sync.RWMutex // held exclusively while consistent snapshots are read
+)
@@ -0,0 +1,52 @@
+package metrics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+)
+
+func TestConsistentSnapshots(t *testing.T) {
+	metrics.Reset()
+	metrics.SetConsistentSnapshots(true)
+	defer metrics.SetConsistentSnapshots(false)
+
+	h := metrics.NewHistogram("Latency", 1, 1000, 3)
+	defer h.Remove()
+
+	// evaluated between reading counters and histograms, giving the writer
+	// time to skew them
+	metrics.Gauge("Slow").SetFunc(func() int64 {
+		time.Sleep(time.Millisecond)
+		return 0
+	})
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	defer func() {
+		close(done)
+		<-stopped
+	}()
+
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				_ = h.RecordValue(10)
+				metrics.Counter("Requests").Add()
+			}
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		r := metrics.Capture()
+		count, requests := r.Histograms["Latency"].Count, int64(r.Counters["Requests"])
+		if count != requests && count != requests+1 {
+			t.Fatalf("Histogram count was %d with %d requests", count, requests)
+		}
+	}
+}
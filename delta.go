@@ -0,0 +1,55 @@
+package metrics
+
+import "time"
+
+// A Delta describes the change in counters between two reports.
+type Delta struct {
+	Interval      time.Duration      // the time between the reports
+	Counters      map[string]uint64  // the increase in each counter
+	FloatCounters map[string]float64 // the increase in each float counter
+	Rates         map[string]float64 // the increase in each counter per second
+}
+
+// DeltaSince returns the change in counters and float counters between the
+// previous report and this one. A counter which is lower than in the previous
+// report is assumed to have been reset, and its delta is its current value, as
+// is the delta of a counter not in the previous report. Rates are zero if the
+// reports were not captured in order.
+func (r Report) DeltaSince(prev Report) Delta {
+	d := Delta{
+		Interval:      r.Time.Sub(prev.Time),
+		Counters:      make(map[string]uint64, len(r.Counters)),
+		FloatCounters: make(map[string]float64, len(r.FloatCounters)),
+		Rates:         make(map[string]float64, len(r.Counters)+len(r.FloatCounters)),
+	}
+
+	for n, v := range r.Counters {
+		delta := v
+		if p, ok := prev.Counters[n]; ok && p <= v {
+			delta = v - p
+		}
+		d.Counters[n] = delta
+
+		if d.Interval > 0 {
+			d.Rates[n] = float64(delta) / d.Interval.Seconds()
+		} else {
+			d.Rates[n] = 0
+		}
+	}
+
+	for n, v := range r.FloatCounters {
+		delta := v
+		if p, ok := prev.FloatCounters[n]; ok && p <= v {
+			delta = v - p
+		}
+		d.FloatCounters[n] = delta
+
+		if d.Interval > 0 {
+			d.Rates[n] = delta / d.Interval.Seconds()
+		} else {
+			d.Rates[n] = 0
+		}
+	}
+
+	return d
+}
@@ -0,0 +1,51 @@
+package metrics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+)
+
+func TestDeltaSince(t *testing.T) {
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	prev := metrics.Report{
+		Time: start,
+		Counters: map[string]uint64{
+			"requests": 100,
+			"restarts": 50,
+		},
+	}
+
+	cur := metrics.Report{
+		Time: start.Add(10 * time.Second),
+		Counters: map[string]uint64{
+			"requests": 150,
+			"restarts": 20,
+			"new":      5,
+		},
+	}
+
+	d := cur.DeltaSince(prev)
+
+	if v, want := d.Interval, 10*time.Second; v != want {
+		t.Errorf("Interval was %v, but expected %v", v, want)
+	}
+
+	expected := map[string]uint64{
+		"requests": 50,
+		"restarts": 20,
+		"new":      5,
+	}
+
+	for n, want := range expected {
+		if v := d.Counters[n]; v != want {
+			t.Errorf("Delta of %q was %v, but expected %v", n, v, want)
+		}
+	}
+
+	if v, want := d.Rates["requests"], 5.0; v != want {
+		t.Errorf("Rate was %v, but expected %v", v, want)
+	}
+}
@@ -0,0 +1,85 @@
+package metrics
+
+// Derive registers a gauge whose value is computed from the values of other
+// counters and gauges each time a snapshot is taken, after all other counters
+// and gauges have been evaluated. The function must not modify the maps it is
+// given, and should not depend on the values of other derived gauges. If it
+// panics, the gauge is omitted from the snapshot and the Metrics.GaugeErrors
+// counter is incremented.
+//
+// Use a derived gauge to publish values computed from other metrics (e.g., an
+// error rate) so that every dashboard does not have to repeat the math.
+func Derive(name string, f func(counters map[string]uint64, gauges map[string]int64) int64) {
+	name, ok := resolve(name)
+	if !ok {
+		return
+	}
+
+	gm.Lock()
+	_, exists := derived[name]
+	if !exists {
+		_, exists = gauges[name]
+	}
+	derived[name] = f
+	gm.Unlock()
+
+	if !exists {
+		notifyRegistered(name, "gauge")
+	}
+}
+
+// Ratio registers a derived gauge whose value is the value of the numerator
+// metric divided by the sum of the values of the denominator metrics,
+// multiplied by the given scale. Counters and gauges may both be used; if a
+// metric exists as both, the counter is used. If the denominator is zero, the
+// gauge's value is zero.
+//
+// Because gauges are integers, the scale determines the ratio's precision. For
+// example, to publish a cache's hit rate in thousandths:
+//
+//	metrics.Ratio("Cache.HitRate", 1000, "Cache.Hits", "Cache.Hits", "Cache.Misses")
+//
+// The values of counters are totals over the life of the process, so a ratio of
+// counters is a lifetime average rather than a recent rate.
+func Ratio(name string, scale int64, numerator string, denominators ...string) {
+	// snapshots are keyed by the names under which metrics are registered
+	numerator, _ = resolve(numerator)
+	denominators = append([]string(nil), denominators...)
+	for i, n := range denominators {
+		denominators[i], _ = resolve(n)
+	}
+
+	Derive(name, func(counters map[string]uint64, gauges map[string]int64) int64 {
+		value := func(n string) int64 {
+			if v, ok := counters[n]; ok {
+				return int64(v)
+			}
+			return gauges[n]
+		}
+
+		var den int64
+		for _, n := range denominators {
+			den += value(n)
+		}
+
+		if den == 0 {
+			return 0
+		}
+		return int64(float64(value(numerator)) / float64(den) * float64(scale))
+	})
+}
+
+// copyDerived returns the functions of all derived gauges which are not
+// silenced.
+func copyDerived() map[string]func(map[string]uint64, map[string]int64) int64 {
+	gm.RLock()
+	defer gm.RUnlock()
+
+	m := make(map[string]func(map[string]uint64, map[string]int64) int64, len(derived))
+	for n, f := range derived {
+		if !isSilenced(n) {
+			m[n] = f
+		}
+	}
+	return m
+}
@@ -0,0 +1,74 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/codahale/metrics"
+)
+
+func TestRatio(t *testing.T) {
+	metrics.Reset()
+
+	metrics.Counter("Cache.Hits").AddN(3)
+	metrics.Counter("Cache.Misses").AddN(1)
+	metrics.Ratio("Cache.HitRate", 1000, "Cache.Hits", "Cache.Hits", "Cache.Misses")
+	metrics.Ratio("Cache.Empty", 1000, "Cache.Hits", "Cache.Nothing")
+
+	_, gauges := metrics.Snapshot()
+
+	if v, want := gauges["Cache.HitRate"], int64(750); v != want {
+		t.Errorf("Ratio was %v, but expected %v", v, want)
+	}
+
+	if v, want := gauges["Cache.Empty"], int64(0); v != want {
+		t.Errorf("Ratio was %v, but expected %v", v, want)
+	}
+}
+
+func TestDerive(t *testing.T) {
+	metrics.Reset()
+
+	metrics.Gauge("Pool.Size").Set(10)
+	metrics.Gauge("Pool.Busy").Set(4)
+	metrics.Derive("Pool.Idle", func(counters map[string]uint64, gauges map[string]int64) int64 {
+		return gauges["Pool.Size"] - gauges["Pool.Busy"]
+	})
+
+	_, gauges := metrics.Snapshot()
+	if v, want := gauges["Pool.Idle"], int64(6); v != want {
+		t.Errorf("Gauge was %v, but expected %v", v, want)
+	}
+
+	metrics.Gauge("Pool.Idle").Remove()
+
+	_, gauges = metrics.Snapshot()
+	if v, ok := gauges["Pool.Idle"]; ok {
+		t.Errorf("Gauge was %v, but expected nothing", v)
+	}
+}
+
+func TestDerivePanic(t *testing.T) {
+	metrics.Reset()
+
+	metrics.Gauge("woo").Set(2)
+	metrics.Derive("whee", func(counters map[string]uint64, gauges map[string]int64) int64 {
+		panic("oh no")
+	})
+	metrics.Derive("double", func(counters map[string]uint64, gauges map[string]int64) int64 {
+		return gauges["woo"] * 2
+	})
+
+	_, gauges := metrics.Snapshot()
+	if v, ok := gauges["whee"]; ok {
+		t.Errorf("Gauge was %v, but expected nothing", v)
+	}
+
+	if v, want := gauges["double"], int64(4); v != want {
+		t.Errorf("Gauge was %v, but expected %v", v, want)
+	}
+
+	counters, _ := metrics.Snapshot()
+	if v, want := counters["Metrics.GaugeErrors"], uint64(1); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+}
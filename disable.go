@@ -0,0 +1,27 @@
+package metrics
+
+import "sync/atomic"
+
+// Disable turns the recording operations of all counters, gauges, and
+// histograms (e.g., Add, Set, and RecordValue) into no-ops, and makes Snapshot
+// and Capture return no metrics without calling any gauge or counter
+// functions. Metrics may still be registered while disabled, and recorded
+// values are retained, so that Enable restores them.
+//
+// Use Disable to rule out the overhead of instrumentation in benchmarks or
+// performance-sensitive deployments without changing code.
+func Disable() {
+	atomic.StoreInt32(&disabled, 1)
+}
+
+// Enable reverses the effect of Disable.
+func Enable() {
+	atomic.StoreInt32(&disabled, 0)
+}
+
+// Enabled returns false if metrics have been disabled.
+func Enabled() bool {
+	return atomic.LoadInt32(&disabled) == 0
+}
+
+var disabled int32
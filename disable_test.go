@@ -0,0 +1,68 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/codahale/metrics"
+)
+
+func TestDisable(t *testing.T) {
+	metrics.Reset()
+
+	metrics.Counter("whee").Add()
+
+	metrics.Disable()
+	defer metrics.Enable()
+
+	calls := 0
+	metrics.Gauge("woo").SetFunc(func() int64 {
+		calls++
+		return 1
+	})
+	metrics.Counter("whee").Add()
+	h := metrics.NewHistogram("heyo", 1, 1000, 3)
+	h.RecordValue(100)
+
+	counters, gauges := metrics.Snapshot()
+	if len(counters) != 0 || len(gauges) != 0 {
+		t.Errorf("Snapshot was %v/%v, but expected nothing", counters, gauges)
+	}
+
+	if v, want := len(metrics.Capture().Histograms), 0; v != want {
+		t.Errorf("Histogram count was %v, but expected %v", v, want)
+	}
+
+	if v, want := calls, 0; v != want {
+		t.Errorf("Gauge was called %v times, but expected %v", v, want)
+	}
+
+	metrics.Enable()
+
+	counters, gauges = metrics.Snapshot()
+	if v, want := counters["whee"], uint64(1); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	if v, want := gauges["woo"], int64(1); v != want {
+		t.Errorf("Gauge was %v, but expected %v", v, want)
+	}
+
+	if v, want := h.TotalCount(), int64(0); v != want {
+		t.Errorf("Count was %v, but expected %v", v, want)
+	}
+}
+
+func BenchmarkCounterAddDisabled(b *testing.B) {
+	metrics.Reset()
+	metrics.Disable()
+	defer metrics.Enable()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			metrics.Counter("test1").Add()
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// Dump writes a human-readable report of all metrics, including the
+// distributions of all histograms, to the given writer.
+func Dump(w io.Writer) error {
+	return writeReport(w, Capture())
+}
+
+// DumpOnSignal writes a human-readable report of all metrics to the given writer
+// (e.g., os.Stderr) whenever the process receives one of the given signals. If
+// no signals are given, it uses SIGUSR1 on platforms which support it. It
+// returns a function which stops the dumping.
+//
+// Use this to inspect the metrics of processes whose HTTP ports are not
+// reachable (e.g., kill -USR1 <pid>).
+func DumpOnSignal(w io.Writer, sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = dumpSignals
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+
+	go func() {
+		for {
+			select {
+			case <-ch:
+				_ = Dump(w)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
+
+func writeReport(out io.Writer, r Report) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(w, "metrics at %s\n", r.Time.Format(time.RFC3339))
+
+	var names []string
+	for n := range r.Counters {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		fmt.Fprintf(w, "counter\t%s\t%d\n", n, r.Counters[n])
+	}
+
+	names = names[:0]
+	for n := range r.FloatCounters {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		fmt.Fprintf(w, "counter\t%s\t%s\n", n, strconv.FormatFloat(r.FloatCounters[n], 'g', -1, 64))
+	}
+
+	names = names[:0]
+	for n := range r.Gauges {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		fmt.Fprintf(w, "gauge\t%s\t%d\n", n, r.Gauges[n])
+	}
+
+	names = names[:0]
+	for n := range r.Histograms {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		s := r.Histograms[n]
+		fmt.Fprintf(w, "histogram\t%s\tcount=%d min=%d max=%d mean=%.2f stddev=%.2f\n",
+			n, s.Count, s.Min, s.Max, s.Mean, s.StdDev)
+
+		qs := make([]float64, 0, len(s.Quantiles))
+		for q := range s.Quantiles {
+			qs = append(qs, q)
+		}
+		sort.Float64s(qs)
+
+		for _, q := range qs {
+			fmt.Fprintf(w, "histogram\t%s\tP%v=%d\n", n, q, s.Quantiles[q])
+		}
+	}
+
+	return w.Flush()
+}
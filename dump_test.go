@@ -0,0 +1,36 @@
+package metrics_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/codahale/metrics"
+)
+
+func TestDump(t *testing.T) {
+	metrics.Reset()
+
+	metrics.Counter("whee").AddN(3)
+	metrics.FloatCounter("wow").Add(1.5)
+	metrics.Gauge("woo").Set(-2)
+	h := metrics.NewHistogram("heyo", 1, 1000, 3)
+	h.RecordValue(7)
+
+	var buf bytes.Buffer
+	if err := metrics.Dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{
+		"counter    whee  3",
+		"counter    wow   1.5",
+		"gauge      woo   -2",
+		"histogram  heyo  count=1 min=7 max=7",
+		"histogram  heyo  P99.9=7",
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("Dump did not contain %q:\n%s", s, buf.String())
+		}
+	}
+}
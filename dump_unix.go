@@ -0,0 +1,10 @@
+// +build !windows
+
+package metrics
+
+import (
+	"os"
+	"syscall"
+)
+
+var dumpSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,5 @@
+package metrics
+
+import "os"
+
+var dumpSignals []os.Signal
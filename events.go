@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// An Event describes a problem or state change which the package otherwise
+// only records in its own counters, such as an exporter failing to send a
+// report, so that it can be logged (e.g., with the slogbridge package).
+type Event struct {
+	// Kind is "error" for an error counted by one of the package's counters,
+	// "name violation" for a name changed or rejected by the name validator,
+	// or "threshold" for a trigger crossing one of its thresholds.
+	Kind string
+
+	// Counter is the Metrics.* counter incremented by the event, if any (e.g.,
+	// Metrics.ExportErrors).
+	Counter string
+
+	// Name is the name of the metric, collector, or trigger concerned, if any.
+	Name string
+
+	// Err is the error, if any.
+	Err error
+
+	// Value and Above are the value which crossed a threshold, and whether it
+	// rose above the high threshold or fell to the low one.
+	Value int64
+	Above bool
+}
+
+// SetEventHandler sets the function called with each event, or removes it if
+// f is nil. The handler is called synchronously, in the goroutine which
+// caused the event and possibly while a snapshot is being taken, so it must
+// not take snapshots (e.g., with Capture) and should return quickly.
+func SetEventHandler(f func(Event)) {
+	em.Lock()
+	defer em.Unlock()
+
+	eventHandler = f
+	if f != nil {
+		atomic.StoreInt32(&handlingEvents, 1)
+	} else {
+		atomic.StoreInt32(&handlingEvents, 0)
+	}
+}
+
+// emit calls the event handler, if any.
+func emit(e Event) {
+	if atomic.LoadInt32(&handlingEvents) == 0 {
+		return
+	}
+
+	em.RLock()
+	f := eventHandler
+	em.RUnlock()
+
+	if f != nil {
+		f(e)
+	}
+}
+
+// countError increments the given counter and emits an error event.
+func countError(c Counter, name string, err error) {
+	c.Add()
+	emit(Event{Kind: "error", Counter: string(c), Name: name, Err: err})
+}
+
+var (
+	eventHandler   func(Event)
+	handlingEvents int32
+	em             sync.RWMutex
+)
@@ -0,0 +1,39 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestEventHandler(t *testing.T) {
+	metricstest.Reset(t)
+
+	var events []metrics.Event
+	metrics.SetEventHandler(func(e metrics.Event) {
+		events = append(events, e)
+	})
+	defer metrics.SetEventHandler(nil)
+
+	metrics.Gauge("Broken").SetFunc(func() int64 {
+		panic("oops")
+	})
+	metrics.Snapshot()
+
+	if len(events) != 1 {
+		t.Fatalf("Events were %+v, but expected one", events)
+	}
+
+	e := events[0]
+	if e.Kind != "error" || e.Counter != "Metrics.GaugeErrors" || e.Name != "Broken" || e.Err == nil {
+		t.Errorf("Event was %+v", e)
+	}
+
+	metrics.SetEventHandler(nil)
+	metrics.Snapshot()
+
+	if len(events) != 1 {
+		t.Errorf("Events were %+v, but expected no more", events)
+	}
+}
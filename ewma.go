@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// A MovingAverage is a gauge which publishes an exponentially-weighted moving
+// average of the samples given to it. A sample's weight halves every
+// half-life, so the average smooths out noisy measurements (e.g., queue
+// latency) while tracking sustained changes.
+type MovingAverage struct {
+	halfLife time.Duration
+	avg      float64
+	last     time.Time
+	m        sync.Mutex
+}
+
+// NewMovingAverage returns a moving average with the given half-life,
+// published as a gauge with the given name.
+func NewMovingAverage(name string, halfLife time.Duration) *MovingAverage {
+	a := &MovingAverage{halfLife: halfLife}
+	Gauge(name).SetFunc(func() int64 {
+		return int64(math.Round(a.Value()))
+	})
+	return a
+}
+
+// Update adds a sample to the average. The first sample becomes the average;
+// subsequent samples are weighted by the time since the previous sample.
+func (a *MovingAverage) Update(v int64) {
+	t := now()
+
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	if a.last.IsZero() {
+		a.avg = float64(v)
+	} else {
+		dt := t.Sub(a.last).Seconds()
+		alpha := 1 - math.Exp(-dt*math.Ln2/a.halfLife.Seconds())
+		a.avg += alpha * (float64(v) - a.avg)
+	}
+	a.last = t
+}
+
+// Value returns the current average.
+func (a *MovingAverage) Value() float64 {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	return a.avg
+}
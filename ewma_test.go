@@ -0,0 +1,26 @@
+package metrics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestMovingAverage(t *testing.T) {
+	metricstest.Reset(t)
+	c := metricstest.UseFakeClock(t)
+
+	a := metrics.NewMovingAverage("Latency", 10*time.Second)
+	a.Update(100)
+	metricstest.AssertGauge(t, "Latency", 100)
+
+	c.Advance(10 * time.Second)
+	a.Update(200)
+	metricstest.AssertGauge(t, "Latency", 150)
+
+	c.Advance(20 * time.Second)
+	a.Update(150)
+	metricstest.AssertGauge(t, "Latency", 150)
+}
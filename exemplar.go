@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// An Exemplar is a recorded value along with labels identifying where it came
+// from (e.g., a trace ID), allowing a spike in a histogram's quantiles to be
+// traced to an offending request.
+type Exemplar struct {
+	Value  int64
+	Labels map[string]string
+	Time   time.Time
+}
+
+// RecordValueWithExemplar records the given value along with an exemplar
+// carrying the given labels, or returns an error if the value is out of range.
+//
+// A histogram retains the most recent exemplar for each power-of-two range of
+// values, until the window in which it was recorded is dropped, and reports the
+// exemplar for the range containing each quantile.
+func (h *Histogram) RecordValueWithExemplar(v int64, labels map[string]string) error {
+	if atomic.LoadInt32(&disabled) != 0 || isSilenced(h.name) {
+		return nil
+	}
+
+	e := Exemplar{Value: v, Labels: labels, Time: now()}
+
+	locked := beginUpdate()
+	h.rw.Lock()
+	err := h.record(v, 1)
+	if err == nil {
+		if h.exemplars == nil {
+			h.exemplars = make(map[int]Exemplar)
+		}
+		h.exemplars[bucketIndex(v)] = e
+	}
+	h.rw.Unlock()
+	endUpdate(locked)
+
+	if err != nil {
+		return Error{h.name, err}
+	}
+	notifyUpdated(h.name, "histogram")
+	return nil
+}
+
+// exemplar returns the most recent exemplar in the range containing v. It must
+// be called with h.rw held.
+func (h *Histogram) exemplar(v int64) (Exemplar, bool) {
+	e, ok := h.exemplars[bucketIndex(v)]
+	return e, ok
+}
@@ -0,0 +1,61 @@
+package metrics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestRecordValueWithExemplar(t *testing.T) {
+	metrics.Reset()
+
+	h := metrics.NewHistogram("heyo", 1, 1000, 3)
+	for i := 1; i <= 100; i++ {
+		h.RecordValue(int64(i))
+	}
+	h.RecordValueWithExemplar(100, map[string]string{"trace_id": "abc"})
+
+	s := h.Summary()
+
+	e, ok := s.Exemplars[99]
+	if !ok {
+		t.Fatalf("No exemplar for P99: %v", s.Exemplars)
+	}
+
+	if v, want := e.Labels["trace_id"], "abc"; v != want {
+		t.Errorf("Trace ID was %q, but expected %q", v, want)
+	}
+
+	if e, ok := s.Exemplars[50]; ok {
+		t.Errorf("Unexpected exemplar for P50: %v", e)
+	}
+
+	if err := h.RecordValueWithExemplar(5000, nil); err == nil {
+		t.Error("Expected an error but got none")
+	}
+}
+
+func TestExemplarExpiry(t *testing.T) {
+	metricstest.Reset(t)
+	c := metricstest.UseFakeClock(t)
+
+	h := metrics.NewHistogramWithOptions("heyo", 1, 1000, 3, metrics.HistogramOptions{
+		Interval: 10 * time.Second,
+	})
+	h.RecordValueWithExemplar(100, map[string]string{"trace_id": "abc"})
+
+	c.Advance(40 * time.Second)
+	h.RecordValue(100)
+
+	if _, ok := h.Summary().Exemplars[99]; !ok {
+		t.Error("Exemplar expired before its window")
+	}
+
+	c.Advance(11 * time.Second)
+
+	if e, ok := h.Summary().Exemplars[99]; ok {
+		t.Errorf("Exemplar %v outlived its window", e)
+	}
+}
@@ -0,0 +1,205 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// writeExposition writes the report in the Prometheus text format or, if
+// openMetrics is true, the OpenMetrics 1.0 text format. Histograms are written
+// as summaries or, if buckets is true, as histograms with cumulative buckets.
+func writeExposition(out io.Writer, r Report, md map[string]Metadata, created map[string]time.Time, openMetrics, buckets bool) error {
+	w := bufio.NewWriter(out)
+
+	family := func(name, typ string, m Metadata) string {
+		n := PrometheusName(name)
+
+		// OpenMetrics counter families are named without the _total suffix
+		// of their samples
+		if openMetrics && typ == "counter" {
+			n = strings.TrimSuffix(n, "_total")
+		}
+
+		if openMetrics && m.Unit != "" && !strings.HasSuffix(n, "_"+m.Unit) {
+			n += "_" + PrometheusName(m.Unit)
+		}
+
+		// the Prometheus text format has no separate family name for counters
+		if !openMetrics && typ == "counter" && !strings.HasSuffix(n, "_total") {
+			n += "_total"
+		}
+
+		help := m.Help
+		if help == "" {
+			help = name
+		}
+
+		fmt.Fprintf(w, "# TYPE %s %s\n", n, typ)
+		if openMetrics && m.Unit != "" {
+			fmt.Fprintf(w, "# UNIT %s %s\n", n, PrometheusName(m.Unit))
+		}
+		fmt.Fprintf(w, "# HELP %s %s\n", n, escapeHelp(help))
+		return n
+	}
+
+	for _, name := range sortedKeys(r.Counters) {
+		n := family(name, "counter", md[name])
+		if openMetrics {
+			fmt.Fprintf(w, "%s_total %d\n", n, r.Counters[name])
+			if t, ok := created[name]; ok {
+				fmt.Fprintf(w, "%s_created %s\n", n, formatTime(t))
+			}
+		} else {
+			fmt.Fprintf(w, "%s %d\n", n, r.Counters[name])
+		}
+	}
+
+	for _, name := range sortedKeys(r.FloatCounters) {
+		n := family(name, "counter", md[name])
+		v := strconv.FormatFloat(r.FloatCounters[name], 'f', -1, 64)
+		if openMetrics {
+			fmt.Fprintf(w, "%s_total %s\n", n, v)
+		} else {
+			fmt.Fprintf(w, "%s %s\n", n, v)
+		}
+	}
+
+	for _, name := range sortedKeys(r.Gauges) {
+		n := family(name, "gauge", md[name])
+		if name == BuildInfoGauge {
+			fmt.Fprintf(w, "%s%s %d\n", n, formatLabels(copyBuildInfo()), r.Gauges[name])
+			continue
+		}
+		fmt.Fprintf(w, "%s %d\n", n, r.Gauges[name])
+	}
+
+	for _, name := range sortedKeys(r.Histograms) {
+		s := r.Histograms[name]
+
+		if buckets {
+			n := family(name, "histogram", md[name])
+			for _, b := range s.Buckets {
+				fmt.Fprintf(w, "%s_bucket{le=\"%d\"} %d", n, b.UpperBound, b.Count)
+				if openMetrics && b.Exemplar != nil {
+					fmt.Fprintf(w, " # %s %d %s", formatLabels(b.Exemplar.Labels), b.Exemplar.Value, formatTime(b.Exemplar.Time))
+				}
+				fmt.Fprint(w, "\n")
+			}
+			fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", n, s.Count)
+			fmt.Fprintf(w, "%s_sum %s\n", n, strconv.FormatFloat(s.Mean*float64(s.Count), 'f', -1, 64))
+			fmt.Fprintf(w, "%s_count %d\n", n, s.Count)
+			continue
+		}
+
+		n := family(name, "summary", md[name])
+
+		qs := make([]float64, 0, len(s.Quantiles))
+		for q := range s.Quantiles {
+			qs = append(qs, q)
+		}
+		sort.Float64s(qs)
+
+		for _, q := range qs {
+			fmt.Fprintf(w, "%s{quantile=\"%s\"} %d\n", n, formatQuantile(q), s.Quantiles[q])
+		}
+		fmt.Fprintf(w, "%s_sum %s\n", n, strconv.FormatFloat(s.Mean*float64(s.Count), 'f', -1, 64))
+		fmt.Fprintf(w, "%s_count %d\n", n, s.Count)
+	}
+
+	if openMetrics {
+		fmt.Fprint(w, "# EOF\n")
+	}
+
+	return w.Flush()
+}
+
+// PrometheusName replaces each character in the name which is not valid in a
+// Prometheus metric name with an underscore. It can be used with Sanitize or
+// NormalizeNames.
+func PrometheusName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		valid := c == '_' || c == ':' ||
+			('a' <= c && c <= 'z') ||
+			('A' <= c && c <= 'Z') ||
+			('0' <= c && c <= '9' && i > 0)
+		if !valid {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// formatQuantile formats a quantile from 0-100 as a fraction from 0-1, rounding
+// away floating point noise (e.g., 0.9990000000000001).
+func formatQuantile(q float64) string {
+	return strconv.FormatFloat(math.Round(q*1e4)/1e6, 'f', -1, 64)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+// formatLabels formats a set of labels as a sorted, brace-enclosed list.
+func formatLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", PrometheusName(k), escapeLabel(labels[k]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', 3, 64)
+}
+
+// sortedKeys returns the keys of a map of counters, gauges, histograms, or tags
+// in sorted order.
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]uint64:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]int64:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]float64:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]HistogramSummary:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]string:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"sync"
+	"sync/atomic"
+)
+
+// expvarName is the name of the expvar the metrics are published under when
+// the package is initialized. It may be overridden at build time via -ldflags
+// "-X"; an empty name disables publication.
+var expvarName = "metrics"
+
+// Publish publishes the counters and gauges as an expvar with the given name.
+// Like expvar.Publish, it panics if the name is already in use.
+func Publish(name string) {
+	pm.Lock()
+	published[name] = true
+	pm.Unlock()
+
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		if atomic.LoadInt32(&expvarDisabled) != 0 && isDefaultExpvar(name) {
+			return nil
+		}
+
+		counters, gauges := Snapshot()
+		return map[string]interface{}{
+			"Counters": counters,
+			"Gauges":   gauges,
+		}
+	}))
+}
+
+// MirrorExpvars publishes every numeric expvar (e.g., those published by
+// third-party libraries) as a gauge, with the given prefix prepended to its
+// name. Numeric fields of expvars which are JSON objects are published with
+// their keys appended to the expvar's name, separated by periods (e.g.,
+// "memstats.HeapAlloc"). The expvars are walked on each snapshot, so expvars
+// published later are mirrored as they appear. Fractional values are
+// truncated, and the metrics package's own expvars are skipped.
+func MirrorExpvars(prefix string) {
+	m := &expvarMirror{
+		prefix: prefix,
+		values: make(map[string]int64),
+	}
+
+	Gauge(prefix+"Expvars.Count").SetBatchFunc(m, m.walk, m.count)
+}
+
+type expvarMirror struct {
+	prefix string
+
+	m      sync.Mutex
+	n      int64
+	values map[string]int64
+}
+
+func (m *expvarMirror) walk() {
+	values := make(map[string]int64)
+	n := int64(0)
+
+	expvar.Do(func(kv expvar.KeyValue) {
+		pm.Lock()
+		skip := published[kv.Key]
+		pm.Unlock()
+
+		if skip {
+			return
+		}
+		n++
+
+		var v interface{}
+		if err := json.Unmarshal([]byte(kv.Value.String()), &v); err != nil {
+			return
+		}
+		flatten(values, m.prefix+kv.Key, v)
+	})
+
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	m.n = n
+	for name, v := range values {
+		if _, ok := m.values[name]; !ok {
+			name := name
+			Gauge(name).SetFunc(func() int64 {
+				m.m.Lock()
+				defer m.m.Unlock()
+
+				return m.values[name]
+			})
+		}
+		m.values[name] = v
+	}
+}
+
+func (m *expvarMirror) count() int64 {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	return m.n
+}
+
+// flatten adds the numeric values in v to values, named by their paths.
+func flatten(values map[string]int64, name string, v interface{}) {
+	switch v := v.(type) {
+	case float64:
+		values[name] = int64(v)
+	case map[string]interface{}:
+		for k, e := range v {
+			flatten(values, name+"."+k, e)
+		}
+	}
+}
+
+func isDefaultExpvar(name string) bool {
+	pm.Lock()
+	defer pm.Unlock()
+
+	return name == defaultExpvar
+}
+
+var (
+	published      = make(map[string]bool) // the names of expvars published by Publish
+	defaultExpvar  string                  // the name of the expvar published by init
+	expvarDisabled int32                   // whether the default expvar is disabled
+	pm             sync.Mutex
+)
@@ -0,0 +1,64 @@
+package metrics_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"strings"
+	"testing"
+
+	"github.com/codahale/metrics"
+)
+
+func TestPublish(t *testing.T) {
+	metrics.Reset()
+	metrics.Counter("whee").Add()
+
+	metrics.Publish("custom.metrics")
+
+	var v struct {
+		Counters map[string]uint64
+	}
+	if err := json.Unmarshal([]byte(expvar.Get("custom.metrics").String()), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := v.Counters["whee"], uint64(1); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	if expvar.Get("metrics") == nil {
+		t.Error("Default expvar was not published")
+	}
+}
+
+func TestMirrorExpvars(t *testing.T) {
+	metrics.Reset()
+
+	expvar.NewInt("mirror.int").Set(5)
+	m := expvar.NewMap("mirror.map")
+	m.Add("hits", 3)
+	m.AddFloat("ratio", 1.5)
+	expvar.NewString("mirror.string").Set("nope")
+
+	metrics.MirrorExpvars("Vars.")
+
+	_, gauges := metrics.Snapshot()
+
+	expected := map[string]int64{
+		"Vars.mirror.int":       5,
+		"Vars.mirror.map.hits":  3,
+		"Vars.mirror.map.ratio": 1,
+	}
+
+	for n, want := range expected {
+		if v, ok := gauges[n]; !ok || v != want {
+			t.Errorf("Gauge %q was %v, but expected %v", n, v, want)
+		}
+	}
+
+	for n := range gauges {
+		if strings.HasPrefix(n, "Vars.mirror.string") || strings.HasPrefix(n, "Vars.metrics") {
+			t.Errorf("Unexpected gauge %q", n)
+		}
+	}
+}
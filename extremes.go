@@ -0,0 +1,58 @@
+package metrics
+
+import "sync"
+
+// A MaxGauge is a gauge which publishes the largest value observed since it was
+// last evaluated, then resets. It captures spikes (e.g., the largest batch
+// size) which a gauge set to the latest value would miss between snapshots. If
+// no values were observed, it publishes zero.
+type MaxGauge struct {
+	extreme
+}
+
+// NewMaxGauge returns a max gauge with the given name.
+func NewMaxGauge(name string) *MaxGauge {
+	g := &MaxGauge{extreme{max: true}}
+	Gauge(name).SetFunc(g.reset)
+	return g
+}
+
+// A MinGauge is a gauge which publishes the smallest value observed since it
+// was last evaluated, then resets. If no values were observed, it publishes
+// zero.
+type MinGauge struct {
+	extreme
+}
+
+// NewMinGauge returns a min gauge with the given name.
+func NewMinGauge(name string) *MinGauge {
+	g := &MinGauge{}
+	Gauge(name).SetFunc(g.reset)
+	return g
+}
+
+type extreme struct {
+	max bool
+	v   int64
+	ok  bool
+	m   sync.Mutex
+}
+
+// Observe records a value.
+func (e *extreme) Observe(v int64) {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	if !e.ok || (e.max && v > e.v) || (!e.max && v < e.v) {
+		e.v, e.ok = v, true
+	}
+}
+
+func (e *extreme) reset() int64 {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	v := e.v
+	e.v, e.ok = 0, false
+	return v
+}
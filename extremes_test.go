@@ -0,0 +1,40 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/codahale/metrics"
+)
+
+func TestMaxGauge(t *testing.T) {
+	metrics.Reset()
+
+	g := metrics.NewMaxGauge("BatchSize")
+	g.Observe(3)
+	g.Observe(10)
+	g.Observe(-1)
+
+	_, gauges := metrics.Snapshot()
+	if v, want := gauges["BatchSize"], int64(10); v != want {
+		t.Errorf("Max was %v, but expected %v", v, want)
+	}
+
+	_, gauges = metrics.Snapshot()
+	if v, want := gauges["BatchSize"], int64(0); v != want {
+		t.Errorf("Max was %v, but expected %v", v, want)
+	}
+}
+
+func TestMinGauge(t *testing.T) {
+	metrics.Reset()
+
+	g := metrics.NewMinGauge("FreeSlots")
+	g.Observe(3)
+	g.Observe(10)
+	g.Observe(-1)
+
+	_, gauges := metrics.Snapshot()
+	if v, want := gauges["FreeSlots"], int64(-1); v != want {
+		t.Errorf("Min was %v, but expected %v", v, want)
+	}
+}
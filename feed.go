@@ -0,0 +1,368 @@
+package metrics
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Feed is an HTTP handler which sends the values of counters and gauges to
+// clients over WebSockets, allowing embedded dashboards to receive only the
+// metrics they display. Clients send JSON messages to change their
+// subscriptions:
+//
+//	{"Subscribe": ["HTTP.*", "Mem.Alloc"], "Interval": "5s", "Changes": true}
+//	{"Unsubscribe": ["HTTP.*"]}
+//
+// Subscriptions are glob patterns (see path.Match) matched against names after
+// the feed's pipeline is applied. Interval, if set, changes the interval at
+// which values are sent to the client, though never to less than the feed's
+// own interval, since each message requires a snapshot. Changes, if true,
+// sends only the values which have changed since the last message. After each
+// subscription message, and once per interval, the feed sends the client a JSON
+// message of the matching values:
+//
+//	{"Time": "...", "Counters": {"HTTP.Requests": 4}, "Gauges": {"Mem.Alloc": 1024}}
+//
+// No messages are sent to clients without subscriptions, and clients which do
+// not accept a message within ten seconds are disconnected.
+type Feed struct {
+	// Pipeline is applied to the names of metrics before they are matched.
+	Pipeline Pipeline
+
+	interval time.Duration
+}
+
+// NewFeed returns a feed which sends values once per the given interval, unless
+// clients request otherwise. It panics if the interval is not positive.
+func NewFeed(interval time.Duration) *Feed {
+	if interval <= 0 {
+		panic("feed interval must be positive")
+	}
+	return &Feed{interval: interval}
+}
+
+type feedRequest struct {
+	Subscribe   []string
+	Unsubscribe []string
+	Interval    string
+	Changes     bool
+}
+
+type feedUpdate struct {
+	Time     time.Time
+	Counters map[string]uint64
+	Gauges   map[string]int64
+}
+
+// ServeHTTP upgrades the connection to a WebSocket and sends values until the
+// client disconnects.
+func (f *Feed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer ws.c.Close()
+
+	requests := make(chan feedRequest)
+	done, quit := make(chan struct{}), make(chan struct{})
+	defer close(quit)
+
+	go func() {
+		defer close(done)
+		for {
+			b, err := ws.read()
+			if err != nil {
+				return
+			}
+
+			var req feedRequest
+			if err := json.Unmarshal(b, &req); err != nil {
+				return
+			}
+
+			select {
+			case requests <- req:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	patterns := make(map[string]struct{})
+	interval, changes := f.interval, false
+	lastCounters, lastGauges := make(map[string]uint64), make(map[string]int64)
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case req := <-requests:
+			for _, p := range req.Subscribe {
+				patterns[p] = struct{}{}
+			}
+
+			for _, p := range req.Unsubscribe {
+				delete(patterns, p)
+			}
+
+			if req.Interval != "" {
+				if d, err := time.ParseDuration(req.Interval); err == nil && d > 0 {
+					if d < f.interval {
+						d = f.interval
+					}
+					interval = d
+					t.Reset(d)
+				}
+			}
+
+			// send everything matching the new subscriptions
+			changes = req.Changes
+			lastCounters, lastGauges = make(map[string]uint64), make(map[string]int64)
+		case <-t.C:
+		case <-done:
+			return
+		}
+
+		if len(patterns) == 0 {
+			continue
+		}
+
+		u := f.update(patterns, changes, lastCounters, lastGauges)
+		if changes && len(u.Counters) == 0 && len(u.Gauges) == 0 {
+			continue
+		}
+
+		b, err := json.Marshal(u)
+		if err == nil {
+			err = ws.write(wsText, b)
+		}
+
+		if err != nil {
+			countError("Metrics.ExportErrors", "", err)
+			return
+		}
+	}
+}
+
+// update returns the values of the metrics matching the patterns, omitting
+// those which are unchanged since the last update if changes is true, and
+// records them as the last values sent.
+func (f *Feed) update(patterns map[string]struct{}, changes bool, lastCounters map[string]uint64, lastGauges map[string]int64) feedUpdate {
+	counters, gauges := Snapshot()
+
+	u := feedUpdate{
+		Time:     now(),
+		Counters: make(map[string]uint64),
+		Gauges:   make(map[string]int64),
+	}
+
+	for n, v := range counters {
+		if n, ok := f.match(patterns, n); ok {
+			if last, ok := lastCounters[n]; !changes || !ok || last != v {
+				u.Counters[n] = v
+			}
+			lastCounters[n] = v
+		}
+	}
+
+	for n, v := range gauges {
+		if n, ok := f.match(patterns, n); ok {
+			if last, ok := lastGauges[n]; !changes || !ok || last != v {
+				u.Gauges[n] = v
+			}
+			lastGauges[n] = v
+		}
+	}
+
+	return u
+}
+
+// match applies the feed's pipeline to a name and returns the resulting name
+// and true if it matches any of the patterns.
+func (f *Feed) match(patterns map[string]struct{}, name string) (string, bool) {
+	name, ok := f.Pipeline.Name(name)
+	if !ok {
+		return "", false
+	}
+
+	for p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// WebSocket opcodes, from RFC 6455.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+// maxWSMessage is the largest message accepted from a WebSocket client.
+const maxWSMessage = 64 * 1024
+
+var errWSProtocol = errors.New("websocket: protocol error")
+
+// wsWriteTimeout is the time allowed for writing each frame to a client.
+const wsWriteTimeout = 10 * time.Second
+
+// A wsConn is the server side of a WebSocket connection.
+type wsConn struct {
+	c  net.Conn
+	r  *bufio.Reader
+	wm sync.Mutex
+}
+
+// upgrade completes a WebSocket handshake and hijacks the connection.
+func upgrade(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+		return nil, errWSProtocol
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket unsupported", http.StatusInternalServerError)
+		return nil, errWSProtocol
+	}
+
+	c, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	_, err = io.WriteString(c, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: "+base64.StdEncoding.EncodeToString(h[:])+"\r\n\r\n")
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return &wsConn{c: c, r: rw.Reader}, nil
+}
+
+// read returns the next data message from the client, answering any control
+// frames which precede it.
+func (ws *wsConn) read() ([]byte, error) {
+	var msg []byte
+	for {
+		var h [2]byte
+		if _, err := io.ReadFull(ws.r, h[:]); err != nil {
+			return nil, err
+		}
+
+		fin, op := h[0]&0x80 != 0, h[0]&0x0f
+		if h[1]&0x80 == 0 {
+			return nil, errWSProtocol // client frames must be masked
+		}
+
+		n := uint64(h[1] & 0x7f)
+		switch n {
+		case 126:
+			var b [2]byte
+			if _, err := io.ReadFull(ws.r, b[:]); err != nil {
+				return nil, err
+			}
+			n = uint64(binary.BigEndian.Uint16(b[:]))
+		case 127:
+			var b [8]byte
+			if _, err := io.ReadFull(ws.r, b[:]); err != nil {
+				return nil, err
+			}
+			n = binary.BigEndian.Uint64(b[:])
+		}
+
+		// checked separately so that huge lengths cannot overflow the sum
+		if n > maxWSMessage || uint64(len(msg)) > maxWSMessage-n {
+			_ = ws.write(wsClose, []byte{0x03, 0xf1}) // 1009: message too big
+			return nil, errWSProtocol
+		}
+
+		var mask [4]byte
+		if _, err := io.ReadFull(ws.r, mask[:]); err != nil {
+			return nil, err
+		}
+
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(ws.r, payload); err != nil {
+			return nil, err
+		}
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+
+		switch op {
+		case wsClose:
+			_ = ws.write(wsClose, nil)
+			return nil, io.EOF
+		case wsPing:
+			if err := ws.write(wsPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsText, wsBinary, wsContinuation:
+			msg = append(msg, payload...)
+		default:
+			return nil, errWSProtocol
+		}
+
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+// write sends an unfragmented frame to the client.
+func (ws *wsConn) write(op byte, payload []byte) error {
+	ws.wm.Lock()
+	defer ws.wm.Unlock()
+
+	h := []byte{0x80 | op, 0}
+	switch n := len(payload); {
+	case n < 126:
+		h[1] = byte(n)
+	case n <= 0xffff:
+		h[1] = 126
+		h = append(h, 0, 0)
+		binary.BigEndian.PutUint16(h[2:], uint16(n))
+	default:
+		h[1] = 127
+		h = append(h, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(h[2:], uint64(n))
+	}
+
+	if err := ws.c.SetWriteDeadline(time.Now().Add(wsWriteTimeout)); err != nil {
+		return err
+	}
+
+	if _, err := ws.c.Write(h); err != nil {
+		return err
+	}
+	_, err := ws.c.Write(payload)
+	return err
+}
@@ -0,0 +1,197 @@
+package metrics_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+type feedClient struct {
+	c net.Conn
+	r *bufio.Reader
+}
+
+func dialFeed(t *testing.T, url string) *feedClient {
+	c, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = io.WriteString(c, "GET / HTTP/1.1\r\n"+
+		"Host: localhost\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := bufio.NewReader(c)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := resp.StatusCode, http.StatusSwitchingProtocols; v != want {
+		t.Fatalf("Status was %v, but expected %v", v, want)
+	}
+
+	if v, want := resp.Header.Get("Sec-WebSocket-Accept"), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; v != want {
+		t.Errorf("Accept was %q, but expected %q", v, want)
+	}
+
+	return &feedClient{c: c, r: r}
+}
+
+func (fc *feedClient) send(t *testing.T, msg string) {
+	mask := []byte{1, 2, 3, 4}
+	frame := append([]byte{0x81, 0x80 | byte(len(msg))}, mask...)
+	for i := 0; i < len(msg); i++ {
+		frame = append(frame, msg[i]^mask[i%4])
+	}
+
+	if _, err := fc.c.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (fc *feedClient) receive(t *testing.T) (u struct {
+	Counters map[string]uint64
+	Gauges   map[string]int64
+}) {
+	h := make([]byte, 2)
+	if _, err := io.ReadFull(fc.r, h); err != nil {
+		t.Fatal(err)
+	}
+
+	n := int(h[1])
+	if n == 126 {
+		b := make([]byte, 2)
+		if _, err := io.ReadFull(fc.r, b); err != nil {
+			t.Fatal(err)
+		}
+		n = int(binary.BigEndian.Uint16(b))
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(fc.r, b); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Unmarshal(b, &u); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestFeed(t *testing.T) {
+	metricstest.Reset(t)
+	metrics.Counter("HTTP.Requests").AddN(4)
+	metrics.Gauge("HTTP.Conns").Set(2)
+	metrics.Gauge("Mem.Alloc").Set(1024)
+
+	srv := httptest.NewServer(metrics.NewFeed(10 * time.Millisecond))
+	defer srv.Close()
+
+	fc := dialFeed(t, srv.URL)
+	defer fc.c.Close()
+
+	fc.send(t, `{"Subscribe": ["HTTP.*"], "Interval": "20ms", "Changes": true}`)
+
+	u := fc.receive(t)
+	if v, want := u.Counters["HTTP.Requests"], uint64(4); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	if v, want := u.Gauges["HTTP.Conns"], int64(2); v != want {
+		t.Errorf("Gauge was %v, but expected %v", v, want)
+	}
+
+	if v, ok := u.Gauges["Mem.Alloc"]; ok {
+		t.Errorf("Gauge was %v, but expected nothing", v)
+	}
+
+	metrics.Gauge("HTTP.Conns").Set(3)
+
+	u = fc.receive(t)
+	if v, want := len(u.Counters), 0; v != want {
+		t.Errorf("Counters were %v, but expected none", u.Counters)
+	}
+
+	if v, want := u.Gauges["HTTP.Conns"], int64(3); v != want {
+		t.Errorf("Gauge was %v, but expected %v", v, want)
+	}
+}
+
+func TestFeedMinimumInterval(t *testing.T) {
+	metricstest.Reset(t)
+	metrics.Counter("HTTP.Requests").Add()
+
+	srv := httptest.NewServer(metrics.NewFeed(time.Hour))
+	defer srv.Close()
+
+	fc := dialFeed(t, srv.URL)
+	defer fc.c.Close()
+
+	fc.send(t, `{"Subscribe": ["HTTP.*"], "Interval": "1ns"}`)
+	fc.receive(t)
+
+	if err := fc.c.SetReadDeadline(time.Now().Add(100 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fc.r.ReadByte(); err == nil {
+		t.Error("Received a message before the feed's interval")
+	}
+}
+
+func TestFeedOversizedContinuation(t *testing.T) {
+	metricstest.Reset(t)
+
+	srv := httptest.NewServer(metrics.NewFeed(time.Hour))
+	defer srv.Close()
+
+	fc := dialFeed(t, srv.URL)
+	defer fc.c.Close()
+
+	// a non-final text frame, then a continuation frame claiming 2^64-1 bytes
+	frames := []byte{0x01, 0x81, 1, 2, 3, 4, 'x' ^ 1}
+	frames = append(frames, 0x80, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 1, 2, 3, 4)
+	if _, err := fc.c.Write(frames); err != nil {
+		t.Fatal(err)
+	}
+
+	h := make([]byte, 4)
+	if _, err := io.ReadFull(fc.r, h); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := h, []byte{0x88, 0x02, 0x03, 0xf1}; string(v) != string(want) {
+		t.Errorf("Frame was %x, but expected %x", v, want)
+	}
+
+	if _, err := fc.r.ReadByte(); err != io.EOF {
+		t.Errorf("Error was %v, but expected EOF", err)
+	}
+}
+
+func TestNewFeedInvalidInterval(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewFeed did not panic")
+		}
+	}()
+
+	metrics.NewFeed(0)
+}
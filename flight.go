@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// A FlightRecorder periodically captures a report of all metrics and writes
+// the most recent one to a file if the process panics, so that postmortems
+// have the telemetry which was never scraped. Defer its Recover method at the
+// top of main and of each long-lived goroutine:
+//
+//	fr := metrics.NewFlightRecorder("/var/crash/api.metrics", 10*time.Second)
+//	defer fr.Recover()
+//
+// Memory faults (e.g., nil pointer dereferences in unsafe code) are only
+// recoverable in goroutines which call runtime/debug.SetPanicOnFault(true).
+type FlightRecorder struct {
+	path string
+	t    Timer
+
+	m    sync.Mutex
+	last Report
+}
+
+// NewFlightRecorder returns a flight recorder which captures a report once per
+// the given interval and writes it to the given path on a panic. It panics if
+// the interval is not positive.
+func NewFlightRecorder(path string, interval time.Duration) *FlightRecorder {
+	checkInterval(interval)
+
+	fr := &FlightRecorder{path: path}
+	fr.capture()
+	fr.t = repeat(interval, fr.capture)
+	return fr
+}
+
+// Recover, when deferred, writes the flight record if the goroutine panics and
+// then continues panicking with the same value.
+func (fr *FlightRecorder) Recover() {
+	if r := recover(); r != nil {
+		_ = fr.Write(r)
+		panic(r)
+	}
+}
+
+// Write writes the flight record, with the given reason (e.g., the value
+// passed to panic), the current goroutine's stack, and a report of all
+// metrics. The report is captured anew if possible, but if that takes longer
+// than a second (e.g., because a gauge function panicked while a snapshot
+// was being taken), the most recent periodic report is written instead.
+func (fr *FlightRecorder) Write(reason interface{}) error {
+	stack := debug.Stack()
+
+	ch := make(chan Report, 1)
+	go func() {
+		ch <- Capture()
+	}()
+
+	var r Report
+	select {
+	case r = <-ch:
+	case <-time.After(1 * time.Second):
+		fr.m.Lock()
+		r = fr.last
+		fr.m.Unlock()
+	}
+
+	f, err := os.Create(fr.path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(f, "panic: %v\n\n%s\n", reason, stack)
+	if err := writeReport(f, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Stop stops capturing reports.
+func (fr *FlightRecorder) Stop() {
+	fr.t.Stop()
+}
+
+func (fr *FlightRecorder) capture() {
+	r := Capture()
+
+	fr.m.Lock()
+	defer fr.m.Unlock()
+
+	fr.last = r
+}
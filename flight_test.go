@@ -0,0 +1,54 @@
+package metrics_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestFlightRecorder(t *testing.T) {
+	metricstest.Reset(t)
+	metricstest.UseFakeClock(t)
+
+	path := filepath.Join(t.TempDir(), "flight")
+	fr := metrics.NewFlightRecorder(path, 10*time.Second)
+	defer fr.Stop()
+
+	metrics.Counter("Requests").AddN(3)
+	h := metrics.NewHistogram("Latency", 1, 1000, 3)
+	_ = h.RecordValue(42)
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("Panic was %v, but expected boom", r)
+			}
+		}()
+		defer fr.Recover()
+
+		panic("boom")
+	}()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ignore the alignment of columns
+	record := strings.Join(strings.Fields(string(b)), " ")
+	for _, s := range []string{
+		"panic: boom",
+		"TestFlightRecorder",
+		"counter Requests 3",
+		"histogram Latency count=1 min=42 max=42",
+	} {
+		if !strings.Contains(record, s) {
+			t.Errorf("Flight record did not contain %q:\n%s", s, b)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// A FloatCounter is a monotonically increasing floating point value.
+//
+// Use a float counter for fractional accumulations (e.g., dollars billed or
+// seconds of CPU time). Unlike gauges, float counters are exported as
+// cumulative values, from which rates can be derived.
+type FloatCounter string
+
+// Add increments the counter by the given delta. Negative and NaN deltas are
+// discarded and counted by the Metrics.CounterViolations counter.
+func (c FloatCounter) Add(delta float64) {
+	if atomic.LoadInt32(&disabled) != 0 {
+		return
+	}
+
+	name, ok := resolve(string(c))
+	if !ok || isSilenced(name) {
+		return
+	}
+
+	if delta < 0 || math.IsNaN(delta) {
+		_ = violation(name, ErrCounterDecremented)
+		return
+	}
+
+	fcm.RLock()
+	v, ok := floatCounters[name]
+	fcm.RUnlock()
+
+	if !ok {
+		fcm.Lock()
+		if v, ok = floatCounters[name]; !ok {
+			v = new(uint64)
+			floatCounters[name] = v
+		}
+		fcm.Unlock()
+
+		if !ok {
+			notifyRegistered(name, "float counter")
+		}
+	}
+
+	locked := beginUpdate()
+	for {
+		old := atomic.LoadUint64(v)
+		n := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(v, old, n) {
+			break
+		}
+	}
+	endUpdate(locked)
+	touch(name)
+	notifyUpdated(name, "float counter")
+}
+
+// Value returns the counter's current value, or zero if the counter does not
+// exist.
+func (c FloatCounter) Value() float64 {
+	name, ok := resolve(string(c))
+	if !ok {
+		return 0
+	}
+
+	fcm.RLock()
+	v, ok := floatCounters[name]
+	fcm.RUnlock()
+
+	if ok {
+		return math.Float64frombits(atomic.LoadUint64(v))
+	}
+	return 0
+}
+
+// Remove removes the given counter.
+func (c FloatCounter) Remove() {
+	if name, ok := resolve(string(c)); ok {
+		removeFloatCounter(name)
+	}
+}
+
+// removeFloatCounter removes the float counter with the given resolved name.
+func removeFloatCounter(name string) {
+	fcm.Lock()
+	_, exists := floatCounters[name]
+	delete(floatCounters, name)
+	untouch(name)
+	fcm.Unlock()
+
+	if exists {
+		notifyRemoved(name, "float counter")
+	}
+}
+
+// snapshotFloats returns a copy of the values of all float counters.
+func snapshotFloats() map[string]float64 {
+	fcm.RLock()
+	defer fcm.RUnlock()
+
+	t := now()
+	m := make(map[string]float64, len(floatCounters))
+	for n, v := range floatCounters {
+		if !isSilenced(n) && !isStale(n, t) {
+			m[n] = math.Float64frombits(atomic.LoadUint64(v))
+		}
+	}
+	return m
+}
+
+var (
+	floatCounters = make(map[string]*uint64) // float64 bits by name
+	fcm           sync.RWMutex
+)
@@ -0,0 +1,100 @@
+package metrics_test
+
+import (
+	"math"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestFloatCounter(t *testing.T) {
+	metricstest.Reset(t)
+	c := metricstest.UseFakeClock(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				metrics.FloatCounter("CPU.Seconds").Add(0.25)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v, want := metrics.FloatCounter("CPU.Seconds").Value(), 250.0; v != want {
+		t.Errorf("Value was %v, but expected %v", v, want)
+	}
+
+	metrics.FloatCounter("CPU.Seconds").Add(-1)
+	metrics.FloatCounter("CPU.Seconds").Add(math.NaN())
+	metricstest.AssertCounter(t, "Metrics.CounterViolations", 2)
+
+	prev := metrics.Capture()
+	c.Advance(10 * time.Second)
+	metrics.FloatCounter("CPU.Seconds").Add(5)
+	r := metrics.Capture()
+
+	if v, want := r.FloatCounters["CPU.Seconds"], 255.0; v != want {
+		t.Errorf("Value was %v, but expected %v", v, want)
+	}
+
+	if _, ok := r.Gauges["CPU.Seconds"]; ok {
+		t.Error("Float counter was reported as a gauge")
+	}
+
+	d := r.DeltaSince(prev)
+	if v, want := d.FloatCounters["CPU.Seconds"], 5.0; v != want {
+		t.Errorf("Delta was %v, but expected %v", v, want)
+	}
+
+	if v, want := d.Rates["CPU.Seconds"], 0.5; v != want {
+		t.Errorf("Rate was %v, but expected %v", v, want)
+	}
+
+	b, err := r.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var r2 metrics.Report
+	if err := r2.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := r2.FloatCounters["CPU.Seconds"], 255.0; v != want {
+		t.Errorf("Decoded value was %v, but expected %v", v, want)
+	}
+
+	metrics.FloatCounter("CPU.Seconds").Remove()
+	if v := metrics.FloatCounter("CPU.Seconds").Value(); v != 0 {
+		t.Errorf("Value was %v, but expected 0", v)
+	}
+}
+
+func TestFloatCounterExposition(t *testing.T) {
+	metricstest.Reset(t)
+
+	metrics.FloatCounter("Billing.Dollars").Add(12.5)
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Accept", "text/plain")
+	w := httptest.NewRecorder()
+	metrics.Handler{}.ServeHTTP(w, req)
+
+	body := w.Body.String()
+	for _, line := range []string{
+		"# TYPE Billing_Dollars_total counter\n",
+		"Billing_Dollars_total 12.5\n",
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("Exposition was missing %q:\n%s", line, body)
+		}
+	}
+}
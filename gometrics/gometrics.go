@@ -0,0 +1,155 @@
+// Package gometrics mirrors the metrics of a github.com/rcrowley/go-metrics
+// registry into the metrics package, so that dependencies instrumented with
+// go-metrics are published alongside everything else.
+//
+// To use, mirror the registry your dependencies use:
+//
+//	gometrics.Mirror(gm.DefaultRegistry, "")
+package gometrics
+
+import (
+	"sync"
+
+	"github.com/codahale/metrics"
+	gm "github.com/rcrowley/go-metrics"
+)
+
+// Mirror publishes the metrics in the given go-metrics registry via the metrics
+// package, with the given prefix prepended to their names. The registry is
+// walked on each snapshot, and metrics which are registered later are
+// published as they appear.
+//
+// Counters are published as counters, and gauges as gauges. Meters are
+// published as a counter of their events, suffixed with ".Count", and gauges of
+// their one-, five-, and fifteen-minute rates, suffixed with ".Rate1",
+// ".Rate5", and ".Rate15". Timers and histograms are published as a counter of
+// their samples, suffixed with ".Count", and gauges of their minimum, maximum,
+// mean, and quantiles, suffixed in the same manner as the metrics package's
+// histograms (e.g., ".P99"). Fractional values are truncated.
+func Mirror(r gm.Registry, prefix string) {
+	m := &mirror{
+		r:        r,
+		prefix:   prefix,
+		counters: make(map[string]uint64),
+		gauges:   make(map[string]int64),
+	}
+
+	metrics.Gauge(prefix+"GoMetrics.Count").SetBatchFunc(m, m.walk, m.count)
+}
+
+var quantiles = []struct {
+	q      float64
+	suffix string
+}{
+	{0.5, ".P50"},
+	{0.75, ".P75"},
+	{0.9, ".P90"},
+	{0.95, ".P95"},
+	{0.99, ".P99"},
+	{0.999, ".P999"},
+}
+
+type mirror struct {
+	r      gm.Registry
+	prefix string
+
+	m        sync.Mutex
+	n        int64
+	counters map[string]uint64
+	gauges   map[string]int64
+}
+
+type sampled interface {
+	Count() int64
+	Min() int64
+	Max() int64
+	Mean() float64
+	Percentiles([]float64) []float64
+}
+
+func (m *mirror) walk() {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	m.n = 0
+	m.r.Each(func(name string, i interface{}) {
+		m.n++
+		n := m.prefix + name
+
+		switch v := i.(type) {
+		case gm.Counter:
+			c := v.Count()
+			if c < 0 {
+				c = 0
+			}
+			m.counter(n, uint64(c))
+		case gm.Gauge:
+			m.gauge(n, v.Value())
+		case gm.GaugeFloat64:
+			m.gauge(n, int64(v.Value()))
+		case gm.Meter:
+			s := v.Snapshot()
+			m.counter(n+".Count", uint64(s.Count()))
+			m.gauge(n+".Rate1", int64(s.Rate1()))
+			m.gauge(n+".Rate5", int64(s.Rate5()))
+			m.gauge(n+".Rate15", int64(s.Rate15()))
+		case gm.Timer:
+			m.sampled(n, v.Snapshot())
+		case gm.Histogram:
+			m.sampled(n, v.Snapshot())
+		}
+	})
+}
+
+// sampled records the values of a timer or histogram. It must be called with
+// m.m held.
+func (m *mirror) sampled(n string, s sampled) {
+	m.counter(n+".Count", uint64(s.Count()))
+	m.gauge(n+".Min", s.Min())
+	m.gauge(n+".Max", s.Max())
+	m.gauge(n+".Mean", int64(s.Mean()))
+
+	ps := make([]float64, len(quantiles))
+	for i, q := range quantiles {
+		ps[i] = q.q
+	}
+
+	for i, v := range s.Percentiles(ps) {
+		m.gauge(n+quantiles[i].suffix, int64(v))
+	}
+}
+
+func (m *mirror) count() int64 {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	return m.n
+}
+
+// counter records a counter's value, registering it if it is new. It must be
+// called with m.m held.
+func (m *mirror) counter(n string, v uint64) {
+	if _, ok := m.counters[n]; !ok {
+		metrics.Counter(n).SetFunc(func() uint64 {
+			m.m.Lock()
+			defer m.m.Unlock()
+
+			return m.counters[n]
+		})
+	}
+	m.counters[n] = v
+}
+
+// gauge records a gauge's value, registering it if it is new. It must be called
+// with m.m held.
+func (m *mirror) gauge(n string, v int64) {
+	if _, ok := m.gauges[n]; !ok {
+		metrics.Gauge(n).SetFunc(func() int64 {
+			m.m.Lock()
+			defer m.m.Unlock()
+
+			return m.gauges[n]
+		})
+	}
+	m.gauges[n] = v
+}
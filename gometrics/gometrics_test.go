@@ -0,0 +1,38 @@
+package gometrics
+
+import (
+	"testing"
+
+	"github.com/codahale/metrics"
+	gm "github.com/rcrowley/go-metrics"
+)
+
+func TestMirror(t *testing.T) {
+	metrics.Reset()
+
+	r := gm.NewRegistry()
+	gm.GetOrRegisterCounter("requests", r).Inc(3)
+	gm.GetOrRegisterGauge("conns", r).Update(2)
+
+	Mirror(r, "Deps.")
+
+	gm.GetOrRegisterTimer("latency", r).Update(5)
+
+	counters, gauges := metrics.Snapshot()
+
+	if v, want := counters["Deps.requests"], uint64(3); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	if v, want := gauges["Deps.conns"], int64(2); v != want {
+		t.Errorf("Gauge was %v, but expected %v", v, want)
+	}
+
+	if v, want := counters["Deps.latency.Count"], uint64(1); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	if v, want := gauges["Deps.latency.P99"], int64(5); v != want {
+		t.Errorf("Gauge was %v, but expected %v", v, want)
+	}
+}
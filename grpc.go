@@ -0,0 +1,255 @@
+package metrics
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A GRPCService is an HTTP handler which implements the metrics.Metrics gRPC
+// service described in metrics.proto, allowing tooling to pull metrics over an
+// existing gRPC mesh:
+//
+//	GetSnapshot      returns a report of all metrics
+//	StreamSnapshots  streams a report of all metrics once per interval
+//	GetHistogram     returns the summary of the named histogram
+//
+// gRPC requires HTTP/2, so the service must be served by an http.Server with
+// TLS (e.g., with mutual authentication via ClientAuth), under the service's
+// path prefix:
+//
+//	mux.Handle("/metrics.Metrics/", metrics.GRPCService{})
+//	srv := &http.Server{Handler: mux, TLSConfig: mTLSConfig}
+//	srv.ListenAndServeTLS("", "")
+//
+// Message compression is not supported.
+type GRPCService struct {
+	// Pipeline is applied to each report before it is sent.
+	Pipeline Pipeline
+
+	// Interval is the interval at which StreamSnapshots sends reports to
+	// clients which do not request one, and the shortest interval clients may
+	// request; it defaults to ten seconds.
+	Interval time.Duration
+}
+
+var (
+	errGRPCMalformed = errors.New("metrics: malformed gRPC message")
+	errCompressed    = errors.New("metrics: compressed gRPC messages are unsupported")
+	errGRPCTooLarge  = errors.New("metrics: gRPC request message is too large")
+)
+
+// maxGRPCRequest is the largest request message accepted, which is ample for
+// the service's requests of a histogram name or an interval.
+const maxGRPCRequest = 64 * 1024
+
+// gRPC status codes
+const (
+	grpcOK            = 0
+	grpcInvalidArg    = 3
+	grpcNotFound      = 5
+	grpcExhausted     = 8
+	grpcUnimplemented = 12
+	grpcInternal      = 13
+)
+
+// ServeHTTP answers a gRPC call.
+func (s GRPCService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/grpc+proto")
+
+	req, err := readGRPCMessage(r.Body)
+	if err == errCompressed {
+		grpcError(w, grpcUnimplemented, err.Error())
+		return
+	} else if err == errGRPCTooLarge {
+		grpcError(w, grpcExhausted, err.Error())
+		return
+	} else if err != nil {
+		grpcError(w, grpcInvalidArg, err.Error())
+		return
+	}
+
+	switch r.URL.Path[strings.LastIndexByte(r.URL.Path, '/')+1:] {
+	case "GetSnapshot":
+		b, _ := s.Pipeline.Apply(Capture()).MarshalBinary()
+		s.reply(w, b)
+	case "GetHistogram":
+		var name string
+		if err := readProto(req, func(field int, v protoValue) error {
+			if field == 1 {
+				name = string(v.b)
+			}
+			return nil
+		}); err != nil {
+			grpcError(w, grpcInvalidArg, err.Error())
+			return
+		}
+
+		h, ok := s.Pipeline.Apply(Capture()).Histograms[name]
+		if !ok {
+			grpcError(w, grpcNotFound, "no such histogram: "+name)
+			return
+		}
+
+		var pw protoWriter
+		h.marshalProto(&pw)
+		s.reply(w, pw.b)
+	case "StreamSnapshots":
+		var (
+			ms  int64
+			set bool
+		)
+		if err := readProto(req, func(field int, v protoValue) error {
+			if field == 1 {
+				ms, set = int64(v.n), true
+			}
+			return nil
+		}); err != nil {
+			grpcError(w, grpcInvalidArg, err.Error())
+			return
+		}
+
+		if set && ms <= 0 {
+			grpcError(w, grpcInvalidArg, "interval must be positive: "+strconv.FormatInt(ms, 10))
+			return
+		}
+
+		interval := time.Duration(math.MaxInt64)
+		if ms < int64(interval/time.Millisecond) {
+			interval = time.Duration(ms) * time.Millisecond
+		}
+		s.stream(w, r, interval)
+	default:
+		grpcError(w, grpcUnimplemented, "unknown method: "+r.URL.Path)
+	}
+}
+
+// reply sends a single response message.
+func (s GRPCService) reply(w http.ResponseWriter, b []byte) {
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+
+	code, msg := grpcOK, ""
+	if err := writeGRPCMessage(w, b); err != nil {
+		countError("Metrics.ExportErrors", "", err)
+		code, msg = grpcInternal, err.Error()
+	}
+	setGRPCStatus(w.Header(), code, msg)
+}
+
+// stream sends reports until the client cancels the call.
+func (s GRPCService) stream(w http.ResponseWriter, r *http.Request, interval time.Duration) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		grpcError(w, grpcInternal, "streaming unsupported")
+		return
+	}
+
+	min := s.Interval
+	if min <= 0 {
+		min = 10 * time.Second
+	}
+
+	if interval < min {
+		interval = min
+	}
+
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		b, _ := s.Pipeline.Apply(Capture()).MarshalBinary()
+		if err := writeGRPCMessage(w, b); err != nil {
+			countError("Metrics.ExportErrors", "", err)
+			setGRPCStatus(w.Header(), grpcInternal, err.Error())
+			return
+		}
+		f.Flush()
+
+		select {
+		case <-t.C:
+		case <-r.Context().Done():
+			setGRPCStatus(w.Header(), grpcOK, "")
+			return
+		}
+	}
+}
+
+// grpcError responds with an error status and no messages.
+func grpcError(w http.ResponseWriter, code int, msg string) {
+	setGRPCStatus(w.Header(), code, msg)
+	w.WriteHeader(http.StatusOK)
+}
+
+func setGRPCStatus(h http.Header, code int, msg string) {
+	h.Set("Grpc-Status", strconv.Itoa(code))
+	if msg != "" {
+		h.Set("Grpc-Message", grpcPercentEncode(msg))
+	}
+}
+
+// grpcPercentEncode encodes a status message as required by the gRPC protocol,
+// escaping '%' and all bytes outside of printable ASCII.
+func grpcPercentEncode(msg string) string {
+	const hex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c < 0x20 || c > 0x7e || c == '%' {
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0x0f])
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// readGRPCMessage reads the single length-prefixed message of a unary or
+// server-streaming call.
+func readGRPCMessage(r io.Reader) ([]byte, error) {
+	var h [5]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		if err == io.EOF {
+			return nil, nil // an empty request
+		}
+		return nil, errGRPCMalformed
+	}
+
+	if h[0] != 0 {
+		return nil, errCompressed
+	}
+
+	n := binary.BigEndian.Uint32(h[1:])
+	if n > maxGRPCRequest {
+		return nil, errGRPCTooLarge
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, errGRPCMalformed
+	}
+	return b, nil
+}
+
+func writeGRPCMessage(w io.Writer, b []byte) error {
+	h := make([]byte, 5, 5+len(b))
+	binary.BigEndian.PutUint32(h[1:], uint32(len(b)))
+	_, err := w.Write(append(h, b...))
+	return err
+}
@@ -0,0 +1,150 @@
+package metrics_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestGRPCService(t *testing.T) {
+	metricstest.Reset(t)
+
+	metrics.Counter("whee").AddN(3)
+	metrics.NewHistogram("heyo", 1, 1000, 3).RecordValue(5)
+
+	srv := httptest.NewUnstartedServer(metrics.GRPCService{Interval: 10 * time.Millisecond})
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	call := func(ctx context.Context, method string, msg []byte) *http.Response {
+		body := make([]byte, 5, 5+len(msg))
+		binary.BigEndian.PutUint32(body[1:], uint32(len(msg)))
+		body = append(body, msg...)
+
+		req, err := http.NewRequestWithContext(ctx, "POST", srv.URL+"/metrics.Metrics/"+method, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/grpc")
+
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.ProtoMajor != 2 {
+			t.Fatalf("Protocol was %s, but expected HTTP/2", resp.Proto)
+		}
+		return resp
+	}
+
+	readMessage := func(r io.Reader) []byte {
+		var h [5]byte
+		if _, err := io.ReadFull(r, h[:]); err != nil {
+			t.Fatal(err)
+		}
+
+		b := make([]byte, binary.BigEndian.Uint32(h[1:]))
+		if _, err := io.ReadFull(r, b); err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	status := func(resp *http.Response) string {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		if s := resp.Header.Get("Grpc-Status"); s != "" {
+			return s
+		}
+		return resp.Trailer.Get("Grpc-Status")
+	}
+
+	// GetSnapshot
+	resp := call(context.Background(), "GetSnapshot", nil)
+	var r metrics.Report
+	if err := r.UnmarshalBinary(readMessage(resp.Body)); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := r.Counters["whee"], uint64(3); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	if v := status(resp); v != "0" {
+		t.Errorf("Status was %q, but expected 0", v)
+	}
+
+	// GetHistogram, with name = "heyo"
+	resp = call(context.Background(), "GetHistogram", []byte("\x0a\x04heyo"))
+	if b := readMessage(resp.Body); len(b) == 0 {
+		t.Error("Histogram was empty")
+	}
+
+	if v := status(resp); v != "0" {
+		t.Errorf("Status was %q, but expected 0", v)
+	}
+
+	resp = call(context.Background(), "GetHistogram", []byte("\x0a\x08n\xc3\xb6pe 5%"))
+	if v, want := resp.Header.Get("Grpc-Message"), "no such histogram: n%C3%B6pe 5%25"; v != want {
+		t.Errorf("Message was %q, but expected %q", v, want)
+	}
+
+	if v := status(resp); v != "5" {
+		t.Errorf("Status was %q, but expected 5 (NOT_FOUND)", v)
+	}
+
+	// a header claiming a 4 GiB request
+	req, err := http.NewRequest("POST", srv.URL+"/metrics.Metrics/GetHistogram", bytes.NewReader([]byte{0, 0xff, 0xff, 0xff, 0xff}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+
+	resp, err = srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := status(resp); v != "8" {
+		t.Errorf("Status was %q, but expected 8 (RESOURCE_EXHAUSTED)", v)
+	}
+
+	// StreamSnapshots, with interval_ms = 1, below the service's interval
+	start := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	resp = call(ctx, "StreamSnapshots", []byte("\x08\x01"))
+	for i := 0; i < 3; i++ {
+		if err := r.UnmarshalBinary(readMessage(resp.Body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cancel()
+	_ = resp.Body.Close()
+
+	if v, min := time.Since(start), 20*time.Millisecond; v < min {
+		t.Errorf("Three reports took %v, but expected at least %v", v, min)
+	}
+
+	// StreamSnapshots, with interval_ms = 0 and interval_ms = -1
+	for _, msg := range []string{"\x08\x00", "\x08\xff\xff\xff\xff\xff\xff\xff\xff\xff\x01"} {
+		resp = call(context.Background(), "StreamSnapshots", []byte(msg))
+		if v := status(resp); v != "3" {
+			t.Errorf("Status was %q, but expected 3 (INVALID_ARGUMENT)", v)
+		}
+	}
+
+	resp = call(context.Background(), "Nope", nil)
+	if v := status(resp); v != "12" {
+		t.Errorf("Status was %q, but expected 12 (UNIMPLEMENTED)", v)
+	}
+}
@@ -0,0 +1,231 @@
+package metrics
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Content types served by Handler.
+const (
+	JSONContentType        = "application/json"
+	PrometheusContentType  = "text/plain; version=0.0.4; charset=utf-8"
+	OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	ProtobufContentType    = "application/x-protobuf; proto=metrics.Report"
+)
+
+// A Handler is an HTTP handler which responds with a report of all metrics. The
+// format is negotiated using the request's Accept header: OpenMetrics 1.0 for
+// application/openmetrics-text, the Prometheus text format for text/plain, the
+// binary encoding described by metrics.proto for application/x-protobuf, and
+// JSON otherwise.
+//
+// Responses are compressed with gzip if the request's Accept-Encoding header
+// allows it. Each response has an ETag derived from the values it contains,
+// and a request whose If-None-Match header matches it is answered with 304 Not
+// Modified, so that scrapers which poll more often than metrics change can
+// skip transferring unchanged responses. The ETag ignores the report's time,
+// the ends of histograms' windows, and the package's snapshot timing metrics.
+type Handler struct {
+	// Buckets, if true, exposes histograms in the Prometheus and OpenMetrics
+	// formats as histograms with cumulative power-of-two buckets, which can be
+	// aggregated across processes, rather than as summaries of pre-computed
+	// quantiles, which cannot. In the OpenMetrics format, each bucket includes
+	// its most recent exemplar, if any.
+	Buckets bool
+
+	// Pipeline is applied to each report before it is written.
+	Pipeline Pipeline
+
+	// Source, if not nil, returns the report to respond with instead of a
+	// report of this process's metrics (e.g., a report merged from other
+	// processes). Metadata and counter creation times are not exposed for
+	// reports from a source.
+	Source func() Report
+}
+
+// ServeHTTP responds with a report of all metrics.
+func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	capture := h.Source
+	if capture == nil {
+		capture = Capture
+	}
+
+	report := h.Pipeline.Apply(capture())
+	ct := negotiate(r.Header.Get("Accept"))
+
+	var md map[string]Metadata
+	var created map[string]time.Time
+	if (ct == OpenMetricsContentType || ct == PrometheusContentType) && h.Source == nil {
+		md, created = h.Pipeline.metadata(copyMetadata(), copyCreated())
+	}
+
+	encoding := "identity"
+	if acceptsGzip(r.Header.Get("Accept-Encoding")) {
+		encoding = "gzip"
+	}
+
+	etag := reportETag(report, md, created, ct, encoding, h.Buckets)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Vary", "Accept, Accept-Encoding")
+	w.Header().Set("Content-Type", ct)
+
+	if matchETag(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	var out io.Writer = w
+	var gz *gzip.Writer
+	if encoding == "gzip" {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz = gzip.NewWriter(w)
+		out = gz
+	}
+
+	var err error
+	switch ct {
+	case OpenMetricsContentType, PrometheusContentType:
+		err = writeExposition(out, report, md, created, ct == OpenMetricsContentType, h.Buckets)
+	case ProtobufContentType:
+		var b []byte
+		if b, err = report.MarshalBinary(); err == nil {
+			_, err = out.Write(b)
+		}
+	default:
+		err = json.NewEncoder(out).Encode(report)
+	}
+
+	if gz != nil {
+		if cerr := gz.Close(); err == nil {
+			err = cerr
+		}
+	}
+
+	if err != nil {
+		countError("Metrics.ExportErrors", "", err)
+	}
+}
+
+// reportETag returns a strong ETag for the response to a request, derived from
+// the values which would be written to it and its content coding, since the
+// gzipped and identity representations differ.
+func reportETag(r Report, md map[string]Metadata, created map[string]time.Time, ct, encoding string, buckets bool) string {
+	counters := make(map[string]uint64, len(r.Counters))
+	for n, v := range r.Counters {
+		if !strings.HasPrefix(n, "Metrics.Snapshot") {
+			counters[n] = v
+		}
+	}
+
+	gauges := make(map[string]int64, len(r.Gauges))
+	for n, v := range r.Gauges {
+		if !strings.HasPrefix(n, "Metrics.Snapshot") {
+			gauges[n] = v
+		}
+	}
+
+	hists := make(map[string]HistogramSummary, len(r.Histograms))
+	for n, s := range r.Histograms {
+		s.End = time.Time{}
+		hists[n] = s
+	}
+
+	// maps are encoded in sorted order, so the encoding is deterministic
+	b, _ := json.Marshal(struct {
+		Report      Report
+		Metadata    map[string]Metadata
+		Created     map[string]time.Time
+		ContentType string
+		Encoding    string
+		Buckets     bool
+	}{
+		Report{
+			Counters:      counters,
+			FloatCounters: r.FloatCounters,
+			Gauges:        gauges,
+			Histograms:    hists,
+			Tags:          r.Tags,
+		},
+		md, created, ct, encoding, buckets,
+	})
+
+	f := fnv.New64a()
+	_, _ = f.Write(b)
+	return fmt.Sprintf(`"%016x"`, f.Sum64())
+}
+
+// matchETag returns true if the If-None-Match header matches the ETag.
+func matchETag(header, etag string) bool {
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimPrefix(strings.TrimSpace(part), "W/")
+		if part == etag || part == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsGzip returns true if the Accept-Encoding header allows gzip.
+func acceptsGzip(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mt != "gzip" {
+			continue
+		}
+
+		if v, ok := params["q"]; ok {
+			if q, err := strconv.ParseFloat(v, 64); err != nil || q == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// negotiate returns the content type which best matches the Accept header.
+func negotiate(accept string) string {
+	best, bestQ := JSONContentType, 0.0
+
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		var ct string
+		switch mt {
+		case "application/openmetrics-text":
+			ct = OpenMetricsContentType
+		case "text/plain":
+			ct = PrometheusContentType
+		case "application/x-protobuf":
+			ct = ProtobufContentType
+		case "application/json", "*/*":
+			ct = JSONContentType
+		default:
+			continue
+		}
+
+		if q > bestQ {
+			best, bestQ = ct, q
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,313 @@
+package metrics_test
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func serve(h metrics.Handler, accept string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/metrics", nil)
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestHandlerJSON(t *testing.T) {
+	metricstest.Reset(t)
+	metrics.Counter("whee").Add()
+
+	w := serve(metrics.Handler{}, "")
+
+	if v, want := w.Header().Get("Content-Type"), metrics.JSONContentType; v != want {
+		t.Errorf("Content-Type was %q, but expected %q", v, want)
+	}
+
+	var r metrics.Report
+	if err := json.NewDecoder(w.Body).Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := r.Counters["whee"], uint64(1); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+}
+
+func TestHandlerPrometheus(t *testing.T) {
+	metricstest.Reset(t)
+	metrics.Counter("http.requests").AddN(3)
+	metrics.Gauge("conns").Set(2)
+	h := metrics.NewHistogram("latency", 1, 1000, 3)
+	h.RecordValue(10)
+
+	w := serve(metrics.Handler{}, "text/plain;version=0.0.4;q=0.5,*/*;q=0.1")
+
+	if v, want := w.Header().Get("Content-Type"), metrics.PrometheusContentType; v != want {
+		t.Errorf("Content-Type was %q, but expected %q", v, want)
+	}
+
+	for _, s := range []string{
+		"# TYPE http_requests_total counter\n",
+		"http_requests_total 3\n",
+		"# TYPE conns gauge\n",
+		"conns 2\n",
+		"# TYPE latency summary\n",
+		"latency{quantile=\"0.999\"} 10\n",
+		"latency_count 1\n",
+	} {
+		if !strings.Contains(w.Body.String(), s) {
+			t.Errorf("Response did not contain %q:\n%s", s, w.Body.String())
+		}
+	}
+}
+
+func TestHandlerOpenMetrics(t *testing.T) {
+	metricstest.Reset(t)
+	metricstest.UseFakeClock(t)
+
+	metrics.Counter("http.requests").AddN(3)
+	metrics.Describe("http.requests", metrics.Metadata{
+		Help: "The number of requests.",
+	})
+	metrics.Counter("jobs_total").AddN(2)
+	metrics.Gauge("heap").Set(1024)
+	metrics.Describe("heap", metrics.Metadata{
+		Unit: "bytes",
+	})
+
+	w := serve(metrics.Handler{}, "application/openmetrics-text;version=1.0.0,text/plain;q=0.5")
+
+	if v, want := w.Header().Get("Content-Type"), metrics.OpenMetricsContentType; v != want {
+		t.Errorf("Content-Type was %q, but expected %q", v, want)
+	}
+
+	for _, s := range []string{
+		"# TYPE http_requests counter\n",
+		"# HELP http_requests The number of requests.\n",
+		"http_requests_total 3\n",
+		"http_requests_created 946684800.000\n",
+		"# TYPE jobs counter\n",
+		"jobs_total 2\n",
+		"# TYPE heap_bytes gauge\n",
+		"# UNIT heap_bytes bytes\n",
+		"heap_bytes 1024\n",
+	} {
+		if !strings.Contains(w.Body.String(), s) {
+			t.Errorf("Response did not contain %q:\n%s", s, w.Body.String())
+		}
+	}
+
+	if !strings.HasSuffix(w.Body.String(), "# EOF\n") {
+		t.Errorf("Response did not end with EOF:\n%s", w.Body.String())
+	}
+}
+
+func TestHandlerBuckets(t *testing.T) {
+	metricstest.Reset(t)
+	metricstest.UseFakeClock(t)
+
+	h := metrics.NewHistogram("latency", 1, 1000, 3)
+	for i := int64(1); i <= 10; i++ {
+		h.RecordValue(i)
+	}
+	h.RecordValueWithExemplar(5, map[string]string{"trace_id": "abc"})
+
+	w := serve(metrics.Handler{Buckets: true}, "application/openmetrics-text")
+
+	for _, s := range []string{
+		"# TYPE latency histogram\n",
+		"latency_bucket{le=\"1\"} 1\n",
+		"latency_bucket{le=\"3\"} 3\n",
+		"latency_bucket{le=\"7\"} 8 # {trace_id=\"abc\"} 5 946684800.000\n",
+		"latency_bucket{le=\"15\"} 11\n",
+		"latency_bucket{le=\"1023\"} 11\n",
+		"latency_bucket{le=\"+Inf\"} 11\n",
+		"latency_count 11\n",
+	} {
+		if !strings.Contains(w.Body.String(), s) {
+			t.Errorf("Response did not contain %q:\n%s", s, w.Body.String())
+		}
+	}
+}
+
+func TestHandlerPipeline(t *testing.T) {
+	metricstest.Reset(t)
+
+	metrics.Counter("HTTP.Requests").Add()
+	metrics.Describe("HTTP.Requests", metrics.Metadata{Help: "The number of requests."})
+	metrics.Counter("Debug.Allocs").Add()
+
+	w := serve(metrics.Handler{
+		Pipeline: metrics.Pipeline{
+			Filters: []metrics.Filter{
+				metrics.Exclude("Debug.*"),
+				metrics.Rename("HTTP.Requests", "requests"),
+			},
+		},
+	}, "text/plain")
+
+	if !strings.Contains(w.Body.String(), "# HELP requests_total The number of requests.\n") {
+		t.Errorf("Response did not contain renamed metric:\n%s", w.Body.String())
+	}
+
+	if strings.Contains(w.Body.String(), "Debug") {
+		t.Errorf("Response contained excluded metric:\n%s", w.Body.String())
+	}
+}
+
+func TestHandlerGzip(t *testing.T) {
+	metricstest.Reset(t)
+	metrics.Counter("whee").Add()
+
+	r := httptest.NewRequest("GET", "/metrics", nil)
+	r.Header.Set("Accept-Encoding", "gzip, deflate")
+	w := httptest.NewRecorder()
+	metrics.Handler{}.ServeHTTP(w, r)
+
+	if v, want := w.Header().Get("Content-Encoding"), "gzip"; v != want {
+		t.Fatalf("Content-Encoding was %q, but expected %q", v, want)
+	}
+
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var report metrics.Report
+	if err := json.NewDecoder(gz).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := report.Counters["whee"], uint64(1); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+}
+
+func TestHandlerETag(t *testing.T) {
+	metricstest.Reset(t)
+	metrics.Counter("whee").Add()
+
+	w := serve(metrics.Handler{}, "text/plain")
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("No ETag")
+	}
+
+	conditional := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		r.Header.Set("Accept", "text/plain")
+		r.Header.Set("If-None-Match", etag)
+		w := httptest.NewRecorder()
+		metrics.Handler{}.ServeHTTP(w, r)
+		return w
+	}
+
+	if v, want := conditional().Code, http.StatusNotModified; v != want {
+		t.Errorf("Status was %v, but expected %v", v, want)
+	}
+
+	metrics.Counter("whee").Add()
+
+	w = conditional()
+	if v, want := w.Code, http.StatusOK; v != want {
+		t.Errorf("Status was %v, but expected %v", v, want)
+	}
+
+	if w.Header().Get("ETag") == etag {
+		t.Error("ETag did not change")
+	}
+
+	etag = w.Header().Get("ETag")
+	metrics.FloatCounter("bytes").Add(1.5)
+
+	if v, want := conditional().Code, http.StatusOK; v != want {
+		t.Errorf("Status was %v, but expected %v", v, want)
+	}
+}
+
+func TestHandlerETagEncoding(t *testing.T) {
+	metricstest.Reset(t)
+	metrics.Counter("whee").Add()
+
+	get := func(encoding, etag string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		r.Header.Set("Accept", "text/plain")
+		r.Header.Set("Accept-Encoding", encoding)
+		r.Header.Set("If-None-Match", etag)
+		w := httptest.NewRecorder()
+		metrics.Handler{}.ServeHTTP(w, r)
+		return w
+	}
+
+	identity, gzipped := get("identity", ""), get("gzip", "")
+
+	for _, w := range []*httptest.ResponseRecorder{identity, gzipped} {
+		if v := w.Header().Get("Vary"); !strings.Contains(v, "Accept-Encoding") {
+			t.Errorf("Vary was %q, but expected it to contain Accept-Encoding", v)
+		}
+	}
+
+	if v, want := gzipped.Header().Get("Content-Encoding"), "gzip"; v != want {
+		t.Errorf("Content-Encoding was %q, but expected %q", v, want)
+	}
+
+	if identity.Header().Get("ETag") == gzipped.Header().Get("ETag") {
+		t.Error("Identity and gzip responses had the same ETag")
+	}
+
+	if v, want := get("gzip", identity.Header().Get("ETag")).Code, http.StatusOK; v != want {
+		t.Errorf("Status was %v, but expected %v", v, want)
+	}
+
+	if v, want := get("gzip", gzipped.Header().Get("ETag")).Code, http.StatusNotModified; v != want {
+		t.Errorf("Status was %v, but expected %v", v, want)
+	}
+}
+
+func TestHandlerProtobuf(t *testing.T) {
+	metricstest.Reset(t)
+	metrics.Counter("whee").AddN(2)
+
+	w := serve(metrics.Handler{}, "application/x-protobuf")
+
+	if v, want := w.Header().Get("Content-Type"), metrics.ProtobufContentType; v != want {
+		t.Errorf("Content-Type was %q, but expected %q", v, want)
+	}
+
+	var r metrics.Report
+	if err := r.UnmarshalBinary(w.Body.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := r.Counters["whee"], uint64(2); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+}
+
+func TestHandlerSource(t *testing.T) {
+	metricstest.Reset(t)
+	metrics.Counter("local").Add()
+
+	w := serve(metrics.Handler{
+		Source: func() metrics.Report {
+			return metrics.Report{Counters: map[string]uint64{"remote": 5}}
+		},
+	}, "text/plain")
+
+	if !strings.Contains(w.Body.String(), "remote_total 5\n") {
+		t.Errorf("Response did not contain source's counter:\n%s", w.Body.String())
+	}
+
+	if strings.Contains(w.Body.String(), "local") {
+		t.Errorf("Response contained local counter:\n%s", w.Body.String())
+	}
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A HealthHandler is an HTTP handler which answers liveness and readiness
+// probes (e.g., from Kubernetes) by evaluating conditions over a snapshot of
+// all counters and gauges. Requests for paths ending in /healthz are answered
+// using the liveness conditions, and requests for paths ending in /readyz are
+// answered using both the liveness and readiness conditions.
+//
+// As with a Watcher, a condition which holds indicates a problem (e.g., the
+// error rate gauge exceeds 50), so the same conditions can be used to alert and
+// to answer probes. If no condition holds, the handler responds with 200 OK;
+// otherwise, it responds with 503 Service Unavailable and the names of the
+// conditions which hold.
+type HealthHandler struct {
+	m         sync.Mutex
+	liveness  map[string]Condition
+	readiness map[string]Condition
+}
+
+// NewHealthHandler returns a new HealthHandler with no conditions.
+func NewHealthHandler() *HealthHandler {
+	return &HealthHandler{
+		liveness:  make(map[string]Condition),
+		readiness: make(map[string]Condition),
+	}
+}
+
+// Liveness registers a condition under the given name, while which the process
+// is neither live nor ready.
+func (h *HealthHandler) Liveness(name string, cond Condition) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	h.liveness[name] = cond
+}
+
+// Readiness registers a condition under the given name, while which the
+// process is not ready.
+func (h *HealthHandler) Readiness(name string, cond Condition) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	h.readiness[name] = cond
+}
+
+// ServeHTTP responds to a liveness or readiness probe.
+func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var ready bool
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/healthz"):
+	case strings.HasSuffix(r.URL.Path, "/readyz"):
+		ready = true
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	failing := h.check(ready)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if len(failing) == 0 {
+		fmt.Fprintln(w, "ok")
+		return
+	}
+
+	w.WriteHeader(http.StatusServiceUnavailable)
+	for _, name := range failing {
+		fmt.Fprintln(w, name)
+	}
+}
+
+// check returns the sorted names of the liveness conditions, and if ready is
+// true the readiness conditions, which hold over a snapshot.
+func (h *HealthHandler) check(ready bool) []string {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	counters, gauges := Snapshot()
+
+	var failing []string
+	for name, cond := range h.liveness {
+		if cond(counters, gauges) {
+			failing = append(failing, name)
+		}
+	}
+
+	if ready {
+		for name, cond := range h.readiness {
+			if cond(counters, gauges) {
+				failing = append(failing, name)
+			}
+		}
+	}
+
+	sort.Strings(failing)
+	return failing
+}
@@ -0,0 +1,60 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestHealthHandler(t *testing.T) {
+	metricstest.Reset(t)
+
+	h := metrics.NewHealthHandler()
+	h.Liveness("stalled", func(c map[string]uint64, g map[string]int64) bool {
+		return g["Loop.Lag"] > 1000
+	})
+	h.Readiness("errors", func(c map[string]uint64, g map[string]int64) bool {
+		return g["Requests.ErrorRate"] > 50
+	})
+
+	probe := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		if v, want := probe(path).Code, http.StatusOK; v != want {
+			t.Errorf("Status for %s was %v, but expected %v", path, v, want)
+		}
+	}
+
+	metrics.Gauge("Requests.ErrorRate").Set(100)
+
+	if v, want := probe("/healthz").Code, http.StatusOK; v != want {
+		t.Errorf("Liveness status was %v, but expected %v", v, want)
+	}
+
+	w := probe("/readyz")
+	if v, want := w.Code, http.StatusServiceUnavailable; v != want {
+		t.Errorf("Readiness status was %v, but expected %v", v, want)
+	}
+
+	if v, want := w.Body.String(), "errors\n"; v != want {
+		t.Errorf("Body was %q, but expected %q", v, want)
+	}
+
+	metrics.Gauge("Loop.Lag").Set(5000)
+
+	w = probe("/readyz")
+	if v, want := w.Body.String(), "errors\nstalled\n"; v != want {
+		t.Errorf("Body was %q, but expected %q", v, want)
+	}
+
+	if v, want := probe("/metrics").Code, http.StatusNotFound; v != want {
+		t.Errorf("Status was %v, but expected %v", v, want)
+	}
+}
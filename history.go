@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// EnableHistory begins capturing a report of all metrics once per the given
+// interval, retaining the most recent reports up to the given number. Calling
+// it again discards the retained reports and starts over with the new settings.
+//
+// Use history to see recent values of metrics from the process itself when no
+// external time-series database is available (e.g., 60 reports at one-minute
+// intervals for the last hour). It panics if the size or interval is not
+// positive.
+func EnableHistory(size int, interval time.Duration) {
+	if size <= 0 {
+		panic("history size must be positive")
+	}
+	checkInterval(interval)
+
+	DisableHistory()
+
+	hsm.Lock()
+	defer hsm.Unlock()
+
+	historySize = size
+	historyTimer = repeat(interval, recordHistory)
+}
+
+// DisableHistory stops capturing reports and discards the retained reports.
+func DisableHistory() {
+	hsm.Lock()
+	defer hsm.Unlock()
+
+	if historyTimer != nil {
+		historyTimer.Stop()
+		historyTimer = nil
+	}
+	history = nil
+}
+
+// History returns the retained reports, oldest first.
+func History() []Report {
+	hsm.Lock()
+	defer hsm.Unlock()
+
+	return append([]Report(nil), history...)
+}
+
+// HistoryHandler returns an HTTP handler which responds with the retained
+// reports as a JSON array, oldest first.
+func HistoryHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(History())
+	})
+}
+
+func recordHistory() {
+	r := Capture()
+
+	hsm.Lock()
+	defer hsm.Unlock()
+
+	if historyTimer == nil {
+		return
+	}
+
+	history = append(history, r)
+	if len(history) > historySize {
+		history = append(history[:0], history[len(history)-historySize:]...)
+	}
+}
+
+var (
+	history      []Report
+	historySize  int
+	historyTimer Timer
+	hsm          sync.Mutex
+)
@@ -0,0 +1,77 @@
+package metrics_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestHistory(t *testing.T) {
+	metricstest.Reset(t)
+	c := metricstest.UseFakeClock(t)
+
+	metrics.EnableHistory(2, time.Minute)
+	defer metrics.DisableHistory()
+
+	for i := 0; i < 3; i++ {
+		metrics.Counter("whee").Add()
+		c.Advance(time.Minute)
+	}
+
+	h := metrics.History()
+	if v, want := len(h), 2; v != want {
+		t.Fatalf("History length was %v, but expected %v", v, want)
+	}
+
+	if v, want := h[0].Counters["whee"], uint64(2); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	if v, want := h[1].Counters["whee"], uint64(3); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	w := httptest.NewRecorder()
+	metrics.HistoryHandler().ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	var reports []metrics.Report
+	if err := json.NewDecoder(w.Body).Decode(&reports); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := len(reports), 2; v != want {
+		t.Errorf("History length was %v, but expected %v", v, want)
+	}
+}
+
+func TestEnableHistoryInvalid(t *testing.T) {
+	metricstest.Reset(t)
+
+	for _, test := range []struct {
+		size     int
+		interval time.Duration
+	}{
+		{-1, time.Minute},
+		{0, time.Minute},
+		{10, 0},
+		{10, -time.Minute},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("EnableHistory(%v, %v) did not panic", test.size, test.interval)
+				}
+			}()
+
+			metrics.EnableHistory(test.size, test.interval)
+		}()
+	}
+
+	if v := metrics.History(); v != nil {
+		t.Errorf("History was %v, but expected none", v)
+	}
+}
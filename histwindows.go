@@ -0,0 +1,240 @@
+package metrics
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/codahale/hdrhistogram"
+)
+
+// histWindows are the five windows of a histogram, the newest of which records
+// values. A window is only allocated once a value is recorded in it, and past
+// windows may be compressed, so that thousands of mostly idle histograms use
+// little memory.
+type histWindows struct {
+	lo, hi   int64
+	sigfigs  int
+	compress bool
+	current  *hdrhistogram.Histogram // nil until a value is recorded
+	past     []pastWindow            // oldest first
+}
+
+// a pastWindow is empty, a histogram, or a compressed histogram.
+type pastWindow struct {
+	h      *hdrhistogram.Histogram
+	packed []byte
+}
+
+const histWindowCount = 5
+
+func newHistWindows(lo, hi int64, sigfigs int, compress bool) *histWindows {
+	return &histWindows{lo: lo, hi: hi, sigfigs: sigfigs, compress: compress}
+}
+
+// record records n occurrences of the given value in the current window.
+func (w *histWindows) record(v, n int64) error {
+	if w.current == nil {
+		w.current = hdrhistogram.New(w.lo, w.hi, w.sigfigs)
+	}
+	return w.current.RecordValues(v, n)
+}
+
+// restore merges the given histogram into the current window.
+func (w *histWindows) restore(m *hdrhistogram.Histogram) {
+	if w.current == nil {
+		w.current = hdrhistogram.New(w.lo, w.hi, w.sigfigs)
+	}
+	w.current.Merge(m)
+}
+
+// rotate starts a new window, dropping the oldest.
+func (w *histWindows) rotate() {
+	p := pastWindow{h: w.current}
+	if w.compress && w.current != nil {
+		p = pastWindow{packed: packCounts(w.current.Export().Counts)}
+	}
+
+	if len(w.past) == histWindowCount-1 {
+		copy(w.past, w.past[1:])
+		w.past = w.past[:len(w.past)-1]
+	}
+	w.past = append(w.past, p)
+	w.current = nil
+}
+
+// clear discards all windows.
+func (w *histWindows) clear() {
+	w.current = nil
+	w.past = nil
+}
+
+// merge returns a histogram of the values in all windows.
+func (w *histWindows) merge() *hdrhistogram.Histogram {
+	m := w.empty()
+	for _, p := range w.past {
+		switch {
+		case p.h != nil:
+			m.Merge(p.h)
+		case p.packed != nil:
+			m.Merge(hdrhistogram.Import(&hdrhistogram.Snapshot{
+				LowestTrackableValue:  w.lo,
+				HighestTrackableValue: w.hi,
+				SignificantFigures:    int64(w.sigfigs),
+				Counts:                unpackCounts(p.packed),
+			}))
+		}
+	}
+	if w.current != nil {
+		m.Merge(w.current)
+	}
+	return m
+}
+
+// empty returns a new histogram with the windows' range and precision.
+func (w *histWindows) empty() *hdrhistogram.Histogram {
+	return hdrhistogram.New(w.lo, w.hi, w.sigfigs)
+}
+
+// memory returns the approximate number of bytes used by the windows.
+func (w *histWindows) memory() int {
+	var n int
+	if w.current != nil {
+		n += w.current.ByteSize()
+	}
+	for _, p := range w.past {
+		if p.h != nil {
+			n += p.h.ByteSize()
+		}
+		n += len(p.packed)
+	}
+	return n
+}
+
+// packCounts encodes histogram counts as varints, with each run of zeros
+// encoded as a zero byte followed by the length of the run.
+func packCounts(counts []int64) []byte {
+	b := []byte{}
+	buf := make([]byte, binary.MaxVarintLen64)
+	for i := 0; i < len(counts); {
+		if counts[i] == 0 {
+			j := i
+			for j < len(counts) && counts[j] == 0 {
+				j++
+			}
+			b = append(b, 0)
+			b = append(b, buf[:binary.PutUvarint(buf, uint64(j-i))]...)
+			i = j
+			continue
+		}
+
+		// only zero is encoded with a leading zero byte
+		b = append(b, buf[:binary.PutVarint(buf, counts[i])]...)
+		i++
+	}
+	return b
+}
+
+func unpackCounts(b []byte) []int64 {
+	var counts []int64
+	for len(b) > 0 {
+		if b[0] == 0 {
+			run, n := binary.Uvarint(b[1:])
+			if n <= 0 {
+				break
+			}
+			counts = append(counts, make([]int64, run)...)
+			b = b[1+n:]
+			continue
+		}
+
+		v, n := binary.Varint(b)
+		if n <= 0 {
+			break
+		}
+		counts = append(counts, v)
+		b = b[n:]
+	}
+	return counts
+}
+
+// rotateEvery rotates the histogram's windows every d, at instants which are
+// whole multiples of d after phase, until the returned timer is stopped.
+// Histograms which rotate at the same instants share a single timer.
+func rotateEvery(d time.Duration, phase time.Time, h *Histogram) Timer {
+	offset := phase.UnixNano() % int64(d)
+	if offset < 0 {
+		offset += int64(d)
+	}
+	key := rotationKey{d: d, offset: offset}
+
+	rgm.Lock()
+	defer rgm.Unlock()
+
+	g, ok := rotations[key]
+	if !ok {
+		g = &rotationGroup{hists: make(map[*Histogram]struct{})}
+		g.timer = repeatPhased(d, phase, g.rotate)
+		rotations[key] = g
+	}
+	g.hists[h] = struct{}{}
+
+	return &rotationMember{key: key, h: h}
+}
+
+type rotationKey struct {
+	d      time.Duration
+	offset int64 // the phase modulo d, in nanoseconds
+}
+
+type rotationGroup struct {
+	hists map[*Histogram]struct{}
+	timer Timer
+}
+
+func (g *rotationGroup) rotate() {
+	rgm.Lock()
+	hists := make([]*Histogram, 0, len(g.hists))
+	for h := range g.hists {
+		hists = append(hists, h)
+	}
+	rgm.Unlock()
+
+	for _, h := range hists {
+		h.rotate()
+	}
+}
+
+// a rotationMember is the membership of a histogram in a rotation group.
+type rotationMember struct {
+	key rotationKey
+	h   *Histogram
+}
+
+// Stop removes the histogram from its group, stopping the group's timer if it
+// was the last member.
+func (m *rotationMember) Stop() bool {
+	rgm.Lock()
+	defer rgm.Unlock()
+
+	g, ok := rotations[m.key]
+	if !ok {
+		return false
+	}
+
+	if _, ok := g.hists[m.h]; !ok {
+		return false
+	}
+	delete(g.hists, m.h)
+
+	if len(g.hists) == 0 {
+		g.timer.Stop()
+		delete(rotations, m.key)
+	}
+	return true
+}
+
+var (
+	rotations = make(map[rotationKey]*rotationGroup)
+	rgm       sync.Mutex
+)
@@ -0,0 +1,117 @@
+package metrics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestHistogramCompress(t *testing.T) {
+	metricstest.Reset(t)
+	c := metricstest.UseFakeClock(t)
+
+	plain := metrics.NewHistogram("Plain", 1, 100000, 3)
+	defer plain.Remove()
+	packed := metrics.NewHistogramWithOptions("Packed", 1, 100000, 3, metrics.HistogramOptions{
+		Compress: true,
+	})
+	defer packed.Remove()
+
+	for w := int64(0); w < 3; w++ {
+		for i := int64(1); i <= 1000; i++ {
+			_ = plain.RecordValue(i * (w + 1))
+			_ = packed.RecordValue(i * (w + 1))
+		}
+		c.Advance(time.Minute)
+	}
+
+	for _, q := range []float64{50, 90, 99, 100} {
+		if v, want := packed.ValueAtQuantile(q), plain.ValueAtQuantile(q); v != want {
+			t.Errorf("P%v was %v, but expected %v", q, v, want)
+		}
+	}
+
+	if v, want := packed.TotalCount(), int64(3000); v != want {
+		t.Errorf("Count was %v, but expected %v", v, want)
+	}
+
+	// values older than five windows are dropped
+	c.Advance(4 * time.Minute)
+	if v, want := packed.TotalCount(), int64(0); v != want {
+		t.Errorf("Count was %v, but expected %v", v, want)
+	}
+}
+
+func TestHistogramSharedRotation(t *testing.T) {
+	metricstest.Reset(t)
+	c := metricstest.UseFakeClock(t)
+
+	var hists []*metrics.Histogram
+	for _, n := range []string{"A", "B", "C"} {
+		h := metrics.NewHistogram(n, 1, 1000, 3)
+		_ = h.RecordValue(10)
+		hists = append(hists, h)
+	}
+
+	// removing one histogram doesn't stop the others rotating
+	hists[0].Remove()
+	c.Advance(5 * time.Minute)
+
+	for _, h := range hists[1:] {
+		if v := h.TotalCount(); v != 0 {
+			t.Errorf("%s had %v values after five windows, but expected none", h.Name(), v)
+		}
+		h.Remove()
+	}
+}
+
+func TestHistogramInterval(t *testing.T) {
+	metricstest.Reset(t)
+	c := metricstest.UseFakeClock(t)
+
+	h := metrics.NewHistogramWithOptions("heyo", 1, 1000, 3, metrics.HistogramOptions{
+		Interval: 10 * time.Second,
+		Jitter:   time.Second,
+	})
+	h.RecordValue(100)
+
+	c.Advance(40 * time.Second)
+	metricstest.AssertGauge(t, "heyo.P50", 100)
+
+	c.Advance(11 * time.Second)
+	metricstest.AssertGauge(t, "heyo.P50", 0)
+}
+
+func TestHistogramAlign(t *testing.T) {
+	metricstest.Reset(t)
+	c := metricstest.UseFakeClock(t)
+	start := c.Now()
+
+	c.Advance(30 * time.Second)
+
+	h := metrics.NewHistogramWithOptions("heyo", 1, 1000, 3, metrics.HistogramOptions{
+		Align: true,
+	})
+	h.RecordValue(100)
+
+	if v, want := h.Summary().Start, start.Add(30*time.Second); !v.Equal(want) {
+		t.Errorf("Start was %v, but expected %v", v, want)
+	}
+
+	c.Advance(4*time.Minute + 29*time.Second)
+	metricstest.AssertGauge(t, "heyo.P50", 100)
+
+	c.Advance(2 * time.Second)
+	metricstest.AssertGauge(t, "heyo.P50", 0)
+
+	s := h.Summary()
+	if v, want := s.Start, start.Add(1*time.Minute); !v.Equal(want) {
+		t.Errorf("Start was %v, but expected %v", v, want)
+	}
+
+	if v, want := s.End, start.Add(5*time.Minute+1*time.Second); !v.Equal(want) {
+		t.Errorf("End was %v, but expected %v", v, want)
+	}
+}
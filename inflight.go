@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// An InFlight tracks the operations (e.g., requests) which are currently in
+// progress. It publishes the number of operations in progress as the
+// <name>.Current gauge, the largest number in progress at once during the
+// current minute as the <name>.Peak gauge, and the durations of completed
+// operations, in microseconds, as the <name>.Latency histogram.
+type InFlight struct {
+	current, peak int64 // accessed atomically
+	latency       *Histogram
+	reset         Timer
+	name          string
+	currentName   string // the resolved name of the current gauge, for triggers
+}
+
+// NewInFlight returns an in-flight tracker with the given name, whose latency
+// histogram tracks durations up to maxLatency.
+func NewInFlight(name string, maxLatency time.Duration) *InFlight {
+	f := &InFlight{
+		name:    name,
+		latency: NewHistogram(name+".Latency", 1, int64(maxLatency/time.Microsecond), 3),
+	}
+	f.reset = repeat(1*time.Minute, f.ResetPeak)
+	f.currentName, _ = resolve(name + ".Current")
+
+	Gauge(name + ".Current").SetFunc(func() int64 {
+		return atomic.LoadInt64(&f.current)
+	})
+	Gauge(name + ".Peak").SetFunc(func() int64 {
+		return atomic.LoadInt64(&f.peak)
+	})
+	Describe(name+".Latency", Metadata{Unit: "microseconds"})
+
+	return f
+}
+
+// Start records the start of an operation. The returned value's Done method
+// must be called when the operation completes.
+//
+//	op := inFlight.Start()
+//	defer op.Done()
+func (f *InFlight) Start() Operation {
+	n := atomic.AddInt64(&f.current, 1)
+	checkTriggers(f.currentName, n)
+	for {
+		peak := atomic.LoadInt64(&f.peak)
+		if n <= peak || atomic.CompareAndSwapInt64(&f.peak, peak, n) {
+			break
+		}
+	}
+	return Operation{f: f, start: now()}
+}
+
+// ResetPeak resets the peak gauge to the number of operations currently in
+// progress. It is called automatically once a minute.
+func (f *InFlight) ResetPeak() {
+	atomic.StoreInt64(&f.peak, atomic.LoadInt64(&f.current))
+}
+
+// Remove removes the tracker's gauges and histogram.
+func (f *InFlight) Remove() {
+	f.reset.Stop()
+	f.latency.Remove()
+	Gauge(f.name + ".Current").Remove()
+	Gauge(f.name + ".Peak").Remove()
+}
+
+// An Operation is an operation in progress, tracked by an InFlight.
+type Operation struct {
+	f     *InFlight
+	start time.Time
+}
+
+// Done records the completion of the operation. Latencies which are out of
+// the histogram's range are not recorded.
+func (op Operation) Done() {
+	checkTriggers(op.f.currentName, atomic.AddInt64(&op.f.current, -1))
+	_ = op.f.latency.RecordValue(int64(now().Sub(op.start) / time.Microsecond))
+}
@@ -0,0 +1,31 @@
+package metrics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestInFlight(t *testing.T) {
+	metricstest.Reset(t)
+	c := metricstest.UseFakeClock(t)
+
+	f := metrics.NewInFlight("Requests", time.Second)
+
+	a := f.Start()
+	b := f.Start()
+	c.Advance(1 * time.Millisecond)
+	a.Done()
+
+	metricstest.AssertGauge(t, "Requests.Current", 1)
+	metricstest.AssertGauge(t, "Requests.Peak", 2)
+	metricstest.AssertGauge(t, "Requests.Latency.P50", 1000)
+
+	c.Advance(1 * time.Minute)
+	metricstest.AssertGauge(t, "Requests.Peak", 1)
+
+	b.Done()
+	metricstest.AssertGauge(t, "Requests.Current", 0)
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"context"
+	"time"
+)
+
+// Instrument returns a function which calls f, counting its calls in the
+// <name>.Calls counter and its errors in the <name>.Errors counter, and
+// tracking its concurrent calls and latencies with an InFlight of the same
+// name. Latencies of up to an hour are recorded.
+//
+//	fetch := metrics.Instrument("Fetch", func() error {
+//		return client.Fetch()
+//	})
+func Instrument(name string, f func() error) func() error {
+	g := InstrumentContext(name, func(context.Context) error {
+		return f()
+	})
+	return func() error {
+		return g(context.Background())
+	}
+}
+
+// InstrumentContext is like Instrument, for functions which take a context.
+func InstrumentContext(name string, f func(ctx context.Context) error) func(ctx context.Context) error {
+	var (
+		calls    = Counter(name + ".Calls")
+		errs     = Counter(name + ".Errors")
+		inFlight = NewInFlight(name, 1*time.Hour)
+	)
+
+	return func(ctx context.Context) error {
+		calls.Add()
+
+		op := inFlight.Start()
+		defer op.Done()
+
+		err := f(ctx)
+		if err != nil {
+			errs.Add()
+		}
+		return err
+	}
+}
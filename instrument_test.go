@@ -0,0 +1,36 @@
+package metrics_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestInstrument(t *testing.T) {
+	metricstest.Reset(t)
+
+	fail := false
+	f := metrics.Instrument("Fetch", func() error {
+		metricstest.AssertGauge(t, "Fetch.Current", 1)
+		if fail {
+			return errors.New("oh no")
+		}
+		return nil
+	})
+
+	if err := f(); err != nil {
+		t.Fatal(err)
+	}
+
+	fail = true
+	if err := f(); err == nil {
+		t.Error("Expected an error but got none")
+	}
+
+	metricstest.AssertCounter(t, "Fetch.Calls", 2)
+	metricstest.AssertCounter(t, "Fetch.Errors", 1)
+	metricstest.AssertGauge(t, "Fetch.Current", 0)
+	metricstest.AssertGauge(t, "Fetch.Peak", 1)
+}
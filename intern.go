@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Counter2 returns the counter named by joining the two parts with a period
+// (e.g., Counter2("HTTP.Requests", method) is the HTTP.Requests.GET counter).
+// Unlike building the name with concatenation or fmt.Sprintf, repeated calls
+// with the same parts do not allocate, so it may be used on hot paths with
+// dynamic name components.
+//
+// Joined names are cached up to a fixed limit, beyond which new combinations
+// of parts are joined on each call, so high-cardinality parts cannot grow the
+// cache without bound.
+func Counter2(prefix, suffix string) Counter {
+	return Counter(join2(prefix, suffix))
+}
+
+// Gauge2 returns the gauge named by joining the two parts with a period, as
+// with Counter2.
+func Gauge2(prefix, suffix string) Gauge {
+	return Gauge(join2(prefix, suffix))
+}
+
+// maxInterned is the maximum number of joined names which are cached.
+const maxInterned = 10000
+
+// join2 returns the two parts joined with a period, from the cache if
+// possible.
+func join2(prefix, suffix string) string {
+	k := [2]string{prefix, suffix}
+
+	im.RLock()
+	s, ok := interned[k]
+	im.RUnlock()
+
+	if ok {
+		return s
+	}
+
+	s = prefix + "." + suffix
+	if atomic.LoadInt32(&internedLen) >= maxInterned {
+		return s
+	}
+
+	im.Lock()
+	defer im.Unlock()
+
+	if v, ok := interned[k]; ok {
+		return v
+	}
+
+	if len(interned) < maxInterned {
+		interned[k] = s
+		atomic.StoreInt32(&internedLen, int32(len(interned)))
+	}
+	return s
+}
+
+var (
+	interned    = make(map[[2]string]string)
+	internedLen int32
+	im          sync.RWMutex
+)
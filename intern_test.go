@@ -0,0 +1,42 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestCounter2(t *testing.T) {
+	metricstest.Reset(t)
+
+	method := string([]byte("GET")) // a dynamic part
+	metrics.Counter2("HTTP.Requests", method).Add()
+	metrics.Gauge2("HTTP.Queue", method).Set(2)
+
+	metricstest.AssertCounter(t, "HTTP.Requests.GET", 1)
+	metricstest.AssertGauge(t, "HTTP.Queue.GET", 2)
+
+	if n := testing.AllocsPerRun(100, func() {
+		_ = metrics.Counter2("HTTP.Requests", method)
+	}); n != 0 {
+		t.Errorf("Counter2 made %v allocations, but expected none", n)
+	}
+}
+
+func BenchmarkCounter2Add(b *testing.B) {
+	metrics.Reset()
+
+	methods := []string{"GET", "POST", "PUT", "DELETE"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			metrics.Counter2("test.requests", methods[i%len(methods)]).Add()
+			i++
+		}
+	})
+}
@@ -0,0 +1,213 @@
+// Package aggregate merges reports from many processes into a single report
+// describing the fleet.
+package aggregate
+
+import (
+	"math"
+	"sort"
+
+	"github.com/codahale/metrics"
+)
+
+// A Mode determines how the values of a gauge from many processes are merged.
+type Mode string
+
+// Modes of merging gauges.
+const (
+	Sum Mode = "sum"
+	Avg Mode = "avg"
+	Max Mode = "max"
+	Min Mode = "min"
+)
+
+// Merge returns a report whose counters and float counters are the sums of the
+// reports' counters and float counters, whose gauges are merged according to
+// the mode, and whose histograms are merged from the reports' histograms. Only
+// tags with the same value in every report are kept. The report's time is the
+// latest of the reports' times.
+//
+// Histograms are merged using their cumulative buckets, so quantiles of merged
+// histograms are the upper bounds of the buckets containing them, capped by the
+// merged maximum. Quantiles cannot be merged without buckets, so if any report
+// with values in a histogram has no buckets for it (e.g., one reconstructed from
+// expvars), or has buckets with different bounds than the others (e.g., from a
+// histogram with a different range), the merged histogram has neither buckets
+// nor quantiles unless that report is the only one with values.
+func Merge(reports []metrics.Report, gauges Mode) metrics.Report {
+	out := metrics.Report{
+		Counters:      make(map[string]uint64),
+		FloatCounters: make(map[string]float64),
+		Gauges:        make(map[string]int64),
+		Histograms:    make(map[string]metrics.HistogramSummary),
+		Tags:          make(map[string]string),
+	}
+
+	seen := make(map[string]int64)
+	hists := make(map[string][]metrics.HistogramSummary)
+
+	for i, r := range reports {
+		if r.Time.After(out.Time) {
+			out.Time = r.Time
+		}
+
+		for n, v := range r.Counters {
+			out.Counters[n] += v
+		}
+
+		for n, v := range r.FloatCounters {
+			out.FloatCounters[n] += v
+		}
+
+		for n, v := range r.Gauges {
+			cur, ok := out.Gauges[n]
+			switch {
+			case !ok:
+				out.Gauges[n] = v
+			case gauges == Max && v > cur, gauges == Min && v < cur:
+				out.Gauges[n] = v
+			case gauges == Sum, gauges == Avg:
+				out.Gauges[n] = cur + v
+			}
+			seen[n]++
+		}
+
+		for n, s := range r.Histograms {
+			hists[n] = append(hists[n], s)
+		}
+
+		if i == 0 {
+			for k, v := range r.Tags {
+				out.Tags[k] = v
+			}
+		} else {
+			for k, v := range out.Tags {
+				if r.Tags[k] != v {
+					delete(out.Tags, k)
+				}
+			}
+		}
+	}
+
+	if gauges == Avg {
+		for n, v := range out.Gauges {
+			out.Gauges[n] = v / seen[n]
+		}
+	}
+
+	for n, ss := range hists {
+		out.Histograms[n] = mergeHistograms(ss)
+	}
+
+	return out
+}
+
+func mergeHistograms(ss []metrics.HistogramSummary) metrics.HistogramSummary {
+	out := metrics.HistogramSummary{
+		Quantiles: make(map[float64]int64),
+		Min:       math.MaxInt64,
+	}
+
+	var sum, sumSquares float64
+	buckets := make(map[int64]int64)
+	withBuckets := true
+	var sources []metrics.HistogramSummary // the summaries with values
+
+	for _, s := range ss {
+		if s.Count > 0 {
+			sources = append(sources, s)
+			if len(s.Buckets) == 0 || !sameBounds(s.Buckets, sources[0].Buckets) {
+				withBuckets = false
+			}
+
+			for _, b := range s.Buckets {
+				buckets[b.UpperBound] += b.Count
+			}
+
+			out.Count += s.Count
+			if s.Min < out.Min {
+				out.Min = s.Min
+			}
+			if s.Max > out.Max {
+				out.Max = s.Max
+			}
+
+			n := float64(s.Count)
+			sum += s.Mean * n
+			sumSquares += (s.StdDev*s.StdDev + s.Mean*s.Mean) * n
+		}
+
+		for q := range s.Quantiles {
+			out.Quantiles[q] = 0
+		}
+
+		if out.Start.IsZero() || (!s.Start.IsZero() && s.Start.Before(out.Start)) {
+			out.Start = s.Start
+		}
+		if s.End.After(out.End) {
+			out.End = s.End
+		}
+	}
+
+	if out.Count == 0 {
+		out.Min = 0
+		return out
+	}
+
+	out.Mean = sum / float64(out.Count)
+	out.StdDev = math.Sqrt(math.Max(0, sumSquares/float64(out.Count)-out.Mean*out.Mean))
+
+	switch {
+	case withBuckets:
+		bounds := make([]int64, 0, len(buckets))
+		for b := range buckets {
+			bounds = append(bounds, b)
+		}
+		sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+
+		for _, b := range bounds {
+			out.Buckets = append(out.Buckets, metrics.Bucket{UpperBound: b, Count: buckets[b]})
+		}
+
+		for q := range out.Quantiles {
+			out.Quantiles[q] = quantile(out.Buckets, out.Count, q, out.Max)
+		}
+	case len(sources) == 1:
+		// a single report's quantiles are exact
+		for q, v := range sources[0].Quantiles {
+			out.Quantiles[q] = v
+		}
+	default:
+		out.Quantiles = make(map[float64]int64)
+	}
+
+	return out
+}
+
+// sameBounds returns true if the buckets have the same upper bounds.
+func sameBounds(a, b []metrics.Bucket) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].UpperBound != b[i].UpperBound {
+			return false
+		}
+	}
+	return true
+}
+
+// quantile returns the upper bound of the bucket containing the given quantile
+// (0-100), capped by the maximum value.
+func quantile(buckets []metrics.Bucket, count int64, q float64, max int64) int64 {
+	rank := int64(math.Ceil(q / 100 * float64(count)))
+	for _, b := range buckets {
+		if b.Count >= rank {
+			if b.UpperBound > max {
+				return max
+			}
+			return b.UpperBound
+		}
+	}
+	return max
+}
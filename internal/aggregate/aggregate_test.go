@@ -0,0 +1,134 @@
+package aggregate
+
+import (
+	"testing"
+
+	"github.com/codahale/metrics"
+)
+
+func TestMerge(t *testing.T) {
+	reports := []metrics.Report{
+		{
+			Counters:      map[string]uint64{"requests": 10},
+			FloatCounters: map[string]float64{"bytes": 1.5},
+			Gauges:        map[string]int64{"conns": 2},
+			Histograms: map[string]metrics.HistogramSummary{
+				"latency": {
+					Count:     2,
+					Min:       1,
+					Max:       3,
+					Mean:      2,
+					Quantiles: map[float64]int64{50: 1, 99: 3},
+					Buckets:   []metrics.Bucket{{UpperBound: 1, Count: 1}, {UpperBound: 3, Count: 2}, {UpperBound: 7, Count: 2}},
+				},
+			},
+			Tags: map[string]string{"env": "prod", "host": "web1"},
+		},
+		{
+			Counters:      map[string]uint64{"requests": 5},
+			FloatCounters: map[string]float64{"bytes": 2.25},
+			Gauges:        map[string]int64{"conns": 6},
+			Histograms: map[string]metrics.HistogramSummary{
+				"latency": {
+					Count:     2,
+					Min:       5,
+					Max:       7,
+					Mean:      6,
+					Quantiles: map[float64]int64{50: 5, 99: 7},
+					Buckets:   []metrics.Bucket{{UpperBound: 1, Count: 0}, {UpperBound: 3, Count: 0}, {UpperBound: 7, Count: 2}},
+				},
+			},
+			Tags: map[string]string{"env": "prod", "host": "web2"},
+		},
+	}
+
+	for mode, want := range map[Mode]int64{Sum: 8, Avg: 4, Max: 6, Min: 2} {
+		if v := Merge(reports, mode).Gauges["conns"]; v != want {
+			t.Errorf("%s of gauges was %v, but expected %v", mode, v, want)
+		}
+	}
+
+	r := Merge(reports, Sum)
+
+	if v, want := r.Counters["requests"], uint64(15); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	if v, want := r.FloatCounters["bytes"], 3.75; v != want {
+		t.Errorf("Float counter was %v, but expected %v", v, want)
+	}
+
+	if v, want := len(r.Tags), 1; v != want || r.Tags["env"] != "prod" {
+		t.Errorf("Tags were %v, but expected only env=prod", r.Tags)
+	}
+
+	h := r.Histograms["latency"]
+	if h.Count != 4 || h.Min != 1 || h.Max != 7 || h.Mean != 4 {
+		t.Errorf("Histogram was %+v, but expected count 4, min 1, max 7, mean 4", h)
+	}
+
+	if v, want := h.Quantiles[50], int64(3); v != want {
+		t.Errorf("P50 was %v, but expected %v", v, want)
+	}
+
+	if v, want := h.Quantiles[99], int64(7); v != want {
+		t.Errorf("P99 was %v, but expected %v", v, want)
+	}
+
+	if v, want := h.Buckets[2].Count, int64(4); v != want {
+		t.Errorf("Bucket count was %v, but expected %v", v, want)
+	}
+}
+
+func TestMergeWithoutBuckets(t *testing.T) {
+	r := Merge([]metrics.Report{
+		{Histograms: map[string]metrics.HistogramSummary{"latency": {Count: 10, Max: 3, Quantiles: map[float64]int64{99: 3}}}},
+		{Histograms: map[string]metrics.HistogramSummary{"latency": {Count: 20, Max: 7, Quantiles: map[float64]int64{99: 7}}}},
+		{Histograms: map[string]metrics.HistogramSummary{"latency": {Quantiles: map[float64]int64{99: 0}}}},
+	}, Sum)
+
+	h := r.Histograms["latency"]
+	if v, want := h.Count, int64(30); v != want {
+		t.Errorf("Count was %v, but expected %v", v, want)
+	}
+
+	if len(h.Quantiles) != 0 {
+		t.Errorf("Quantiles were %v, but expected none", h.Quantiles)
+	}
+
+	// a single report with values has exact quantiles
+	r = Merge([]metrics.Report{
+		{Histograms: map[string]metrics.HistogramSummary{"latency": {Count: 10, Max: 3, Quantiles: map[float64]int64{99: 3}}}},
+		{Histograms: map[string]metrics.HistogramSummary{"latency": {Quantiles: map[float64]int64{99: 0}}}},
+	}, Sum)
+
+	if v, want := r.Histograms["latency"].Quantiles[99], int64(3); v != want {
+		t.Errorf("P99 was %v, but expected %v", v, want)
+	}
+}
+
+func TestMergeMismatchedBuckets(t *testing.T) {
+	r := Merge([]metrics.Report{
+		{Histograms: map[string]metrics.HistogramSummary{"latency": {
+			Count: 2, Max: 3, Quantiles: map[float64]int64{99: 3},
+			Buckets: []metrics.Bucket{{UpperBound: 1, Count: 1}, {UpperBound: 3, Count: 2}},
+		}}},
+		{Histograms: map[string]metrics.HistogramSummary{"latency": {
+			Count: 2, Max: 15, Quantiles: map[float64]int64{99: 15},
+			Buckets: []metrics.Bucket{{UpperBound: 7, Count: 1}, {UpperBound: 15, Count: 2}},
+		}}},
+	}, Sum)
+
+	h := r.Histograms["latency"]
+	if v, want := h.Count, int64(4); v != want {
+		t.Errorf("Count was %v, but expected %v", v, want)
+	}
+
+	if len(h.Buckets) != 0 {
+		t.Errorf("Buckets were %v, but expected none", h.Buckets)
+	}
+
+	if len(h.Quantiles) != 0 {
+		t.Errorf("Quantiles were %v, but expected none", h.Quantiles)
+	}
+}
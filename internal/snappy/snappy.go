@@ -0,0 +1,168 @@
+// Package snappy implements the Snappy block format, as used by the Prometheus
+// remote-write protocol. The encoder finds matches with a simple hash table and
+// trades some compression for brevity.
+package snappy
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// ErrCorrupt is returned when decoding invalid input.
+var ErrCorrupt = errors.New("snappy: corrupt input")
+
+const (
+	tagLiteral = 0x00
+	tagCopy1   = 0x01
+	tagCopy2   = 0x02
+	tagCopy4   = 0x03
+
+	tableBits = 14
+	maxOffset = 1<<16 - 1
+)
+
+// Encode returns the Snappy block encoding of src.
+func Encode(src []byte) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	dst := append([]byte(nil), buf[:binary.PutUvarint(buf[:], uint64(len(src)))]...)
+
+	var table [1 << tableBits]int // positions in src plus one, by hash
+	lit := 0
+	for i := 0; i+4 <= len(src); {
+		h := hash(load32(src, i))
+		c := table[h] - 1
+		table[h] = i + 1
+
+		if c < 0 || i-c > maxOffset || load32(src, c) != load32(src, i) {
+			i++
+			continue
+		}
+
+		dst = emitLiteral(dst, src[lit:i])
+
+		n := 4
+		for i+n < len(src) && src[c+n] == src[i+n] {
+			n++
+		}
+		dst = emitCopy(dst, i-c, n)
+
+		i += n
+		lit = i
+	}
+
+	return emitLiteral(dst, src[lit:])
+}
+
+// Decode returns the decoded form of the Snappy block src.
+func Decode(src []byte) ([]byte, error) {
+	n, k := binary.Uvarint(src)
+	if k <= 0 || n > 1<<32 {
+		return nil, ErrCorrupt
+	}
+	src = src[k:]
+
+	dst := make([]byte, 0, n)
+	for len(src) > 0 {
+		var length, offset int
+		switch src[0] & 0x03 {
+		case tagLiteral:
+			length = int(src[0] >> 2)
+			src = src[1:]
+			if length >= 60 {
+				w := length - 59
+				if len(src) < w {
+					return nil, ErrCorrupt
+				}
+				length = 0
+				for i := w - 1; i >= 0; i-- {
+					length = length<<8 | int(src[i])
+				}
+				src = src[w:]
+			}
+			length++
+
+			if len(src) < length {
+				return nil, ErrCorrupt
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+			continue
+		case tagCopy1:
+			if len(src) < 2 {
+				return nil, ErrCorrupt
+			}
+			length = 4 + int(src[0]>>2&0x07)
+			offset = int(src[0]&0xe0)<<3 | int(src[1])
+			src = src[2:]
+		case tagCopy2:
+			if len(src) < 3 {
+				return nil, ErrCorrupt
+			}
+			length = 1 + int(src[0]>>2)
+			offset = int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+		case tagCopy4:
+			if len(src) < 5 {
+				return nil, ErrCorrupt
+			}
+			length = 1 + int(src[0]>>2)
+			offset = int(binary.LittleEndian.Uint32(src[1:]))
+			src = src[5:]
+		}
+
+		if offset <= 0 || offset > len(dst) {
+			return nil, ErrCorrupt
+		}
+
+		// copies may overlap their own output, so copy byte by byte
+		for i := 0; i < length; i++ {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+
+	if uint64(len(dst)) != n {
+		return nil, ErrCorrupt
+	}
+	return dst, nil
+}
+
+func emitLiteral(dst, lit []byte) []byte {
+	if len(lit) == 0 {
+		return dst
+	}
+
+	switch n := len(lit) - 1; {
+	case n < 60:
+		dst = append(dst, byte(n)<<2|tagLiteral)
+	case n < 1<<8:
+		dst = append(dst, 60<<2|tagLiteral, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2|tagLiteral, byte(n), byte(n>>8))
+	case n < 1<<24:
+		dst = append(dst, 62<<2|tagLiteral, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		dst = append(dst, 63<<2|tagLiteral, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+	return append(dst, lit...)
+}
+
+// emitCopy emits copies of at most 64 bytes with two-byte offsets.
+func emitCopy(dst []byte, offset, n int) []byte {
+	for n > 0 {
+		m := n
+		if m > 64 {
+			m = 64
+		}
+		dst = append(dst, byte(m-1)<<2|tagCopy2, byte(offset), byte(offset>>8))
+		n -= m
+	}
+	return dst
+}
+
+func load32(b []byte, i int) uint32 {
+	return binary.LittleEndian.Uint32(b[i:])
+}
+
+func hash(v uint32) uint32 {
+	return v * 0x1e35a7bd >> (32 - tableBits)
+}
@@ -0,0 +1,61 @@
+package snappy
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	inputs := []string{
+		"",
+		"a",
+		"hello, world",
+		strings.Repeat("http_requests_total", 500),
+		strings.Repeat("abcdefghijklmnopqrstuvwxyz0123456789", 3000),
+		strings.Repeat("x", 70000),
+	}
+
+	for _, in := range inputs {
+		enc := Encode([]byte(in))
+
+		dec, err := Decode(enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(dec, []byte(in)) {
+			t.Errorf("Round trip of %d bytes failed", len(in))
+		}
+	}
+}
+
+func TestCompression(t *testing.T) {
+	in := []byte(strings.Repeat("http_requests_total", 500))
+
+	if v, max := len(Encode(in)), len(in)/10; v > max {
+		t.Errorf("Encoded length was %v, but expected at most %v", v, max)
+	}
+}
+
+func TestDecodeCopies(t *testing.T) {
+	for _, enc := range [][]byte{
+		{0x0c, 0x14, 'h', 'e', 'l', 'l', 'o', ' ', 0x05, 0x06, 0x00, 'x'},       // one-byte offset
+		{0x0c, 0x14, 'h', 'e', 'l', 'l', 'o', ' ', 0x12, 0x06, 0x00, 0x00, 'x'}, // two-byte offset
+	} {
+		dec, err := Decode(enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if v, want := string(dec), "hello hellox"; v != want {
+			t.Errorf("Decoded was %q, but expected %q", v, want)
+		}
+	}
+}
+
+func TestDecodeCorrupt(t *testing.T) {
+	if _, err := Decode([]byte{0x05, 0x00, 'a'}); err != ErrCorrupt {
+		t.Errorf("Error was %v, but expected %v", err, ErrCorrupt)
+	}
+}
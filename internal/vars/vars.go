@@ -0,0 +1,130 @@
+// Package vars fetches the metrics published as expvars or by metrics.Handler
+// by other processes.
+package vars
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/codahale/metrics"
+)
+
+// Fetch retrieves the expvars at the given URL (e.g.,
+// http://localhost:8080/debug/vars) and returns the metrics published under the
+// given expvar name as a report. The report's time is the time of the request,
+// and its quantile gauges are grouped into histogram summaries.
+func Fetch(c *http.Client, url, name string) (metrics.Report, error) {
+	var r metrics.Report
+
+	t := time.Now()
+	resp, err := c.Get(url)
+	if err != nil {
+		return r, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return r, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	var vars map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&vars); err != nil {
+		return r, fmt.Errorf("%s: %v", url, err)
+	}
+
+	v, ok := vars[name]
+	if !ok {
+		return r, fmt.Errorf("%s: no expvar named %q", url, name)
+	}
+
+	if err := json.Unmarshal(v, &r); err != nil {
+		return r, fmt.Errorf("%s: %v", url, err)
+	}
+
+	if r.Time.IsZero() {
+		r.Time = t
+	}
+
+	if len(r.Histograms) == 0 {
+		r.Gauges, r.Histograms = SplitHistograms(r.Gauges)
+	}
+
+	return r, nil
+}
+
+// FetchReport retrieves the JSON report served by a metrics.Handler at the
+// given URL (e.g., http://localhost:8080/metrics).
+func FetchReport(c *http.Client, url string) (metrics.Report, error) {
+	var r metrics.Report
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return r, err
+	}
+	req.Header.Set("Accept", metrics.JSONContentType)
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return r, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return r, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return r, fmt.Errorf("%s: %v", url, err)
+	}
+
+	return r, nil
+}
+
+// quantiles maps the suffixes of histograms' quantile gauges to quantiles.
+var quantiles = map[string]float64{
+	".P50":  50,
+	".P75":  75,
+	".P90":  90,
+	".P95":  95,
+	".P99":  99,
+	".P999": 99.9,
+}
+
+// SplitHistograms separates the quantile gauges published by histograms (e.g.,
+// "latency.P99") from other gauges, returning the other gauges and summaries of
+// the histograms' quantiles. Gauges are only treated as quantile gauges if all
+// of a histogram's quantile gauges are present.
+func SplitHistograms(gauges map[string]int64) (map[string]int64, map[string]metrics.HistogramSummary) {
+	found := make(map[string]int)
+	for n := range gauges {
+		for suffix := range quantiles {
+			if strings.HasSuffix(n, suffix) {
+				found[strings.TrimSuffix(n, suffix)]++
+			}
+		}
+	}
+
+	rest := make(map[string]int64, len(gauges))
+	for n, v := range gauges {
+		rest[n] = v
+	}
+
+	hists := make(map[string]metrics.HistogramSummary)
+	for h, n := range found {
+		if n != len(quantiles) {
+			continue
+		}
+
+		s := metrics.HistogramSummary{Quantiles: make(map[float64]int64)}
+		for suffix, q := range quantiles {
+			s.Quantiles[q] = gauges[h+suffix]
+			delete(rest, h+suffix)
+		}
+		hists[h] = s
+	}
+
+	return rest, hists
+}
@@ -0,0 +1,81 @@
+package vars
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{
+			"cmdline": ["app"],
+			"metrics": {
+				"Counters": {"requests": 10},
+				"Gauges": {
+					"conns": 3,
+					"latency.P50": 1,
+					"latency.P75": 2,
+					"latency.P90": 3,
+					"latency.P95": 4,
+					"latency.P99": 5,
+					"latency.P999": 6,
+					"partial.P50": 7
+				}
+			}
+		}`)
+	}))
+	defer srv.Close()
+
+	r, err := Fetch(http.DefaultClient, srv.URL, "metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := r.Counters["requests"], uint64(10); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	if v, want := len(r.Gauges), 2; v != want {
+		t.Errorf("Gauge count was %v, but expected %v: %v", v, want, r.Gauges)
+	}
+
+	if v, want := r.Histograms["latency"].Quantiles[99.9], int64(6); v != want {
+		t.Errorf("P999 was %v, but expected %v", v, want)
+	}
+
+	if r.Time.IsZero() {
+		t.Error("Report has no timestamp")
+	}
+}
+
+func TestFetchMissing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{}`)
+	}))
+	defer srv.Close()
+
+	if _, err := Fetch(http.DefaultClient, srv.URL, "metrics"); err == nil {
+		t.Error("Expected an error but got none")
+	}
+}
+
+func TestFetchReport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v, want := r.Header.Get("Accept"), "application/json"; v != want {
+			t.Errorf("Accept was %q, but expected %q", v, want)
+		}
+		fmt.Fprint(w, `{"Counters": {"requests": 10}, "Gauges": {}, "Histograms": {}}`)
+	}))
+	defer srv.Close()
+
+	r, err := FetchReport(http.DefaultClient, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := r.Counters["requests"], uint64(10); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+}
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"io"
+	"time"
+)
+
+// IOStats measures the throughput of readers or writers. It counts the bytes
+// transferred in the <name>.Bytes counter and records the duration of each
+// call, in microseconds, in the <name>.Latency histogram.
+//
+// Create one IOStats per direction and stream type, and use it to wrap each
+// stream:
+//
+//	reads := metrics.NewIOStats("Uploads.Read", time.Second)
+//	...
+//	body := reads.Reader(r.Body)
+type IOStats struct {
+	bytes   Counter
+	latency *Histogram
+}
+
+// NewIOStats returns a new IOStats with the given name, whose latency
+// histogram tracks call durations up to maxLatency.
+func NewIOStats(name string, maxLatency time.Duration) *IOStats {
+	Describe(name+".Latency", Metadata{Unit: "microseconds"})
+	return &IOStats{
+		bytes:   Counter(name + ".Bytes"),
+		latency: NewHistogram(name+".Latency", 1, int64(maxLatency/time.Microsecond), 3),
+	}
+}
+
+// Reader returns a reader which measures calls to r's Read method.
+func (s *IOStats) Reader(r io.Reader) io.Reader {
+	return &reader{r: r, s: s}
+}
+
+// Writer returns a writer which measures calls to w's Write method.
+func (s *IOStats) Writer(w io.Writer) io.Writer {
+	return &writer{w: w, s: s}
+}
+
+// Remove removes the counter and histogram.
+func (s *IOStats) Remove() {
+	s.bytes.Remove()
+	s.latency.Remove()
+}
+
+func (s *IOStats) record(start time.Time, n int) {
+	s.bytes.AddN(uint64(n))
+	_ = s.latency.RecordValue(int64(now().Sub(start) / time.Microsecond))
+}
+
+type reader struct {
+	r io.Reader
+	s *IOStats
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	start := now()
+	n, err := r.r.Read(p)
+	r.s.record(start, n)
+	return n, err
+}
+
+type writer struct {
+	w io.Writer
+	s *IOStats
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	start := now()
+	n, err := w.w.Write(p)
+	w.s.record(start, n)
+	return n, err
+}
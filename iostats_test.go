@@ -0,0 +1,37 @@
+package metrics_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+)
+
+func TestIOStats(t *testing.T) {
+	metrics.Reset()
+
+	reads := metrics.NewIOStats("Read", time.Second)
+	writes := metrics.NewIOStats("Write", time.Second)
+
+	if _, err := ioutil.ReadAll(reads.Reader(strings.NewReader("hello"))); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	w := writes.Writer(&buf)
+	w.Write([]byte("hi"))
+	w.Write([]byte("there"))
+
+	counters, _ := metrics.Snapshot()
+
+	if v, want := counters["Read.Bytes"], uint64(5); v != want {
+		t.Errorf("Bytes read were %v, but expected %v", v, want)
+	}
+
+	if v, want := counters["Write.Bytes"], uint64(7); v != want {
+		t.Errorf("Bytes written were %v, but expected %v", v, want)
+	}
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NewListener returns a listener which instruments the connections accepted by
+// l. It counts accepted connections in the <name>.Accepted counter, publishes
+// the number of open connections as the <name>.Open gauge, counts the bytes
+// read from and written to connections in the <name>.BytesIn and
+// <name>.BytesOut counters, and records the lifetimes of closed connections,
+// in milliseconds, in the <name>.Lifetime histogram.
+func NewListener(l net.Listener, name string, maxLifetime time.Duration) net.Listener {
+	il := &listener{
+		Listener: l,
+		accepted: Counter(name + ".Accepted"),
+		bytesIn:  Counter(name + ".BytesIn"),
+		bytesOut: Counter(name + ".BytesOut"),
+		lifetime: NewHistogram(name+".Lifetime", 1, int64(maxLifetime/time.Millisecond), 3),
+	}
+
+	Gauge(name + ".Open").SetFunc(func() int64 {
+		return atomic.LoadInt64(&il.open)
+	})
+	Describe(name+".Lifetime", Metadata{Unit: "milliseconds"})
+	Describe(name+".BytesIn", Metadata{Unit: "bytes"})
+	Describe(name+".BytesOut", Metadata{Unit: "bytes"})
+
+	return il
+}
+
+type listener struct {
+	net.Listener
+	open                        int64 // accessed atomically
+	accepted, bytesIn, bytesOut Counter
+	lifetime                    *Histogram
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	l.accepted.Add()
+	atomic.AddInt64(&l.open, 1)
+
+	return &conn{Conn: c, l: l, start: now()}, nil
+}
+
+type conn struct {
+	net.Conn
+	l     *listener
+	start time.Time
+	once  sync.Once
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.l.bytesIn.AddN(uint64(n))
+	return n, err
+}
+
+func (c *conn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.l.bytesOut.AddN(uint64(n))
+	return n, err
+}
+
+func (c *conn) Close() error {
+	c.once.Do(func() {
+		atomic.AddInt64(&c.l.open, -1)
+		_ = c.l.lifetime.RecordValue(int64(now().Sub(c.start) / time.Millisecond))
+	})
+	return c.Conn.Close()
+}
@@ -0,0 +1,58 @@
+package metrics_test
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestListener(t *testing.T) {
+	metricstest.Reset(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l = metrics.NewListener(l, "Echo", time.Minute)
+	defer l.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		metricstest.AssertGauge(t, "Echo.Open", 1)
+
+		io.Copy(c, c)
+		c.Close()
+	}()
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	b := make([]byte, 5)
+	if _, err := io.ReadFull(c, b); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	<-done
+
+	metricstest.AssertCounter(t, "Echo.Accepted", 1)
+	metricstest.AssertCounter(t, "Echo.BytesIn", 5)
+	metricstest.AssertCounter(t, "Echo.BytesOut", 5)
+	metricstest.AssertGauge(t, "Echo.Open", 0)
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// A LocalCounter batches increments of a counter in a buffer owned by a single
+// goroutine (e.g., a worker), and adds them to the counter once they reach a
+// threshold or once per interval, for tight loops where even an atomic add per
+// event is too expensive. A LocalCounter is not safe for concurrent use; give
+// each goroutine its own.
+//
+//	c := metrics.NewLocalCounter("Worker.Items", 1000, time.Second)
+//	defer c.Stop()
+//	for item := range items {
+//		c.Add()
+//		...
+//	}
+//
+// Buffered increments are not visible in snapshots until they are flushed.
+// Because interval flushes happen on the next increment, a goroutine which
+// stops incrementing the counter should call Flush or Stop.
+type LocalCounter struct {
+	c         Counter
+	pending   uint64
+	threshold uint64
+	due       int32 // set by the timer, accessed atomically
+	timer     Timer
+}
+
+// NewLocalCounter returns a local counter which adds buffered increments to the
+// counter with the given name once they reach the threshold, or on the first
+// increment after each interval. A zero interval disables interval flushes.
+func NewLocalCounter(name string, threshold uint64, interval time.Duration) *LocalCounter {
+	l := &LocalCounter{c: Counter(name), threshold: threshold}
+	if interval > 0 {
+		l.timer = repeat(interval, func() {
+			atomic.StoreInt32(&l.due, 1)
+		})
+	}
+	return l
+}
+
+// Add increments the counter by one.
+func (l *LocalCounter) Add() {
+	l.AddN(1)
+}
+
+// AddN increments the counter by N.
+func (l *LocalCounter) AddN(delta uint64) {
+	l.pending += delta
+	if l.pending >= l.threshold || atomic.LoadInt32(&l.due) != 0 {
+		l.Flush()
+	}
+}
+
+// Flush adds any buffered increments to the counter.
+func (l *LocalCounter) Flush() {
+	atomic.StoreInt32(&l.due, 0)
+	if l.pending > 0 {
+		l.c.AddN(l.pending)
+		l.pending = 0
+	}
+}
+
+// Stop flushes any buffered increments and stops interval flushes.
+func (l *LocalCounter) Stop() {
+	l.Flush()
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+}
@@ -0,0 +1,53 @@
+package metrics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestLocalCounter(t *testing.T) {
+	metricstest.Reset(t)
+	c := metricstest.UseFakeClock(t)
+
+	l := metrics.NewLocalCounter("Items", 10, time.Second)
+
+	for i := 0; i < 9; i++ {
+		l.Add()
+	}
+	if v := metrics.Counter("Items").Value(); v != 0 {
+		t.Errorf("Counter was %d before the threshold, expected 0", v)
+	}
+
+	l.Add()
+	metricstest.AssertCounter(t, "Items", 10)
+
+	l.AddN(3)
+	metricstest.AssertCounter(t, "Items", 10)
+
+	c.Advance(time.Second)
+	l.Add()
+	metricstest.AssertCounter(t, "Items", 14)
+
+	l.AddN(2)
+	l.Stop()
+	metricstest.AssertCounter(t, "Items", 16)
+}
+
+func BenchmarkLocalCounterAdd(b *testing.B) {
+	metrics.Reset()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		l := metrics.NewLocalCounter("test.local", 1000, time.Second)
+		defer l.Stop()
+
+		for pb.Next() {
+			l.Add()
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// A Messenger sends reports of all metrics to a message broker (e.g., a Kafka
+// topic or NATS subject), so that a streaming pipeline can consume them without
+// scraping. The broker's client is supplied by the caller, e.g. for NATS:
+//
+//	m := metrics.Messenger{
+//		Send: func(b []byte) error {
+//			return nc.Publish("metrics.checkout", b)
+//		},
+//	}
+//	m.SendEvery(10 * time.Second)
+//
+// Or for Kafka, using github.com/segmentio/kafka-go:
+//
+//	m := metrics.Messenger{
+//		Send: func(b []byte) error {
+//			return w.WriteMessages(context.Background(), kafka.Message{Value: b})
+//		},
+//	}
+type Messenger struct {
+	// Send delivers an encoded report to the broker.
+	Send func(b []byte) error
+
+	// Binary, if true, encodes reports with MarshalBinary rather than as JSON.
+	Binary bool
+
+	// Pipeline is applied to each report before it is sent.
+	Pipeline Pipeline
+}
+
+// SendReport sends a report of all metrics to the broker.
+func (m Messenger) SendReport() error {
+	r := m.Pipeline.Apply(Capture())
+
+	var b []byte
+	var err error
+	if m.Binary {
+		b, err = r.MarshalBinary()
+	} else {
+		b, err = json.Marshal(r)
+	}
+
+	if err != nil {
+		return err
+	}
+	return m.Send(b)
+}
+
+// SendEvery sends a report once per the given interval until the returned timer
+// is stopped. Errors are counted by the Metrics.ExportErrors counter.
+func (m Messenger) SendEvery(d time.Duration) Timer {
+	return flushEvery(d, "Metrics.ExportErrors", m.SendReport)
+}
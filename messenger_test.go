@@ -0,0 +1,55 @@
+package metrics_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestMessenger(t *testing.T) {
+	metricstest.Reset(t)
+	metrics.Counter("whee").AddN(2)
+
+	var sent [][]byte
+	send := func(b []byte) error {
+		sent = append(sent, b)
+		return nil
+	}
+
+	if err := (metrics.Messenger{Send: send}).SendReport(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (metrics.Messenger{Send: send, Binary: true}).SendReport(); err != nil {
+		t.Fatal(err)
+	}
+
+	var r1, r2 metrics.Report
+	if err := json.Unmarshal(sent[0], &r1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r2.UnmarshalBinary(sent[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []metrics.Report{r1, r2} {
+		if v, want := r.Counters["whee"], uint64(2); v != want {
+			t.Errorf("Counter was %v, but expected %v", v, want)
+		}
+	}
+}
+
+func TestMessengerError(t *testing.T) {
+	metricstest.Reset(t)
+
+	want := errors.New("broker unavailable")
+	m := metrics.Messenger{Send: func([]byte) error { return want }}
+
+	if err := m.SendReport(); err != want {
+		t.Errorf("Error was %v, but expected %v", err, want)
+	}
+}
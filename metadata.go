@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// Metadata describes a metric for exporters whose formats support it.
+type Metadata struct {
+	Help string // a description of the metric
+	Unit string // the metric's unit (e.g., "seconds" or "bytes")
+}
+
+// Describe associates metadata with the metric of the given name.
+func Describe(name string, md Metadata) {
+	name, ok := resolve(name)
+	if !ok {
+		return
+	}
+
+	mdm.Lock()
+	defer mdm.Unlock()
+
+	metadata[name] = md
+}
+
+// copyMetadata returns the metadata of all metrics.
+func copyMetadata() map[string]Metadata {
+	mdm.Lock()
+	defer mdm.Unlock()
+
+	m := make(map[string]Metadata, len(metadata))
+	for n, md := range metadata {
+		m[n] = md
+	}
+	return m
+}
+
+// copyCreated returns the creation times of all counters.
+func copyCreated() map[string]time.Time {
+	cm.RLock()
+	defer cm.RUnlock()
+
+	m := make(map[string]time.Time, len(created))
+	for n, t := range created {
+		m[n] = t
+	}
+	return m
+}
+
+var (
+	metadata = make(map[string]Metadata)
+	mdm      sync.Mutex
+)
@@ -1,34 +1,49 @@
 // Package metrics provides minimalist instrumentation for your applications in
 // the form of counters and gauges.
 //
-// Counters
+// # Counters
 //
 // A counter is a monotonically-increasing, unsigned, 64-bit integer used to
 // represent the number of times an event has occurred. By tracking the deltas
 // between measurements of a counter over intervals of time, an aggregation
 // layer can derive rates, acceleration, etc.
 //
-// Gauges
+// # Gauges
 //
 // A gauge returns instantaneous measurements of something using signed, 64-bit
 // integers. This value does not need to be monotonic.
 //
-// Histograms
+// # Histograms
 //
 // A histogram tracks the distribution of a stream of values (e.g. the number of
 // milliseconds it takes to handle requests), adding gauges for the values at
 // meaningful quantiles: 50th, 75th, 90th, 95th, 99th, 99.9th.
 //
-// Reporting
+// # Reporting
 //
 // Measurements from counters and gauges are available as expvars. Your service
 // should return its expvars from an HTTP endpoint (i.e., /debug/vars) as a JSON
 // object.
+//
+// The expvar is named "metrics" by default. To publish it under a different
+// name, or not at all, set the name at build time:
+//
+//	go build -ldflags "-X github.com/codahale/metrics.expvarName=app.metrics"
+//	go build -ldflags "-X github.com/codahale/metrics.expvarName="
+//
+// Publish can then be used to publish the metrics under a name chosen at
+// runtime, and Init(WithExpvar(false)) disables the default expvar.
+//
+// For exporters, Capture returns a Report containing the values of all
+// counters and gauges along with summaries of all histograms.
 package metrics
 
 import (
-	"expvar"
+	"errors"
+	"fmt"
+	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/codahale/hdrhistogram"
@@ -36,6 +51,12 @@ import (
 
 // Note - If multiple locks must be concurrently held they should be
 // acquired in this order hm, gm, cm or deadlock will result.
+//
+// Counters are looked up on the increment path in counterIndex, which mirrors
+// counters without requiring cm, so that increments of existing counters never
+// contend on the registry's locks. Both are only modified with cm held.
+// Likewise, gauges set with Set are stored in gaugeValues, which is only
+// modified with gm held.
 
 // A Counter is a monotonically increasing unsigned integer.
 //
@@ -48,49 +69,185 @@ func (c Counter) Add() {
 	c.AddN(1)
 }
 
-// AddN increments the counter by N.
+// AddN increments the counter by N. Deltas larger than math.MaxInt64, which
+// usually indicate an attempt to decrement the counter, are discarded and
+// counted by the Metrics.CounterViolations counter, as are increments which
+// wrap the counter around.
 func (c Counter) AddN(delta uint64) {
-	cm.Lock()
-	counters[string(c)] += delta
-	cm.Unlock()
+	_ = c.TryAddN(delta)
+}
+
+// TryAddN increments the counter by N, as with AddN, but returns an Error if
+// the increment was rejected by the update validator or was a counter
+// violation.
+func (c Counter) TryAddN(delta uint64) error {
+	if atomic.LoadInt32(&disabled) != 0 {
+		return nil
+	}
+
+	name, ok := resolve(string(c))
+	if !ok {
+		return nil
+	}
+	return addCounter(name, delta)
+}
+
+// addCounter increments the counter with the given resolved name.
+func addCounter(name string, delta uint64) error {
+	if isSilenced(name) {
+		return nil
+	}
+
+	if err := checkDelta(name, delta); err != nil {
+		return err
+	}
+
+	var v *uint64
+	if p, ok := counterIndex.Load(name); ok {
+		v = p.(*uint64)
+	} else {
+		cm.Lock()
+		if v, ok = counters[name]; !ok {
+			v = new(uint64)
+			counters[name] = v
+			counterIndex.Store(name, v)
+			markCreated(name)
+		}
+		cm.Unlock()
+
+		if !ok {
+			notifyRegistered(name, "counter")
+		}
+	}
+
+	locked := beginUpdate()
+	n := atomic.AddUint64(v, delta)
+	endUpdate(locked)
+	err := checkWrapped(name, delta, n)
+	touch(name)
+	notifyUpdated(name, "counter")
+	checkTriggers(name, int64(n))
+	return err
+}
+
+// Value returns the counter's current value, or zero if the counter does not
+// exist.
+func (c Counter) Value() uint64 {
+	name, ok := resolve(string(c))
+	if !ok {
+		return 0
+	}
+
+	cm.RLock()
+	v, ok := counters[name]
+	f := counterFuncs[name]
+	cm.RUnlock()
+
+	if f != nil {
+		return f()
+	}
+
+	if ok {
+		return atomic.LoadUint64(v)
+	}
+	return 0
 }
 
 // SetFunc sets the counter's value to the lazily-called return value of the
 // given function.
 func (c Counter) SetFunc(f func() uint64) {
+	name, ok := resolve(string(c))
+	if !ok {
+		return
+	}
+
 	cm.Lock()
-	defer cm.Unlock()
+	exists := counterExists(name)
+	counterFuncs[name] = f
+	delete(counterBatches, name)
+	markCreated(name)
+	untouch(name)
+	cm.Unlock()
 
-	counterFuncs[string(c)] = f
+	if !exists {
+		notifyRegistered(name, "counter")
+	}
 }
 
 // SetBatchFunc sets the counter's value to the lazily-called return value of
 // the given function, with an additional initializer function for a related
 // batch of counters, all of which are keyed by an arbitrary value.
 func (c Counter) SetBatchFunc(key interface{}, init func(), f func() uint64) {
-	gm.Lock()
-	defer gm.Unlock()
+	name, ok := resolve(string(c))
+	if !ok {
+		return
+	}
 
+	gm.Lock()
 	cm.Lock()
-	defer cm.Unlock()
-
-	counterFuncs[string(c)] = f
+	exists := counterExists(name)
+	counterFuncs[name] = f
+	counterBatches[name] = key
+	markCreated(name)
+	untouch(name)
 	if _, ok := inits[key]; !ok {
 		inits[key] = init
 	}
+	cm.Unlock()
+	gm.Unlock()
+
+	if !exists {
+		notifyRegistered(name, "counter")
+	}
+}
+
+// counterExists returns true if a counter with the given name exists. It must
+// be called with cm held.
+func counterExists(name string) bool {
+	_, ok := counters[name]
+	if !ok {
+		_, ok = counterFuncs[name]
+	}
+	return ok
+}
+
+// markCreated records the creation time of a counter if it is new. It must be
+// called with cm held.
+func markCreated(name string) {
+	if _, ok := created[name]; !ok {
+		created[name] = now()
+	}
 }
 
 // Remove removes the given counter.
 func (c Counter) Remove() {
-	gm.Lock()
-	defer gm.Unlock()
+	if name, ok := resolve(string(c)); ok {
+		removeCounter(name)
+	}
+}
 
+// removeCounter removes the counter with the given resolved name.
+func removeCounter(name string) {
+	gm.Lock()
 	cm.Lock()
-	defer cm.Unlock()
+	exists := counterExists(name)
+	key, batched := counterBatches[name]
+	delete(counters, name)
+	counterIndex.Delete(name)
+	untouch(name)
+	delete(created, name)
+	delete(counterFuncs, name)
+	delete(counterBatches, name)
+	delete(inits, name)
+	if batched {
+		removeBatch(key)
+	}
+	cm.Unlock()
+	gm.Unlock()
 
-	delete(counters, string(c))
-	delete(counterFuncs, string(c))
-	delete(inits, string(c))
+	if exists {
+		notifyRemoved(name, "counter")
+	}
 }
 
 // A Gauge is an instantaneous measurement of a value.
@@ -101,46 +258,187 @@ type Gauge string
 
 // Set the gauge's value to the given value.
 func (g Gauge) Set(value int64) {
-	gm.Lock()
-	defer gm.Unlock()
+	if atomic.LoadInt32(&disabled) != 0 {
+		return
+	}
+
+	name, ok := resolve(string(g))
+	if !ok || isSilenced(name) {
+		return
+	}
+
+	exists := true
+	if p, ok := gaugeValues.Load(name); ok {
+		atomic.StoreInt64(p.(*int64), value)
+	} else {
+		gm.Lock()
+		_, exists = gauges[name]
+		v := new(int64)
+		*v = value
+		gauges[name] = func() int64 {
+			return atomic.LoadInt64(v)
+		}
+		delete(gaugeBatches, name)
+		gaugeValues.Store(name, v)
+		gm.Unlock()
+	}
+	touch(name)
 
-	gauges[string(g)] = func() int64 {
-		return value
+	if !exists {
+		notifyRegistered(name, "gauge")
 	}
+	notifyUpdated(name, "gauge")
+	checkTriggers(name, value)
 }
 
 // SetFunc sets the gauge's value to the lazily-called return value of the given
 // function.
 func (g Gauge) SetFunc(f func() int64) {
+	name, ok := resolve(string(g))
+	if !ok {
+		return
+	}
+
 	gm.Lock()
-	defer gm.Unlock()
+	_, exists := gauges[name]
+	gauges[name] = f
+	delete(gaugeBatches, name)
+	gaugeValues.Delete(name)
+	untouch(name)
+	gm.Unlock()
 
-	gauges[string(g)] = f
+	if !exists {
+		notifyRegistered(name, "gauge")
+	}
 }
 
 // SetBatchFunc sets the gauge's value to the lazily-called return value of the
 // given function, with an additional initializer function for a related batch
 // of gauges, all of which are keyed by an arbitrary value.
 func (g Gauge) SetBatchFunc(key interface{}, init func(), f func() int64) {
+	name, ok := resolve(string(g))
+	if ok && setGaugeBatchFunc(name, key, init, f) {
+		notifyRegistered(name, "gauge")
+	}
+}
+
+// setGaugeBatchFunc sets the batch function of the gauge with the given
+// resolved name, returning true if the gauge is new.
+func setGaugeBatchFunc(name string, key interface{}, init func(), f func() int64) bool {
 	gm.Lock()
 	defer gm.Unlock()
 
-	gauges[string(g)] = f
+	_, exists := gauges[name]
+	gauges[name] = f
+	gaugeBatches[name] = key
+	gaugeValues.Delete(name)
+	untouch(name)
 	if _, ok := inits[key]; !ok {
 		inits[key] = init
 	}
+	return !exists
+}
+
+// Value returns the gauge's current value and true, or false if the gauge does
+// not exist or its function panics or times out. Gauges set with SetBatchFunc
+// use the values from their initializer's most recent invocation.
+func (g Gauge) Value() (int64, bool) {
+	name, ok := resolve(string(g))
+	if !ok {
+		return 0, false
+	}
+
+	gm.RLock()
+	f, ok := gauges[name]
+	timeout := gaugeTimeouts[name]
+	gm.RUnlock()
+
+	if !ok {
+		return 0, false
+	}
+
+	v, err := gaugeFunc{name: name, f: f, timeout: timeout}.eval()
+	return v, err == nil
+}
+
+// SetTimeout limits the time the gauge's function may take to return a value.
+// If the function takes longer, the gauge is omitted from the snapshot and the
+// Metrics.GaugeErrors counter is incremented. A zero duration removes the
+// limit.
+func (g Gauge) SetTimeout(d time.Duration) {
+	name, ok := resolve(string(g))
+	if !ok {
+		return
+	}
+
+	gm.Lock()
+	defer gm.Unlock()
+
+	if d > 0 {
+		gaugeTimeouts[name] = d
+	} else {
+		delete(gaugeTimeouts, name)
+	}
 }
 
 // Remove removes the given gauge.
 func (g Gauge) Remove() {
+	if name, ok := resolve(string(g)); ok && removeGauge(name) {
+		notifyRemoved(name, "gauge")
+	}
+}
+
+// removeGauge removes the gauge with the given resolved name, returning true
+// if it existed. Unlike Remove, it does not notify observers.
+func removeGauge(name string) bool {
 	gm.Lock()
 	defer gm.Unlock()
 
-	delete(gauges, string(g))
-	delete(inits, string(g))
+	_, exists := gauges[name]
+	if !exists {
+		_, exists = derived[name]
+	}
+	delete(gauges, name)
+	gaugeValues.Delete(name)
+	untouch(name)
+	delete(gaugeTimeouts, name)
+	delete(derived, name)
+	key, batched := gaugeBatches[name]
+	delete(gaugeBatches, name)
+	delete(inits, name)
+	if batched {
+		cm.RLock()
+		removeBatch(key)
+		cm.RUnlock()
+	}
+	return exists
+}
+
+// removeBatch removes the initializer of the batch with the given key, and the
+// time of its last call, if none of the batch's counters and gauges remain. It
+// must be called with gm held and cm at least read-locked.
+func removeBatch(key interface{}) {
+	for _, k := range counterBatches {
+		if k == key {
+			return
+		}
+	}
+
+	for _, k := range gaugeBatches {
+		if k == key {
+			return
+		}
+	}
+
+	delete(inits, key)
+	delete(initCalls, key)
 }
 
-// Reset removes all existing counters and gauges.
+// Reset removes all existing counters, gauges, histograms, and sketches.
+//
+// Histograms which exist at the time of the reset are detached: they stop
+// rotating and are no longer reported, though they still record values and can
+// be queried, and new histograms with the same names may be created.
 func Reset() {
 	hm.Lock()
 	defer hm.Unlock()
@@ -151,98 +449,457 @@ func Reset() {
 	cm.Lock()
 	defer cm.Unlock()
 
-	counters = make(map[string]uint64)
+	for _, h := range histograms {
+		h.pause()
+	}
+	updated.Range(func(k, _ interface{}) bool {
+		updated.Delete(k)
+		return true
+	})
+
+	counters = make(map[string]*uint64)
+	counterIndex.Range(func(k, _ interface{}) bool {
+		counterIndex.Delete(k)
+		return true
+	})
 	counterFuncs = make(map[string]func() uint64)
+	counterBatches = make(map[string]interface{})
+	created = make(map[string]time.Time)
 	gauges = make(map[string]func() int64)
+	gaugeValues.Range(func(k, _ interface{}) bool {
+		gaugeValues.Delete(k)
+		return true
+	})
+	gaugeTimeouts = make(map[string]time.Duration)
+	gaugeBatches = make(map[string]interface{})
 	histograms = make(map[string]*Histogram)
+	sketches = make(map[string]*Sketch)
 	inits = make(map[interface{}]func())
+	derived = make(map[string]func(map[string]uint64, map[string]int64) int64)
+	collectors = make(map[string]CollectorFunc)
+	initMaxAges = make(map[interface{}]time.Duration)
+	initCalls = make(map[interface{}]time.Time)
+
+	fcm.Lock()
+	floatCounters = make(map[string]*uint64)
+	fcm.Unlock()
 }
 
 // Snapshot returns a copy of the values of all registered counters and gauges.
+//
+// Batch initializers, counter functions, gauge functions, derived gauges, and
+// collectors are all called without holding the registry's locks, so they may
+// safely use this package. If a counter or gauge function or a derived gauge
+// panics, or a gauge function exceeds its timeout, the metric is omitted from
+// the snapshot and the Metrics.GaugeErrors counter is incremented. If a batch
+// initializer panics, all the counters and gauges of its batch are omitted.
 func Snapshot() (c map[string]uint64, g map[string]int64) {
-	hm.Lock()
-	defer hm.Unlock()
+	if atomic.LoadInt32(&disabled) != 0 {
+		return make(map[string]uint64), make(map[string]int64)
+	}
+
+	sm.Lock()
+	defer sm.Unlock()
+
+	t := now()
+	failed := make(map[interface{}]bool)
+	for _, init := range copyInits() {
+		if init.maxAge > 0 {
+			if !init.last.IsZero() && t.Sub(init.last) < init.maxAge {
+				continue
+			}
+			setInitCall(init.key, t)
+		}
+
+		if err := protect("", init.f); err != nil {
+			failed[init.key] = true
+			countError("Metrics.GaugeErrors", "", err)
+		}
+	}
+
+	c, cfuncs, gfuncs := copyMetrics(failed)
+
+	for n, f := range cfuncs {
+		var v uint64
+		if err := protect(n, func() { v = f() }); err != nil {
+			countError("Metrics.GaugeErrors", n, err)
+			continue
+		}
+		c[n] = v
+	}
+
+	g = make(map[string]int64, len(gfuncs))
+	for i, v := range evalGauges(gfuncs) {
+		if v.err == nil {
+			g[gfuncs[i].name] = v.v
+		} else {
+			countError("Metrics.GaugeErrors", gfuncs[i].name, v.err)
+		}
+	}
+
+	collect(c, g)
+
+	for n, f := range copyDerived() {
+		var v int64
+		if err := protect(n, func() { v = f(c, g) }); err != nil {
+			countError("Metrics.GaugeErrors", n, err)
+			continue
+		}
+		g[n] = v
+	}
+
+	Gauge("Metrics.SnapshotDuration").Set(int64(now().Sub(t)))
+
+	return
+}
+
+// SetSnapshotConcurrency sets the number of gauge functions which snapshots
+// may evaluate concurrently. The default of one evaluates them serially; a
+// higher value speeds up snapshots of many slow gauges, provided the gauges'
+// functions are safe to call concurrently.
+//
+// The duration of each snapshot, in nanoseconds, is published as the
+// Metrics.SnapshotDuration gauge.
+func SetSnapshotConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	atomic.StoreInt32(&concurrency, int32(n))
+}
+
+type gaugeValue struct {
+	v   int64
+	err error
+}
+
+// evalGauges evaluates the given gauge functions using up to the configured
+// number of goroutines.
+func evalGauges(gfuncs []gaugeFunc) []gaugeValue {
+	values := make([]gaugeValue, len(gfuncs))
 
+	n := int(atomic.LoadInt32(&concurrency))
+	if n <= 1 || len(gfuncs) <= 1 {
+		for i, gf := range gfuncs {
+			values[i].v, values[i].err = gf.eval()
+		}
+		return values
+	}
+
+	if n > len(gfuncs) {
+		n = len(gfuncs)
+	}
+
+	var next int64 = -1
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for w := 0; w < n; w++ {
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(gfuncs) {
+					return
+				}
+				values[i].v, values[i].err = gfuncs[i].eval()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return values
+}
+
+// copyInits returns the registered batch initializers.
+func copyInits() []batchInit {
+	gm.RLock()
+	defer gm.RUnlock()
+
+	batch := make([]batchInit, 0, len(inits))
+	for key, init := range inits {
+		batch = append(batch, batchInit{key: key, f: init, maxAge: initMaxAges[key], last: initCalls[key]})
+	}
+	return batch
+}
+
+// setInitCall records the time of a call to the initializer of the batch with
+// the given key, unless the batch has since been removed.
+func setInitCall(key interface{}, t time.Time) {
 	gm.Lock()
 	defer gm.Unlock()
 
-	cm.Lock()
-	defer cm.Unlock()
+	if _, ok := inits[key]; ok {
+		initCalls[key] = t
+	}
+}
+
+type batchInit struct {
+	key    interface{}
+	f      func()
+	maxAge time.Duration
+	last   time.Time // the time of the last call, if limited by maxAge
+}
 
-	for _, init := range inits {
-		init()
+// SetBatchMaxAge limits how often the initializer of the batch with the given
+// key is called: if it has been called by a snapshot within the given duration,
+// subsequent snapshots use the values from that call. This prevents expensive
+// initializers (e.g., runtime.ReadMemStats) from being called for every request
+// when several scrapers poll the same process. A zero duration removes the
+// limit.
+func SetBatchMaxAge(key interface{}, maxAge time.Duration) {
+	gm.Lock()
+	defer gm.Unlock()
+
+	if maxAge > 0 {
+		initMaxAges[key] = maxAge
+	} else {
+		delete(initMaxAges, key)
+		delete(initCalls, key)
 	}
+}
+
+// copyMetrics returns the current counter values, the counter functions, and
+// the gauge functions, omitting the members of the given failed batches.
+func copyMetrics(failed map[interface{}]bool) (map[string]uint64, map[string]func() uint64, []gaugeFunc) {
+	start := time.Now()
+
+	gm.RLock()
+	defer gm.RUnlock()
 
-	c = make(map[string]uint64, len(counters)+len(counterFuncs))
+	cm.RLock()
+	defer cm.RUnlock()
+
+	atomic.AddInt64(&lockWait, int64(time.Since(start)))
+
+	c := make(map[string]uint64, len(counters)+len(counterFuncs))
+	t := now()
 	for n, v := range counters {
-		c[n] = v
+		if !isSilenced(n) && !isStale(n, t) {
+			c[n] = atomic.LoadUint64(v)
+		}
 	}
 
+	cfuncs := make(map[string]func() uint64, len(counterFuncs))
 	for n, f := range counterFuncs {
-		c[n] = f()
+		if key, ok := counterBatches[n]; ok && failed[key] {
+			continue
+		}
+		if !isSilenced(n) {
+			cfuncs[n] = f
+		}
 	}
 
-	g = make(map[string]int64, len(gauges))
+	gfuncs := make([]gaugeFunc, 0, len(gauges))
 	for n, f := range gauges {
-		g[n] = f()
+		if key, ok := gaugeBatches[n]; ok && failed[key] {
+			continue
+		}
+		if isSilenced(n) || isStale(n, t) {
+			continue
+		}
+		gfuncs = append(gfuncs, gaugeFunc{name: n, f: f, timeout: gaugeTimeouts[n]})
+	}
+
+	return c, cfuncs, gfuncs
+}
+
+type gaugeFunc struct {
+	name    string
+	f       func() int64
+	timeout time.Duration
+}
+
+var errTimeout = errors.New("timed out")
+
+// eval calls the gauge's function, recovering from panics and enforcing the
+// gauge's timeout, if any.
+func (gf gaugeFunc) eval() (int64, error) {
+	if gf.timeout <= 0 {
+		return gf.call()
 	}
 
+	type result struct {
+		v   int64
+		err error
+	}
+
+	ch := make(chan result, 1)
+	go func() {
+		v, err := gf.call()
+		ch <- result{v, err}
+	}()
+
+	t := time.NewTimer(gf.timeout)
+	defer t.Stop()
+
+	select {
+	case r := <-ch:
+		return r.v, r.err
+	case <-t.C:
+		return 0, Error{gf.name, errTimeout}
+	}
+}
+
+func (gf gaugeFunc) call() (v int64, err error) {
+	err = protect(gf.name, func() { v = gf.f() })
 	return
 }
 
+// protect calls f, returning an Error for the metric with the given name if it
+// panics.
+func protect(name string, f func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = Error{name, fmt.Errorf("panic: %v", r)}
+		}
+	}()
+
+	f()
+	return nil
+}
+
 // NewHistogram returns a windowed HDR histogram which drops data older than
 // five minutes. The returned histogram is safe to use from multiple goroutines.
 //
 // Use a histogram to track the distribution of a stream of values (e.g., the
-// latency associated with HTTP requests).
+// latency associated with HTTP requests). NewHistogram panics if a histogram
+// with the given name already exists.
 func NewHistogram(name string, minValue, maxValue int64, sigfigs int) *Histogram {
-	hm.Lock()
-	defer hm.Unlock()
+	return NewHistogramWithOptions(name, minValue, maxValue, sigfigs, HistogramOptions{})
+}
 
-	if _, ok := histograms[name]; ok {
-		panic(name + " already exists")
-	}
+// HistogramOptions configure the windows or reservoir of a histogram.
+type HistogramOptions struct {
+	// Interval is the duration of each of the histogram's five windows. If
+	// zero, one minute (or the interval set with WithRotationInterval) is
+	// used.
+	Interval time.Duration
+
+	// Jitter, if non-zero, offsets the histogram's rotations by a random
+	// duration of up to Jitter, so that many histograms with the same interval
+	// do not all rotate at once.
+	Jitter time.Duration
+
+	// Align, if true, rotates the histogram's windows on wall-clock boundaries
+	// which are multiples of Interval (e.g., at the start of each minute), so
+	// that its windows line up with external scrape intervals.
+	Align bool
+
+	// Compress, if true, stores the histogram's past windows compressed,
+	// trading CPU time when the histogram is queried for memory (e.g., for
+	// thousands of histograms, most of which record few values).
+	Compress bool
+
+	// Reservoir, if not nil, samples the values recorded by the histogram, and
+	// its quantiles and summaries are computed from the sample rather than
+	// from its windows (e.g., NewSlidingWindowReservoir(1000) for the last
+	// thousand values). Values out of the histogram's range are still
+	// rejected. Each histogram must have its own reservoir, and checkpoints do
+	// not restore reservoirs.
+	Reservoir Reservoir
+}
 
+// NewHistogramWithOptions returns a windowed HDR histogram which drops data
+// older than five of the given intervals or, if the options have a reservoir,
+// a histogram of the reservoir's sample.
+func NewHistogramWithOptions(name string, minValue, maxValue int64, sigfigs int, opts HistogramOptions) *Histogram {
 	hist := &Histogram{
-		name: name,
-		hist: hdrhistogram.NewWindowed(5, minValue, maxValue, sigfigs),
+		name:      name,
+		hist:      newHistWindows(minValue, maxValue, sigfigs, opts.Compress),
+		reservoir: opts.Reservoir,
+	}
+
+	if opts.Interval <= 0 {
+		opts.Interval = time.Duration(atomic.LoadInt64(&rotationInterval))
+	}
+
+	t := now()
+	hist.starts = []time.Time{t}
+
+	// histograms created in the same second share a rotation timer
+	phase := t.Truncate(time.Second)
+	if opts.Align {
+		phase = time.Unix(0, 0)
+	}
+	if opts.Jitter > 0 {
+		phase = phase.Add(time.Duration(rand.Int63n(int64(opts.Jitter))))
+	}
+	hist.schedule = func() Timer {
+		return rotateEvery(opts.Interval, phase, hist)
+	}
+	hist.rotation = hist.schedule()
+
+	name, ok := resolve(name)
+	if !ok {
+		return hist
+	}
+	hist.name = name
+
+	hm.Lock()
+	if _, ok := histograms[name]; ok {
+		hm.Unlock()
+		hist.rotation.Stop()
+		panic(name + " already exists")
 	}
 	histograms[name] = hist
 
-	Gauge(name+".P50").SetBatchFunc(hname(name), hist.merge, hist.valueAt(50))
-	Gauge(name+".P75").SetBatchFunc(hname(name), hist.merge, hist.valueAt(75))
-	Gauge(name+".P90").SetBatchFunc(hname(name), hist.merge, hist.valueAt(90))
-	Gauge(name+".P95").SetBatchFunc(hname(name), hist.merge, hist.valueAt(95))
-	Gauge(name+".P99").SetBatchFunc(hname(name), hist.merge, hist.valueAt(99))
-	Gauge(name+".P999").SetBatchFunc(hname(name), hist.merge, hist.valueAt(99.9))
+	for _, q := range quantiles {
+		setGaugeBatchFunc(name+q.suffix, hname(name), hist.merge, hist.valueAt(q.q))
+	}
+	hm.Unlock()
 
+	notifyRegistered(name, "histogram")
 	return hist
 }
 
-// Remove removes the given histogram.
+// Remove removes the given histogram. Removing a histogram detached by Reset
+// does not affect a histogram since created with the same name.
 func (h *Histogram) Remove() {
-
 	hm.Lock()
-	defer hm.Unlock()
+	exists := histograms[h.name] == h
+	if exists {
+		for _, q := range quantiles {
+			removeGauge(h.name + q.suffix)
+		}
+		delete(histograms, h.name)
+	}
+	hm.Unlock()
 
-	Gauge(h.name + ".P50").Remove()
-	Gauge(h.name + ".P75").Remove()
-	Gauge(h.name + ".P90").Remove()
-	Gauge(h.name + ".P95").Remove()
-	Gauge(h.name + ".P99").Remove()
-	Gauge(h.name + ".P999").Remove()
+	// stopped once unregistered, so that a concurrent Registry.Start cannot
+	// resume it
+	h.pause()
 
-	delete(histograms, h.name)
+	if exists {
+		notifyRemoved(h.name, "histogram")
+	}
 }
 
 type hname string // unexported to prevent collisions
 
+// quantiles are the quantiles at which histograms publish gauges, along with the
+// suffixes appended to the histograms' names to name the gauges.
+var quantiles = []struct {
+	q      float64
+	suffix string
+}{
+	{50, ".P50"},
+	{75, ".P75"},
+	{90, ".P90"},
+	{95, ".P95"},
+	{99, ".P99"},
+	{99.9, ".P999"},
+}
+
 // A Histogram measures the distribution of a stream of values.
 type Histogram struct {
-	name string
-	hist *hdrhistogram.WindowedHistogram
-	m    *hdrhistogram.Histogram
-	rw   sync.RWMutex
+	name      string
+	hist      *histWindows
+	m         *hdrhistogram.Histogram // the values at the most recent snapshot
+	exemplars map[int]Exemplar        // the most recent exemplar in each bucket
+	reservoir Reservoir               // if not nil, used instead of the windows
+	starts    []time.Time             // the start times of the windows, oldest first
+	rotation  Timer                   // guarded by rw
+	schedule  func() Timer            // starts rotating the windows
+	rw        sync.RWMutex
 }
 
 // Name returns the name of the histogram
@@ -254,28 +911,132 @@ func (h *Histogram) Name() string {
 // of range.
 // Returned error values are of type Error.
 func (h *Histogram) RecordValue(v int64) error {
+	if atomic.LoadInt32(&disabled) != 0 || isSilenced(h.name) {
+		return nil
+	}
+
+	locked := beginUpdate()
 	h.rw.Lock()
-	defer h.rw.Unlock()
+	err := h.record(v, 1)
+	h.rw.Unlock()
+	endUpdate(locked)
 
-	err := h.hist.Current.RecordValue(v)
 	if err != nil {
 		return Error{h.name, err}
 	}
+	notifyUpdated(h.name, "histogram")
 	return nil
 }
 
+// RecordValues records n occurrences of the given value, or returns an error if
+// the value is out of range or n is not positive. Use it to record
+// pre-aggregated data in one call.
+// Returned error values are of type Error.
+func (h *Histogram) RecordValues(v, n int64) error {
+	if atomic.LoadInt32(&disabled) != 0 || isSilenced(h.name) {
+		return nil
+	}
+
+	locked := beginUpdate()
+	h.rw.Lock()
+	err := h.record(v, n)
+	h.rw.Unlock()
+	endUpdate(locked)
+
+	if err != nil {
+		return Error{h.name, err}
+	}
+	notifyUpdated(h.name, "histogram")
+	return nil
+}
+
+// record records n occurrences of the given value in the histogram's current
+// window or its reservoir. It must be called with h.rw held.
+func (h *Histogram) record(v, n int64) error {
+	if n <= 0 {
+		return fmt.Errorf("count %d is not positive", n)
+	}
+
+	if h.reservoir == nil {
+		return h.hist.record(v, n)
+	}
+
+	if v < 0 || v > h.hist.hi {
+		return fmt.Errorf("value %d is out of range", v)
+	}
+
+	if n == 1 {
+		h.reservoir.Update(v)
+	} else {
+		h.reservoir.UpdateN(v, n)
+	}
+	return nil
+}
+
+// merged returns a histogram of the values recorded over the histogram's
+// windows or in its reservoir. It must be called with h.rw held.
+func (h *Histogram) merged() *hdrhistogram.Histogram {
+	if h.reservoir == nil {
+		return h.hist.merge()
+	}
+
+	m := h.hist.empty()
+	for _, v := range h.reservoir.Values() {
+		_ = m.RecordValue(v)
+	}
+	return m
+}
+
 func (h *Histogram) rotate() {
 	h.rw.Lock()
 	defer h.rw.Unlock()
 
-	h.hist.Rotate()
+	h.hist.rotate()
+
+	h.starts = append(h.starts, now())
+	if len(h.starts) > 5 {
+		h.starts = h.starts[1:]
+	}
+
+	// exemplars expire along with the windows in which they were recorded
+	for i, e := range h.exemplars {
+		if e.Time.Before(h.starts[0]) {
+			delete(h.exemplars, i)
+		}
+	}
+}
+
+// pause stops rotating the histogram's windows.
+func (h *Histogram) pause() {
+	h.rw.Lock()
+	defer h.rw.Unlock()
+
+	h.rotation.Stop()
+}
+
+// resume starts rotating the histogram's windows again after pause.
+func (h *Histogram) resume() {
+	h.rw.Lock()
+	defer h.rw.Unlock()
+
+	h.rotation.Stop()
+	h.rotation = h.schedule()
+}
+
+// memory returns the approximate number of bytes used by the histogram's
+// windows.
+func (h *Histogram) memory() int {
+	h.rw.RLock()
+	defer h.rw.RUnlock()
+
+	return h.hist.memory()
 }
 
 func (h *Histogram) merge() {
 	h.rw.Lock()
 	defer h.rw.Unlock()
 
-	h.m = h.hist.Merge()
+	h.m = h.merged()
 }
 
 func (h *Histogram) valueAt(q float64) func() int64 {
@@ -291,6 +1052,108 @@ func (h *Histogram) valueAt(q float64) func() int64 {
 	}
 }
 
+// query merges the histogram's windows and passes the result to f.
+func (h *Histogram) query(f func(m *hdrhistogram.Histogram)) {
+	h.rw.Lock()
+	defer h.rw.Unlock()
+
+	f(h.merged())
+}
+
+// ValueAtQuantile returns the recorded value at the given quantile (0-100) over
+// the histogram's current window.
+func (h *Histogram) ValueAtQuantile(q float64) (v int64) {
+	h.query(func(m *hdrhistogram.Histogram) {
+		v = m.ValueAtQuantile(q)
+	})
+	return
+}
+
+// Mean returns the mean of the values recorded over the histogram's current
+// window.
+func (h *Histogram) Mean() (v float64) {
+	h.query(func(m *hdrhistogram.Histogram) {
+		v = m.Mean()
+	})
+	return
+}
+
+// Max returns the largest value recorded over the histogram's current window.
+func (h *Histogram) Max() (v int64) {
+	h.query(func(m *hdrhistogram.Histogram) {
+		v = m.Max()
+	})
+	return
+}
+
+// TotalCount returns the number of values recorded over the histogram's current
+// window.
+func (h *Histogram) TotalCount() (n int64) {
+	h.query(func(m *hdrhistogram.Histogram) {
+		n = m.TotalCount()
+	})
+	return
+}
+
+// PercentileBelow returns the percentage (0-100) of values recorded over the
+// histogram's current window which are less than or equal to v.
+func (h *Histogram) PercentileBelow(v int64) (p float64) {
+	h.query(func(m *hdrhistogram.Histogram) {
+		total := m.TotalCount()
+		if total == 0 {
+			return
+		}
+
+		var n int64
+		for _, b := range m.Distribution() {
+			if b.To <= v {
+				n += b.Count
+			}
+		}
+		p = 100 * float64(n) / float64(total)
+	})
+	return
+}
+
+// PercentileOf returns the percentile rank (0-100) of v: the percentage of
+// values recorded over the histogram's current window which are less than or
+// equal to v. For example, if latencies are recorded in milliseconds,
+// h.PercentileOf(100) is the percentage of requests which finished within
+// 100ms. It is equivalent to PercentileBelow.
+func (h *Histogram) PercentileOf(v int64) float64 {
+	return h.PercentileBelow(v)
+}
+
+// A CDFPoint is the fraction (0-1) of a histogram's values which are less than
+// or equal to a value.
+type CDFPoint struct {
+	Value    int64
+	Fraction float64
+}
+
+// CDF returns the cumulative distribution of values recorded over the
+// histogram's current window, with one point for each distinct range of
+// recorded values, in increasing order. PercentileOf returns the same
+// fractions, as percentages, for arbitrary values.
+func (h *Histogram) CDF() (cdf []CDFPoint) {
+	h.query(func(m *hdrhistogram.Histogram) {
+		total := m.TotalCount()
+		if total == 0 {
+			return
+		}
+
+		var n int64
+		for _, b := range m.Distribution() {
+			if b.Count == 0 {
+				continue
+			}
+			n += b.Count
+			cdf = append(cdf, CDFPoint{Value: b.To, Fraction: float64(n) / float64(total)})
+		}
+	})
+	return
+}
+
 // Error describes an error and the name of the metric where it occurred.
 type Error struct {
 	Metric string
@@ -301,32 +1164,39 @@ func (e Error) Error() string {
 	return e.Metric + ": " + e.Err.Error()
 }
 
+// Unwrap returns the underlying error.
+func (e Error) Unwrap() error {
+	return e.Err
+}
+
 var (
-	counters     = make(map[string]uint64)
-	counterFuncs = make(map[string]func() uint64)
-	gauges       = make(map[string]func() int64)
-	inits        = make(map[interface{}]func())
-	histograms   = make(map[string]*Histogram)
+	counters       = make(map[string]*uint64)
+	counterIndex   sync.Map // name to *uint64, for lookups without cm
+	counterFuncs   = make(map[string]func() uint64)
+	counterBatches = make(map[string]interface{}) // the batch key of each counter set with SetBatchFunc
+	created        = make(map[string]time.Time)   // when each counter was created
+	gauges         = make(map[string]func() int64)
+	gaugeValues    sync.Map // name to *int64 for gauges set with Set, for lookups without gm
+	gaugeTimeouts  = make(map[string]time.Duration)
+	gaugeBatches   = make(map[string]interface{}) // the batch key of each gauge set with SetBatchFunc
+	inits          = make(map[interface{}]func())
+	initMaxAges    = make(map[interface{}]time.Duration)
+	initCalls      = make(map[interface{}]time.Time)
+	derived        = make(map[string]func(map[string]uint64, map[string]int64) int64)
+	histograms     = make(map[string]*Histogram)
 
-	cm, gm, hm sync.Mutex
+	cm, gm, hm sync.RWMutex
+	sm         sync.Mutex // serializes the evaluation of snapshots
+
+	concurrency int32 = 1 // the number of gauges evaluated concurrently
 )
 
 func init() {
-	expvar.Publish("metrics", expvar.Func(func() interface{} {
-		counters, gauges := Snapshot()
-		return map[string]interface{}{
-			"Counters": counters,
-			"Gauges":   gauges,
-		}
-	}))
+	if expvarName != "" {
+		pm.Lock()
+		defaultExpvar = expvarName
+		pm.Unlock()
 
-	go func() {
-		for _ = range time.NewTicker(1 * time.Minute).C {
-			hm.Lock()
-			for _, h := range histograms {
-				h.rotate()
-			}
-			hm.Unlock()
-		}
-	}()
+		Publish(expvarName)
+	}
 }
@@ -1,9 +1,14 @@
 package metrics_test
 
 import (
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
 )
 
 func TestCounter(t *testing.T) {
@@ -18,6 +23,27 @@ func TestCounter(t *testing.T) {
 	}
 }
 
+func TestCounterValue(t *testing.T) {
+	metrics.Reset()
+
+	metrics.Counter("whee").AddN(10)
+	metrics.Counter("woo").SetFunc(func() uint64 {
+		return 100
+	})
+
+	if v, want := metrics.Counter("whee").Value(), uint64(10); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	if v, want := metrics.Counter("woo").Value(), uint64(100); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	if v, want := metrics.Counter("nope").Value(), uint64(0); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+}
+
 func TestCounterFunc(t *testing.T) {
 	metrics.Reset()
 
@@ -89,6 +115,20 @@ func TestGaugeValue(t *testing.T) {
 	}
 }
 
+func TestGaugeGetValue(t *testing.T) {
+	metrics.Reset()
+
+	metrics.Gauge("whee").Set(-100)
+
+	if v, ok := metrics.Gauge("whee").Value(); !ok || v != -100 {
+		t.Errorf("Gauge was %v/%v, but expected -100/true", v, ok)
+	}
+
+	if v, ok := metrics.Gauge("nope").Value(); ok {
+		t.Errorf("Gauge was %v, but expected nothing", v)
+	}
+}
+
 func TestGaugeFunc(t *testing.T) {
 	metrics.Reset()
 
@@ -102,6 +142,164 @@ func TestGaugeFunc(t *testing.T) {
 	}
 }
 
+func TestGaugeSetAndFunc(t *testing.T) {
+	metrics.Reset()
+
+	metrics.Gauge("whee").Set(1)
+	metrics.Gauge("whee").SetFunc(func() int64 {
+		return 2
+	})
+	if v, _ := metrics.Gauge("whee").Value(); v != 2 {
+		t.Errorf("Gauge was %v, but expected 2", v)
+	}
+
+	metrics.Gauge("whee").Set(3)
+	metrics.Gauge("whee").Set(4)
+	if v, _ := metrics.Gauge("whee").Value(); v != 4 {
+		t.Errorf("Gauge was %v, but expected 4", v)
+	}
+
+	metrics.Gauge("whee").Remove()
+	metrics.Gauge("whee").Set(5)
+	if v, _ := metrics.Gauge("whee").Value(); v != 5 {
+		t.Errorf("Gauge was %v, but expected 5", v)
+	}
+
+	if n := testing.AllocsPerRun(100, func() {
+		metrics.Gauge("whee").Set(6)
+	}); n != 0 {
+		t.Errorf("Set made %v allocations, but expected none", n)
+	}
+}
+
+func TestGaugeFuncPanic(t *testing.T) {
+	metrics.Reset()
+
+	metrics.Gauge("whee").SetFunc(func() int64 {
+		panic("oh no")
+	})
+	metrics.Gauge("woo").Set(1)
+
+	_, gauges := metrics.Snapshot()
+	if v, ok := gauges["whee"]; ok {
+		t.Errorf("Gauge was %v, but expected nothing", v)
+	}
+
+	if v, want := gauges["woo"], int64(1); v != want {
+		t.Errorf("Gauge was %v, but expected %v", v, want)
+	}
+
+	counters, _ := metrics.Snapshot()
+	if v, want := counters["Metrics.GaugeErrors"], uint64(1); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+}
+
+func TestCounterFuncPanic(t *testing.T) {
+	metrics.Reset()
+
+	metrics.Counter("whee").SetFunc(func() uint64 {
+		panic("oh no")
+	})
+	metrics.Counter("woo").Add()
+
+	counters, _ := metrics.Snapshot()
+	if v, ok := counters["whee"]; ok {
+		t.Errorf("Counter was %v, but expected nothing", v)
+	}
+
+	if v, want := counters["woo"], uint64(1); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	counters, _ = metrics.Snapshot()
+	if v, want := counters["Metrics.GaugeErrors"], uint64(1); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+}
+
+func TestBatchInitPanic(t *testing.T) {
+	metrics.Reset()
+
+	type key struct{}
+	init := func() { panic("oh no") }
+	metrics.Counter("batch.count").SetBatchFunc(key{}, init, func() uint64 { return 1 })
+	metrics.Gauge("batch.value").SetBatchFunc(key{}, init, func() int64 { return 1 })
+	metrics.Gauge("woo").Set(1)
+
+	counters, gauges := metrics.Snapshot()
+	if v, ok := counters["batch.count"]; ok {
+		t.Errorf("Counter was %v, but expected nothing", v)
+	}
+
+	if v, ok := gauges["batch.value"]; ok {
+		t.Errorf("Gauge was %v, but expected nothing", v)
+	}
+
+	if v, want := gauges["woo"], int64(1); v != want {
+		t.Errorf("Gauge was %v, but expected %v", v, want)
+	}
+
+	counters, _ = metrics.Snapshot()
+	if v, want := counters["Metrics.GaugeErrors"], uint64(2); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+}
+
+func TestGaugeTimeout(t *testing.T) {
+	metrics.Reset()
+
+	block := make(chan struct{})
+	defer close(block)
+
+	metrics.Gauge("whee").SetFunc(func() int64 {
+		<-block
+		return 1
+	})
+	metrics.Gauge("whee").SetTimeout(10 * time.Millisecond)
+
+	_, gauges := metrics.Snapshot()
+	if v, ok := gauges["whee"]; ok {
+		t.Errorf("Gauge was %v, but expected nothing", v)
+	}
+
+	counters, _ := metrics.Snapshot()
+	if v, want := counters["Metrics.GaugeErrors"], uint64(1); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+}
+
+func TestGaugeFuncReentrant(t *testing.T) {
+	metrics.Reset()
+
+	metrics.Gauge("whee").SetFunc(func() int64 {
+		metrics.Counter("evaluations").Add()
+		metrics.Gauge("woo").Set(2)
+		return 1
+	})
+
+	metrics.Gauge("batch").SetBatchFunc("yay", func() {
+		metrics.Gauge("registered").Set(3)
+	}, func() int64 {
+		return 4
+	})
+
+	metrics.Snapshot()
+	counters, gauges := metrics.Snapshot()
+
+	if v, want := counters["evaluations"], uint64(1); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	if v, want := gauges["woo"], int64(2); v != want {
+		t.Errorf("Gauge was %v, but expected %v", v, want)
+	}
+
+	if v, want := gauges["registered"], int64(3); v != want {
+		t.Errorf("Gauge was %v, but expected %v", v, want)
+	}
+}
+
 func TestGaugeRemove(t *testing.T) {
 	metrics.Reset()
 
@@ -151,6 +349,96 @@ func TestHistogram(t *testing.T) {
 	}
 }
 
+func TestHistogramQueries(t *testing.T) {
+	metrics.Reset()
+
+	h := metrics.NewHistogram("heyo", 1, 1000, 3)
+	for i := 1; i <= 100; i++ {
+		h.RecordValue(int64(i))
+	}
+
+	if v, want := h.ValueAtQuantile(50), int64(50); v != want {
+		t.Errorf("P50 was %v, but expected %v", v, want)
+	}
+
+	if v, want := h.Mean(), 50.5; v != want {
+		t.Errorf("Mean was %v, but expected %v", v, want)
+	}
+
+	if v, want := h.Max(), int64(100); v != want {
+		t.Errorf("Max was %v, but expected %v", v, want)
+	}
+
+	if v, want := h.TotalCount(), int64(100); v != want {
+		t.Errorf("Count was %v, but expected %v", v, want)
+	}
+
+	if v, want := h.PercentileBelow(25), 25.0; v != want {
+		t.Errorf("Percentile was %v, but expected %v", v, want)
+	}
+}
+
+func TestHistogramCDF(t *testing.T) {
+	metrics.Reset()
+
+	h := metrics.NewHistogram("heyo", 1, 1000, 3)
+	h.RecordValues(50, 3)
+	h.RecordValues(100, 1)
+
+	if v, want := fmt.Sprint(h.CDF()), "[{50 0.75} {100 1}]"; v != want {
+		t.Errorf("CDF was %v, but expected %v", v, want)
+	}
+
+	if v, want := h.PercentileBelow(99), 75.0; v != want {
+		t.Errorf("Percentile was %v, but expected %v", v, want)
+	}
+}
+
+func TestHistogramPercentileOf(t *testing.T) {
+	metricstest.Reset(t)
+	clock := metricstest.UseFakeClock(t)
+
+	// request latencies in milliseconds, across two windows
+	h := metrics.NewHistogram("Latency", 1, 60000, 3)
+	h.RecordValues(40, 6)
+	h.RecordValues(90, 2)
+	clock.Advance(1 * time.Minute)
+	h.RecordValues(250, 1)
+	h.RecordValues(1200, 1)
+
+	if v, want := h.PercentileOf(100), 80.0; v != want {
+		t.Errorf("Requests under 100ms were %v%%, but expected %v%%", v, want)
+	}
+
+	if v, want := h.PercentileOf(10), 0.0; v != want {
+		t.Errorf("Requests under 10ms were %v%%, but expected %v%%", v, want)
+	}
+
+	if v, want := h.PercentileOf(60000), 100.0; v != want {
+		t.Errorf("Requests under 60s were %v%%, but expected %v%%", v, want)
+	}
+}
+
+func TestHistogramRecordValues(t *testing.T) {
+	metrics.Reset()
+
+	h := metrics.NewHistogram("heyo", 1, 1000, 3)
+	h.RecordValues(10, 10000)
+	h.RecordValues(500, 10)
+
+	if v, want := h.TotalCount(), int64(10010); v != want {
+		t.Errorf("Count was %v, but expected %v", v, want)
+	}
+
+	if v, want := h.Max(), int64(500); v != want {
+		t.Errorf("Max was %v, but expected %v", v, want)
+	}
+
+	if err := h.RecordValues(5000, 1); err == nil {
+		t.Error("Out-of-range value was recorded")
+	}
+}
+
 func TestHistogramRemove(t *testing.T) {
 	metrics.Reset()
 
@@ -163,6 +451,34 @@ func TestHistogramRemove(t *testing.T) {
 	}
 }
 
+func TestHistogramAfterReset(t *testing.T) {
+	metrics.Reset()
+
+	old := metrics.NewHistogram("heyo", 1, 1000, 3)
+	metrics.Reset()
+
+	h := metrics.NewHistogram("heyo", 1, 1000, 3)
+	defer h.Remove()
+
+	if err := old.RecordValue(1000); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.RecordValue(10); err != nil {
+		t.Fatal(err)
+	}
+
+	old.Remove()
+
+	_, gauges := metrics.Snapshot()
+	if v, want := gauges["heyo.P50"], int64(10); v != want {
+		t.Errorf("P50 was %v, but expected %v", v, want)
+	}
+
+	if s := metrics.Capture().Histograms["heyo"]; s.Count != 1 || s.Max != 10 {
+		t.Errorf("Histogram was %+v, but expected one value of 10", s)
+	}
+}
+
 func BenchmarkCounterAdd(b *testing.B) {
 	metrics.Reset()
 
@@ -189,6 +505,22 @@ func BenchmarkCounterAddN(b *testing.B) {
 	})
 }
 
+func BenchmarkCounterAddDistinct(b *testing.B) {
+	metrics.Reset()
+
+	var n int32
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		c := metrics.Counter(fmt.Sprintf("test%d", atomic.AddInt32(&n, 1)))
+		for pb.Next() {
+			c.Add()
+		}
+	})
+}
+
 func BenchmarkGaugeSet(b *testing.B) {
 	metrics.Reset()
 
@@ -215,3 +547,127 @@ func BenchmarkHistogramRecordValue(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkSnapshot(b *testing.B) {
+	metrics.Reset()
+	for i := 0; i < 100; i++ {
+		metrics.Counter(fmt.Sprintf("counter%d", i)).Add()
+		metrics.Gauge(fmt.Sprintf("gauge%d", i)).Set(int64(i))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			metrics.Snapshot()
+		}
+	})
+}
+
+func BenchmarkCounterAddDuringSnapshots(b *testing.B) {
+	metrics.Reset()
+	for i := 0; i < 100; i++ {
+		metrics.Counter(fmt.Sprintf("counter%d", i)).Add()
+		metrics.Gauge(fmt.Sprintf("gauge%d", i)).Set(int64(i))
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	for i := 0; i < 4; i++ {
+		go func() {
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					metrics.Snapshot()
+				}
+			}
+		}()
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			metrics.Counter("counter1").Add()
+		}
+	})
+}
+
+func TestSnapshotConcurrency(t *testing.T) {
+	metrics.Reset()
+	metrics.SetSnapshotConcurrency(4)
+	defer metrics.SetSnapshotConcurrency(1)
+
+	var started sync.WaitGroup
+	started.Add(4)
+	all := make(chan struct{})
+	go func() {
+		started.Wait()
+		close(all)
+	}()
+
+	for i := 0; i < 4; i++ {
+		metrics.Gauge(fmt.Sprintf("gauge%d", i)).SetFunc(func() int64 {
+			started.Done()
+			select {
+			case <-all:
+				return 1
+			case <-time.After(1 * time.Second):
+				return 0
+			}
+		})
+	}
+
+	_, gauges := metrics.Snapshot()
+	for i := 0; i < 4; i++ {
+		if v, want := gauges[fmt.Sprintf("gauge%d", i)], int64(1); v != want {
+			t.Errorf("Gauge was %v, but expected %v", v, want)
+		}
+		metrics.Gauge(fmt.Sprintf("gauge%d", i)).Remove()
+	}
+
+	_, gauges = metrics.Snapshot()
+	if _, ok := gauges["Metrics.SnapshotDuration"]; !ok {
+		t.Error("No snapshot duration gauge")
+	}
+}
+
+func TestBatchMaxAge(t *testing.T) {
+	metricstest.Reset(t)
+	c := metricstest.UseFakeClock(t)
+
+	calls := 0
+	register := func() {
+		metrics.Gauge("whee").SetBatchFunc("batch", func() {
+			calls++
+		}, func() int64 {
+			return int64(calls)
+		})
+		metrics.SetBatchMaxAge("batch", 10*time.Second)
+	}
+	register()
+
+	metricstest.AssertGauge(t, "whee", 1)
+
+	c.Advance(5 * time.Second)
+	metricstest.AssertGauge(t, "whee", 1)
+
+	c.Advance(5 * time.Second)
+	metricstest.AssertGauge(t, "whee", 2)
+
+	// a batch registered again after a reset or removal is initialized anew
+	metrics.Reset()
+	calls = 0
+	register()
+	metricstest.AssertGauge(t, "whee", 1)
+
+	metrics.Gauge("whee").Remove()
+	calls = 0
+	register()
+	metricstest.AssertGauge(t, "whee", 1)
+}
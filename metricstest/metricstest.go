@@ -0,0 +1,157 @@
+// Package metricstest provides utilities for testing code instrumented with
+// the metrics package.
+//
+// Because metrics are registered globally, tests which use these helpers must
+// not be run in parallel with other tests which record metrics.
+package metricstest
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+)
+
+// Reset removes all existing metrics, and again when the test and all its
+// subtests complete, so that each test observes only the metrics it records.
+func Reset(t testing.TB) {
+	t.Helper()
+
+	metrics.Reset()
+	t.Cleanup(metrics.Reset)
+}
+
+// CollectCounters returns a snapshot of all registered counters.
+func CollectCounters(t testing.TB) map[string]uint64 {
+	t.Helper()
+
+	counters, _ := metrics.Snapshot()
+	return counters
+}
+
+// CollectGauges returns a snapshot of all registered gauges.
+func CollectGauges(t testing.TB) map[string]int64 {
+	t.Helper()
+
+	_, gauges := metrics.Snapshot()
+	return gauges
+}
+
+// AssertCounter fails the test if the named counter does not exist or does not
+// have the given value.
+func AssertCounter(t testing.TB, name string, want uint64) {
+	t.Helper()
+
+	v, ok := CollectCounters(t)[name]
+	if !ok {
+		t.Errorf("Counter %q does not exist", name)
+	} else if v != want {
+		t.Errorf("Counter %q was %v, but expected %v", name, v, want)
+	}
+}
+
+// AssertGauge fails the test if the named gauge does not exist or does not have
+// the given value.
+func AssertGauge(t testing.TB, name string, want int64) {
+	t.Helper()
+
+	v, ok := CollectGauges(t)[name]
+	if !ok {
+		t.Errorf("Gauge %q does not exist", name)
+	} else if v != want {
+		t.Errorf("Gauge %q was %v, but expected %v", name, v, want)
+	}
+}
+
+// A FakeClock is a metrics.Clock whose time only changes when advanced.
+type FakeClock struct {
+	now     time.Time
+	pending []*fakeTimer
+	m       sync.Mutex
+}
+
+// UseFakeClock installs a fake clock as the metrics package's clock and
+// restores the system clock when the test completes.
+func UseFakeClock(t testing.TB) *FakeClock {
+	t.Helper()
+
+	c := NewFakeClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	metrics.SetClock(c)
+	t.Cleanup(func() {
+		metrics.SetClock(metrics.SystemClock)
+	})
+	return c
+}
+
+// NewFakeClock returns a fake clock set to the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the clock's current time.
+func (c *FakeClock) Now() time.Time {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	return c.now
+}
+
+// AfterFunc schedules f to be called once the clock has been advanced by d.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) metrics.Timer {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	t := &fakeTimer{c: c, at: c.now.Add(d), f: f}
+	c.pending = append(c.pending, t)
+	return t
+}
+
+// Advance moves the clock forward by d, calling any scheduled functions which
+// come due in the order of their scheduled times. Functions are called
+// synchronously, so histogram windows have been rotated when Advance returns.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.m.Lock()
+	end := c.now.Add(d)
+	c.m.Unlock()
+
+	for {
+		c.m.Lock()
+		sort.SliceStable(c.pending, func(i, j int) bool {
+			return c.pending[i].at.Before(c.pending[j].at)
+		})
+
+		if len(c.pending) == 0 || c.pending[0].at.After(end) {
+			c.now = end
+			c.m.Unlock()
+			return
+		}
+
+		t := c.pending[0]
+		c.pending = c.pending[1:]
+		c.now = t.at
+		c.m.Unlock()
+
+		t.f()
+	}
+}
+
+type fakeTimer struct {
+	c  *FakeClock
+	at time.Time
+	f  func()
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.c.m.Lock()
+	defer t.c.m.Unlock()
+
+	for i, p := range t.c.pending {
+		if p == t {
+			t.c.pending = append(t.c.pending[:i], t.c.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
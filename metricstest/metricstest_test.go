@@ -0,0 +1,35 @@
+package metricstest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestAssertions(t *testing.T) {
+	metricstest.Reset(t)
+
+	metrics.Counter("whee").AddN(3)
+	metrics.Gauge("woo").Set(-4)
+
+	metricstest.AssertCounter(t, "whee", 3)
+	metricstest.AssertGauge(t, "woo", -4)
+}
+
+func TestFakeClock(t *testing.T) {
+	metricstest.Reset(t)
+	c := metricstest.UseFakeClock(t)
+
+	h := metrics.NewHistogram("heyo", 1, 1000, 3)
+	h.RecordValue(100)
+
+	metricstest.AssertGauge(t, "heyo.P50", 100)
+
+	c.Advance(4 * time.Minute)
+	metricstest.AssertGauge(t, "heyo.P50", 100)
+
+	c.Advance(1 * time.Minute)
+	metricstest.AssertGauge(t, "heyo.P50", 0)
+}
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrCounterWrapped is reported when incrementing a counter overflows it.
+	ErrCounterWrapped = errors.New("counter wrapped around")
+
+	// ErrCounterDecremented is reported when a counter is incremented by a
+	// delta larger than math.MaxInt64, which almost always means a negative
+	// value was converted to a uint64 in an attempt to decrement the counter.
+	// Such increments are discarded.
+	ErrCounterDecremented = errors.New("counter decremented")
+
+	// ErrDeltaTooLarge is returned by validators created with MaxDelta.
+	ErrDeltaTooLarge = errors.New("delta too large")
+)
+
+// An UpdateValidator checks an increment of the counter with the given name,
+// returning an error if the increment should be rejected.
+type UpdateValidator func(name string, delta uint64) error
+
+// SetUpdateValidator sets the validator applied to counter increments, or
+// removes it if v is nil. Rejected increments are discarded and treated as
+// counter violations: they increment the Metrics.CounterViolations counter,
+// panic if SetCounterPanics is enabled, and are returned by TryAddN.
+//
+// Validating every increment is not free, so use an update validator in
+// development and tests to catch instrumentation bugs early.
+func SetUpdateValidator(v UpdateValidator) {
+	uvm.Lock()
+	defer uvm.Unlock()
+
+	updateValidator = v
+
+	if v != nil {
+		atomic.StoreInt32(&validatingUpdates, 1)
+	} else {
+		atomic.StoreInt32(&validatingUpdates, 0)
+	}
+}
+
+// MaxDelta returns a validator which rejects increments larger than max.
+func MaxDelta(max uint64) UpdateValidator {
+	return func(name string, delta uint64) error {
+		if delta > max {
+			return ErrDeltaTooLarge
+		}
+		return nil
+	}
+}
+
+// SetCounterPanics makes counter violations (ErrCounterWrapped,
+// ErrCounterDecremented, and increments rejected by the update validator) panic
+// with an Error naming the counter, in addition to incrementing the
+// Metrics.CounterViolations counter. Enable it in development and tests to
+// catch instrumentation bugs where they happen.
+func SetCounterPanics(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&counterPanics, 1)
+	} else {
+		atomic.StoreInt32(&counterPanics, 0)
+	}
+}
+
+// checkDelta returns an error if the delta would decrement the counter or is
+// rejected by the update validator.
+func checkDelta(name string, delta uint64) error {
+	if delta > math.MaxInt64 {
+		return violation(name, ErrCounterDecremented)
+	}
+
+	if atomic.LoadInt32(&validatingUpdates) == 0 || name == "Metrics.CounterViolations" {
+		return nil
+	}
+
+	uvm.RLock()
+	v := updateValidator
+	uvm.RUnlock()
+
+	if v != nil {
+		if err := v(name, delta); err != nil {
+			return violation(name, err)
+		}
+	}
+	return nil
+}
+
+// checkWrapped returns an error if the counter's new value shows that the delta
+// wrapped it around.
+func checkWrapped(name string, delta, v uint64) error {
+	if v < delta {
+		return violation(name, ErrCounterWrapped)
+	}
+	return nil
+}
+
+func violation(name string, err error) error {
+	Counter("Metrics.CounterViolations").Add()
+
+	e := Error{name, err}
+	if atomic.LoadInt32(&counterPanics) != 0 {
+		panic(e)
+	}
+	return e
+}
+
+var (
+	counterPanics     int32
+	updateValidator   UpdateValidator
+	validatingUpdates int32
+	uvm               sync.RWMutex
+)
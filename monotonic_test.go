@@ -0,0 +1,65 @@
+package metrics_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestCounterViolations(t *testing.T) {
+	metricstest.Reset(t)
+
+	metrics.Counter("whee").AddN(5)
+
+	n := -1
+	metrics.Counter("whee").AddN(uint64(n))
+	metricstest.AssertCounter(t, "whee", 5)
+	metricstest.AssertCounter(t, "Metrics.CounterViolations", 1)
+
+	metrics.Counter("woo").AddN(math.MaxInt64)
+	metrics.Counter("woo").AddN(math.MaxInt64)
+	metrics.Counter("woo").AddN(math.MaxInt64)
+	metricstest.AssertCounter(t, "Metrics.CounterViolations", 2)
+}
+
+func TestCounterPanics(t *testing.T) {
+	metricstest.Reset(t)
+
+	metrics.SetCounterPanics(true)
+	defer metrics.SetCounterPanics(false)
+
+	defer func() {
+		e, ok := recover().(metrics.Error)
+		if !ok || e.Metric != "whee" || !errors.Is(e.Err, metrics.ErrCounterDecremented) {
+			t.Errorf("Panic was %v, but expected a decrement of whee", e)
+		}
+	}()
+
+	metrics.Counter("whee").AddN(math.MaxUint64)
+}
+
+func TestUpdateValidator(t *testing.T) {
+	metricstest.Reset(t)
+
+	metrics.SetUpdateValidator(metrics.MaxDelta(100))
+	defer metrics.SetUpdateValidator(nil)
+
+	if err := metrics.Counter("whee").TryAddN(100); err != nil {
+		t.Fatal(err)
+	}
+
+	err := metrics.Counter("whee").TryAddN(101)
+	if !errors.Is(err, metrics.ErrDeltaTooLarge) {
+		t.Errorf("Error was %v, but expected %v", err, metrics.ErrDeltaTooLarge)
+	}
+
+	if v, want := err.Error(), "whee: delta too large"; v != want {
+		t.Errorf("Error was %q, but expected %q", v, want)
+	}
+
+	metricstest.AssertCounter(t, "whee", 100)
+	metricstest.AssertCounter(t, "Metrics.CounterViolations", 1)
+}
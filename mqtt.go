@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An MQTTReporter publishes the values of metrics to MQTT topics, for devices
+// which report through a broker rather than being scraped. Each counter, float
+// counter, and gauge is published to its own topic, named by the reporter's prefix followed
+// by the metric's name with dots replaced by slashes (e.g., "Sensor.Temp" with
+// a prefix of "devices/42/" is published to "devices/42/Sensor/Temp"), with
+// its value as a decimal string. Histograms are published as their quantile
+// values (e.g., "devices/42/Latency/P99").
+//
+// Use the reporter's pipeline to select which metrics are published. The MQTT
+// client is supplied by the caller, e.g. using
+// github.com/eclipse/paho.mqtt.golang:
+//
+//	r := metrics.MQTTReporter{
+//		Publish: func(topic string, qos byte, retained bool, payload []byte) error {
+//			t := client.Publish(topic, qos, retained, payload)
+//			t.Wait()
+//			return t.Error()
+//		},
+//		Prefix:   "devices/42/",
+//		QoS:      1,
+//		Retained: true,
+//		Pipeline: metrics.Pipeline{
+//			Filters: []metrics.Filter{metrics.Include("Sensor.*")},
+//		},
+//	}
+//	r.ReportEvery(time.Minute)
+type MQTTReporter struct {
+	// Publish publishes a message to the broker.
+	Publish func(topic string, qos byte, retained bool, payload []byte) error
+
+	// Prefix is prepended to each topic.
+	Prefix string
+
+	// QoS is the quality of service level of each message (0, 1, or 2).
+	QoS byte
+
+	// Retained, if true, asks the broker to retain the last value of each
+	// topic for new subscribers.
+	Retained bool
+
+	// Pipeline is applied to each report before it is published.
+	Pipeline Pipeline
+}
+
+// Report publishes the current value of each metric. If any publication fails,
+// the others are still attempted and the first error is returned.
+func (m MQTTReporter) Report() error {
+	r := m.Pipeline.Apply(Capture())
+
+	var first error
+	publish := func(name, v string) {
+		topic := m.Prefix + strings.Replace(name, ".", "/", -1)
+		err := m.Publish(topic, m.QoS, m.Retained, []byte(v))
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+
+	for _, n := range sortedKeys(r.Counters) {
+		publish(n, strconv.FormatUint(r.Counters[n], 10))
+	}
+
+	for _, n := range sortedKeys(r.FloatCounters) {
+		publish(n, strconv.FormatFloat(r.FloatCounters[n], 'g', -1, 64))
+	}
+
+	for _, n := range sortedKeys(r.Gauges) {
+		publish(n, strconv.FormatInt(r.Gauges[n], 10))
+	}
+
+	for _, n := range sortedKeys(r.Histograms) {
+		for _, q := range quantiles {
+			publish(n+q.suffix, strconv.FormatInt(r.Histograms[n].Quantiles[q.q], 10))
+		}
+	}
+
+	return first
+}
+
+// ReportEvery publishes the values of metrics once per the given interval until
+// the returned timer is stopped. Errors are counted by the Metrics.ExportErrors
+// counter.
+func (m MQTTReporter) ReportEvery(d time.Duration) Timer {
+	return flushEvery(d, "Metrics.ExportErrors", m.Report)
+}
@@ -0,0 +1,42 @@
+package metrics_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestMQTTReporter(t *testing.T) {
+	metricstest.Reset(t)
+
+	metrics.Counter("Sensor.Reads").AddN(3)
+	metrics.FloatCounter("Sensor.Energy").Add(1.5)
+	metrics.Gauge("Sensor.Temp").Set(-4)
+	metrics.Gauge("Debug.Allocs").Set(100)
+
+	var published []string
+	r := metrics.MQTTReporter{
+		Publish: func(topic string, qos byte, retained bool, payload []byte) error {
+			published = append(published, fmt.Sprintf("%s %d %v %s", topic, qos, retained, payload))
+			return nil
+		},
+		Prefix:   "devices/42/",
+		QoS:      1,
+		Retained: true,
+		Pipeline: metrics.Pipeline{
+			Filters: []metrics.Filter{metrics.Include("Sensor.*")},
+		},
+	}
+
+	if err := r.Report(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "devices/42/Sensor/Reads 1 true 3\ndevices/42/Sensor/Energy 1 true 1.5\ndevices/42/Sensor/Temp 1 true -4"
+	if v := strings.Join(published, "\n"); v != want {
+		t.Errorf("Published was\n%s\nbut expected\n%s", v, want)
+	}
+}
@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// A NameValidator checks the name of a metric as it is registered, returning
+// the name under which the metric should be registered, or false if the metric
+// should be rejected. Operations on rejected metrics do nothing.
+type NameValidator func(name string) (string, bool)
+
+// SetNameValidator sets the validator applied to the names of counters,
+// gauges, and histograms, or removes it if v is nil. Each name which the
+// validator changes or rejects increments the Metrics.NameViolations counter
+// the first time it is used.
+//
+// The package's own Metrics.* counters are exempt from validation. The
+// validator should be set before any metrics are registered, since names are
+// not re-validated.
+func SetNameValidator(v NameValidator) {
+	vm.Lock()
+	defer vm.Unlock()
+
+	validator = v
+	validated = make(map[string]string)
+	updateValidating()
+}
+
+// updateValidating records whether names must be resolved, which is the case
+// if there is a validator or a prefix. It must be called with vm held.
+func updateValidating() {
+	if validator != nil || namePrefix != "" {
+		atomic.StoreInt32(&validating, 1)
+	} else {
+		atomic.StoreInt32(&validating, 0)
+	}
+}
+
+// RejectNames returns a validator which rejects names for which valid returns
+// false.
+func RejectNames(valid func(name string) bool) NameValidator {
+	return func(name string) (string, bool) {
+		return name, valid(name)
+	}
+}
+
+// NormalizeNames returns a validator which registers metrics under the names
+// returned by f (e.g., PrometheusName).
+func NormalizeNames(f func(name string) string) NameValidator {
+	return func(name string) (string, bool) {
+		return f(name), true
+	}
+}
+
+// Sanitize returns a filter which exports each metric under the name returned
+// by f. Use Sanitize in an exporter's pipeline to adapt names to the character
+// set of a particular backend.
+func Sanitize(f func(name string) string) Filter {
+	return func(name string) (string, bool) {
+		return f(name), true
+	}
+}
+
+// GraphiteName replaces each character in the name which is not a letter,
+// digit, period, hyphen, or underscore with an underscore.
+func GraphiteName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		valid := c == '.' || c == '-' || c == '_' ||
+			('a' <= c && c <= 'z') ||
+			('A' <= c && c <= 'Z') ||
+			('0' <= c && c <= '9')
+		if !valid {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// resolve returns the name under which the metric with the given name is
+// registered, or false if the name has been rejected.
+func resolve(name string) (string, bool) {
+	if atomic.LoadInt32(&validating) == 0 || strings.HasPrefix(name, "Metrics.") {
+		return name, true
+	}
+
+	// resolved names are cached, so that the fast path does not allocate
+	vm.RLock()
+	n, ok := validated[name]
+	v, prefix := validator, namePrefix
+	vm.RUnlock()
+
+	if ok {
+		return n, n != ""
+	}
+
+	prefixed := prefix + name
+	n = prefixed
+	if v != nil {
+		if n, ok = v(prefixed); !ok {
+			n = ""
+		}
+	}
+
+	vm.Lock()
+	_, seen := validated[name]
+	if prefix == namePrefix {
+		validated[name] = n
+	}
+	vm.Unlock()
+
+	if !seen && n != prefixed {
+		Counter("Metrics.NameViolations").Add()
+		emit(Event{Kind: "name violation", Counter: "Metrics.NameViolations", Name: prefixed})
+	}
+
+	return n, n != ""
+}
+
+// resolvePrefix returns the prefix of the resolved names of metrics whose names
+// begin with the given prefix, ignoring the name validator.
+func resolvePrefix(prefix string) string {
+	vm.RLock()
+	defer vm.RUnlock()
+
+	return namePrefix + prefix
+}
+
+var (
+	validator  NameValidator
+	namePrefix string                    // prepended to names, guarded by vm
+	validated  = make(map[string]string) // resolved names by requested name, or "" if rejected
+	validating int32
+	vm         sync.RWMutex
+)
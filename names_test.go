@@ -0,0 +1,82 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/codahale/metrics"
+)
+
+func TestRejectNames(t *testing.T) {
+	metrics.Reset()
+	metrics.SetNameValidator(metrics.RejectNames(func(name string) bool {
+		return metrics.PrometheusName(name) == name
+	}))
+	defer metrics.SetNameValidator(nil)
+
+	metrics.Counter("http_requests").Add()
+	metrics.Counter("http requests").Add()
+	metrics.Counter("http requests").Add()
+	metrics.Gauge("heap bytes").Set(1)
+	metrics.NewHistogram("latency ms", 1, 1000, 3).RecordValue(1)
+
+	counters, gauges := metrics.Snapshot()
+
+	if v, want := counters["http_requests"], uint64(1); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	if v, ok := counters["http requests"]; ok {
+		t.Errorf("Counter was %v, but expected nothing", v)
+	}
+
+	if v, want := len(gauges), 0; v != want {
+		t.Errorf("Gauge count was %v, but expected %v: %v", v, want, gauges)
+	}
+
+	if v, want := counters["Metrics.NameViolations"], uint64(3); v != want {
+		t.Errorf("Violations were %v, but expected %v", v, want)
+	}
+}
+
+func TestNormalizeNames(t *testing.T) {
+	metrics.Reset()
+	metrics.SetNameValidator(metrics.NormalizeNames(metrics.GraphiteName))
+	defer metrics.SetNameValidator(nil)
+
+	metrics.Counter("http requests").AddN(2)
+	metrics.Gauge("heap:bytes").Set(1)
+
+	if v, want := metrics.Counter("http requests").Value(), uint64(2); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	counters, gauges := metrics.Snapshot()
+
+	if v, want := counters["http_requests"], uint64(2); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	if v, want := gauges["heap_bytes"], int64(1); v != want {
+		t.Errorf("Gauge was %v, but expected %v", v, want)
+	}
+
+	if v, want := counters["Metrics.NameViolations"], uint64(2); v != want {
+		t.Errorf("Violations were %v, but expected %v", v, want)
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	p := metrics.Pipeline{
+		Filters: []metrics.Filter{metrics.Sanitize(metrics.PrometheusName)},
+	}
+
+	if v, _ := p.Name("HTTP.Requests"); v != "HTTP_Requests" {
+		t.Errorf("Name was %q, but expected %q", v, "HTTP_Requests")
+	}
+}
+
+func TestPrometheusName(t *testing.T) {
+	if v, want := metrics.PrometheusName("1Mem.Heap-Size:Max"), "_Mem_Heap_Size:Max"; v != want {
+		t.Errorf("Name was %q, but expected %q", v, want)
+	}
+}
@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// A NewRelicReporter sends the values of metrics to the New Relic Metric API.
+// Counters and float counters are sent as counts of their increase since the
+// previous report, so the first report sends no counters; gauges and the quantiles of histograms
+// (e.g., Latency.P99) are sent as gauges. The report's tags are sent as common
+// attributes.
+type NewRelicReporter struct {
+	// APIKey is the license or insert key used to authenticate.
+	APIKey string
+
+	// URL is the Metric API endpoint. If empty, the US endpoint,
+	// https://metric-api.newrelic.com/metric/v1, is used.
+	URL string
+
+	// Client, if not nil, is used to send requests. Otherwise,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	// Pipeline is applied to each report before it is sent.
+	Pipeline Pipeline
+
+	m    sync.Mutex
+	prev Report
+}
+
+type newRelicMetric struct {
+	Name     string      `json:"name"`
+	Type     string      `json:"type"`
+	Value    interface{} `json:"value"`
+	Interval int64       `json:"interval.ms,omitempty"`
+}
+
+type newRelicBatch struct {
+	Common struct {
+		Timestamp  int64             `json:"timestamp"`
+		Attributes map[string]string `json:"attributes,omitempty"`
+	} `json:"common"`
+	Metrics []newRelicMetric `json:"metrics"`
+}
+
+// Report sends the values of metrics to New Relic.
+func (nr *NewRelicReporter) Report() error {
+	nr.m.Lock()
+	defer nr.m.Unlock()
+
+	r := nr.Pipeline.Apply(Capture())
+
+	var batch newRelicBatch
+	batch.Common.Timestamp = r.Time.UnixNano() / int64(time.Millisecond)
+	batch.Common.Attributes = r.Tags
+
+	if !nr.prev.Time.IsZero() {
+		d := r.DeltaSince(nr.prev)
+		for _, n := range sortedKeys(d.Counters) {
+			batch.Metrics = append(batch.Metrics, newRelicMetric{
+				Name:     n,
+				Type:     "count",
+				Value:    d.Counters[n],
+				Interval: int64(d.Interval / time.Millisecond),
+			})
+		}
+
+		for _, n := range sortedKeys(d.FloatCounters) {
+			batch.Metrics = append(batch.Metrics, newRelicMetric{
+				Name:     n,
+				Type:     "count",
+				Value:    d.FloatCounters[n],
+				Interval: int64(d.Interval / time.Millisecond),
+			})
+		}
+	}
+
+	for _, n := range sortedKeys(r.Gauges) {
+		batch.Metrics = append(batch.Metrics, newRelicMetric{Name: n, Type: "gauge", Value: r.Gauges[n]})
+	}
+
+	for _, n := range sortedKeys(r.Histograms) {
+		for _, q := range quantiles {
+			batch.Metrics = append(batch.Metrics, newRelicMetric{
+				Name:  n + q.suffix,
+				Type:  "gauge",
+				Value: r.Histograms[n].Quantiles[q.q],
+			})
+		}
+	}
+
+	b, err := json.Marshal([]newRelicBatch{batch})
+	if err != nil {
+		return err
+	}
+
+	url := nr.URL
+	if url == "" {
+		url = "https://metric-api.newrelic.com/metric/v1"
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Api-Key", nr.APIKey)
+
+	if err := send(nr.Client, req); err != nil {
+		return err
+	}
+
+	nr.prev = r
+	return nil
+}
+
+// ReportEvery sends the values of metrics once per the given interval until the
+// returned timer is stopped. Errors are counted by the Metrics.ExportErrors
+// counter.
+func (nr *NewRelicReporter) ReportEvery(d time.Duration) Timer {
+	return flushEvery(d, "Metrics.ExportErrors", nr.Report)
+}
+
+// send sends the request with the given client, or http.DefaultClient if nil,
+// and returns an error if the response status is not 2xx.
+func send(c *http.Client, req *http.Request) error {
+	if c == nil {
+		c = http.DefaultClient
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("metrics: %s %s: %s", req.Method, req.URL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package metrics_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+type newRelicBatch struct {
+	Metrics []struct {
+		Name     string
+		Type     string
+		Value    float64
+		Interval int64 `json:"interval.ms"`
+	}
+}
+
+func TestNewRelicReporter(t *testing.T) {
+	metricstest.Reset(t)
+	c := metricstest.UseFakeClock(t)
+
+	var batches [][]newRelicBatch
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v, want := r.Header.Get("Api-Key"), "key"; v != want {
+			t.Errorf("Api-Key was %q, but expected %q", v, want)
+		}
+
+		var b []newRelicBatch
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			t.Error(err)
+		}
+		batches = append(batches, b)
+	}))
+	defer srv.Close()
+
+	nr := &metrics.NewRelicReporter{
+		APIKey: "key",
+		URL:    srv.URL,
+		Pipeline: metrics.Pipeline{
+			Filters: []metrics.Filter{metrics.Exclude("Metrics.*")},
+		},
+	}
+
+	metrics.Counter("Requests").AddN(3)
+	metrics.Gauge("Conns").Set(2)
+	if err := nr.Report(); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Advance(10 * time.Second)
+	metrics.Counter("Requests").AddN(4)
+	metrics.FloatCounter("Bytes").Add(1.5)
+	if err := nr.Report(); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := len(batches[0][0].Metrics), 1; v != want {
+		t.Fatalf("First report had %v metrics, but expected %v", v, want)
+	}
+
+	m := batches[1][0].Metrics[0]
+	if m.Name != "Requests" || m.Type != "count" || m.Value != 4 || m.Interval != 10000 {
+		t.Errorf("Count was %+v, but expected Requests/count/4/10000", m)
+	}
+
+	m = batches[1][0].Metrics[1]
+	if m.Name != "Bytes" || m.Type != "count" || m.Value != 1.5 || m.Interval != 10000 {
+		t.Errorf("Count was %+v, but expected Bytes/count/1.5/10000", m)
+	}
+}
@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// An Observer is notified when metrics are registered and removed, allowing
+// features such as service discovery registration or schema validation to
+// react to changes in the registry without polling it. Kinds are "counter",
+// "float counter", "gauge", "histogram", or "sketch"; the quantile gauges of
+// histograms and sketches are considered part of them.
+//
+// Observers are called synchronously, without holding the registry's locks,
+// so they may use this package, but should return quickly. Reset does not
+// notify observers.
+type Observer interface {
+	// MetricRegistered is called when a metric is first registered.
+	MetricRegistered(name, kind string)
+
+	// MetricRemoved is called when a metric is removed.
+	MetricRemoved(name, kind string)
+}
+
+// An UpdateObserver is an Observer which is also notified of each update to a
+// counter, float counter, gauge, or histogram (e.g., each call to AddN, Set, or
+// RecordValue). Update notifications are costly on hot paths, so they are only
+// made while at least one UpdateObserver is registered.
+type UpdateObserver interface {
+	Observer
+
+	// MetricUpdated is called when a metric is updated.
+	MetricUpdated(name, kind string)
+}
+
+// AddObserver registers an observer. If it implements UpdateObserver, it is
+// also notified of updates.
+func AddObserver(o Observer) {
+	om.Lock()
+	defer om.Unlock()
+
+	observers = append(observers[:len(observers):len(observers)], o)
+	if u, ok := o.(UpdateObserver); ok {
+		updateObservers = append(updateObservers[:len(updateObservers):len(updateObservers)], u)
+	}
+	storeObserving()
+}
+
+// RemoveObserver unregisters an observer.
+func RemoveObserver(o Observer) {
+	om.Lock()
+	defer om.Unlock()
+
+	var os []Observer
+	for _, v := range observers {
+		if v != o {
+			os = append(os, v)
+		}
+	}
+	observers = os
+
+	var us []UpdateObserver
+	for _, v := range updateObservers {
+		if Observer(v) != o {
+			us = append(us, v)
+		}
+	}
+	updateObservers = us
+	storeObserving()
+}
+
+// storeObserving updates the flags which enable notifications. It must be
+// called with om held.
+func storeObserving() {
+	atomic.StoreInt32(&observing, int32(len(observers)))
+	atomic.StoreInt32(&observingUpdates, int32(len(updateObservers)))
+}
+
+func notifyRegistered(name, kind string) {
+	if atomic.LoadInt32(&observing) == 0 {
+		return
+	}
+
+	om.RLock()
+	os := observers
+	om.RUnlock()
+
+	for _, o := range os {
+		o.MetricRegistered(name, kind)
+	}
+}
+
+func notifyRemoved(name, kind string) {
+	if atomic.LoadInt32(&observing) == 0 {
+		return
+	}
+
+	om.RLock()
+	os := observers
+	om.RUnlock()
+
+	for _, o := range os {
+		o.MetricRemoved(name, kind)
+	}
+}
+
+func notifyUpdated(name, kind string) {
+	if atomic.LoadInt32(&observingUpdates) == 0 {
+		return
+	}
+
+	om.RLock()
+	us := updateObservers
+	om.RUnlock()
+
+	for _, o := range us {
+		o.MetricUpdated(name, kind)
+	}
+}
+
+var (
+	observers        []Observer       // replaced, never modified, when changed
+	updateObservers  []UpdateObserver // replaced, never modified, when changed
+	observing        int32
+	observingUpdates int32
+	om               sync.RWMutex
+)
@@ -0,0 +1,96 @@
+package metrics_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+type recorder struct {
+	events []string
+}
+
+func (r *recorder) MetricRegistered(name, kind string) {
+	r.events = append(r.events, fmt.Sprintf("+%s %s", kind, name))
+}
+
+func (r *recorder) MetricRemoved(name, kind string) {
+	r.events = append(r.events, fmt.Sprintf("-%s %s", kind, name))
+}
+
+type updateRecorder struct {
+	recorder
+}
+
+func (r *updateRecorder) MetricUpdated(name, kind string) {
+	r.events = append(r.events, fmt.Sprintf("*%s %s", kind, name))
+}
+
+func TestObserver(t *testing.T) {
+	metricstest.Reset(t)
+
+	r := &recorder{}
+	metrics.AddObserver(r)
+	defer metrics.RemoveObserver(r)
+
+	metrics.Counter("whee").Add()
+	metrics.Counter("whee").Add()
+	metrics.Gauge("woo").Set(1)
+	metrics.Gauge("woo").Set(2)
+	h := metrics.NewHistogram("heyo", 1, 1000, 3)
+	h.RecordValue(5)
+	metrics.FloatCounter("cost").Add(0.5)
+
+	// observers may use the package
+	_ = metrics.Capture()
+
+	metrics.Counter("whee").Remove()
+	metrics.Gauge("woo").Remove()
+	metrics.Gauge("nope").Remove()
+	h.Remove()
+	metrics.FloatCounter("cost").Remove()
+
+	want := []string{
+		"+counter whee",
+		"+gauge woo",
+		"+histogram heyo",
+		"+float counter cost",
+		"+gauge Metrics.SnapshotDuration",
+		"-counter whee",
+		"-gauge woo",
+		"-histogram heyo",
+		"-float counter cost",
+	}
+	if !reflect.DeepEqual(r.events, want) {
+		t.Errorf("Events were %v, but expected %v", r.events, want)
+	}
+}
+
+func TestUpdateObserver(t *testing.T) {
+	metricstest.Reset(t)
+
+	r := &updateRecorder{}
+	metrics.AddObserver(r)
+
+	metrics.Counter("whee").AddN(2)
+	metrics.Gauge("woo").Set(1)
+	metrics.NewHistogram("heyo", 1, 1000, 3).RecordValue(5)
+
+	metrics.RemoveObserver(r)
+	metrics.Counter("whee").Add()
+
+	want := []string{
+		"+counter whee",
+		"*counter whee",
+		"+gauge woo",
+		"*gauge woo",
+		"+histogram heyo",
+		"*histogram heyo",
+	}
+	if !reflect.DeepEqual(r.events, want) {
+		t.Errorf("Events were %v, but expected %v", r.events, want)
+	}
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// An Option configures the package's behavior with Init.
+type Option func()
+
+// Init applies the given options. It should be called once, as the service
+// starts and before any metrics are registered, since metrics registered
+// beforehand keep the names and intervals they were registered with. Settings
+// which are not given are left unchanged.
+//
+//	metrics.Init(
+//		metrics.WithPrefix("api."),
+//		metrics.WithRotationInterval(10*time.Second),
+//	)
+func Init(opts ...Option) {
+	for _, o := range opts {
+		o()
+	}
+}
+
+// WithPrefix prepends the given prefix to the names of all counters, gauges,
+// and histograms registered afterward, other than the package's own Metrics.*
+// metrics. The prefix is applied before the name validator, if any.
+func WithPrefix(prefix string) Option {
+	return func() {
+		vm.Lock()
+		defer vm.Unlock()
+
+		namePrefix = prefix
+		validated = make(map[string]string)
+		updateValidating()
+	}
+}
+
+// WithExpvar enables or disables the expvar under which the metrics are
+// published when the package is initialized (see Publish). Since expvars
+// cannot be removed, a disabled expvar remains published with a null value,
+// and snapshots are no longer taken when it is read.
+func WithExpvar(enabled bool) Option {
+	return func() {
+		if enabled {
+			atomic.StoreInt32(&expvarDisabled, 0)
+		} else {
+			atomic.StoreInt32(&expvarDisabled, 1)
+		}
+	}
+}
+
+// WithRotationInterval sets the interval used by histograms created afterward
+// whose options do not specify one. The default is one minute.
+func WithRotationInterval(d time.Duration) Option {
+	return func() {
+		if d <= 0 {
+			d = defaultRotationInterval
+		}
+		atomic.StoreInt64(&rotationInterval, int64(d))
+	}
+}
+
+// WithClock replaces the package's clock, as with SetClock.
+func WithClock(c Clock) Option {
+	return func() {
+		SetClock(c)
+	}
+}
+
+const defaultRotationInterval = 1 * time.Minute
+
+var rotationInterval = int64(defaultRotationInterval)
@@ -0,0 +1,137 @@
+package metrics_test
+
+import (
+	"expvar"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestInitPrefix(t *testing.T) {
+	metricstest.Reset(t)
+
+	metrics.Init(metrics.WithPrefix("api."))
+	defer metrics.Init(metrics.WithPrefix(""))
+
+	metrics.Counter("Requests").Add()
+	metrics.Counter("Metrics.Internal").Add()
+	metrics.Gauge("Conns").Set(3)
+
+	metricstest.AssertCounter(t, "api.Requests", 1)
+	metricstest.AssertCounter(t, "Metrics.Internal", 1)
+	metricstest.AssertGauge(t, "api.Conns", 3)
+
+	if v, want := metrics.Counter("Requests").Value(), uint64(1); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+}
+
+func TestInitRotationInterval(t *testing.T) {
+	metricstest.Reset(t)
+	clock := metricstest.UseFakeClock(t)
+
+	metrics.Init(metrics.WithRotationInterval(10 * time.Second))
+	defer metrics.Init(metrics.WithRotationInterval(0))
+
+	h := metrics.NewHistogram("Latency", 1, 1000, 3)
+	if err := h.RecordValue(100); err != nil {
+		t.Fatal(err)
+	}
+
+	// five ten-second windows
+	clock.Advance(50 * time.Second)
+
+	if v, want := h.TotalCount(), int64(0); v != want {
+		t.Errorf("Count was %v, but expected %v", v, want)
+	}
+}
+
+func TestInitExpvar(t *testing.T) {
+	metrics.Init(metrics.WithExpvar(false))
+	defer metrics.Init(metrics.WithExpvar(true))
+
+	if v, want := expvar.Get("metrics").String(), "null"; v != want {
+		t.Errorf("Expvar was %q, but expected %q", v, want)
+	}
+
+	metrics.Init(metrics.WithExpvar(true))
+
+	if v := expvar.Get("metrics").String(); v == "null" {
+		t.Error("Expvar was not re-enabled")
+	}
+}
+
+func TestInitPrefixQuantiles(t *testing.T) {
+	metricstest.Reset(t)
+
+	metrics.Init(metrics.WithPrefix("api."))
+	defer metrics.Init(metrics.WithPrefix(""))
+
+	h := metrics.NewHistogram("lat", 1, 1000, 3)
+	_ = h.RecordValue(10)
+	s := metrics.NewSketch("size", 0.01)
+	_ = s.RecordValue(5)
+
+	r := metrics.Capture()
+	if _, ok := r.Histograms["api.lat"]; !ok {
+		t.Errorf("Histograms were %v, but expected api.lat", r.Histograms)
+	}
+
+	for n := range r.Gauges {
+		if strings.HasPrefix(n, "api.lat") || strings.HasPrefix(n, "api.api.") {
+			t.Errorf("Unexpected gauge %q", n)
+		}
+	}
+
+	_, gauges := metrics.Snapshot()
+	for _, n := range []string{"api.lat.P50", "api.size.P50"} {
+		if _, ok := gauges[n]; !ok {
+			t.Errorf("Gauge %q does not exist", n)
+		}
+	}
+
+	h.Remove()
+	s.Remove()
+
+	_, gauges = metrics.Snapshot()
+	for n := range gauges {
+		if strings.HasPrefix(n, "api.") {
+			t.Errorf("Gauge %q was not removed", n)
+		}
+	}
+}
+
+func TestInitPrefixAllocs(t *testing.T) {
+	metricstest.Reset(t)
+
+	metrics.Init(metrics.WithPrefix("api."))
+	defer metrics.Init(metrics.WithPrefix(""))
+
+	metrics.Counter("whee").Add()
+	metrics.Gauge("woo").Set(1)
+
+	if n := testing.AllocsPerRun(100, func() {
+		metrics.Counter("whee").Add()
+		metrics.Gauge("woo").Set(2)
+	}); n != 0 {
+		t.Errorf("Add and Set made %v allocations, but expected none", n)
+	}
+
+	metricstest.AssertCounter(t, "api.whee", 102)
+}
+
+func TestInitPrefixRatio(t *testing.T) {
+	metricstest.Reset(t)
+
+	metrics.Init(metrics.WithPrefix("api."))
+	defer metrics.Init(metrics.WithPrefix(""))
+
+	metrics.Counter("Hits").AddN(3)
+	metrics.Gauge("Misses").Set(1)
+	metrics.Ratio("HitRate", 1000, "Hits", "Hits", "Misses")
+
+	metricstest.AssertGauge(t, "api.HitRate", 750)
+}
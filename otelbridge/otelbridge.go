@@ -0,0 +1,128 @@
+// Package otelbridge records the durations of OpenTelemetry spans with the
+// metrics package, giving latency metrics for operations which are already
+// traced:
+//
+//	tp := sdktrace.NewTracerProvider(
+//		sdktrace.WithSpanProcessor(otelbridge.NewSpanProcessor("Spans.")),
+//	)
+package otelbridge
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/codahale/metrics"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// A SpanProcessor is an OpenTelemetry span processor which, as each span ends,
+// records its duration, in microseconds, in the <prefix><span name>.Latency
+// histogram and counts it in the <prefix><span name>.Status.<status> counter,
+// where status is Unset, Ok, or Error.
+//
+// Because each span name has its own histogram, spans should be named after
+// operations (e.g., "GET /users/{id}") rather than include identifiers. Use
+// Name to rename or skip spans otherwise. If a histogram with the same name is
+// already registered (e.g., by the application or another processor with the
+// same prefix), the latencies of those spans are not recorded, even once that
+// histogram is removed.
+type SpanProcessor struct {
+	// Name, if not nil, returns the name under which the span is recorded,
+	// or an empty string if it should not be recorded.
+	Name func(s sdktrace.ReadOnlySpan) string
+
+	// MaxLatency is the largest duration tracked by the histograms. If zero,
+	// one hour is used.
+	MaxLatency time.Duration
+
+	prefix     string
+	histograms map[string]*metrics.Histogram // nil for names which collided
+	m          sync.Mutex
+}
+
+// NewSpanProcessor returns a span processor which records spans under the
+// given prefix.
+func NewSpanProcessor(prefix string) *SpanProcessor {
+	return &SpanProcessor{
+		prefix:     prefix,
+		histograms: make(map[string]*metrics.Histogram),
+	}
+}
+
+// OnStart does nothing.
+func (p *SpanProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {
+}
+
+// OnEnd records the span's duration and status.
+func (p *SpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	name := s.Name()
+	if p.Name != nil {
+		name = p.Name(s)
+	}
+	if name == "" {
+		return
+	}
+	name = p.prefix + name
+
+	if h, err := p.histogram(name); err == nil {
+		d := s.EndTime().Sub(s.StartTime())
+		_ = h.RecordValue(int64(d / time.Microsecond))
+	}
+	metrics.Counter(name + ".Status." + s.Status().Code.String()).Add()
+}
+
+// Shutdown removes the processor's histograms.
+func (p *SpanProcessor) Shutdown(context.Context) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	for n, h := range p.histograms {
+		if h != nil {
+			h.Remove()
+		}
+		delete(p.histograms, n)
+	}
+	return nil
+}
+
+// ForceFlush does nothing, since spans are recorded as they end.
+func (p *SpanProcessor) ForceFlush(context.Context) error {
+	return nil
+}
+
+// histogram returns the latency histogram for spans with the given name,
+// creating it if necessary.
+func (p *SpanProcessor) histogram(name string) (h *metrics.Histogram, err error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if h, ok := p.histograms[name]; ok {
+		if h == nil {
+			return nil, fmt.Errorf("otelbridge: %s.Latency already exists", name)
+		}
+		return h, nil
+	}
+
+	// NewHistogram panics if another histogram already has the name, in which
+	// case the failure is cached so that later spans don't retry it
+	defer func() {
+		if e := recover(); e != nil {
+			p.histograms[name] = nil
+			err = fmt.Errorf("otelbridge: %v", e)
+		}
+	}()
+
+	max := p.MaxLatency
+	if max <= 0 {
+		max = 1 * time.Hour
+	}
+
+	h = metrics.NewHistogram(name+".Latency", 1, int64(max/time.Microsecond), 3)
+	metrics.Describe(name+".Latency", metrics.Metadata{Unit: "microseconds"})
+	p.histograms[name] = h
+	return h, nil
+}
+
+var _ sdktrace.SpanProcessor = (*SpanProcessor)(nil)
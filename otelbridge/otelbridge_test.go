@@ -0,0 +1,108 @@
+package otelbridge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/codahale/metrics"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestSpanProcessor(t *testing.T) {
+	metrics.Reset()
+
+	p := NewSpanProcessor("Spans.")
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(p))
+	defer tp.Shutdown(context.Background())
+
+	tr := tp.Tracer("test")
+	for i := 0; i < 3; i++ {
+		_, span := tr.Start(context.Background(), "Fetch")
+		if i == 0 {
+			span.SetStatus(codes.Error, "timeout")
+		}
+		span.End()
+	}
+
+	counters, _ := metrics.Snapshot()
+
+	if v, want := counters["Spans.Fetch.Status.Error"], uint64(1); v != want {
+		t.Errorf("Errors were %v, but expected %v", v, want)
+	}
+
+	if v, want := counters["Spans.Fetch.Status.Unset"], uint64(2); v != want {
+		t.Errorf("Unset were %v, but expected %v", v, want)
+	}
+
+	if v, want := metrics.Capture().Histograms["Spans.Fetch.Latency"].Count, int64(3); v != want {
+		t.Errorf("Count was %v, but expected %v", v, want)
+	}
+}
+
+func TestSpanProcessorName(t *testing.T) {
+	metrics.Reset()
+
+	p := NewSpanProcessor("")
+	p.Name = func(s sdktrace.ReadOnlySpan) string {
+		if s.Name() == "skipped" {
+			return ""
+		}
+		return "Renamed"
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(p))
+	defer tp.Shutdown(context.Background())
+
+	tr := tp.Tracer("test")
+	for _, n := range []string{"kept", "skipped"} {
+		_, span := tr.Start(context.Background(), n)
+		span.End()
+	}
+
+	counters, _ := metrics.Snapshot()
+
+	if v, want := counters["Renamed.Status.Unset"], uint64(1); v != want {
+		t.Errorf("Renamed were %v, but expected %v", v, want)
+	}
+
+	if _, ok := counters["skipped.Status.Unset"]; ok {
+		t.Error("Skipped span was recorded")
+	}
+}
+
+func TestSpanProcessorCollision(t *testing.T) {
+	metrics.Reset()
+
+	app := metrics.NewHistogram("Spans.Fetch.Latency", 1, 1000, 3)
+	defer app.Remove()
+
+	p1, p2 := NewSpanProcessor("Spans."), NewSpanProcessor("Spans.")
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(p1), sdktrace.WithSpanProcessor(p2))
+	defer tp.Shutdown(context.Background())
+
+	tr := tp.Tracer("test")
+	for _, n := range []string{"Fetch", "Store"} {
+		_, span := tr.Start(context.Background(), n)
+		span.End()
+	}
+
+	counters, _ := metrics.Snapshot()
+
+	if v, want := counters["Spans.Fetch.Status.Unset"], uint64(2); v != want {
+		t.Errorf("Unset were %v, but expected %v", v, want)
+	}
+
+	if v, want := metrics.Capture().Histograms["Spans.Store.Latency"].Count, int64(1); v != want {
+		t.Errorf("Count was %v, but expected %v", v, want)
+	}
+
+	// the collision is remembered rather than retried for each span
+	app.Remove()
+
+	_, span := tr.Start(context.Background(), "Fetch")
+	span.End()
+
+	if _, ok := metrics.Capture().Histograms["Spans.Fetch.Latency"]; ok {
+		t.Error("Histogram was created after a collision")
+	}
+}
@@ -0,0 +1,44 @@
+package metrics
+
+// An Outcome records the successes and failures of an operation. It counts
+// them in the <name>.Successes and <name>.Failures counters, and publishes the
+// fraction of operations which failed, in thousandths, as the <name>.ErrorRate
+// gauge.
+type Outcome struct {
+	// Classify, if not nil, returns the type of a failure's error (e.g.,
+	// "Timeout"), which is counted in the <name>.Failures.<type> counter.
+	// Because each type is a separate counter, Classify should return one of a
+	// small, fixed set of types.
+	Classify func(err error) string
+
+	name string
+}
+
+// NewOutcome returns an outcome recorder with the given name.
+func NewOutcome(name string) *Outcome {
+	Ratio(name+".ErrorRate", 1000, name+".Failures", name+".Successes", name+".Failures")
+	return &Outcome{name: name}
+}
+
+// Success records a successful operation.
+func (o *Outcome) Success() {
+	Counter(o.name + ".Successes").Add()
+}
+
+// Failure records a failed operation.
+func (o *Outcome) Failure(err error) {
+	Counter(o.name + ".Failures").Add()
+	if o.Classify != nil {
+		Counter(o.name + ".Failures." + o.Classify(err)).Add()
+	}
+}
+
+// Record records a successful operation if err is nil, and a failed one
+// otherwise.
+func (o *Outcome) Record(err error) {
+	if err != nil {
+		o.Failure(err)
+	} else {
+		o.Success()
+	}
+}
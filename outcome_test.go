@@ -0,0 +1,44 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/codahale/metrics"
+)
+
+func TestOutcome(t *testing.T) {
+	metrics.Reset()
+
+	o := metrics.NewOutcome("Fetch")
+	o.Classify = func(err error) string {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return "Timeout"
+		}
+		return "Other"
+	}
+
+	o.Success()
+	o.Success()
+	o.Record(nil)
+	o.Failure(context.DeadlineExceeded)
+
+	counters, gauges := metrics.Snapshot()
+
+	if v, want := counters["Fetch.Successes"], uint64(3); v != want {
+		t.Errorf("Successes were %v, but expected %v", v, want)
+	}
+
+	if v, want := counters["Fetch.Failures"], uint64(1); v != want {
+		t.Errorf("Failures were %v, but expected %v", v, want)
+	}
+
+	if v, want := counters["Fetch.Failures.Timeout"], uint64(1); v != want {
+		t.Errorf("Timeouts were %v, but expected %v", v, want)
+	}
+
+	if v, want := gauges["Fetch.ErrorRate"], int64(250); v != want {
+		t.Errorf("Error rate was %v, but expected %v", v, want)
+	}
+}
@@ -0,0 +1,283 @@
+// Package perfcounter publishes selected metrics as native Windows Performance
+// Counters, so they can be monitored with perfmon, SCOM, and other tools which
+// read counters through PDH.
+//
+// Windows requires counters to be described by a manifest, which must be
+// installed with lodctr before the service starts:
+//
+//	cs := perfcounter.CounterSet{
+//		Name:           "My Service",
+//		ProviderGUID:   "{5c6e0c4c-6b42-4f5b-9f43-0a0d1c8f5b11}",
+//		CounterSetGUID: "{a7e1b2d3-24c9-4a7e-8d2e-3b1f6c9d0e42}",
+//		Counters:       []string{"HTTP.Requests"},
+//		Gauges:         []string{"HTTP.InFlight"},
+//	}
+//	cs.WriteManifest(f, `C:\Program Files\My Service\service.exe`)
+//
+//	lodctr /m:service.man
+//
+// The service then publishes the metrics' values periodically:
+//
+//	p, err := perfcounter.Publish(cs, 10*time.Second)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer p.Stop()
+//
+// Counters are published as rates (PERF_COUNTER_BULK_COUNT) and gauges as raw
+// values (PERF_COUNTER_LARGE_RAWCOUNT). On platforms other than Windows,
+// Publish returns ErrUnsupported.
+package perfcounter
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codahale/metrics"
+)
+
+// ErrUnsupported is returned on platforms other than Windows.
+var ErrUnsupported = errors.New("perfcounter: unsupported platform")
+
+// A CounterSet describes the metrics published as performance counters.
+type CounterSet struct {
+	Name           string   // the name of the counter set, as shown by perfmon
+	Description    string   // a description of the counter set
+	ProviderGUID   string   // a GUID identifying the provider (e.g., "{...}")
+	CounterSetGUID string   // a GUID identifying the counter set
+	Counters       []string // the names of the counters to publish
+	Gauges         []string // the names of the gauges to publish
+}
+
+// WriteManifest writes an instrumentation manifest describing the counter set,
+// to be installed with lodctr, for the executable at the given path.
+func (cs CounterSet) WriteManifest(w io.Writer, exe string) error {
+	set := xmlCounterSet{
+		GUID:        cs.CounterSetGUID,
+		URI:         uri(cs.Name),
+		Name:        cs.Name,
+		Description: cs.Description,
+		Instances:   "single",
+	}
+
+	if set.Description == "" {
+		set.Description = cs.Name
+	}
+
+	for i, n := range cs.names() {
+		typ := "perf_counter_large_rawcount"
+		if i < len(cs.Counters) {
+			typ = "perf_counter_bulk_count"
+		}
+
+		set.Counters = append(set.Counters, xmlCounter{
+			ID:          i,
+			URI:         uri(cs.Name) + "." + uri(n),
+			Name:        n,
+			Description: n,
+			Type:        typ,
+			DetailLevel: "standard",
+		})
+	}
+
+	m := xmlManifest{
+		XMLNS: "http://schemas.microsoft.com/win/2004/08/events",
+		Counters: xmlCounters{
+			XMLNS:         "http://schemas.microsoft.com/win/2005/12/counters",
+			SchemaVersion: "2.0",
+			Provider: xmlProvider{
+				Name:     cs.Name,
+				GUID:     cs.ProviderGUID,
+				Identity: exe,
+				Type:     "userMode",
+				Set:      set,
+			},
+		},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(m); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// names returns the names of the counter set's metrics, in the order of their
+// counter IDs.
+func (cs CounterSet) names() []string {
+	return append(append([]string(nil), cs.Counters...), cs.Gauges...)
+}
+
+// A Publisher periodically publishes the values of a counter set's metrics.
+type Publisher struct {
+	cs   CounterSet
+	p    *provider
+	stop chan struct{}
+	once sync.Once
+}
+
+// Publish registers the counter set with Windows and publishes its metrics'
+// values every interval until the publisher is stopped. Errors publishing
+// values are counted by the Metrics.ExportErrors counter.
+func Publish(cs CounterSet, interval time.Duration) (*Publisher, error) {
+	p, err := open(cs)
+	if err != nil {
+		return nil, err
+	}
+
+	pub := &Publisher{cs: cs, p: p, stop: make(chan struct{})}
+	if err := pub.Update(); err != nil {
+		p.close()
+		return nil, err
+	}
+
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-t.C:
+				if err := pub.Update(); err != nil {
+					metrics.Counter("Metrics.ExportErrors").Add()
+				}
+			case <-pub.stop:
+				return
+			}
+		}
+	}()
+
+	return pub, nil
+}
+
+// Update publishes the current values of the counter set's metrics. Negative
+// gauge values are published as zero.
+func (pub *Publisher) Update() error {
+	counters, gauges := metrics.Snapshot()
+
+	for i, n := range pub.cs.Counters {
+		if err := pub.p.set(uint32(i), counters[n]); err != nil {
+			return fmt.Errorf("perfcounter: %s: %w", n, err)
+		}
+	}
+
+	for i, n := range pub.cs.Gauges {
+		v := gauges[n]
+		if v < 0 {
+			v = 0
+		}
+
+		if err := pub.p.set(uint32(len(pub.cs.Counters)+i), uint64(v)); err != nil {
+			return fmt.Errorf("perfcounter: %s: %w", n, err)
+		}
+	}
+
+	return nil
+}
+
+// Stop stops publishing values and unregisters the counter set.
+func (pub *Publisher) Stop() {
+	pub.once.Do(func() {
+		close(pub.stop)
+		pub.p.close()
+	})
+}
+
+// guid is the Windows GUID structure.
+type guid struct {
+	Data1 uint32
+	Data2 uint16
+	Data3 uint16
+	Data4 [8]byte
+}
+
+// parseGUID parses a GUID in the registry format (e.g.,
+// "{5c6e0c4c-6b42-4f5b-9f43-0a0d1c8f5b11}"), with or without braces.
+func parseGUID(s string) (g guid, err error) {
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "{"), "}")
+	parts := strings.Split(s, "-")
+	if len(parts) != 5 || len(parts[0]) != 8 || len(parts[1]) != 4 ||
+		len(parts[2]) != 4 || len(parts[3]) != 4 || len(parts[4]) != 12 {
+		return g, fmt.Errorf("perfcounter: invalid GUID %q", s)
+	}
+
+	n := func(s string, bits int) uint64 {
+		v, e := strconv.ParseUint(s, 16, bits)
+		if e != nil && err == nil {
+			err = fmt.Errorf("perfcounter: invalid GUID %q", s)
+		}
+		return v
+	}
+
+	g.Data1 = uint32(n(parts[0], 32))
+	g.Data2 = uint16(n(parts[1], 16))
+	g.Data3 = uint16(n(parts[2], 16))
+
+	rest := parts[3] + parts[4]
+	for i := range g.Data4 {
+		g.Data4[i] = byte(n(rest[2*i:2*i+2], 8))
+	}
+
+	return g, err
+}
+
+// uri converts a name to the dotted identifier used for URIs in manifests.
+func uri(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '.' || r == '_' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+type xmlManifest struct {
+	XMLName  xml.Name    `xml:"instrumentationManifest"`
+	XMLNS    string      `xml:"xmlns,attr"`
+	Counters xmlCounters `xml:"instrumentation>counters"`
+}
+
+type xmlCounters struct {
+	XMLNS         string      `xml:"xmlns,attr"`
+	SchemaVersion string      `xml:"schemaVersion,attr"`
+	Provider      xmlProvider `xml:"provider"`
+}
+
+type xmlProvider struct {
+	Name     string        `xml:"providerName,attr"`
+	GUID     string        `xml:"providerGuid,attr"`
+	Identity string        `xml:"applicationIdentity,attr"`
+	Type     string        `xml:"providerType,attr"`
+	Set      xmlCounterSet `xml:"counterSet"`
+}
+
+type xmlCounterSet struct {
+	GUID        string       `xml:"guid,attr"`
+	URI         string       `xml:"uri,attr"`
+	Name        string       `xml:"name,attr"`
+	Description string       `xml:"description,attr"`
+	Instances   string       `xml:"instances,attr"`
+	Counters    []xmlCounter `xml:"counter"`
+}
+
+type xmlCounter struct {
+	ID          int    `xml:"id,attr"`
+	URI         string `xml:"uri,attr"`
+	Name        string `xml:"name,attr"`
+	Description string `xml:"description,attr"`
+	Type        string `xml:"type,attr"`
+	DetailLevel string `xml:"detailLevel,attr"`
+}
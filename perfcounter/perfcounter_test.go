@@ -0,0 +1,56 @@
+package perfcounter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteManifest(t *testing.T) {
+	cs := CounterSet{
+		Name:           "My Service",
+		ProviderGUID:   "{5c6e0c4c-6b42-4f5b-9f43-0a0d1c8f5b11}",
+		CounterSetGUID: "{a7e1b2d3-24c9-4a7e-8d2e-3b1f6c9d0e42}",
+		Counters:       []string{"HTTP.Requests"},
+		Gauges:         []string{"HTTP.InFlight"},
+	}
+
+	var buf bytes.Buffer
+	if err := cs.WriteManifest(&buf, `C:\svc\service.exe`); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{
+		`<provider providerName="My Service" providerGuid="{5c6e0c4c-6b42-4f5b-9f43-0a0d1c8f5b11}" applicationIdentity="C:\svc\service.exe" providerType="userMode">`,
+		`<counterSet guid="{a7e1b2d3-24c9-4a7e-8d2e-3b1f6c9d0e42}" uri="My_Service" name="My Service" description="My Service" instances="single">`,
+		`<counter id="0" uri="My_Service.HTTP.Requests" name="HTTP.Requests" description="HTTP.Requests" type="perf_counter_bulk_count" detailLevel="standard"></counter>`,
+		`<counter id="1" uri="My_Service.HTTP.InFlight" name="HTTP.InFlight" description="HTTP.InFlight" type="perf_counter_large_rawcount" detailLevel="standard"></counter>`,
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("Manifest was missing %s:\n%s", s, buf.String())
+		}
+	}
+}
+
+func TestParseGUID(t *testing.T) {
+	g, err := parseGUID("{5c6e0c4c-6b42-4f5b-9f43-0a0d1c8f5b11}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := guid{
+		Data1: 0x5c6e0c4c,
+		Data2: 0x6b42,
+		Data3: 0x4f5b,
+		Data4: [8]byte{0x9f, 0x43, 0x0a, 0x0d, 0x1c, 0x8f, 0x5b, 0x11},
+	}
+	if g != want {
+		t.Errorf("GUID was %+v, but expected %+v", g, want)
+	}
+
+	for _, s := range []string{"", "{5c6e0c4c-6b42-4f5b-9f43}", "5c6e0c4c-6b42-4f5b-9f43-0a0d1c8f5bzz"} {
+		if _, err := parseGUID(s); err == nil {
+			t.Errorf("GUID %q was parsed", s)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package perfcounter
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const (
+	perfCounterBulkCount         = 0x10110500 // PERF_COUNTER_BULK_COUNT
+	perfCounterLargeRawCount     = 0x00010100 // PERF_COUNTER_LARGE_RAWCOUNT
+	perfDetailNovice             = 100        // PERF_DETAIL_NOVICE
+	perfCountersetSingleInstance = 0          // PERF_COUNTERSET_SINGLE_INSTANCE
+)
+
+var (
+	advapi32 = syscall.NewLazyDLL("advapi32.dll")
+
+	procPerfStartProvider            = advapi32.NewProc("PerfStartProvider")
+	procPerfStopProvider             = advapi32.NewProc("PerfStopProvider")
+	procPerfSetCounterSetInfo        = advapi32.NewProc("PerfSetCounterSetInfo")
+	procPerfCreateInstance           = advapi32.NewProc("PerfCreateInstance")
+	procPerfDeleteInstance           = advapi32.NewProc("PerfDeleteInstance")
+	procPerfSetULongLongCounterValue = advapi32.NewProc("PerfSetULongLongCounterValue")
+)
+
+// counterSetInfo is PERF_COUNTERSET_INFO.
+type counterSetInfo struct {
+	CounterSetGUID guid
+	ProviderGUID   guid
+	NumCounters    uint32
+	InstanceType   uint32
+}
+
+// counterInfo is PERF_COUNTER_INFO.
+type counterInfo struct {
+	CounterID   uint32
+	Type        uint32
+	Attrib      uint64
+	Size        uint32
+	DetailLevel uint32
+	Scale       int32
+	Offset      uint32
+}
+
+type provider struct {
+	h        uintptr
+	instance uintptr
+}
+
+func open(cs CounterSet) (*provider, error) {
+	providerGUID, err := parseGUID(cs.ProviderGUID)
+	if err != nil {
+		return nil, err
+	}
+
+	setGUID, err := parseGUID(cs.CounterSetGUID)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &provider{}
+	if r, _, _ := procPerfStartProvider.Call(
+		uintptr(unsafe.Pointer(&providerGUID)), 0, uintptr(unsafe.Pointer(&p.h)),
+	); r != 0 {
+		return nil, syscall.Errno(r)
+	}
+
+	// the template is a PERF_COUNTERSET_INFO followed by a PERF_COUNTER_INFO
+	// for each counter
+	names := cs.names()
+	hdr := unsafe.Sizeof(counterSetInfo{})
+	template := make([]byte, hdr+uintptr(len(names))*unsafe.Sizeof(counterInfo{}))
+
+	*(*counterSetInfo)(unsafe.Pointer(&template[0])) = counterSetInfo{
+		CounterSetGUID: setGUID,
+		ProviderGUID:   providerGUID,
+		NumCounters:    uint32(len(names)),
+		InstanceType:   perfCountersetSingleInstance,
+	}
+
+	for i := range names {
+		typ := uint32(perfCounterLargeRawCount)
+		if i < len(cs.Counters) {
+			typ = perfCounterBulkCount
+		}
+
+		off := hdr + uintptr(i)*unsafe.Sizeof(counterInfo{})
+		*(*counterInfo)(unsafe.Pointer(&template[off])) = counterInfo{
+			CounterID:   uint32(i),
+			Type:        typ,
+			Size:        8,
+			DetailLevel: perfDetailNovice,
+			Offset:      uint32(i * 8),
+		}
+	}
+
+	if r, _, _ := procPerfSetCounterSetInfo.Call(
+		p.h, uintptr(unsafe.Pointer(&template[0])), uintptr(len(template)),
+	); r != 0 {
+		p.close()
+		return nil, syscall.Errno(r)
+	}
+
+	name, err := syscall.UTF16PtrFromString("_Default")
+	if err != nil {
+		p.close()
+		return nil, err
+	}
+
+	p.instance, _, err = procPerfCreateInstance.Call(
+		p.h, uintptr(unsafe.Pointer(&setGUID)), uintptr(unsafe.Pointer(name)), 0,
+	)
+	if p.instance == 0 {
+		p.close()
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *provider) set(id uint32, v uint64) error {
+	var r uintptr
+	if unsafe.Sizeof(uintptr(0)) == 4 {
+		// a ULONGLONG argument occupies two words on 32-bit platforms
+		r, _, _ = procPerfSetULongLongCounterValue.Call(
+			p.h, p.instance, uintptr(id), uintptr(uint32(v)), uintptr(v>>32),
+		)
+	} else {
+		r, _, _ = procPerfSetULongLongCounterValue.Call(
+			p.h, p.instance, uintptr(id), uintptr(v),
+		)
+	}
+
+	if r != 0 {
+		return syscall.Errno(r)
+	}
+	return nil
+}
+
+func (p *provider) close() {
+	if p.instance != 0 {
+		_, _, _ = procPerfDeleteInstance.Call(p.h, p.instance)
+		p.instance = 0
+	}
+	_, _, _ = procPerfStopProvider.Call(p.h)
+}
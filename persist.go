@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/codahale/hdrhistogram"
+)
+
+// A Checkpointer saves the values of counters to a file and restores them, so
+// that counters such as the total number of requests served survive restarts.
+// Counters set with SetFunc or SetBatchFunc are not saved.
+type Checkpointer struct {
+	// Path is the checkpoint file, which is replaced atomically on each save.
+	Path string
+
+	// Histograms, if true, also saves the recorded values of all histograms in
+	// compressed HDR form. On restore, they are recorded into the current
+	// window of histograms with the same names, which must already exist.
+	Histograms bool
+}
+
+type checkpoint struct {
+	Counters   map[string]uint64
+	Histograms map[string]*hdrhistogram.Snapshot `json:",omitempty"`
+}
+
+// Save writes the current values of all counters (and histograms, if enabled)
+// to the checkpoint file.
+func (c Checkpointer) Save() error {
+	cp := checkpoint{Counters: make(map[string]uint64)}
+
+	cm.RLock()
+	for n, v := range counters {
+		cp.Counters[n] = atomic.LoadUint64(v)
+	}
+	cm.RUnlock()
+
+	if c.Histograms {
+		cp.Histograms = make(map[string]*hdrhistogram.Snapshot)
+
+		hm.RLock()
+		for n, h := range histograms {
+			h.query(func(m *hdrhistogram.Histogram) {
+				cp.Histograms[n] = m.Export()
+			})
+		}
+		hm.RUnlock()
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(c.Path), filepath.Base(c.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	gz := gzip.NewWriter(f)
+	if err := json.NewEncoder(gz).Encode(cp); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := gz.Close(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), c.Path)
+}
+
+// Restore adds the values in the checkpoint file to the corresponding counters
+// (and histograms, if enabled). It returns nil if the file does not exist.
+func (c Checkpointer) Restore() error {
+	f, err := os.Open(c.Path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+
+	var cp checkpoint
+	if err := json.NewDecoder(gz).Decode(&cp); err != nil {
+		return err
+	}
+
+	for n, v := range cp.Counters {
+		_ = addCounter(n, v)
+	}
+
+	if c.Histograms {
+		hm.RLock()
+		defer hm.RUnlock()
+
+		for n, s := range cp.Histograms {
+			if h, ok := histograms[n]; ok {
+				h.restore(hdrhistogram.Import(s))
+			}
+		}
+	}
+
+	return nil
+}
+
+// SaveEvery saves a checkpoint once per the given interval until the returned
+// timer is stopped. Errors are counted by the Metrics.CheckpointErrors counter.
+func (c Checkpointer) SaveEvery(d time.Duration) Timer {
+	return flushEvery(d, "Metrics.CheckpointErrors", c.Save)
+}
+
+func (h *Histogram) restore(m *hdrhistogram.Histogram) {
+	h.rw.Lock()
+	defer h.rw.Unlock()
+
+	h.hist.restore(m)
+}
@@ -0,0 +1,83 @@
+package metrics_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codahale/metrics"
+)
+
+func TestCheckpointer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := metrics.Checkpointer{
+		Path:       filepath.Join(dir, "checkpoint"),
+		Histograms: true,
+	}
+
+	metrics.Reset()
+	metrics.Counter("whee").AddN(10)
+	h := metrics.NewHistogram("heyo", 1, 1000, 3)
+	h.RecordValue(50)
+
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics.Reset()
+	metrics.Counter("whee").AddN(1)
+	h = metrics.NewHistogram("heyo", 1, 1000, 3)
+
+	if err := c.Restore(); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := metrics.Counter("whee").Value(), uint64(11); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	if v, want := h.Max(), int64(50); v != want {
+		t.Errorf("Max was %v, but expected %v", v, want)
+	}
+}
+
+func TestCheckpointerMissingFile(t *testing.T) {
+	c := metrics.Checkpointer{Path: filepath.Join(os.TempDir(), "does-not-exist")}
+	if err := c.Restore(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCheckpointerPrefix(t *testing.T) {
+	metrics.Init(metrics.WithPrefix("api."))
+	defer metrics.Init(metrics.WithPrefix(""))
+
+	c := metrics.Checkpointer{Path: filepath.Join(t.TempDir(), "checkpoint")}
+
+	metrics.Reset()
+	metrics.Counter("req").AddN(10)
+
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics.Reset()
+	if err := c.Restore(); err != nil {
+		t.Fatal(err)
+	}
+
+	counters, _ := metrics.Snapshot()
+	if v, want := counters["api.req"], uint64(10); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	if v, ok := counters["api.api.req"]; ok {
+		t.Errorf("Counter was restored as api.api.req (%v)", v)
+	}
+}
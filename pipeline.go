@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"path"
+	"regexp"
+	"time"
+)
+
+// A Filter maps the name of a metric to the name under which it should be
+// exported, or returns false if the metric should not be exported at all.
+type Filter func(name string) (string, bool)
+
+// A Pipeline is a chain of filters, plus a set of static tags, applied to
+// reports at export time. Pipelines allow an exporter to suppress or rename
+// metrics without changing the code which records them.
+//
+//	h := metrics.Handler{
+//		Pipeline: metrics.Pipeline{
+//			Filters: []metrics.Filter{
+//				metrics.Exclude("Debug.*"),
+//				metrics.Rename("HTTP.Requests", "http.requests"),
+//			},
+//			Tags: map[string]string{"region": "us-east-1"},
+//		},
+//	}
+type Pipeline struct {
+	Filters []Filter          // applied in order to each metric name
+	Tags    map[string]string // added to each report's tags
+}
+
+// Name applies the pipeline's filters to a metric name, returning the name
+// under which it should be exported, or false if it should be dropped.
+func (p Pipeline) Name(name string) (string, bool) {
+	for _, f := range p.Filters {
+		var ok bool
+		if name, ok = f(name); !ok {
+			return "", false
+		}
+	}
+	return name, true
+}
+
+// Apply returns a copy of the report with the pipeline's filters applied to
+// the names of its counters, float counters, gauges, and histograms, and the pipeline's tags
+// added to its tags.
+func (p Pipeline) Apply(r Report) Report {
+	out := Report{
+		Time:          r.Time,
+		Counters:      make(map[string]uint64, len(r.Counters)),
+		FloatCounters: make(map[string]float64, len(r.FloatCounters)),
+		Gauges:        make(map[string]int64, len(r.Gauges)),
+		Histograms:    make(map[string]HistogramSummary, len(r.Histograms)),
+		Tags:          make(map[string]string, len(r.Tags)+len(p.Tags)),
+	}
+
+	for n, v := range r.Counters {
+		if n, ok := p.Name(n); ok {
+			out.Counters[n] = v
+		}
+	}
+
+	for n, v := range r.FloatCounters {
+		if n, ok := p.Name(n); ok {
+			out.FloatCounters[n] = v
+		}
+	}
+
+	for n, v := range r.Gauges {
+		if n, ok := p.Name(n); ok {
+			out.Gauges[n] = v
+		}
+	}
+
+	for n, s := range r.Histograms {
+		if n, ok := p.Name(n); ok {
+			out.Histograms[n] = s
+		}
+	}
+
+	for k, v := range r.Tags {
+		out.Tags[k] = v
+	}
+
+	for k, v := range p.Tags {
+		out.Tags[k] = v
+	}
+
+	return out
+}
+
+// metadata returns the metadata and creation times of the given metrics,
+// keyed by their filtered names.
+func (p Pipeline) metadata(md map[string]Metadata, created map[string]time.Time) (map[string]Metadata, map[string]time.Time) {
+	if len(p.Filters) == 0 {
+		return md, created
+	}
+
+	fmd := make(map[string]Metadata, len(md))
+	for n, m := range md {
+		if n, ok := p.Name(n); ok {
+			fmd[n] = m
+		}
+	}
+
+	fcreated := make(map[string]time.Time, len(created))
+	for n, t := range created {
+		if n, ok := p.Name(n); ok {
+			fcreated[n] = t
+		}
+	}
+
+	return fmd, fcreated
+}
+
+// Include returns a filter which drops metrics whose names match none of the
+// given glob patterns (e.g., "HTTP.*"), as interpreted by path.Match.
+func Include(patterns ...string) Filter {
+	return func(name string) (string, bool) {
+		return name, globMatch(patterns, name)
+	}
+}
+
+// Exclude returns a filter which drops metrics whose names match any of the
+// given glob patterns, as interpreted by path.Match.
+func Exclude(patterns ...string) Filter {
+	return func(name string) (string, bool) {
+		return name, !globMatch(patterns, name)
+	}
+}
+
+// IncludeRegexp returns a filter which drops metrics whose names do not match
+// the given regular expression.
+func IncludeRegexp(re *regexp.Regexp) Filter {
+	return func(name string) (string, bool) {
+		return name, re.MatchString(name)
+	}
+}
+
+// ExcludeRegexp returns a filter which drops metrics whose names match the
+// given regular expression.
+func ExcludeRegexp(re *regexp.Regexp) Filter {
+	return func(name string) (string, bool) {
+		return name, !re.MatchString(name)
+	}
+}
+
+// Rename returns a filter which exports the metric with the name old under the
+// name new.
+func Rename(old, new string) Filter {
+	return func(name string) (string, bool) {
+		if name == old {
+			return new, true
+		}
+		return name, true
+	}
+}
+
+// RenameRegexp returns a filter which replaces matches of the given regular
+// expression in metric names with the replacement text, as with
+// regexp.ReplaceAllString.
+func RenameRegexp(re *regexp.Regexp, repl string) Filter {
+	return func(name string) (string, bool) {
+		return re.ReplaceAllString(name, repl), true
+	}
+}
+
+func globMatch(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
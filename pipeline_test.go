@@ -0,0 +1,72 @@
+package metrics_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/codahale/metrics"
+)
+
+func TestPipelineApply(t *testing.T) {
+	p := metrics.Pipeline{
+		Filters: []metrics.Filter{
+			metrics.Exclude("Debug.*"),
+			metrics.ExcludeRegexp(regexp.MustCompile(`\.User\.\d+$`)),
+			metrics.Rename("HTTP.Requests", "http.requests"),
+			metrics.RenameRegexp(regexp.MustCompile(`^Conns`), "connections"),
+		},
+		Tags: map[string]string{"region": "us-east-1"},
+	}
+
+	r := p.Apply(metrics.Report{
+		Counters: map[string]uint64{
+			"HTTP.Requests":     3,
+			"Debug.Allocs":      10,
+			"Logins.User.12345": 1,
+		},
+		Gauges: map[string]int64{
+			"Conns.Open": 2,
+		},
+		Tags: map[string]string{"host": "a"},
+	})
+
+	if v, want := len(r.Counters), 1; v != want {
+		t.Errorf("Counter count was %v, but expected %v: %v", v, want, r.Counters)
+	}
+
+	if v, want := r.Counters["http.requests"], uint64(3); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	if v, want := r.Gauges["connections.Open"], int64(2); v != want {
+		t.Errorf("Gauge was %v, but expected %v", v, want)
+	}
+
+	if v, want := r.Tags["region"], "us-east-1"; v != want {
+		t.Errorf("Tag was %q, but expected %q", v, want)
+	}
+
+	if v, want := r.Tags["host"], "a"; v != want {
+		t.Errorf("Tag was %q, but expected %q", v, want)
+	}
+}
+
+func TestPipelineInclude(t *testing.T) {
+	p := metrics.Pipeline{
+		Filters: []metrics.Filter{
+			metrics.Include("HTTP.*", "Runtime.*"),
+			metrics.IncludeRegexp(regexp.MustCompile(`s$`)),
+		},
+	}
+
+	for name, want := range map[string]bool{
+		"HTTP.Requests": true,
+		"HTTP.Latency":  false,
+		"Runtime.Procs": true,
+		"Debug.Allocs":  false,
+	} {
+		if _, v := p.Name(name); v != want {
+			t.Errorf("Inclusion of %q was %v, but expected %v", name, v, want)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// Package prombridge connects the metrics package with the Prometheus client
+// library, so that services migrating between the two can expose a single,
+// coherent set of metrics.
+//
+// To expose this package's metrics via a Prometheus registry:
+//
+//	prometheus.MustRegister(prombridge.NewCollector())
+//
+// To publish metrics from Prometheus collectors via this package:
+//
+//	reg := prometheus.NewRegistry()
+//	reg.MustRegister(collectors.NewBuildInfoCollector())
+//	prombridge.Mirror(reg, "Prometheus.")
+package prombridge
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/codahale/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// A Collector is a prometheus.Collector which collects all counters, float
+// counters, gauges, and histograms registered with the metrics package.
+// Counters and float counters are collected as Prometheus counters, gauges as
+// gauges, and histograms as summaries or, if Buckets is true, as histograms
+// with cumulative power-of-two buckets.
+//
+// Because the set of metrics changes over time, a Collector is an unchecked
+// collector and describes no metrics in advance.
+type Collector struct {
+	// Namespace, if not empty, is prepended to each metric's name.
+	Namespace string
+
+	// Buckets, if true, collects histograms as Prometheus histograms, which can
+	// be aggregated across processes, rather than as summaries.
+	Buckets bool
+
+	// Pipeline is applied to each report before it is collected.
+	Pipeline metrics.Pipeline
+}
+
+// NewCollector returns a new Collector with no namespace.
+func NewCollector() *Collector {
+	return &Collector{}
+}
+
+// Describe sends no descriptors, making the collector unchecked.
+func (c *Collector) Describe(chan<- *prometheus.Desc) {
+}
+
+// Collect sends the current value of every metric.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	r := c.Pipeline.Apply(metrics.Capture())
+
+	for n, v := range r.Counters {
+		desc := prometheus.NewDesc(c.name(n), n, nil, nil)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(v))
+	}
+
+	for n, v := range r.FloatCounters {
+		desc := prometheus.NewDesc(c.name(n), n, nil, nil)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, v)
+	}
+
+	for n, v := range r.Gauges {
+		desc := prometheus.NewDesc(c.name(n), n, nil, nil)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(v))
+	}
+
+	for n, s := range r.Histograms {
+		desc := prometheus.NewDesc(c.name(n), n, nil, nil)
+
+		if c.Buckets {
+			buckets := make(map[float64]uint64, len(s.Buckets))
+			for _, b := range s.Buckets {
+				buckets[float64(b.UpperBound)] = uint64(b.Count)
+			}
+			ch <- prometheus.MustNewConstHistogram(desc, uint64(s.Count), s.Mean*float64(s.Count), buckets)
+			continue
+		}
+
+		quantiles := make(map[float64]float64, len(s.Quantiles))
+		for q, v := range s.Quantiles {
+			quantiles[math.Round(q*1e4)/1e6] = float64(v)
+		}
+		ch <- prometheus.MustNewConstSummary(desc, uint64(s.Count), s.Mean*float64(s.Count), quantiles)
+	}
+}
+
+func (c *Collector) name(n string) string {
+	if c.Namespace != "" {
+		n = c.Namespace + "_" + n
+	}
+	return metrics.PrometheusName(n)
+}
+
+// Mirror publishes the metrics gathered from the given Prometheus gatherer
+// (e.g., a *prometheus.Registry) via the metrics package, with the given prefix
+// prepended to their names. The gatherer is consulted on each snapshot, and
+// metrics which appear later are published as they appear.
+//
+// Counters are published as counters, and gauges and untyped metrics as gauges.
+// Summaries and histograms are published as counters of their sample counts
+// and sums, suffixed with ".Count" and ".Sum". Each label is appended to the
+// metric's name as ".name=value". Values are truncated to integers.
+func Mirror(g prometheus.Gatherer, prefix string) {
+	m := &mirror{
+		g:        g,
+		prefix:   prefix,
+		counters: make(map[string]uint64),
+		gauges:   make(map[string]int64),
+	}
+
+	metrics.Counter(prefix+"Gathers").SetBatchFunc(m, m.gather, m.count)
+}
+
+// MirrorCollectors registers the given collectors with a new Prometheus
+// registry and mirrors it as with Mirror.
+func MirrorCollectors(prefix string, cs ...prometheus.Collector) error {
+	reg := prometheus.NewRegistry()
+	for _, c := range cs {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+
+	Mirror(reg, prefix)
+	return nil
+}
+
+type mirror struct {
+	g      prometheus.Gatherer
+	prefix string
+
+	m        sync.Mutex
+	gathers  uint64
+	counters map[string]uint64
+	gauges   map[string]int64
+}
+
+func (m *mirror) gather() {
+	families, err := m.g.Gather()
+	if err != nil && len(families) == 0 {
+		return
+	}
+
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	m.gathers++
+
+	for _, mf := range families {
+		for _, pm := range mf.GetMetric() {
+			n := m.prefix + mf.GetName() + labels(pm.GetLabel())
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				m.counter(n, pm.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				m.gauge(n, pm.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				m.gauge(n, pm.GetUntyped().GetValue())
+			case dto.MetricType_SUMMARY:
+				m.counter(n+".Count", float64(pm.GetSummary().GetSampleCount()))
+				m.counter(n+".Sum", pm.GetSummary().GetSampleSum())
+			case dto.MetricType_HISTOGRAM:
+				m.counter(n+".Count", float64(pm.GetHistogram().GetSampleCount()))
+				m.counter(n+".Sum", pm.GetHistogram().GetSampleSum())
+			}
+		}
+	}
+}
+
+func (m *mirror) count() uint64 {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	return m.gathers
+}
+
+// counter records a counter's value, registering it if it is new. It must be
+// called with m.m held.
+func (m *mirror) counter(n string, v float64) {
+	if _, ok := m.counters[n]; !ok {
+		metrics.Counter(n).SetFunc(func() uint64 {
+			m.m.Lock()
+			defer m.m.Unlock()
+
+			return m.counters[n]
+		})
+	}
+	m.counters[n] = uint64(v)
+}
+
+// gauge records a gauge's value, registering it if it is new. It must be called
+// with m.m held.
+func (m *mirror) gauge(n string, v float64) {
+	if _, ok := m.gauges[n]; !ok {
+		metrics.Gauge(n).SetFunc(func() int64 {
+			m.m.Lock()
+			defer m.m.Unlock()
+
+			return m.gauges[n]
+		})
+	}
+	m.gauges[n] = int64(v)
+}
+
+func labels(pairs []*dto.LabelPair) string {
+	s := make([]string, 0, len(pairs))
+	for _, p := range pairs {
+		s = append(s, "."+p.GetName()+"="+p.GetValue())
+	}
+	sort.Strings(s)
+	return strings.Join(s, "")
+}
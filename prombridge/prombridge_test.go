@@ -0,0 +1,40 @@
+package prombridge
+
+import (
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	metrics.Reset()
+	metrics.Counter("Requests.Total").AddN(3)
+	metrics.FloatCounter("Bytes").Add(1.5)
+	metrics.Gauge("Conns").Set(2)
+
+	c := NewCollector()
+
+	if v, want := testutil.CollectAndCount(c), 3; v != want {
+		t.Errorf("Metric count was %v, but expected %v", v, want)
+	}
+}
+
+func TestMirror(t *testing.T) {
+	metrics.Reset()
+
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+	}, []string{"code"})
+	requests.WithLabelValues("200").Add(5)
+
+	if err := MirrorCollectors("Prom.", requests); err != nil {
+		t.Fatal(err)
+	}
+
+	counters, _ := metrics.Snapshot()
+	if v, want := counters["Prom.http_requests_total.code=200"], uint64(5); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+}
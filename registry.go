@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// A Registry is a namespace of metrics belonging to a single tenant or
+// component. Its metrics are registered globally under names prefixed with the
+// registry's tag and value (e.g., "Tenant.acme.Requests"), so the global
+// registry remains a merged view of every tenant, while the registry's own
+// reports contain only its metrics, under their unprefixed names and tagged
+// with its tag and value.
+//
+//	acme := metrics.NewRegistry("Tenant", "acme")
+//	acme.Counter("Requests").Add()
+//
+//	http.Handle("/debug/metrics/acme", metrics.Handler{Source: acme.Capture})
+type Registry struct {
+	tag, value string
+
+	m       sync.Mutex
+	stopped bool
+}
+
+// NewRegistry returns a registry for the metrics tagged with the given tag and
+// value. It panics if either is empty or contains a period, since the prefix of
+// one registry could then also match the metrics of another (e.g., those of
+// "acme" and "acme.eu").
+func NewRegistry(tag, value string) *Registry {
+	for _, s := range []string{tag, value} {
+		if s == "" || strings.Contains(s, ".") {
+			panic(fmt.Sprintf("invalid registry tag or value %q", s))
+		}
+	}
+	return &Registry{tag: tag, value: value}
+}
+
+// Name returns the global name of the registry's metric with the given name.
+func (r *Registry) Name(name string) string {
+	return r.prefix() + name
+}
+
+// Counter returns the registry's counter with the given name.
+func (r *Registry) Counter(name string) Counter {
+	return Counter(r.Name(name))
+}
+
+// Gauge returns the registry's gauge with the given name.
+func (r *Registry) Gauge(name string) Gauge {
+	return Gauge(r.Name(name))
+}
+
+// NewHistogram returns a windowed HDR histogram in the registry, as with
+// NewHistogram.
+func (r *Registry) NewHistogram(name string, minValue, maxValue int64, sigfigs int) *Histogram {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	h := NewHistogram(r.Name(name), minValue, maxValue, sigfigs)
+	if r.stopped {
+		h.pause()
+	}
+	return h
+}
+
+// Stop stops rotating the windows of the registry's histograms, including
+// those created while it is stopped, until Start is called. Values recorded
+// while the registry is stopped are retained until its histograms rotate
+// again. Use it to quiesce a registry whose tenant or component is idle or
+// shutting down.
+func (r *Registry) Stop() {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.stopped = true
+	r.eachHistogram((*Histogram).pause)
+}
+
+// Start resumes rotating the windows of the registry's histograms after Stop.
+func (r *Registry) Start() {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.stopped = false
+	r.eachHistogram((*Histogram).resume)
+}
+
+// eachHistogram calls f with each of the registry's histograms while holding
+// hm, so that histograms are not removed concurrently.
+func (r *Registry) eachHistogram(f func(h *Histogram)) {
+	prefix := resolvePrefix(r.prefix())
+
+	hm.RLock()
+	defer hm.RUnlock()
+
+	for n, h := range histograms {
+		if strings.HasPrefix(n, prefix) {
+			f(h)
+		}
+	}
+}
+
+// NewScope returns a scope which flushes into the registry.
+func (r *Registry) NewScope() *Scope {
+	return NewScope(r.prefix())
+}
+
+// Pipeline returns a pipeline which limits reports to the registry's metrics,
+// strips their prefixes, and tags the reports with the registry's tag and
+// value.
+func (r *Registry) Pipeline() Pipeline {
+	prefix := resolvePrefix(r.prefix())
+	return Pipeline{
+		Filters: []Filter{func(name string) (string, bool) {
+			if !strings.HasPrefix(name, prefix) {
+				return "", false
+			}
+			return name[len(prefix):], true
+		}},
+		Tags: map[string]string{r.tag: r.value},
+	}
+}
+
+// Capture returns a report of the current values of the registry's metrics.
+func (r *Registry) Capture() Report {
+	return r.Pipeline().Apply(Capture())
+}
+
+// Remove removes all of the registry's metrics.
+func (r *Registry) Remove() {
+	prefix := resolvePrefix(r.prefix())
+
+	// remove histograms and sketches first, along with their quantile gauges
+	var names []string
+	hm.RLock()
+	for n := range histograms {
+		names = append(names, n)
+	}
+	for n := range sketches {
+		names = append(names, n)
+	}
+	hm.RUnlock()
+
+	counters, gauges := Snapshot()
+	for n := range counters {
+		names = append(names, n)
+	}
+
+	for n := range gauges {
+		names = append(names, n)
+	}
+
+	for n := range snapshotFloats() {
+		names = append(names, n)
+	}
+
+	for _, n := range names {
+		if strings.HasPrefix(n, prefix) {
+			removeMetric(n)
+		}
+	}
+}
+
+func (r *Registry) prefix() string {
+	return r.tag + "." + r.value + "."
+}
@@ -0,0 +1,149 @@
+package metrics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestRegistry(t *testing.T) {
+	metricstest.Reset(t)
+
+	acme := metrics.NewRegistry("Tenant", "acme")
+	initech := metrics.NewRegistry("Tenant", "initech")
+
+	acme.Counter("Requests").AddN(3)
+	acme.Gauge("Queue").Set(2)
+	acme.NewHistogram("Latency", 1, 1000, 3).RecordValue(5)
+	initech.Counter("Requests").Add()
+	metrics.Counter("Requests").AddN(10)
+
+	metricstest.AssertCounter(t, "Tenant.acme.Requests", 3)
+	metricstest.AssertCounter(t, "Tenant.initech.Requests", 1)
+
+	r := acme.Capture()
+
+	if v, want := r.Counters["Requests"], uint64(3); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	if v, want := r.Gauges["Queue"], int64(2); v != want {
+		t.Errorf("Gauge was %v, but expected %v", v, want)
+	}
+
+	if v, want := r.Histograms["Latency"].Count, int64(1); v != want {
+		t.Errorf("Count was %v, but expected %v", v, want)
+	}
+
+	if v, want := len(r.Counters), 1; v != want {
+		t.Errorf("Report had %v counters, but expected %v: %v", v, want, r.Counters)
+	}
+
+	if v, want := r.Tags["Tenant"], "acme"; v != want {
+		t.Errorf("Tag was %q, but expected %q", v, want)
+	}
+
+	acme.Remove()
+
+	counters, gauges := metrics.Snapshot()
+	for _, n := range []string{"Tenant.acme.Requests", "Tenant.acme.Queue", "Tenant.acme.Latency.P50"} {
+		if _, ok := counters[n]; ok {
+			t.Errorf("Counter %s was not removed", n)
+		}
+
+		if _, ok := gauges[n]; ok {
+			t.Errorf("Gauge %s was not removed", n)
+		}
+	}
+
+	metricstest.AssertCounter(t, "Tenant.initech.Requests", 1)
+}
+
+func TestRegistryStop(t *testing.T) {
+	metricstest.Reset(t)
+	c := metricstest.UseFakeClock(t)
+
+	acme := metrics.NewRegistry("Tenant", "acme")
+	h := acme.NewHistogram("Latency", 1, 1000, 3)
+	_ = h.RecordValue(5)
+
+	acme.Stop()
+	g := acme.NewHistogram("Size", 1, 1000, 3)
+	_ = g.RecordValue(5)
+
+	c.Advance(10 * time.Minute)
+
+	if v, want := h.TotalCount(), int64(1); v != want {
+		t.Errorf("Count was %v while stopped, but expected %v", v, want)
+	}
+
+	if v, want := g.TotalCount(), int64(1); v != want {
+		t.Errorf("Count was %v while stopped, but expected %v", v, want)
+	}
+
+	acme.Start()
+	c.Advance(10 * time.Minute)
+
+	if v, want := h.TotalCount(), int64(0); v != want {
+		t.Errorf("Count was %v after starting, but expected %v", v, want)
+	}
+
+	if v, want := g.TotalCount(), int64(0); v != want {
+		t.Errorf("Count was %v after starting, but expected %v", v, want)
+	}
+}
+
+func TestRegistryPrefix(t *testing.T) {
+	metricstest.Reset(t)
+
+	metrics.Init(metrics.WithPrefix("api."))
+	defer metrics.Init(metrics.WithPrefix(""))
+
+	acme := metrics.NewRegistry("Tenant", "acme")
+	acme.Counter("Requests").AddN(2)
+	acme.NewHistogram("Latency", 1, 1000, 3).RecordValue(10)
+
+	r := acme.Capture()
+	if v, want := r.Counters["Requests"], uint64(2); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	if _, ok := r.Histograms["Latency"]; !ok {
+		t.Errorf("Histograms were %v, but expected Latency", r.Histograms)
+	}
+
+	acme.Remove()
+
+	counters, _ := metrics.Snapshot()
+	if v, ok := counters["api.Tenant.acme.Requests"]; ok {
+		t.Errorf("Counter was not removed (%v)", v)
+	}
+}
+
+func TestRegistryNestedValues(t *testing.T) {
+	metricstest.Reset(t)
+
+	for _, v := range [][2]string{{"Tenant", "acme.eu"}, {"Tenant.Region", "acme"}, {"Tenant", ""}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewRegistry(%q, %q) did not panic", v[0], v[1])
+				}
+			}()
+			metrics.NewRegistry(v[0], v[1])
+		}()
+	}
+
+	acme := metrics.NewRegistry("Tenant", "acme")
+	acmeEU := metrics.NewRegistry("Tenant", "acme_eu")
+	acme.Counter("Requests").Add()
+	acmeEU.Counter("Requests").Add()
+
+	acme.Remove()
+
+	if v, want := acmeEU.Counter("Requests").Value(), uint64(1); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+}
@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/codahale/metrics/internal/snappy"
+)
+
+// A RemoteWriter pushes reports of all metrics to a Prometheus remote-write
+// endpoint (e.g., Mimir, Thanos Receive, or VictoriaMetrics), so that
+// short-lived jobs can be monitored without a scraper.
+//
+// Counters and float counters are written as series suffixed with _total,
+// gauges as series, and
+// histograms as summaries or, if Buckets is true, as histograms with
+// cumulative power-of-two buckets. Each series is labeled with the report's
+// tags, since there is no scraper to add job and instance labels.
+type RemoteWriter struct {
+	// URL is the remote-write endpoint (e.g.,
+	// http://mimir:9009/api/v1/push).
+	URL string
+
+	// Client, if not nil, is used to send requests. Otherwise,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	// Header is added to each request (e.g., for authorization or a tenant
+	// ID).
+	Header http.Header
+
+	// Buckets, if true, writes histograms as histograms with buckets rather
+	// than as summaries.
+	Buckets bool
+
+	// Pipeline is applied to each report before it is written.
+	Pipeline Pipeline
+}
+
+// Push writes a report of all metrics to the endpoint.
+func (rw RemoteWriter) Push() error {
+	r := rw.Pipeline.Apply(Capture())
+
+	req, err := http.NewRequest("POST", rw.URL, bytes.NewReader(snappy.Encode(writeRequest(r, rw.Buckets))))
+	if err != nil {
+		return err
+	}
+
+	for k, v := range rw.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	return send(rw.Client, req)
+}
+
+// PushEvery pushes a report once per the given interval until the returned
+// timer is stopped. Errors are counted by the Metrics.ExportErrors counter.
+func (rw RemoteWriter) PushEvery(d time.Duration) Timer {
+	return flushEvery(d, "Metrics.ExportErrors", rw.Push)
+}
+
+type label struct {
+	name, value string
+}
+
+// writeRequest encodes the report as a Prometheus remote-write WriteRequest.
+func writeRequest(r Report, buckets bool) []byte {
+	var w protoWriter
+	ts := uint64(r.Time.UnixNano() / int64(time.Millisecond))
+
+	tags := make([]label, 0, len(r.Tags))
+	for _, k := range sortedKeys(r.Tags) {
+		tags = append(tags, label{PrometheusName(k), r.Tags[k]})
+	}
+
+	series := func(name string, v float64, extra ...label) {
+		labels := append(append([]label{{"__name__", name}}, extra...), tags...)
+		sort.Slice(labels, func(i, j int) bool {
+			return labels[i].name < labels[j].name
+		})
+
+		w.message(1, func(e *protoWriter) {
+			for _, l := range labels {
+				e.message(1, func(e *protoWriter) {
+					e.string(1, l.name)
+					e.string(2, l.value)
+				})
+			}
+
+			e.message(2, func(e *protoWriter) {
+				e.double(1, v)
+				e.varint(2, ts)
+			})
+		})
+	}
+
+	for _, n := range sortedKeys(r.Counters) {
+		series(PrometheusName(n)+"_total", float64(r.Counters[n]))
+	}
+
+	for _, n := range sortedKeys(r.FloatCounters) {
+		series(PrometheusName(n)+"_total", r.FloatCounters[n])
+	}
+
+	for _, n := range sortedKeys(r.Gauges) {
+		series(PrometheusName(n), float64(r.Gauges[n]))
+	}
+
+	for _, n := range sortedKeys(r.Histograms) {
+		s, name := r.Histograms[n], PrometheusName(n)
+
+		if buckets {
+			for _, b := range s.Buckets {
+				series(name+"_bucket", float64(b.Count), label{"le", strconv.FormatInt(b.UpperBound, 10)})
+			}
+			series(name+"_bucket", float64(s.Count), label{"le", "+Inf"})
+		} else {
+			qs := make([]float64, 0, len(s.Quantiles))
+			for q := range s.Quantiles {
+				qs = append(qs, q)
+			}
+			sort.Float64s(qs)
+
+			for _, q := range qs {
+				series(name, float64(s.Quantiles[q]), label{"quantile", formatQuantile(q)})
+			}
+		}
+
+		series(name+"_sum", s.Mean*float64(s.Count))
+		series(name+"_count", float64(s.Count))
+	}
+
+	return w.b
+}
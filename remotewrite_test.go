@@ -0,0 +1,84 @@
+package metrics_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/internal/snappy"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestRemoteWriter(t *testing.T) {
+	metricstest.Reset(t)
+
+	metrics.Counter("HTTP.Requests").AddN(3)
+	metrics.FloatCounter("HTTP.Bytes").Add(1.5)
+	metrics.Gauge("Conns").Set(2)
+	h := metrics.NewHistogram("Latency", 1, 1000, 3)
+	h.RecordValue(10)
+
+	var body []byte
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if body, err = snappy.Decode(b); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+
+	rw := metrics.RemoteWriter{
+		URL:    srv.URL,
+		Header: http.Header{"X-Scope-Orgid": {"tenant"}},
+		Pipeline: metrics.Pipeline{
+			Tags: map[string]string{"job": "batch"},
+		},
+	}
+
+	if err := rw.Push(); err != nil {
+		t.Fatal(err)
+	}
+
+	for k, want := range map[string]string{
+		"Content-Encoding":                  "snappy",
+		"Content-Type":                      "application/x-protobuf",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+		"X-Scope-Orgid":                     "tenant",
+	} {
+		if v := header.Get(k); v != want {
+			t.Errorf("%s was %q, but expected %q", k, v, want)
+		}
+	}
+
+	for _, s := range []string{
+		"__name__", "HTTP_Requests_total", "HTTP_Bytes_total", "Conns", "Latency_count", "quantile", "0.999", "job", "batch",
+	} {
+		if !bytes.Contains(body, []byte(s)) {
+			t.Errorf("Request did not contain %q", s)
+		}
+	}
+}
+
+func TestRemoteWriterError(t *testing.T) {
+	metricstest.Reset(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	if err := (metrics.RemoteWriter{URL: srv.URL}).Push(); err == nil {
+		t.Error("No error was returned")
+	}
+}
@@ -0,0 +1,230 @@
+package metrics
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/codahale/hdrhistogram"
+)
+
+// A Report is a structured, point-in-time view of all registered metrics.
+//
+// Unlike the values returned by Snapshot, a report's gauges do not include the
+// quantile gauges published by histograms; those are summarized in Histograms.
+type Report struct {
+	Time          time.Time                   // when the report was captured
+	Counters      map[string]uint64           // counter values by name
+	FloatCounters map[string]float64          // float counter values by name
+	Gauges        map[string]int64            // gauge values by name
+	Histograms    map[string]HistogramSummary // histogram summaries by name
+	Tags          map[string]string           // tags describing the report's source
+}
+
+// A HistogramSummary describes the distribution of values recorded by a
+// histogram over its current window.
+type HistogramSummary struct {
+	Count     int64                // the number of recorded values
+	Min       int64                // the smallest recorded value
+	Max       int64                // the largest recorded value
+	Mean      float64              // the mean of the recorded values
+	StdDev    float64              // the standard deviation of the recorded values
+	Quantiles map[float64]int64    // recorded values by quantile (0-100)
+	Exemplars map[float64]Exemplar // exemplars near each quantile, if any
+	Buckets   []Bucket             // cumulative counts by power-of-two upper bound
+	Start     time.Time            // the start of the window
+	End       time.Time            // the end of the window
+}
+
+// Capture returns a report of the current values of all registered metrics.
+// Counters and histograms may be skewed by concurrent updates unless
+// consistent snapshots are enabled with SetConsistentSnapshots.
+func Capture() Report {
+	counters, gauges := Snapshot()
+
+	var hists []*Histogram
+	if Enabled() {
+		hm.RLock()
+		for _, h := range histograms {
+			if !isSilenced(h.name) {
+				hists = append(hists, h)
+			}
+		}
+		hm.RUnlock()
+	}
+
+	r := Report{
+		Time:          now(),
+		Counters:      counters,
+		FloatCounters: make(map[string]float64),
+		Gauges:        gauges,
+		Histograms:    make(map[string]HistogramSummary, len(hists)),
+		Tags:          make(map[string]string),
+	}
+
+	for k, v := range copyTags() {
+		r.Tags[k] = v
+	}
+
+	for k, v := range copyBuildInfo() {
+		r.Tags["build."+k] = v
+	}
+
+	locked := beginSnapshot()
+	if locked {
+		rereadCounters(r.Counters)
+	}
+
+	if Enabled() {
+		r.FloatCounters = snapshotFloats()
+	}
+
+	for _, h := range hists {
+		for _, q := range quantiles {
+			delete(r.Gauges, h.name+q.suffix)
+		}
+		r.Histograms[h.name] = h.Summary()
+	}
+	endSnapshot(locked)
+
+	return r
+}
+
+// Summary returns a summary of the values recorded over the histogram's current
+// window.
+func (h *Histogram) Summary() (s HistogramSummary) {
+	h.query(func(m *hdrhistogram.Histogram) {
+		s = HistogramSummary{
+			Count:     m.TotalCount(),
+			Min:       m.Min(),
+			Max:       m.Max(),
+			Mean:      m.Mean(),
+			StdDev:    m.StdDev(),
+			Quantiles: make(map[float64]int64, len(quantiles)),
+			Buckets:   h.buckets(m),
+			Start:     h.starts[0],
+			End:       now(),
+		}
+
+		for _, q := range quantiles {
+			v := m.ValueAtQuantile(q.q)
+			s.Quantiles[q.q] = v
+
+			if e, ok := h.exemplar(v); ok {
+				if s.Exemplars == nil {
+					s.Exemplars = make(map[float64]Exemplar)
+				}
+				s.Exemplars[q.q] = e
+			}
+		}
+	})
+	return
+}
+
+type jsonReport struct {
+	Time          time.Time
+	Counters      map[string]uint64
+	FloatCounters map[string]float64 `json:",omitempty"`
+	Gauges        map[string]int64
+	Histograms    map[string]HistogramSummary
+	Tags          map[string]string `json:",omitempty"`
+}
+
+// MarshalJSON encodes the report as a JSON object.
+func (r Report) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonReport(r))
+}
+
+// UnmarshalJSON decodes a report from a JSON object.
+func (r *Report) UnmarshalJSON(b []byte) error {
+	var v jsonReport
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*r = Report(v)
+	return nil
+}
+
+type jsonHistogramSummary struct {
+	Count     int64
+	Min       int64
+	Max       int64
+	Mean      float64
+	StdDev    float64
+	Quantiles map[string]int64
+	Exemplars map[string]Exemplar `json:",omitempty"`
+	Buckets   []Bucket            `json:",omitempty"`
+	Start     time.Time
+	End       time.Time
+}
+
+// MarshalJSON encodes the summary as a JSON object. Quantiles are keyed by their
+// decimal representations (e.g., "99.9").
+func (s HistogramSummary) MarshalJSON() ([]byte, error) {
+	v := jsonHistogramSummary{
+		Count:     s.Count,
+		Min:       s.Min,
+		Max:       s.Max,
+		Mean:      s.Mean,
+		StdDev:    s.StdDev,
+		Quantiles: make(map[string]int64, len(s.Quantiles)),
+		Buckets:   s.Buckets,
+		Start:     s.Start,
+		End:       s.End,
+	}
+
+	for q, n := range s.Quantiles {
+		v.Quantiles[strconv.FormatFloat(q, 'f', -1, 64)] = n
+	}
+
+	if len(s.Exemplars) > 0 {
+		v.Exemplars = make(map[string]Exemplar, len(s.Exemplars))
+		for q, e := range s.Exemplars {
+			v.Exemplars[strconv.FormatFloat(q, 'f', -1, 64)] = e
+		}
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes a summary from a JSON object.
+func (s *HistogramSummary) UnmarshalJSON(b []byte) error {
+	var v jsonHistogramSummary
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = HistogramSummary{
+		Count:     v.Count,
+		Min:       v.Min,
+		Max:       v.Max,
+		Mean:      v.Mean,
+		StdDev:    v.StdDev,
+		Quantiles: make(map[float64]int64, len(v.Quantiles)),
+		Buckets:   v.Buckets,
+		Start:     v.Start,
+		End:       v.End,
+	}
+
+	for k, n := range v.Quantiles {
+		q, err := strconv.ParseFloat(k, 64)
+		if err != nil {
+			return err
+		}
+		s.Quantiles[q] = n
+	}
+
+	for k, e := range v.Exemplars {
+		q, err := strconv.ParseFloat(k, 64)
+		if err != nil {
+			return err
+		}
+
+		if s.Exemplars == nil {
+			s.Exemplars = make(map[float64]Exemplar)
+		}
+		s.Exemplars[q] = e
+	}
+
+	return nil
+}
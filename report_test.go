@@ -0,0 +1,154 @@
+package metrics_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestCapture(t *testing.T) {
+	metrics.Reset()
+
+	metrics.Counter("whee").Add()
+	metrics.Gauge("woo").Set(-1)
+
+	h := metrics.NewHistogram("heyo", 1, 1000, 3)
+	for i := 1; i <= 100; i++ {
+		h.RecordValue(int64(i))
+	}
+
+	r := metrics.Capture()
+
+	if v, want := r.Counters["whee"], uint64(1); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	if v, want := r.Gauges["woo"], int64(-1); v != want {
+		t.Errorf("Gauge was %v, but expected %v", v, want)
+	}
+
+	if v, ok := r.Gauges["heyo.P50"]; ok {
+		t.Errorf("Gauge was %v, but expected nothing", v)
+	}
+
+	s := r.Histograms["heyo"]
+	if v, want := s.Count, int64(100); v != want {
+		t.Errorf("Count was %v, but expected %v", v, want)
+	}
+
+	if v, want := s.Quantiles[99.9], int64(100); v != want {
+		t.Errorf("P999 was %v, but expected %v", v, want)
+	}
+
+	if r.Time.IsZero() {
+		t.Error("Report has no timestamp")
+	}
+}
+
+func TestReportJSON(t *testing.T) {
+	metricstest.Reset(t)
+	metricstest.UseFakeClock(t)
+
+	metrics.Counter("whee").Add()
+	h := metrics.NewHistogram("heyo", 1, 1000, 3)
+	h.RecordValue(5)
+
+	r := metrics.Capture()
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v map[string]interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	q := v["Histograms"].(map[string]interface{})["heyo"].(map[string]interface{})["Quantiles"]
+	if v, want := q.(map[string]interface{})["99.9"], 5.0; v != want {
+		t.Errorf("P999 was %v, but expected %v", v, want)
+	}
+
+	var r2 metrics.Report
+	if err := json.Unmarshal(b, &r2); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(r.Histograms, r2.Histograms) {
+		t.Errorf("Histograms were %v, but expected %v", r2.Histograms, r.Histograms)
+	}
+}
+
+func TestHistogramSummaryBuckets(t *testing.T) {
+	metrics.Reset()
+
+	h := metrics.NewHistogram("heyo", 1, 100, 3)
+	for i := int64(1); i <= 100; i++ {
+		h.RecordValue(i)
+	}
+
+	s := h.Summary()
+
+	var bounds, counts []int64
+	for _, b := range s.Buckets {
+		bounds = append(bounds, b.UpperBound)
+		counts = append(counts, b.Count)
+	}
+
+	if v, want := fmt.Sprint(bounds), "[1 3 7 15 31 63 127]"; v != want {
+		t.Errorf("Bounds were %v, but expected %v", v, want)
+	}
+
+	if v, want := fmt.Sprint(counts), "[1 3 7 15 31 63 100]"; v != want {
+		t.Errorf("Counts were %v, but expected %v", v, want)
+	}
+}
+
+func TestReportBinary(t *testing.T) {
+	metricstest.Reset(t)
+	metricstest.UseFakeClock(t)
+
+	metrics.Counter("whee").AddN(3)
+	metrics.Gauge("woo").Set(-4)
+	metrics.SetTag("region", "us-east-1")
+	defer metrics.RemoveTag("region")
+
+	h := metrics.NewHistogram("heyo", 1, 1000, 3)
+	h.RecordValue(5)
+	h.RecordValueWithExemplar(7, map[string]string{"trace_id": "abc"})
+
+	r := metrics.Capture()
+
+	b, err := r.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var r2 metrics.Report
+	if err := r2.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+
+	if !r2.Time.Equal(r.Time) {
+		t.Errorf("Time was %v, but expected %v", r2.Time, r.Time)
+	}
+
+	// compare the remainder via JSON, which normalizes time zones
+	r.Time, r2.Time = time.Time{}, time.Time{}
+
+	j1, _ := json.Marshal(r)
+	j2, _ := json.Marshal(r2)
+	if string(j1) != string(j2) {
+		t.Errorf("Report was\n%s\nbut expected\n%s", j2, j1)
+	}
+
+	if err := r2.UnmarshalBinary(b[:len(b)-1]); err == nil {
+		t.Error("Truncated report was decoded")
+	}
+}
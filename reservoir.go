@@ -0,0 +1,282 @@
+package metrics
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// A Reservoir is a sample of the values recorded by a histogram, from which
+// its quantiles and summaries are computed in place of its windows. Calls to a
+// reservoir are serialized by its histogram.
+type Reservoir interface {
+	// Update adds a value to the reservoir.
+	Update(v int64)
+
+	// UpdateN adds n occurrences of a value to the reservoir, in time
+	// independent of n.
+	UpdateN(v, n int64)
+
+	// Values returns the values in the reservoir.
+	Values() []int64
+
+	// Clear removes all values from the reservoir.
+	Clear()
+}
+
+// A UniformReservoir is a uniform random sample of a fixed number of values
+// from all the values recorded, using Vitter's Algorithm R. Use it for
+// long-running measurements where the distribution doesn't change over time.
+type UniformReservoir struct {
+	values []int64
+	count  int64
+}
+
+// NewUniformReservoir returns a uniform reservoir with the given size.
+func NewUniformReservoir(size int) *UniformReservoir {
+	return &UniformReservoir{values: make([]int64, 0, size)}
+}
+
+// Update adds a value to the reservoir.
+func (r *UniformReservoir) Update(v int64) {
+	r.count++
+	if len(r.values) < cap(r.values) {
+		r.values = append(r.values, v)
+		return
+	}
+
+	if i := rand.Int63n(r.count); i < int64(len(r.values)) {
+		r.values[i] = v
+	}
+}
+
+// UpdateN adds n occurrences of a value to the reservoir. Once the reservoir is
+// full, rather than deciding whether to sample each occurrence, it skips ahead
+// to the next sampled occurrence (as in Vitter's Algorithm X, with a
+// continuous approximation of the number skipped).
+func (r *UniformReservoir) UpdateN(v, n int64) {
+	for n > 0 && len(r.values) < cap(r.values) {
+		r.values = append(r.values, v)
+		r.count++
+		n--
+	}
+
+	size := float64(len(r.values))
+	for n > 0 && size > 0 {
+		// the probability of skipping at least s occurrences is roughly
+		// (count/(count+s))^size
+		u := 1 - rand.Float64()
+		skip := float64(r.count) * (math.Pow(u, -1/size) - 1)
+		if skip >= float64(n) {
+			break
+		}
+
+		s := int64(skip) + 1
+		r.count += s
+		n -= s
+		r.values[rand.Intn(len(r.values))] = v
+	}
+	r.count += n
+}
+
+// Values returns the values in the reservoir.
+func (r *UniformReservoir) Values() []int64 {
+	return append([]int64(nil), r.values...)
+}
+
+// Clear removes all values from the reservoir.
+func (r *UniformReservoir) Clear() {
+	r.values = r.values[:0]
+	r.count = 0
+}
+
+// A SlidingWindowReservoir holds the most recently recorded values, up to a
+// fixed number.
+type SlidingWindowReservoir struct {
+	values []int64
+	next   int
+	full   bool
+}
+
+// NewSlidingWindowReservoir returns a reservoir of the given number of most
+// recent values.
+func NewSlidingWindowReservoir(size int) *SlidingWindowReservoir {
+	return &SlidingWindowReservoir{values: make([]int64, size)}
+}
+
+// Update adds a value to the reservoir, replacing the oldest value if it is
+// full.
+func (r *SlidingWindowReservoir) Update(v int64) {
+	if len(r.values) == 0 {
+		return
+	}
+
+	r.values[r.next] = v
+	r.next++
+	if r.next == len(r.values) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// UpdateN adds n occurrences of a value to the reservoir.
+func (r *SlidingWindowReservoir) UpdateN(v, n int64) {
+	if n >= int64(len(r.values)) {
+		for i := range r.values {
+			r.values[i] = v
+		}
+		r.full = len(r.values) > 0
+		return
+	}
+
+	for i := int64(0); i < n; i++ {
+		r.Update(v)
+	}
+}
+
+// Values returns the values in the reservoir.
+func (r *SlidingWindowReservoir) Values() []int64 {
+	if r.full {
+		return append([]int64(nil), r.values...)
+	}
+	return append([]int64(nil), r.values[:r.next]...)
+}
+
+// Clear removes all values from the reservoir.
+func (r *SlidingWindowReservoir) Clear() {
+	r.next = 0
+	r.full = false
+}
+
+// An ExpDecayReservoir is a random sample of a fixed number of values which is
+// biased towards recent values, using forward decay priority sampling (Cormode
+// et al.). With a size of 1028 and an alpha of 0.015, it represents roughly the
+// last five minutes of values.
+type ExpDecayReservoir struct {
+	size    int
+	alpha   float64
+	start   time.Time // the landmark from which weights are computed
+	rescale time.Time // when the priorities are next rescaled
+	samples decaySamples
+}
+
+// NewExpDecayReservoir returns an exponentially-decaying reservoir with the
+// given size and decay factor. A higher alpha biases the sample more heavily
+// towards recent values.
+func NewExpDecayReservoir(size int, alpha float64) *ExpDecayReservoir {
+	t := now()
+	return &ExpDecayReservoir{
+		size:    size,
+		alpha:   alpha,
+		start:   t,
+		rescale: t.Add(decayRescaleInterval),
+	}
+}
+
+// Update adds a value to the reservoir.
+func (r *ExpDecayReservoir) Update(v int64) {
+	if r.size <= 0 {
+		return
+	}
+
+	t := now()
+	if !t.Before(r.rescale) {
+		r.rescaleAt(t)
+	}
+
+	// 1-Float64 is in (0, 1], so the priority is finite
+	r.add(v, math.Exp(r.alpha*t.Sub(r.start).Seconds())/(1-rand.Float64()))
+}
+
+// UpdateN adds n occurrences of a value to the reservoir. Since at most size
+// of them can be sampled, it only draws the largest size of their n random
+// priorities, in descending order.
+func (r *ExpDecayReservoir) UpdateN(v, n int64) {
+	if r.size <= 0 {
+		return
+	}
+
+	t := now()
+	if !t.Before(r.rescale) {
+		r.rescaleAt(t)
+	}
+	w := math.Exp(r.alpha * t.Sub(r.start).Seconds())
+
+	// the largest of m uniform values is distributed as U^(1/m)
+	x := 1.0
+	for m := n; m > 0 && m > n-int64(r.size); m-- {
+		x *= math.Pow(rand.Float64(), 1/float64(m))
+		if x >= 1 {
+			x = math.Nextafter(1, 0)
+		}
+
+		if !r.add(v, w/(1-x)) {
+			break // the remaining priorities are smaller still
+		}
+	}
+}
+
+// add adds a sample with the given priority, returning false if the priority
+// was too small for the sample to be retained.
+func (r *ExpDecayReservoir) add(v int64, p float64) bool {
+	if len(r.samples) < r.size {
+		heap.Push(&r.samples, decaySample{v: v, priority: p})
+	} else if p > r.samples[0].priority {
+		r.samples[0] = decaySample{v: v, priority: p}
+		heap.Fix(&r.samples, 0)
+	} else {
+		return false
+	}
+	return true
+}
+
+// rescaleAt moves the landmark to the given time, scaling the priorities of
+// the samples accordingly so that they do not overflow.
+func (r *ExpDecayReservoir) rescaleAt(t time.Time) {
+	f := math.Exp(-r.alpha * t.Sub(r.start).Seconds())
+	for i := range r.samples {
+		r.samples[i].priority *= f // uniform scaling preserves the heap
+	}
+	r.start = t
+	r.rescale = t.Add(decayRescaleInterval)
+}
+
+// Values returns the values in the reservoir.
+func (r *ExpDecayReservoir) Values() []int64 {
+	values := make([]int64, len(r.samples))
+	for i, s := range r.samples {
+		values[i] = s.v
+	}
+	return values
+}
+
+// Clear removes all values from the reservoir.
+func (r *ExpDecayReservoir) Clear() {
+	t := now()
+	r.samples = r.samples[:0]
+	r.start = t
+	r.rescale = t.Add(decayRescaleInterval)
+}
+
+const decayRescaleInterval = 1 * time.Hour
+
+type decaySample struct {
+	v        int64
+	priority float64
+}
+
+// decaySamples is a min-heap of samples by priority.
+type decaySamples []decaySample
+
+func (s decaySamples) Len() int            { return len(s) }
+func (s decaySamples) Less(i, j int) bool  { return s[i].priority < s[j].priority }
+func (s decaySamples) Swap(i, j int)       { s[i], s[j] = s[j], s[i] }
+func (s *decaySamples) Push(x interface{}) { *s = append(*s, x.(decaySample)) }
+
+func (s *decaySamples) Pop() interface{} {
+	old := *s
+	x := old[len(old)-1]
+	*s = old[:len(old)-1]
+	return x
+}
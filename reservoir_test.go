@@ -0,0 +1,161 @@
+package metrics_test
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestUniformReservoir(t *testing.T) {
+	r := metrics.NewUniformReservoir(100)
+
+	for i := int64(0); i < 50; i++ {
+		r.Update(i)
+	}
+
+	if v, want := len(r.Values()), 50; v != want {
+		t.Errorf("Reservoir had %d values, but expected %d", v, want)
+	}
+
+	for i := int64(0); i < 1000; i++ {
+		r.Update(i)
+	}
+
+	values := r.Values()
+	if v, want := len(values), 100; v != want {
+		t.Errorf("Reservoir had %d values, but expected %d", v, want)
+	}
+
+	for _, v := range values {
+		if v < 0 || v >= 1000 {
+			t.Errorf("Reservoir had unexpected value %d", v)
+		}
+	}
+
+	r.Clear()
+	if v := r.Values(); len(v) != 0 {
+		t.Errorf("Reservoir had %v after clearing", v)
+	}
+}
+
+func TestSlidingWindowReservoir(t *testing.T) {
+	r := metrics.NewSlidingWindowReservoir(5)
+
+	for i := int64(1); i <= 3; i++ {
+		r.Update(i)
+	}
+	assertValues(t, r.Values(), 1, 2, 3)
+
+	for i := int64(4); i <= 10; i++ {
+		r.Update(i)
+	}
+	assertValues(t, r.Values(), 6, 7, 8, 9, 10)
+
+	r.Clear()
+	assertValues(t, r.Values())
+}
+
+func TestExpDecayReservoir(t *testing.T) {
+	metricstest.Reset(t)
+	c := metricstest.UseFakeClock(t)
+
+	r := metrics.NewExpDecayReservoir(10, 0.015)
+
+	for i := 0; i < 100; i++ {
+		r.Update(1)
+	}
+	c.Advance(10 * time.Minute)
+	for i := 0; i < 100; i++ {
+		r.Update(1000)
+	}
+
+	// recent values are weighted e^9 times more heavily
+	assertValues(t, r.Values(), 1000, 1000, 1000, 1000, 1000, 1000, 1000, 1000, 1000, 1000)
+
+	// rescaling preserves the bias
+	c.Advance(2 * time.Hour)
+	r.Update(5)
+
+	values := r.Values()
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	if values[0] != 5 {
+		t.Errorf("Reservoir was %v, but expected the newest value", values)
+	}
+}
+
+func TestHistogramReservoir(t *testing.T) {
+	metricstest.Reset(t)
+
+	h := metrics.NewHistogramWithOptions("Latency", 1, 1000, 3, metrics.HistogramOptions{
+		Reservoir: metrics.NewSlidingWindowReservoir(5),
+	})
+	defer h.Remove()
+
+	for i := int64(1); i <= 10; i++ {
+		if err := h.RecordValue(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := h.RecordValue(1001); err == nil {
+		t.Error("Out of range value was recorded")
+	}
+
+	metricstest.AssertGauge(t, "Latency.P50", 8)
+
+	if v, want := h.TotalCount(), int64(5); v != want {
+		t.Errorf("Count was %v, but expected %v", v, want)
+	}
+
+	if v, want := metrics.Capture().Histograms["Latency"].Min, int64(6); v != want {
+		t.Errorf("Min was %v, but expected %v", v, want)
+	}
+}
+
+func assertValues(t *testing.T, values []int64, want ...int64) {
+	t.Helper()
+
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	if len(values) != len(want) {
+		t.Fatalf("Reservoir was %v, but expected %v", values, want)
+	}
+	for i := range values {
+		if values[i] != want[i] {
+			t.Fatalf("Reservoir was %v, but expected %v", values, want)
+		}
+	}
+}
+
+func TestHistogramReservoirLargeCount(t *testing.T) {
+	metricstest.Reset(t)
+	metricstest.UseFakeClock(t)
+
+	reservoirs := map[string]metrics.Reservoir{
+		"uniform":  metrics.NewUniformReservoir(100),
+		"sliding":  metrics.NewSlidingWindowReservoir(100),
+		"expdecay": metrics.NewExpDecayReservoir(100, 0.015),
+	}
+
+	for name, r := range reservoirs {
+		h := metrics.NewHistogramWithOptions(name, 1, 1000, 3, metrics.HistogramOptions{Reservoir: r})
+		for i := 0; i < 100; i++ {
+			_ = h.RecordValue(1)
+		}
+
+		if err := h.RecordValues(7, 1e12); err != nil {
+			t.Fatal(err)
+		}
+
+		// the new values vastly outnumber the old ones
+		if v, want := h.ValueAtQuantile(10), int64(7); v != want {
+			t.Errorf("%s: P10 was %v, but expected %v", name, v, want)
+		}
+
+		if err := h.RecordValues(7, 0); err == nil {
+			t.Errorf("%s: a count of zero was accepted", name)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package metrics
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path"
+	"time"
+)
+
+// A RiemannReporter sends the values of metrics to a Riemann server as events
+// over TCP. Each counter, float counter, and gauge is sent as an event whose
+// service is the metric's name, float counters with double-precision metrics; histograms are sent as events for each of their quantiles
+// (e.g., Latency.P99).
+type RiemannReporter struct {
+	// Addr is the address of the Riemann server (e.g., "riemann:5555").
+	Addr string
+
+	// Host is the host of each event. If empty, the hostname is used.
+	Host string
+
+	// Tags are added to each event.
+	Tags []string
+
+	// TTL is the time for which each event is valid. If zero, the TTL is
+	// left to the server's default.
+	TTL time.Duration
+
+	// Thresholds derive the states of events from their values. The first
+	// threshold whose pattern matches an event's service determines its state;
+	// events which match no threshold have no state.
+	Thresholds []Threshold
+
+	// Timeout limits the time taken to send the events. If zero, ten seconds
+	// is used.
+	Timeout time.Duration
+
+	// Pipeline is applied to each report before it is sent.
+	Pipeline Pipeline
+}
+
+// A Threshold derives the state of an event from its value.
+type Threshold struct {
+	// Pattern is a glob pattern (see path.Match) matched against the names of
+	// metrics.
+	Pattern string
+
+	// Warning and Critical are the values at or above which an event's state
+	// is "warning" or "critical" rather than "ok".
+	Warning, Critical int64
+
+	// Below, if true, inverts the threshold, so that an event's state is
+	// "warning" or "critical" at or below the values.
+	Below bool
+}
+
+// State returns the state of an event with the given value.
+func (t Threshold) State(v int64) string {
+	switch {
+	case t.Below && v <= t.Critical, !t.Below && v >= t.Critical:
+		return "critical"
+	case t.Below && v <= t.Warning, !t.Below && v >= t.Warning:
+		return "warning"
+	}
+	return "ok"
+}
+
+// Report sends an event for each metric to the server, and returns an error if
+// the server does not acknowledge them.
+func (rr RiemannReporter) Report() error {
+	r := rr.Pipeline.Apply(Capture())
+
+	host := rr.Host
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+
+	var msg protoWriter
+	// state is the value compared with thresholds, and metric writes the value
+	send := func(service string, state int64, metric func(e *protoWriter)) {
+		msg.message(6, func(e *protoWriter) {
+			e.varint(1, uint64(r.Time.Unix()))
+			for _, t := range rr.Thresholds {
+				if ok, _ := path.Match(t.Pattern, service); ok {
+					e.string(2, t.State(state))
+					break
+				}
+			}
+			e.string(3, service)
+			e.string(4, host)
+			for _, t := range rr.Tags {
+				e.string(7, t)
+			}
+			e.float(8, float32(rr.TTL.Seconds()))
+			metric(e)
+		})
+	}
+
+	event := func(service string, v int64) {
+		send(service, v, func(e *protoWriter) { e.zigzag(13, v) })
+	}
+
+	for _, n := range sortedKeys(r.Counters) {
+		event(n, int64(r.Counters[n]))
+	}
+
+	for _, n := range sortedKeys(r.FloatCounters) {
+		v := r.FloatCounters[n]
+		send(n, int64(v), func(e *protoWriter) { e.double(14, v) })
+	}
+
+	for _, n := range sortedKeys(r.Gauges) {
+		event(n, r.Gauges[n])
+	}
+
+	for _, n := range sortedKeys(r.Histograms) {
+		for _, q := range quantiles {
+			event(n+q.suffix, r.Histograms[n].Quantiles[q.q])
+		}
+	}
+
+	timeout := rr.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	conn, err := net.DialTimeout("tcp", rr.Addr, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+
+	b := make([]byte, 4, 4+len(msg.b))
+	binary.BigEndian.PutUint32(b, uint32(len(msg.b)))
+	if _, err := conn.Write(append(b, msg.b...)); err != nil {
+		return err
+	}
+
+	if _, err := io.ReadFull(conn, b[:4]); err != nil {
+		return err
+	}
+
+	resp := make([]byte, binary.BigEndian.Uint32(b))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return err
+	}
+
+	ok, reason := false, "riemann: events were not acknowledged"
+	err = readProto(resp, func(field int, v protoValue) error {
+		switch field {
+		case 2:
+			ok = v.n != 0
+		case 3:
+			reason = "riemann: " + string(v.b)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return errors.New(reason)
+	}
+	return nil
+}
+
+// ReportEvery sends the values of metrics once per the given interval until the
+// returned timer is stopped. Errors are counted by the Metrics.ExportErrors
+// counter.
+func (rr RiemannReporter) ReportEvery(d time.Duration) Timer {
+	return flushEvery(d, "Metrics.ExportErrors", rr.Report)
+}
@@ -0,0 +1,115 @@
+package metrics_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+// riemannServer accepts a single connection, sends its message to the given
+// channel, and responds with the given encoded message.
+func riemannServer(t *testing.T, resp []byte, msgs chan<- []byte) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		defer ln.Close()
+
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		b := make([]byte, 4)
+		if _, err := io.ReadFull(conn, b); err != nil {
+			return
+		}
+
+		msg := make([]byte, binary.BigEndian.Uint32(b))
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			return
+		}
+		msgs <- msg
+
+		binary.BigEndian.PutUint32(b, uint32(len(resp)))
+		_, _ = conn.Write(append(b, resp...))
+	}()
+
+	return ln.Addr().String()
+}
+
+func TestRiemannReporter(t *testing.T) {
+	metricstest.Reset(t)
+	metrics.Gauge("Queue.Depth").Set(150)
+	metrics.FloatCounter("Queue.Bytes").Add(1.5)
+
+	msgs := make(chan []byte, 1)
+	addr := riemannServer(t, []byte{0x10, 0x01}, msgs) // ok: true
+
+	rr := metrics.RiemannReporter{
+		Addr: addr,
+		Host: "web1",
+		Tags: []string{"app"},
+		TTL:  time.Minute,
+		Thresholds: []metrics.Threshold{
+			{Pattern: "Queue.*", Warning: 100, Critical: 200},
+		},
+		Pipeline: metrics.Pipeline{
+			Filters: []metrics.Filter{metrics.Include("Queue.*")},
+		},
+	}
+	if err := rr.Report(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-msgs
+	metricD := []byte{0x71, 0, 0, 0, 0, 0, 0, 0xf8, 0x3f} // metric_d: 1.5
+	for _, s := range []string{"Queue.Depth", "Queue.Bytes", string(metricD), "web1", "app", "warning"} {
+		if !bytes.Contains(msg, []byte(s)) {
+			t.Errorf("Message did not contain %q", s)
+		}
+	}
+}
+
+func TestRiemannReporterError(t *testing.T) {
+	metricstest.Reset(t)
+
+	msgs := make(chan []byte, 1)
+	addr := riemannServer(t, []byte{0x1a, 0x03, 'b', 'a', 'd'}, msgs) // error: "bad"
+
+	err := metrics.RiemannReporter{Addr: addr}.Report()
+	if v, want := err.Error(), "riemann: bad"; v != want {
+		t.Errorf("Error was %q, but expected %q", v, want)
+	}
+}
+
+func TestThreshold(t *testing.T) {
+	above := metrics.Threshold{Warning: 10, Critical: 20}
+	below := metrics.Threshold{Warning: 10, Critical: 5, Below: true}
+
+	for _, test := range []struct {
+		t     metrics.Threshold
+		v     int64
+		state string
+	}{
+		{above, 5, "ok"},
+		{above, 10, "warning"},
+		{above, 25, "critical"},
+		{below, 15, "ok"},
+		{below, 8, "warning"},
+		{below, 5, "critical"},
+	} {
+		if v := test.t.State(test.v); v != test.state {
+			t.Errorf("State of %v was %q, but expected %q", test.v, v, test.state)
+		}
+	}
+}
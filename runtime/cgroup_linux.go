@@ -0,0 +1,175 @@
+package runtime
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/codahale/metrics"
+)
+
+// cgroupRoot is where the process's cgroup hierarchy is mounted. In a
+// container, this is the container's own cgroup.
+var cgroupRoot = "/sys/fs/cgroup"
+
+func init() {
+	if _, ok := readCgroup(cgroupRoot); !ok {
+		return
+	}
+
+	cg := &cgroupGauges{root: cgroupRoot}
+
+	metrics.Gauge("Container.Memory.Limit").SetBatchFunc(cgroupKey{}, cg.init, cg.memoryLimit)
+	metrics.Gauge("Container.Memory.Usage").SetBatchFunc(cgroupKey{}, cg.init, cg.memoryUsage)
+	metrics.Gauge("Container.CPU.Quota").SetBatchFunc(cgroupKey{}, cg.init, cg.cpuQuota)
+
+	metrics.Counter("Container.CPU.Periods").SetBatchFunc(cgroupKey{}, cg.init, cg.periods)
+	metrics.Counter("Container.CPU.ThrottledPeriods").SetBatchFunc(cgroupKey{}, cg.init, cg.throttledPeriods)
+	metrics.Counter("Container.CPU.ThrottledNs").SetBatchFunc(cgroupKey{}, cg.init, cg.throttledNs)
+}
+
+type cgroupKey struct{} // unexported to prevent collision
+
+type cgroupGauges struct {
+	root  string
+	stats cgroupStats
+}
+
+func (cg *cgroupGauges) init() {
+	cg.stats, _ = readCgroup(cg.root)
+}
+
+func (cg *cgroupGauges) memoryLimit() int64 {
+	return cg.stats.memoryLimit
+}
+
+func (cg *cgroupGauges) memoryUsage() int64 {
+	return cg.stats.memoryUsage
+}
+
+func (cg *cgroupGauges) cpuQuota() int64 {
+	return cg.stats.cpuQuota
+}
+
+func (cg *cgroupGauges) periods() uint64 {
+	return cg.stats.periods
+}
+
+func (cg *cgroupGauges) throttledPeriods() uint64 {
+	return cg.stats.throttledPeriods
+}
+
+func (cg *cgroupGauges) throttledNs() uint64 {
+	return cg.stats.throttledNs
+}
+
+// cgroupStats are the resource limits and usage of a cgroup. Limits are zero
+// if there is no limit. The CPU quota is in thousandths of a CPU.
+type cgroupStats struct {
+	memoryLimit, memoryUsage, cpuQuota     int64
+	periods, throttledPeriods, throttledNs uint64
+}
+
+// readCgroup reads the stats of the cgroup mounted at the given root, using the
+// cgroup v2 unified hierarchy if present and the v1 hierarchies otherwise. It
+// returns false if neither is present.
+func readCgroup(root string) (cgroupStats, bool) {
+	var s cgroupStats
+
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+		s.memoryLimit = readInt(filepath.Join(root, "memory.max"))
+		s.memoryUsage = readInt(filepath.Join(root, "memory.current"))
+
+		if f := readFields(filepath.Join(root, "cpu.max")); len(f) == 2 {
+			s.cpuQuota = quota(parseInt(f[0]), parseInt(f[1]))
+		}
+
+		stat := readStat(filepath.Join(root, "cpu.stat"))
+		s.periods = stat["nr_periods"]
+		s.throttledPeriods = stat["nr_throttled"]
+		s.throttledNs = stat["throttled_usec"] * 1000
+		return s, true
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "memory", "memory.limit_in_bytes")); err == nil {
+		s.memoryLimit = readInt(filepath.Join(root, "memory", "memory.limit_in_bytes"))
+		s.memoryUsage = readInt(filepath.Join(root, "memory", "memory.usage_in_bytes"))
+		s.cpuQuota = quota(
+			readInt(filepath.Join(root, "cpu", "cpu.cfs_quota_us")),
+			readInt(filepath.Join(root, "cpu", "cpu.cfs_period_us")),
+		)
+
+		stat := readStat(filepath.Join(root, "cpu", "cpu.stat"))
+		s.periods = stat["nr_periods"]
+		s.throttledPeriods = stat["nr_throttled"]
+		s.throttledNs = stat["throttled_time"]
+
+		// v1 reports an unlimited memory limit as a very large number
+		if s.memoryLimit >= 1<<62 {
+			s.memoryLimit = 0
+		}
+		return s, true
+	}
+
+	return s, false
+}
+
+// quota returns a CPU quota in thousandths of a CPU, or zero if there is no
+// quota.
+func quota(q, period int64) int64 {
+	if q <= 0 || period <= 0 {
+		return 0
+	}
+	return q * 1000 / period
+}
+
+func readFields(path string) []string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(b))
+}
+
+// readInt reads an integer from a file, returning zero if the file is missing
+// or contains "max".
+func readInt(path string) int64 {
+	f := readFields(path)
+	if len(f) == 0 {
+		return 0
+	}
+	return parseInt(f[0])
+}
+
+func parseInt(s string) int64 {
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// readStat reads a file of "key value" lines.
+func readStat(path string) map[string]uint64 {
+	m := make(map[string]uint64)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return m
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		kv := strings.Fields(s.Text())
+		if len(kv) == 2 {
+			if v, err := strconv.ParseUint(kv[1], 10, 64); err == nil {
+				m[kv[0]] = v
+			}
+		}
+	}
+	return m
+}
@@ -0,0 +1,84 @@
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCgroupV2(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"cgroup.controllers": "cpu memory\n",
+		"memory.max":         "1073741824\n",
+		"memory.current":     "536870912\n",
+		"cpu.max":            "50000 100000\n",
+		"cpu.stat":           "usage_usec 100\nnr_periods 10\nnr_throttled 4\nthrottled_usec 250\n",
+	})
+
+	s, ok := readCgroup(root)
+	if !ok {
+		t.Fatal("No cgroup found")
+	}
+
+	want := cgroupStats{
+		memoryLimit:      1073741824,
+		memoryUsage:      536870912,
+		cpuQuota:         500,
+		periods:          10,
+		throttledPeriods: 4,
+		throttledNs:      250000,
+	}
+	if s != want {
+		t.Errorf("Stats were %+v, but expected %+v", s, want)
+	}
+}
+
+func TestCgroupV1(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{
+		"memory/memory.limit_in_bytes": "9223372036854771712\n",
+		"memory/memory.usage_in_bytes": "1024\n",
+		"cpu/cpu.cfs_quota_us":         "-1\n",
+		"cpu/cpu.cfs_period_us":        "100000\n",
+		"cpu/cpu.stat":                 "nr_periods 3\nnr_throttled 1\nthrottled_time 5000\n",
+	})
+
+	s, ok := readCgroup(root)
+	if !ok {
+		t.Fatal("No cgroup found")
+	}
+
+	want := cgroupStats{
+		memoryUsage:      1024,
+		periods:          3,
+		throttledPeriods: 1,
+		throttledNs:      5000,
+	}
+	if s != want {
+		t.Errorf("Stats were %+v, but expected %+v", s, want)
+	}
+}
@@ -0,0 +1,43 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/codahale/metrics"
+)
+
+func init() {
+	metrics.RegisterConfig("runtime", configure)
+}
+
+// configure validates the settings of the runtime collector, with which
+// contention counters can be enabled:
+//
+//	runtime: {Contention: {BlockRate: 10000, MutexFraction: 100}}
+//
+// The other runtime metrics are registered when the package is imported.
+func configure(settings json.RawMessage) (func(), error) {
+	var c struct {
+		Contention *struct {
+			BlockRate, MutexFraction int
+		}
+	}
+
+	if len(settings) > 0 {
+		d := json.NewDecoder(bytes.NewReader(settings))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&c); err != nil {
+			return nil, err
+		}
+	}
+
+	if ct := c.Contention; ct != nil {
+		if ct.BlockRate < 0 || ct.MutexFraction < 0 {
+			return nil, fmt.Errorf("negative contention rate")
+		}
+		return func() { EnableContention(ct.BlockRate, ct.MutexFraction) }, nil
+	}
+	return func() {}, nil
+}
@@ -0,0 +1,85 @@
+package runtime
+
+import (
+	"runtime"
+
+	"github.com/codahale/metrics"
+)
+
+// EnableContention enables the runtime's block and mutex profiles with the
+// given rate and fraction (see runtime.SetBlockProfileRate and
+// runtime.SetMutexProfileFraction) and registers counters of the contention
+// they sample:
+//
+//     Contention.Block.Events
+//     Contention.Block.Cycles
+//     Contention.Mutex.Events
+//     Contention.Mutex.Cycles
+//
+// Events are the number of sampled blocking or contention events, and cycles
+// are the cumulative CPU ticks spent delayed by them. Profiling has a cost
+// proportional to its rate, so both should be kept low in production (e.g., a
+// block rate of 10000 nanoseconds and a mutex fraction of 100). A zero rate or
+// fraction disables the corresponding profile.
+func EnableContention(blockRate, mutexFraction int) {
+	runtime.SetBlockProfileRate(blockRate)
+	runtime.SetMutexProfileFraction(mutexFraction)
+
+	cg := &contentionGauges{}
+
+	metrics.Counter("Contention.Block.Events").SetBatchFunc(contentionKey{}, cg.init, cg.blockEvents)
+	metrics.Counter("Contention.Block.Cycles").SetBatchFunc(contentionKey{}, cg.init, cg.blockCycles)
+	metrics.Counter("Contention.Mutex.Events").SetBatchFunc(contentionKey{}, cg.init, cg.mutexEvents)
+	metrics.Counter("Contention.Mutex.Cycles").SetBatchFunc(contentionKey{}, cg.init, cg.mutexCycles)
+}
+
+type contentionKey struct{} // unexported to prevent collision
+
+type contentionGauges struct {
+	block, mutex profileTotals
+}
+
+type profileTotals struct {
+	events, cycles uint64
+}
+
+func (cg *contentionGauges) init() {
+	cg.block = readProfile(runtime.BlockProfile)
+	cg.mutex = readProfile(runtime.MutexProfile)
+}
+
+func (cg *contentionGauges) blockEvents() uint64 {
+	return cg.block.events
+}
+
+func (cg *contentionGauges) blockCycles() uint64 {
+	return cg.block.cycles
+}
+
+func (cg *contentionGauges) mutexEvents() uint64 {
+	return cg.mutex.events
+}
+
+func (cg *contentionGauges) mutexCycles() uint64 {
+	return cg.mutex.cycles
+}
+
+// readProfile sums the records of a block or mutex profile.
+func readProfile(profile func([]runtime.BlockProfileRecord) (int, bool)) profileTotals {
+	n, _ := profile(nil)
+	for {
+		records := make([]runtime.BlockProfileRecord, n+16)
+
+		var ok bool
+		if n, ok = profile(records); !ok {
+			continue // the profile grew; try again with a larger slice
+		}
+
+		var t profileTotals
+		for _, r := range records[:n] {
+			t.events += uint64(r.Count)
+			t.cycles += uint64(r.Cycles)
+		}
+		return t
+	}
+}
@@ -0,0 +1,68 @@
+package runtime
+
+import (
+	"encoding/json"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+)
+
+func TestContention(t *testing.T) {
+	EnableContention(1, 1)
+	defer runtime.SetBlockProfileRate(0)
+	defer runtime.SetMutexProfileFraction(0)
+
+	var m sync.Mutex
+	m.Lock()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		m.Unlock()
+	}()
+	m.Lock()
+	m.Unlock()
+
+	counters, _ := metrics.Snapshot()
+
+	for _, name := range []string{
+		"Contention.Block.Events",
+		"Contention.Block.Cycles",
+		"Contention.Mutex.Events",
+		"Contention.Mutex.Cycles",
+	} {
+		if _, ok := counters[name]; !ok {
+			t.Errorf("Missing counter %q", name)
+		}
+	}
+
+	if v := counters["Contention.Block.Events"]; v == 0 {
+		t.Error("No block events were recorded")
+	}
+}
+
+func TestConfigureContention(t *testing.T) {
+	defer runtime.SetBlockProfileRate(0)
+	defer runtime.SetMutexProfileFraction(0)
+
+	if err := metrics.Configure(metrics.Config{
+		Collectors: map[string]json.RawMessage{
+			"runtime": json.RawMessage(`{"Contention": {"BlockRate": 1, "MutexFraction": 1}}`),
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := runtime.SetMutexProfileFraction(-1), 1; v != want {
+		t.Errorf("Mutex profile fraction was %v, but expected %v", v, want)
+	}
+
+	if err := metrics.Configure(metrics.Config{
+		Collectors: map[string]json.RawMessage{
+			"runtime": json.RawMessage(`{"Contention": {"BlockRate": -1}}`),
+		},
+	}); err == nil {
+		t.Error("Negative block rate was accepted")
+	}
+}
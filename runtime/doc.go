@@ -15,5 +15,32 @@
 //     Mem.NextGC
 //     Mem.Alloc
 //     Mem.HeapObjects
+//     Mem.HeapInuse
+//     Mem.HeapIdle
+//     Mem.HeapReleased
+//     Mem.StackInuse
+//     Mem.GCCPUFraction (in millionths)
 //     Goroutines.Num
+//
+// and the following counters:
+//
+//     Mem.Mallocs
+//     Mem.Frees
+//
+// On Linux, if the process is in a cgroup (e.g., a container), it also
+// registers the following gauges, with the CPU quota in thousandths of a CPU
+// and zero for no limit:
+//
+//     Container.Memory.Limit
+//     Container.Memory.Usage
+//     Container.CPU.Quota
+//
+// and the following counters:
+//
+//     Container.CPU.Periods
+//     Container.CPU.ThrottledPeriods
+//     Container.CPU.ThrottledNs
+//
+// Lock contention counters are opt-in, since they require the runtime's block
+// and mutex profiles to be enabled; see EnableContention.
 package runtime
@@ -11,11 +11,18 @@ func init() {
 
 	metrics.Counter("Mem.NumGC").SetBatchFunc(key{}, msg.init, msg.numGC)
 	metrics.Counter("Mem.PauseTotalNs").SetBatchFunc(key{}, msg.init, msg.totalPause)
+	metrics.Counter("Mem.Mallocs").SetBatchFunc(key{}, msg.init, msg.mallocs)
+	metrics.Counter("Mem.Frees").SetBatchFunc(key{}, msg.init, msg.frees)
 
 	metrics.Gauge("Mem.LastGC").SetBatchFunc(key{}, msg.init, msg.lastPause)
 	metrics.Gauge("Mem.Alloc").SetBatchFunc(key{}, msg.init, msg.alloc)
 	metrics.Gauge("Mem.HeapObjects").SetBatchFunc(key{}, msg.init, msg.objects)
 	metrics.Gauge("Mem.NextGC").SetBatchFunc(key{}, msg.init, msg.nextGC)
+	metrics.Gauge("Mem.HeapInuse").SetBatchFunc(key{}, msg.init, msg.heapInuse)
+	metrics.Gauge("Mem.HeapIdle").SetBatchFunc(key{}, msg.init, msg.heapIdle)
+	metrics.Gauge("Mem.HeapReleased").SetBatchFunc(key{}, msg.init, msg.heapReleased)
+	metrics.Gauge("Mem.StackInuse").SetBatchFunc(key{}, msg.init, msg.stackInuse)
+	metrics.Gauge("Mem.GCCPUFraction").SetBatchFunc(key{}, msg.init, msg.gcCPUFraction)
 }
 
 type key struct{} // unexported to prevent collision
@@ -51,3 +58,32 @@ func (msg *memStatGauges) objects() int64 {
 func (msg *memStatGauges) nextGC() int64 {
 	return int64(msg.stats.NextGC)
 }
+
+func (msg *memStatGauges) mallocs() uint64 {
+	return msg.stats.Mallocs
+}
+
+func (msg *memStatGauges) frees() uint64 {
+	return msg.stats.Frees
+}
+
+func (msg *memStatGauges) heapInuse() int64 {
+	return int64(msg.stats.HeapInuse)
+}
+
+func (msg *memStatGauges) heapIdle() int64 {
+	return int64(msg.stats.HeapIdle)
+}
+
+func (msg *memStatGauges) heapReleased() int64 {
+	return int64(msg.stats.HeapReleased)
+}
+
+func (msg *memStatGauges) stackInuse() int64 {
+	return int64(msg.stats.StackInuse)
+}
+
+// gcCPUFraction returns the fraction of CPU time used by the GC in millionths.
+func (msg *memStatGauges) gcCPUFraction() int64 {
+	return int64(msg.stats.GCCPUFraction * 1e6)
+}
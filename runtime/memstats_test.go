@@ -12,6 +12,8 @@ func TestMemStats(t *testing.T) {
 	expectedCounters := []string{
 		"Mem.NumGC",
 		"Mem.PauseTotalNs",
+		"Mem.Mallocs",
+		"Mem.Frees",
 	}
 
 	expectedGauges := []string{
@@ -19,6 +21,11 @@ func TestMemStats(t *testing.T) {
 		"Mem.Alloc",
 		"Mem.HeapObjects",
 		"Mem.NextGC",
+		"Mem.HeapInuse",
+		"Mem.HeapIdle",
+		"Mem.HeapReleased",
+		"Mem.StackInuse",
+		"Mem.GCCPUFraction",
 	}
 
 	for _, name := range expectedCounters {
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// A SampledCounter increments a counter for only one in N events, chosen at
+// random, by N times the event's delta, so that the counter's value is an
+// unbiased estimate of the true count. Use a sampled counter for event streams
+// of millions per second, where contention on the counter would otherwise be
+// significant; the estimate's relative error shrinks as the count grows.
+type SampledCounter struct {
+	c Counter
+	n uint64
+}
+
+// NewSampledCounter returns a counter with the given name which records one in
+// n events. A rate of one or less records every event.
+func NewSampledCounter(name string, n int) *SampledCounter {
+	if n < 1 {
+		n = 1
+	}
+	return &SampledCounter{c: Counter(name), n: uint64(n)}
+}
+
+// Add records an event.
+func (s *SampledCounter) Add() {
+	s.AddN(1)
+}
+
+// AddN records an event of the given size.
+func (s *SampledCounter) AddN(delta uint64) {
+	if s.n == 1 || sample()%s.n == 0 {
+		s.c.AddN(delta * s.n)
+	}
+}
+
+// sample returns a pseudo-random number from a per-processor xorshift
+// generator, avoiding the lock which guards math/rand's global source.
+func sample() uint64 {
+	p := samplers.Get().(*uint64)
+	x := *p
+	x ^= x << 13
+	x ^= x >> 7
+	x ^= x << 17
+	*p = x
+	samplers.Put(p)
+	return x
+}
+
+var samplers = sync.Pool{
+	New: func() interface{} {
+		x := mix(uint64(time.Now().UnixNano())) | 1 // must not be zero
+		return &x
+	},
+}
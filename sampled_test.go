@@ -0,0 +1,45 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestSampledCounter(t *testing.T) {
+	metricstest.Reset(t)
+
+	c := metrics.NewSampledCounter("Events", 100)
+	for i := 0; i < 1000000; i++ {
+		c.Add()
+	}
+
+	// the standard error is about 1%
+	if v := metrics.Counter("Events").Value(); v < 950000 || v > 1050000 {
+		t.Errorf("Counter was %v, but expected ~1000000", v)
+	}
+
+	if v := metrics.Counter("Events").Value(); v%100 != 0 {
+		t.Errorf("Counter was %v, but expected a multiple of the rate", v)
+	}
+
+	every := metrics.NewSampledCounter("All", 0)
+	every.AddN(3)
+	metricstest.AssertCounter(t, "All", 3)
+}
+
+func BenchmarkSampledCounterAdd(b *testing.B) {
+	metrics.Reset()
+
+	c := metrics.NewSampledCounter("test.sampled", 100)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Add()
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+)
+
+// A Scope accumulates counter increments and gauge values for a unit of work
+// (e.g., a request or a tenant's batch job) and flushes them into the registry
+// under the scope's prefix when the work is done. Libraries can record into the
+// scope carried by a context without knowing the names it will be published
+// under.
+//
+//	s := metrics.NewScope("Tenant." + tenant + ".")
+//	defer s.Flush()
+//	ctx = metrics.NewContext(ctx, s)
+//	...
+//	metrics.FromContext(ctx).Add("DB.Queries") // Tenant.acme.DB.Queries
+//
+// A nil scope discards everything recorded into it.
+type Scope struct {
+	prefix   string
+	m        sync.Mutex
+	counters map[string]uint64
+	gauges   map[string]int64
+}
+
+// NewScope returns a new scope which flushes into metrics whose names begin with
+// the given prefix.
+func NewScope(prefix string) *Scope {
+	return &Scope{
+		prefix:   prefix,
+		counters: make(map[string]uint64),
+		gauges:   make(map[string]int64),
+	}
+}
+
+// Add increments the scope's counter with the given name by one.
+func (s *Scope) Add(name string) {
+	s.AddN(name, 1)
+}
+
+// AddN increments the scope's counter with the given name by N.
+func (s *Scope) AddN(name string, delta uint64) {
+	if s == nil {
+		return
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.counters[name] += delta
+}
+
+// Set sets the scope's gauge with the given name to the given value.
+func (s *Scope) Set(name string, value int64) {
+	if s == nil {
+		return
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.gauges[name] = value
+}
+
+// Flush adds the scope's counters to the registry's counters, sets the
+// registry's gauges to the scope's gauges, and clears the scope.
+func (s *Scope) Flush() {
+	if s == nil {
+		return
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	for n, v := range s.counters {
+		Counter(s.prefix + n).AddN(v)
+	}
+
+	for n, v := range s.gauges {
+		Gauge(s.prefix + n).Set(v)
+	}
+
+	s.counters = make(map[string]uint64)
+	s.gauges = make(map[string]int64)
+}
+
+type scopeKey struct{} // unexported to prevent collision
+
+// NewContext returns a copy of the context which carries the given scope.
+func NewContext(ctx context.Context, s *Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, s)
+}
+
+// FromContext returns the scope carried by the context, or nil if it carries
+// none.
+func FromContext(ctx context.Context) *Scope {
+	s, _ := ctx.Value(scopeKey{}).(*Scope)
+	return s
+}
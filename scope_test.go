@@ -0,0 +1,36 @@
+package metrics_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestScope(t *testing.T) {
+	metricstest.Reset(t)
+
+	query := func(ctx context.Context) {
+		metrics.FromContext(ctx).Add("DB.Queries")
+		metrics.FromContext(ctx).Set("DB.Rows", 12)
+	}
+
+	s := metrics.NewScope("Tenant.acme.")
+	ctx := metrics.NewContext(context.Background(), s)
+	query(ctx)
+	query(ctx)
+
+	if _, ok := metrics.Gauge("Tenant.acme.DB.Rows").Value(); ok {
+		t.Error("Gauge was published before the scope was flushed")
+	}
+
+	s.Flush()
+	s.Flush()
+
+	metricstest.AssertCounter(t, "Tenant.acme.DB.Queries", 2)
+	metricstest.AssertGauge(t, "Tenant.acme.DB.Rows", 12)
+
+	// contexts without scopes discard values
+	query(context.Background())
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import "sync/atomic"
+
+// InstrumentSelf publishes metrics about the metrics package itself, so that
+// degraded instrumentation can be detected:
+//
+//	Metrics.Counters          the number of registered counters
+//	Metrics.Gauges            the number of registered gauges
+//	Metrics.Histograms        the number of registered histograms
+//	Metrics.HistogramMemory   the approximate memory used by histograms'
+//	                          windows, in bytes
+//	Metrics.SnapshotLockWait  the total time snapshots have waited for the
+//	                          registry's locks, in nanoseconds
+//
+// The following are always published:
+//
+//	Metrics.SnapshotDuration  the duration of the last snapshot, in nanoseconds
+//	Metrics.GaugeErrors       the number of gauge functions which panicked or
+//	                          timed out
+//	Metrics.ExportErrors      the number of reports which could not be
+//	                          delivered by Handler or Webhook
+//	Metrics.CounterViolations the number of counter increments which wrapped
+//	                          around, attempted to decrement a counter, or
+//	                          were rejected by the update validator
+func InstrumentSelf() {
+	var c, g, h, mem int64
+	init := func() {
+		hm.RLock()
+		gm.RLock()
+		cm.RLock()
+		c, g, h = int64(len(counters)+len(counterFuncs)), int64(len(gauges)), int64(len(histograms))
+		cm.RUnlock()
+		gm.RUnlock()
+
+		mem = 0
+		for _, hist := range histograms {
+			mem += int64(hist.memory())
+		}
+		hm.RUnlock()
+	}
+
+	Gauge("Metrics.Counters").SetBatchFunc(selfKey{}, init, func() int64 { return c })
+	Gauge("Metrics.Gauges").SetBatchFunc(selfKey{}, init, func() int64 { return g })
+	Gauge("Metrics.Histograms").SetBatchFunc(selfKey{}, init, func() int64 { return h })
+	Gauge("Metrics.HistogramMemory").SetBatchFunc(selfKey{}, init, func() int64 { return mem })
+	Counter("Metrics.SnapshotLockWait").SetFunc(func() uint64 {
+		return uint64(atomic.LoadInt64(&lockWait))
+	})
+}
+
+type selfKey struct{} // unexported to prevent collisions
+
+// lockWait is the total time, in nanoseconds, snapshots have waited for the
+// registry's locks.
+var lockWait int64
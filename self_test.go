@@ -0,0 +1,59 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codahale/metrics"
+)
+
+func TestInstrumentSelf(t *testing.T) {
+	metrics.Reset()
+	metrics.InstrumentSelf()
+
+	metrics.Counter("whee").Add()
+	h := metrics.NewHistogram("heyo", 1, 1000, 3)
+
+	_, gauges := metrics.Snapshot()
+
+	// whee and Metrics.SnapshotLockWait
+	if v, want := gauges["Metrics.Counters"], int64(2); v != want {
+		t.Errorf("Counters were %v, but expected %v", v, want)
+	}
+
+	// four self-metrics and six quantiles
+	if v, want := gauges["Metrics.Gauges"], int64(10); v != want {
+		t.Errorf("Gauges were %v, but expected %v", v, want)
+	}
+
+	if v, want := gauges["Metrics.Histograms"], int64(1); v != want {
+		t.Errorf("Histograms were %v, but expected %v", v, want)
+	}
+
+	// windows are allocated when values are first recorded
+	if v := gauges["Metrics.HistogramMemory"]; v != 0 {
+		t.Errorf("Histogram memory was %v, but expected none", v)
+	}
+
+	_ = h.RecordValue(10)
+	_, gauges = metrics.Snapshot()
+	if v := gauges["Metrics.HistogramMemory"]; v <= 0 {
+		t.Errorf("Histogram memory was %v, but expected some", v)
+	}
+}
+
+func TestWebhookExportErrors(t *testing.T) {
+	metrics.Reset()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	metrics.Webhook(srv.URL)(metrics.Alert{Name: "test"})
+
+	if v, want := metrics.Counter("Metrics.ExportErrors").Value(), uint64(1); v != want {
+		t.Errorf("Export errors were %v, but expected %v", v, want)
+	}
+}
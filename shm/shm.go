@@ -0,0 +1,265 @@
+// +build !windows
+
+// Package shm provides counters stored in a memory-mapped file, allowing
+// multiple processes on the same host (e.g., pre-forked workers or CGI
+// programs) to increment shared counters which a single process publishes.
+//
+// To use, open the same file in each process:
+//
+//     r, err := shm.Open("/var/run/app.metrics", 1024)
+//     ...
+//     requests, err := r.Counter("Requests")
+//     ...
+//     requests.Add()
+//
+// And in the process which exports metrics, publish the region's counters:
+//
+//     r.Publish("Workers.")
+package shm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"github.com/codahale/metrics"
+)
+
+const (
+	magic      = "METRICS1"
+	headerSize = 16 // magic, slot count (uint32), used slot count (uint32)
+	slotSize   = 64 // name (56 bytes, NUL-padded), value (uint64)
+	nameSize   = slotSize - 8
+)
+
+var (
+	// ErrFull is returned when a region has no free slots for new counters.
+	ErrFull = errors.New("shm: region is full")
+
+	// ErrNameTooLong is returned when a counter's name is too long to be stored.
+	ErrNameTooLong = errors.New("shm: counter name is too long")
+
+	// ErrCorrupt is returned when a file is not a valid region.
+	ErrCorrupt = errors.New("shm: file is not a metrics region")
+)
+
+// A Region is a memory-mapped file of counters.
+type Region struct {
+	f     *os.File
+	data  []byte
+	slots int
+
+	m        sync.Mutex
+	counters map[string]*Counter
+}
+
+// Open opens the region stored in the file at the given path, creating it with
+// room for the given number of counters if it does not exist. Regions opened by
+// other processes must be created with the same number of counters.
+func Open(path string, slots int) (*Region, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := open(f, slots)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func open(f *os.File, slots int) (*Region, error) {
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return nil, err
+	}
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+
+	size := headerSize + slots*slotSize
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if fi.Size() == 0 {
+		header := make([]byte, headerSize)
+		copy(header, magic)
+		binary.LittleEndian.PutUint32(header[8:], uint32(slots))
+		if _, err := f.WriteAt(header, 0); err != nil {
+			return nil, err
+		}
+
+		if err := f.Truncate(int64(size)); err != nil {
+			return nil, err
+		}
+	} else if fi.Size() != int64(size) {
+		return nil, ErrCorrupt
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(data[:8]) != magic || binary.LittleEndian.Uint32(data[8:]) != uint32(slots) {
+		syscall.Munmap(data)
+		return nil, ErrCorrupt
+	}
+
+	return &Region{
+		f:        f,
+		data:     data,
+		slots:    slots,
+		counters: make(map[string]*Counter),
+	}, nil
+}
+
+// Close unmaps the region and closes its file. Counters from the region must
+// not be used after it is closed.
+func (r *Region) Close() error {
+	if err := syscall.Munmap(r.data); err != nil {
+		return err
+	}
+	return r.f.Close()
+}
+
+// A Counter is a monotonically increasing unsigned integer shared between
+// processes.
+type Counter struct {
+	v *uint64
+}
+
+// Add increments the counter by one.
+func (c *Counter) Add() {
+	c.AddN(1)
+}
+
+// AddN increments the counter by N.
+func (c *Counter) AddN(delta uint64) {
+	atomic.AddUint64(c.v, delta)
+}
+
+// Value returns the counter's current value.
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(c.v)
+}
+
+// Counter returns the counter with the given name, allocating a slot for it in
+// the region if no process has done so yet.
+func (r *Region) Counter(name string) (*Counter, error) {
+	if len(name) >= nameSize {
+		return nil, ErrNameTooLong
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if c, ok := r.counters[name]; ok {
+		return c, nil
+	}
+
+	r.scan()
+	if c, ok := r.counters[name]; ok {
+		return c, nil
+	}
+
+	// allocate a new slot while holding an exclusive lock on the file, rescanning
+	// for slots allocated by other processes in the meantime
+	if err := syscall.Flock(int(r.f.Fd()), syscall.LOCK_EX); err != nil {
+		return nil, err
+	}
+	defer syscall.Flock(int(r.f.Fd()), syscall.LOCK_UN)
+
+	r.scan()
+	if c, ok := r.counters[name]; ok {
+		return c, nil
+	}
+
+	used := int(atomic.LoadUint32(r.used()))
+	if used >= r.slots {
+		return nil, ErrFull
+	}
+
+	slot := r.slot(used)
+	copy(slot[:nameSize], name)
+	atomic.StoreUint32(r.used(), uint32(used+1))
+
+	c := &Counter{v: r.value(used)}
+	r.counters[name] = c
+	return c, nil
+}
+
+// Values returns the current values of all counters in the region.
+func (r *Region) Values() map[string]uint64 {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.scan()
+
+	values := make(map[string]uint64, len(r.counters))
+	for n, c := range r.counters {
+		values[n] = c.Value()
+	}
+	return values
+}
+
+// Publish registers each of the region's counters, including those allocated
+// later by other processes, as a counter in the metrics package, with the
+// given prefix prepended to its name.
+func (r *Region) Publish(prefix string) {
+	published := make(map[string]bool)
+
+	metrics.Counter(prefix+"Slots.Used").SetBatchFunc(r, func() {
+		for n := range r.Values() {
+			if published[n] {
+				continue
+			}
+			published[n] = true
+
+			c, err := r.Counter(n)
+			if err != nil {
+				continue
+			}
+			metrics.Counter(prefix + n).SetFunc(c.Value)
+		}
+	}, func() uint64 {
+		return uint64(atomic.LoadUint32(r.used()))
+	})
+}
+
+// scan adds counters for any slots allocated since the last scan. It must be
+// called with r.m held.
+func (r *Region) scan() {
+	used := int(atomic.LoadUint32(r.used()))
+	for i := len(r.counters); i < used && i < r.slots; i++ {
+		slot := r.slot(i)
+		name := slot[:nameSize]
+		if n := bytes.IndexByte(name, 0); n >= 0 {
+			name = name[:n]
+		}
+
+		if _, ok := r.counters[string(name)]; !ok {
+			r.counters[string(name)] = &Counter{v: r.value(i)}
+		}
+	}
+}
+
+func (r *Region) used() *uint32 {
+	return (*uint32)(unsafe.Pointer(&r.data[12]))
+}
+
+func (r *Region) slot(i int) []byte {
+	off := headerSize + i*slotSize
+	return r.data[off : off+slotSize]
+}
+
+func (r *Region) value(i int) *uint64 {
+	return (*uint64)(unsafe.Pointer(&r.slot(i)[nameSize]))
+}
@@ -0,0 +1,101 @@
+// +build !windows
+
+package shm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codahale/metrics"
+)
+
+func TestSharedCounters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "metrics")
+
+	// two regions on the same file act as two processes
+	a, err := Open(path, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	b, err := Open(path, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	ca, err := a.Counter("requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca.AddN(2)
+
+	cb, err := b.Counter("requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb.AddN(3)
+
+	if v, want := a.Values()["requests"], uint64(5); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	metrics.Reset()
+	b.Publish("Workers.")
+
+	other, err := a.Counter("errors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Add()
+
+	counters, _ := metrics.Snapshot()
+
+	if v, want := counters["Workers.requests"], uint64(5); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	if v, want := counters["Workers.errors"], uint64(1); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+}
+
+func TestRegionLimits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := Open(filepath.Join(dir, "metrics"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if _, err := r.Counter("one"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Counter("two"); err != ErrFull {
+		t.Errorf("Error was %v, but expected %v", err, ErrFull)
+	}
+
+	long := string(make([]byte, 100))
+	if _, err := r.Counter(long); err != ErrNameTooLong {
+		t.Errorf("Error was %v, but expected %v", err, ErrNameTooLong)
+	}
+
+	if _, err := Open(filepath.Join(dir, "metrics"), 2); err != ErrCorrupt {
+		t.Errorf("Error was %v, but expected %v", err, ErrCorrupt)
+	}
+}
@@ -0,0 +1,64 @@
+// Package shm provides counters stored in a memory-mapped file. It is not
+// supported on Windows, where Open and Region's methods return ErrUnsupported.
+package shm
+
+import "errors"
+
+var (
+	// ErrUnsupported is returned when opening a region on an unsupported
+	// platform.
+	ErrUnsupported = errors.New("shm: not supported on this platform")
+
+	// ErrFull is returned when a region has no free slots for new counters.
+	ErrFull = errors.New("shm: region is full")
+
+	// ErrNameTooLong is returned when a counter's name is too long to be stored.
+	ErrNameTooLong = errors.New("shm: counter name is too long")
+
+	// ErrCorrupt is returned when a file is not a valid region.
+	ErrCorrupt = errors.New("shm: file is not a metrics region")
+)
+
+// A Region is a memory-mapped file of counters.
+type Region struct{}
+
+// Open returns ErrUnsupported.
+func Open(path string, slots int) (*Region, error) {
+	return nil, ErrUnsupported
+}
+
+// Close returns ErrUnsupported.
+func (r *Region) Close() error {
+	return ErrUnsupported
+}
+
+// A Counter is a monotonically increasing unsigned integer shared between
+// processes.
+type Counter struct{}
+
+// Add does nothing.
+func (c *Counter) Add() {
+}
+
+// AddN does nothing.
+func (c *Counter) AddN(delta uint64) {
+}
+
+// Value returns zero.
+func (c *Counter) Value() uint64 {
+	return 0
+}
+
+// Counter returns ErrUnsupported.
+func (r *Region) Counter(name string) (*Counter, error) {
+	return nil, ErrUnsupported
+}
+
+// Values returns no values.
+func (r *Region) Values() map[string]uint64 {
+	return nil
+}
+
+// Publish does nothing.
+func (r *Region) Publish(prefix string) {
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Shutdown stops the periodic pushes started by the PushEvery, ReportEvery,
+// SendEvery, and SaveEvery methods, then pushes from each once more,
+// concurrently, so that the last partial interval of a process (e.g., a batch
+// job) isn't lost when it exits. Reporters which send counter deltas send the
+// deltas since their last push.
+//
+// Shutdown returns the first error returned by the final pushes, or the
+// context's error if it is done before they complete.
+func Shutdown(ctx context.Context) error {
+	fm.Lock()
+	fs := make([]*flusher, 0, len(flushers))
+	for f := range flushers {
+		fs = append(fs, f)
+	}
+	flushers = make(map[*flusher]struct{})
+	fm.Unlock()
+
+	errs := make(chan error, len(fs))
+	for _, f := range fs {
+		f.t.Stop()
+		go func(f *flusher) {
+			errs <- f.flush()
+		}(f)
+	}
+
+	var first error
+	for range fs {
+		select {
+		case err := <-errs:
+			if err != nil && first == nil {
+				first = err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return first
+}
+
+// flushEvery calls f every d until the returned timer is stopped, counting
+// errors with the given counter, and registers f to be called by Shutdown.
+func flushEvery(d time.Duration, errors Counter, f func() error) Timer {
+	fl := &flusher{f: f, errors: errors}
+	fl.t = repeat(d, func() {
+		_ = fl.flush()
+	})
+
+	fm.Lock()
+	flushers[fl] = struct{}{}
+	fm.Unlock()
+
+	return fl
+}
+
+type flusher struct {
+	t      Timer
+	f      func() error
+	errors Counter
+}
+
+func (fl *flusher) flush() error {
+	err := fl.f()
+	if err != nil {
+		countError(fl.errors, "", err)
+	}
+	return err
+}
+
+func (fl *flusher) Stop() bool {
+	fm.Lock()
+	delete(flushers, fl)
+	fm.Unlock()
+
+	return fl.t.Stop()
+}
+
+var (
+	flushers = make(map[*flusher]struct{})
+	fm       sync.Mutex
+)
@@ -0,0 +1,89 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestShutdown(t *testing.T) {
+	metricstest.Reset(t)
+	c := metricstest.UseFakeClock(t)
+
+	var sends int32
+	m := metrics.Messenger{Send: func([]byte) error {
+		atomic.AddInt32(&sends, 1)
+		return nil
+	}}
+	m.SendEvery(time.Minute)
+
+	stopped := metrics.Messenger{Send: func([]byte) error {
+		t.Error("Stopped messenger sent a report")
+		return nil
+	}}
+	stopped.SendEvery(time.Minute).Stop()
+
+	if err := metrics.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := atomic.LoadInt32(&sends), int32(1); v != want {
+		t.Errorf("Sent %v reports, but expected %v", v, want)
+	}
+
+	c.Advance(time.Minute)
+
+	if v, want := atomic.LoadInt32(&sends), int32(1); v != want {
+		t.Errorf("Sent %v reports after shutdown, but expected %v", v, want)
+	}
+}
+
+func TestShutdownErrors(t *testing.T) {
+	metricstest.Reset(t)
+	metricstest.UseFakeClock(t)
+
+	errSend := errors.New("nope")
+	metrics.Messenger{Send: func([]byte) error { return errSend }}.SendEvery(time.Minute)
+
+	if err := metrics.Shutdown(context.Background()); err != errSend {
+		t.Errorf("Error was %v, but expected %v", err, errSend)
+	}
+	metricstest.AssertCounter(t, "Metrics.ExportErrors", 1)
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	metricstest.Reset(t)
+	metricstest.UseFakeClock(t)
+
+	block := make(chan struct{})
+	defer close(block)
+
+	metrics.Messenger{Send: func([]byte) error {
+		<-block
+		return nil
+	}}.SendEvery(time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := metrics.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Error was %v, but expected %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestPushEveryInvalidInterval(t *testing.T) {
+	metricstest.Reset(t)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("PushEvery did not panic")
+		}
+	}()
+
+	metrics.RemoteWriter{URL: "http://localhost"}.PushEvery(0)
+}
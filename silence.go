@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Silence disables the metrics matching the given pattern, which is either the
+// name of a metric or a prefix followed by an asterisk (e.g., "Runtime.*").
+// Recording to a silenced metric does nothing, and silenced metrics are
+// omitted from snapshots without their functions being called. Silencing a
+// histogram also silences its quantile gauges.
+//
+// Use Silence to temporarily disable an expensive gauge function in
+// production. Batch initializers are still called, as they may be shared with
+// metrics which are not silenced.
+func Silence(pattern string) {
+	slm.Lock()
+	defer slm.Unlock()
+
+	silenced[pattern] = struct{}{}
+	atomic.StoreInt32(&silencing, int32(len(silenced)))
+}
+
+// Unsilence re-enables the metrics matching the given pattern, which must have
+// been passed to Silence.
+func Unsilence(pattern string) {
+	slm.Lock()
+	defer slm.Unlock()
+
+	delete(silenced, pattern)
+	atomic.StoreInt32(&silencing, int32(len(silenced)))
+}
+
+// Silenced returns the patterns passed to Silence, in sorted order.
+func Silenced() []string {
+	slm.RLock()
+	defer slm.RUnlock()
+
+	patterns := make([]string, 0, len(silenced))
+	for p := range silenced {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+	return patterns
+}
+
+// SilenceHandler returns an HTTP handler for administering silenced metrics.
+// A GET request responds with the silenced patterns as a JSON array. A POST
+// request silences the patterns in its silence form values and unsilences the
+// patterns in its unsilence form values, e.g.:
+//
+//	curl -d silence=Runtime.* http://localhost:8080/debug/metrics/silenced
+func SilenceHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET", "HEAD":
+		case "POST":
+			if err := r.ParseForm(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			for _, p := range r.PostForm["silence"] {
+				Silence(p)
+			}
+
+			for _, p := range r.PostForm["unsilence"] {
+				Unsilence(p)
+			}
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(Silenced())
+	})
+}
+
+// isSilenced returns true if the metric with the given name is silenced.
+func isSilenced(name string) bool {
+	if atomic.LoadInt32(&silencing) == 0 {
+		return false
+	}
+
+	slm.RLock()
+	defer slm.RUnlock()
+
+	if matchSilenced(name) {
+		return true
+	}
+
+	// silencing a histogram silences its quantile gauges
+	for _, q := range quantiles {
+		if strings.HasSuffix(name, q.suffix) && matchSilenced(strings.TrimSuffix(name, q.suffix)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchSilenced returns true if the name matches any silenced pattern. It must
+// be called with slm held.
+func matchSilenced(name string) bool {
+	if _, ok := silenced[name]; ok {
+		return true
+	}
+
+	for p := range silenced {
+		if strings.HasSuffix(p, "*") && strings.HasPrefix(name, p[:len(p)-1]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var (
+	silenced  = make(map[string]struct{})
+	silencing int32
+	slm       sync.RWMutex
+)
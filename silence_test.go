@@ -0,0 +1,85 @@
+package metrics_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/codahale/metrics"
+)
+
+func TestSilence(t *testing.T) {
+	metrics.Reset()
+
+	calls := 0
+	metrics.Gauge("Expensive").SetFunc(func() int64 {
+		calls++
+		return 1
+	})
+	metrics.Counter("Runtime.GCs").Add()
+	metrics.Counter("Requests").Add()
+	h := metrics.NewHistogram("Latency", 1, 1000, 3)
+
+	metrics.Silence("Expensive")
+	metrics.Silence("Runtime.*")
+	metrics.Silence("Latency")
+	defer func() {
+		for _, p := range metrics.Silenced() {
+			metrics.Unsilence(p)
+		}
+	}()
+
+	metrics.Counter("Requests").Add()
+	h.RecordValue(100)
+
+	counters, gauges := metrics.Snapshot()
+
+	if v, want := len(gauges), 0; v != want {
+		t.Errorf("Gauge count was %v, but expected %v: %v", v, want, gauges)
+	}
+
+	if v, ok := counters["Runtime.GCs"]; ok {
+		t.Errorf("Counter was %v, but expected nothing", v)
+	}
+
+	if v, want := counters["Requests"], uint64(2); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	if v, want := calls, 0; v != want {
+		t.Errorf("Gauge was called %v times, but expected %v", v, want)
+	}
+
+	metrics.Unsilence("Latency")
+
+	if v, want := h.TotalCount(), int64(0); v != want {
+		t.Errorf("Count was %v, but expected %v", v, want)
+	}
+
+	if v, want := metrics.Silenced(), []string{"Expensive", "Runtime.*"}; strings.Join(v, ",") != strings.Join(want, ",") {
+		t.Errorf("Silenced was %v, but expected %v", v, want)
+	}
+}
+
+func TestSilenceHandler(t *testing.T) {
+	metrics.Reset()
+	defer metrics.Unsilence("Runtime.*")
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/", strings.NewReader(url.Values{
+		"silence": {"Runtime.*"},
+	}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	metrics.SilenceHandler().ServeHTTP(w, r)
+
+	var patterns []string
+	if err := json.NewDecoder(w.Body).Decode(&patterns); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := strings.Join(patterns, ","), "Runtime.*"; v != want {
+		t.Errorf("Silenced was %v, but expected %v", v, want)
+	}
+}
@@ -0,0 +1,317 @@
+package metrics
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrSketchAccuracy is returned when merging sketches with different relative
+// accuracies.
+var ErrSketchAccuracy = errors.New("sketches have different relative accuracies")
+
+// ErrNotFinite is returned when recording a NaN or infinite value in a sketch.
+var ErrNotFinite = errors.New("value is not finite")
+
+// A Sketch tracks the distribution of a stream of values of any magnitude or
+// sign using a DDSketch, which estimates each quantile to within a fixed
+// relative accuracy (e.g., 1%) without a minimum or maximum value, publishing
+// the same quantile gauges as a histogram. Use a sketch instead of a histogram
+// when the range of the values is unknown or huge.
+//
+// Unlike a histogram, a sketch does not drop old values; it covers all values
+// recorded since it was created or last reset. Sketches from several processes
+// can be combined exactly by merging their snapshots.
+type Sketch struct {
+	name     string
+	accuracy float64
+	logGamma float64
+
+	m       sync.Mutex
+	current SketchSnapshot
+	frozen  SketchSnapshot // the values at the most recent snapshot
+}
+
+// A SketchSnapshot is a copy of a sketch's values, which may be encoded (e.g.,
+// as JSON), merged with snapshots of other sketches with the same relative
+// accuracy, and queried.
+type SketchSnapshot struct {
+	RelativeAccuracy float64          // the relative accuracy of quantiles
+	Count            uint64           // the number of recorded values
+	Sum              float64          // the sum of the recorded values
+	Min              float64          // the smallest recorded value
+	Max              float64          // the largest recorded value
+	Zero             uint64           // the number of zero values
+	Positive         map[int32]uint64 // counts of positive values by bin
+	Negative         map[int32]uint64 // counts of negative values by bin
+}
+
+// NewSketch returns a sketch which estimates quantiles to within the given
+// relative accuracy (e.g., 0.01 for 1%). NewSketch panics if a sketch with
+// the given name already exists or the accuracy is not between 0 and 1.
+func NewSketch(name string, relativeAccuracy float64) *Sketch {
+	if !(relativeAccuracy > 0 && relativeAccuracy < 1) {
+		panic("relative accuracy must be between 0 and 1")
+	}
+
+	s := &Sketch{
+		name:     name,
+		accuracy: relativeAccuracy,
+		logGamma: math.Log((1 + relativeAccuracy) / (1 - relativeAccuracy)),
+	}
+	s.current = s.empty()
+	s.frozen = s.empty()
+
+	name, ok := resolve(name)
+	if !ok {
+		return s
+	}
+	s.name = name
+
+	hm.Lock()
+	if _, ok := sketches[name]; ok {
+		hm.Unlock()
+		panic(name + " already exists")
+	}
+	sketches[name] = s
+
+	for _, q := range quantiles {
+		setGaugeBatchFunc(name+q.suffix, sname(name), s.freeze, s.valueAt(q.q))
+	}
+	hm.Unlock()
+
+	notifyRegistered(name, "sketch")
+	return s
+}
+
+// Name returns the name of the sketch.
+func (s *Sketch) Name() string {
+	return s.name
+}
+
+// RecordValue records the given value, or returns an Error if it is NaN or
+// infinite.
+func (s *Sketch) RecordValue(v float64) error {
+	if atomic.LoadInt32(&disabled) != 0 || isSilenced(s.name) {
+		return nil
+	}
+
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return Error{s.name, ErrNotFinite}
+	}
+
+	s.m.Lock()
+	c := &s.current
+	switch {
+	case v > 0:
+		c.Positive[s.bin(v)]++
+	case v < 0:
+		c.Negative[s.bin(-v)]++
+	default:
+		c.Zero++
+	}
+	if c.Count == 0 || v < c.Min {
+		c.Min = v
+	}
+	if c.Count == 0 || v > c.Max {
+		c.Max = v
+	}
+	c.Count++
+	c.Sum += v
+	s.m.Unlock()
+
+	notifyUpdated(s.name, "sketch")
+	return nil
+}
+
+// ValueAtQuantile returns the estimated value at the given quantile (0-100).
+func (s *Sketch) ValueAtQuantile(q float64) float64 {
+	return s.Snapshot().ValueAtQuantile(q)
+}
+
+// Snapshot returns a copy of the sketch's values.
+func (s *Sketch) Snapshot() SketchSnapshot {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.current.copy()
+}
+
+// Merge adds the values of the given snapshot to the sketch, or returns
+// ErrSketchAccuracy if its relative accuracy differs from the sketch's.
+func (s *Sketch) Merge(o SketchSnapshot) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.current.Merge(o)
+}
+
+// Reset discards all of the sketch's values.
+func (s *Sketch) Reset() {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.current = s.empty()
+}
+
+// Remove removes the sketch and its quantile gauges.
+func (s *Sketch) Remove() {
+	hm.Lock()
+	exists := sketches[s.name] == s
+	if exists {
+		for _, q := range quantiles {
+			removeGauge(s.name + q.suffix)
+		}
+		delete(sketches, s.name)
+	}
+	hm.Unlock()
+
+	if exists {
+		notifyRemoved(s.name, "sketch")
+	}
+}
+
+// bin returns the bin of the given positive value: the smallest i such that
+// v <= gamma^i.
+func (s *Sketch) bin(v float64) int32 {
+	return int32(math.Ceil(math.Log(v) / s.logGamma))
+}
+
+func (s *Sketch) empty() SketchSnapshot {
+	return SketchSnapshot{
+		RelativeAccuracy: s.accuracy,
+		Positive:         make(map[int32]uint64),
+		Negative:         make(map[int32]uint64),
+	}
+}
+
+func (s *Sketch) freeze() {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.frozen = s.current.copy()
+}
+
+func (s *Sketch) valueAt(q float64) func() int64 {
+	return func() int64 {
+		s.m.Lock()
+		defer s.m.Unlock()
+
+		return int64(math.Round(s.frozen.ValueAtQuantile(q)))
+	}
+}
+
+// ValueAtQuantile returns the estimated value at the given quantile (0-100),
+// or zero if the snapshot is empty.
+func (s SketchSnapshot) ValueAtQuantile(q float64) float64 {
+	if s.Count == 0 {
+		return 0
+	}
+
+	q = math.Max(0, math.Min(100, q))
+	rank := uint64(q / 100 * float64(s.Count-1))
+	gamma := (1 + s.RelativeAccuracy) / (1 - s.RelativeAccuracy)
+
+	// the midpoint of a bin, in relative terms, is within the accuracy of
+	// every value in it
+	value := func(i int32) float64 {
+		return 2 * math.Pow(gamma, float64(i)) / (gamma + 1)
+	}
+
+	clamp := func(v float64) float64 {
+		return math.Max(s.Min, math.Min(s.Max, v))
+	}
+
+	var n uint64
+
+	// negative values in ascending order are in descending order of bin
+	neg := sortedBins(s.Negative)
+	for i := len(neg) - 1; i >= 0; i-- {
+		if n += s.Negative[neg[i]]; n > rank {
+			return clamp(-value(neg[i]))
+		}
+	}
+
+	if n += s.Zero; n > rank {
+		return 0
+	}
+
+	for _, b := range sortedBins(s.Positive) {
+		if n += s.Positive[b]; n > rank {
+			return clamp(value(b))
+		}
+	}
+
+	return s.Max
+}
+
+// Merge adds the values of the given snapshot to this one, or returns
+// ErrSketchAccuracy if their relative accuracies differ. A zero snapshot takes
+// the relative accuracy of the first snapshot merged into it, so snapshots
+// from many processes can be merged into one.
+func (s *SketchSnapshot) Merge(o SketchSnapshot) error {
+	if o.Count == 0 {
+		return nil
+	}
+
+	if s.RelativeAccuracy == 0 && s.Count == 0 {
+		s.RelativeAccuracy = o.RelativeAccuracy
+	}
+
+	if s.RelativeAccuracy != o.RelativeAccuracy {
+		return ErrSketchAccuracy
+	}
+
+	if s.Positive == nil {
+		s.Positive = make(map[int32]uint64, len(o.Positive))
+	}
+	if s.Negative == nil {
+		s.Negative = make(map[int32]uint64, len(o.Negative))
+	}
+
+	for b, n := range o.Positive {
+		s.Positive[b] += n
+	}
+	for b, n := range o.Negative {
+		s.Negative[b] += n
+	}
+
+	if s.Count == 0 || o.Min < s.Min {
+		s.Min = o.Min
+	}
+	if s.Count == 0 || o.Max > s.Max {
+		s.Max = o.Max
+	}
+	s.Zero += o.Zero
+	s.Count += o.Count
+	s.Sum += o.Sum
+	return nil
+}
+
+func (s SketchSnapshot) copy() SketchSnapshot {
+	c := s
+	c.Positive = make(map[int32]uint64, len(s.Positive))
+	for b, n := range s.Positive {
+		c.Positive[b] = n
+	}
+	c.Negative = make(map[int32]uint64, len(s.Negative))
+	for b, n := range s.Negative {
+		c.Negative[b] = n
+	}
+	return c
+}
+
+func sortedBins(m map[int32]uint64) []int32 {
+	bins := make([]int32, 0, len(m))
+	for b := range m {
+		bins = append(bins, b)
+	}
+	sort.Slice(bins, func(i, j int) bool { return bins[i] < bins[j] })
+	return bins
+}
+
+type sname string // unexported to prevent collisions
+
+var sketches = make(map[string]*Sketch) // guarded by hm
@@ -0,0 +1,103 @@
+package metrics_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestSketch(t *testing.T) {
+	metricstest.Reset(t)
+
+	s := metrics.NewSketch("Size", 0.01)
+	defer s.Remove()
+
+	for i := 1; i <= 10000; i++ {
+		if err := s.RecordValue(float64(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, q := range []float64{50, 90, 99} {
+		assertRelative(t, s.ValueAtQuantile(q), q*100, 0.01)
+	}
+
+	gauges := metricstest.CollectGauges(t)
+	assertRelative(t, float64(gauges["Size.P99"]), 9900, 0.01)
+
+	if err := s.RecordValue(math.NaN()); err == nil {
+		t.Error("NaN was recorded")
+	}
+}
+
+func TestSketchHugeRange(t *testing.T) {
+	metricstest.Reset(t)
+
+	s := metrics.NewSketch("Huge", 0.01)
+	defer s.Remove()
+
+	for _, v := range []float64{-1e12, -3, 0, 1e-6, 1e15} {
+		_ = s.RecordValue(v)
+	}
+
+	for i, want := range []float64{-1e12, -3, 0, 1e-6, 1e15} {
+		assertRelative(t, s.ValueAtQuantile(float64(i)*25), want, 0.01)
+	}
+}
+
+func TestSketchMerge(t *testing.T) {
+	metricstest.Reset(t)
+
+	a := metrics.NewSketch("A", 0.01)
+	defer a.Remove()
+	b := metrics.NewSketch("B", 0.01)
+	defer b.Remove()
+
+	for i := 1; i <= 1000; i++ {
+		_ = a.RecordValue(float64(i))
+		_ = b.RecordValue(float64(i + 1000))
+	}
+
+	// snapshots survive encoding
+	j, err := json.Marshal(b.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var snap metrics.SketchSnapshot
+	if err := json.Unmarshal(j, &snap); err != nil {
+		t.Fatal(err)
+	}
+
+	var merged metrics.SketchSnapshot
+	if err := merged.Merge(a.Snapshot()); err != nil {
+		t.Fatal(err)
+	}
+	if err := merged.Merge(snap); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := merged.Count, uint64(2000); v != want {
+		t.Errorf("Count was %v, but expected %v", v, want)
+	}
+	assertRelative(t, merged.ValueAtQuantile(50), 1000, 0.01)
+	assertRelative(t, merged.ValueAtQuantile(100), 2000, 0.01)
+
+	c := metrics.NewSketch("C", 0.05)
+	defer c.Remove()
+
+	if err := c.Merge(a.Snapshot()); err != metrics.ErrSketchAccuracy {
+		t.Errorf("Error was %v, but expected ErrSketchAccuracy", err)
+	}
+}
+
+func assertRelative(t *testing.T, v, want, accuracy float64) {
+	t.Helper()
+
+	if math.Abs(v-want) > math.Abs(want)*accuracy {
+		t.Errorf("Value was %v, but expected %v±%v%%", v, want, accuracy*100)
+	}
+}
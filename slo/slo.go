@@ -0,0 +1,175 @@
+// Package slo tracks service level objectives, publishing the remaining error
+// budget and the rate at which it is being burned as gauges.
+//
+// An objective is defined over a pair of counters which count good and bad
+// events, such as the <name>.Successes and <name>.Failures counters of a
+// metrics.Outcome:
+//
+//	o := metrics.NewOutcome("API")
+//	t := slo.TrackOutcome("API", slo.Objective{
+//		Target: 0.999,
+//		Window: 30 * 24 * time.Hour,
+//	})
+//	defer t.Stop()
+//
+// This publishes the following gauges:
+//
+//	API.BudgetRemaining   the fraction of the error budget for the objective's
+//	                      window which remains, in thousandths (negative once
+//	                      the budget is exhausted)
+//	API.BurnRate.<window> the rate at which the error budget was consumed over
+//	                      each of the burn windows (e.g., API.BurnRate.1h), in
+//	                      thousandths of the rate which would exactly exhaust
+//	                      the budget over the objective's window
+//
+// Latency objectives (e.g., 99% of requests in under 250ms) can be tracked by
+// recording latencies with a Latency, which counts values above and below the
+// threshold in addition to recording them in a histogram.
+package slo
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/codahale/metrics"
+)
+
+// BurnWindows are the windows over which burn rates are published, suitable
+// for multi-window, multi-burn-rate alerts. Windows longer than an objective's
+// window are skipped.
+var BurnWindows = []time.Duration{
+	5 * time.Minute,
+	30 * time.Minute,
+	1 * time.Hour,
+	2 * time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+	72 * time.Hour,
+}
+
+// Resolution is the interval at which a tracker samples its counters.
+const Resolution = time.Minute
+
+// An Objective is a target fraction of good events over a window of time.
+type Objective struct {
+	Target float64       // the fraction of events which should be good (e.g., 0.99)
+	Window time.Duration // the window over which the target applies (e.g., 30 days)
+}
+
+// A Tracker publishes the error budget and burn rates of an objective.
+type Tracker struct {
+	o         Objective
+	good, bad *metrics.CounterWindows
+	names     []string
+}
+
+// Track begins tracking the objective over the counters with the given names,
+// publishing gauges prefixed with the given name.
+func Track(name string, o Objective, good, bad string) *Tracker {
+	t := &Tracker{
+		o:    o,
+		good: metrics.NewCounterWindows(good, Resolution, o.Window),
+		bad:  metrics.NewCounterWindows(bad, Resolution, o.Window),
+	}
+
+	n := name + ".BudgetRemaining"
+	t.names = append(t.names, n)
+	metrics.Gauge(n).SetFunc(t.budgetRemaining)
+
+	for _, w := range BurnWindows {
+		if w > o.Window {
+			continue
+		}
+
+		w := w
+		n := name + ".BurnRate." + formatWindow(w)
+		t.names = append(t.names, n)
+		metrics.Gauge(n).SetFunc(func() int64 {
+			return t.burnRate(w)
+		})
+	}
+
+	return t
+}
+
+// TrackOutcome begins tracking the objective over the successes and failures
+// counted by the metrics.Outcome with the given name.
+func TrackOutcome(name string, o Objective) *Tracker {
+	return Track(name, o, name+".Successes", name+".Failures")
+}
+
+// Stop stops tracking the objective and removes its gauges.
+func (t *Tracker) Stop() {
+	t.good.Stop()
+	t.bad.Stop()
+	for _, n := range t.names {
+		metrics.Gauge(n).Remove()
+	}
+}
+
+func (t *Tracker) budgetRemaining() int64 {
+	good, bad := t.good.Count(t.o.Window), t.bad.Count(t.o.Window)
+	budget := (1 - t.o.Target) * float64(good+bad)
+	if budget <= 0 {
+		if bad > 0 {
+			return -1000
+		}
+		return 1000
+	}
+	return int64(math.Round(1000 * (1 - float64(bad)/budget)))
+}
+
+func (t *Tracker) burnRate(w time.Duration) int64 {
+	good, bad := t.good.Count(w), t.bad.Count(w)
+	if good+bad == 0 || t.o.Target >= 1 {
+		return 0
+	}
+	return int64(math.Round(1000 * float64(bad) / float64(good+bad) / (1 - t.o.Target)))
+}
+
+// A Latency records latencies in a histogram and counts those at or below a
+// threshold in the <name>.Good counter and those above it in the <name>.Bad
+// counter, so that a latency objective can be tracked with Track.
+type Latency struct {
+	h         *metrics.Histogram
+	threshold int64
+	good, bad metrics.Counter
+}
+
+// NewLatency returns a latency recorder for the given histogram and threshold.
+func NewLatency(h *metrics.Histogram, threshold int64) *Latency {
+	return &Latency{
+		h:         h,
+		threshold: threshold,
+		good:      metrics.Counter(h.Name() + ".Good"),
+		bad:       metrics.Counter(h.Name() + ".Bad"),
+	}
+}
+
+// RecordValue records the given latency, or returns an error if the value is
+// out of the histogram's range. Out-of-range values are still counted.
+func (l *Latency) RecordValue(v int64) error {
+	if v <= l.threshold {
+		l.good.Add()
+	} else {
+		l.bad.Add()
+	}
+	return l.h.RecordValue(v)
+}
+
+// Track begins tracking the objective over the latencies recorded by l.
+func (l *Latency) Track(o Objective) *Tracker {
+	return Track(l.h.Name(), o, string(l.good), string(l.bad))
+}
+
+// formatWindow formats a duration compactly (e.g., 5m or 6h).
+func formatWindow(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return d.String()
+}
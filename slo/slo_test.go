@@ -0,0 +1,72 @@
+package slo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestTrackOutcome(t *testing.T) {
+	metricstest.Reset(t)
+	c := metricstest.UseFakeClock(t)
+
+	o := metrics.NewOutcome("API")
+	tr := TrackOutcome("API", Objective{Target: 0.99, Window: 2 * time.Hour})
+	defer tr.Stop()
+
+	metricstest.AssertGauge(t, "API.BudgetRemaining", 1000)
+
+	for i := 0; i < 990; i++ {
+		o.Success()
+	}
+	for i := 0; i < 5; i++ {
+		o.Failure(nil)
+	}
+	c.Advance(time.Hour)
+
+	// 5 of 10 failures allowed for 995 requests
+	metricstest.AssertGauge(t, "API.BudgetRemaining", 497)
+
+	for i := 0; i < 10; i++ {
+		o.Success()
+	}
+	for i := 0; i < 10; i++ {
+		o.Failure(nil)
+	}
+
+	// half of the last five minutes' requests failed, 50x the allowed rate
+	metricstest.AssertGauge(t, "API.BurnRate.5m", 50000)
+
+	gauges := metricstest.CollectGauges(t)
+	if _, ok := gauges["API.BurnRate.6h"]; ok {
+		t.Error("Burn rate was published for a window longer than the objective's")
+	}
+
+	tr.Stop()
+
+	gauges = metricstest.CollectGauges(t)
+	if _, ok := gauges["API.BudgetRemaining"]; ok {
+		t.Error("Gauge was not removed")
+	}
+}
+
+func TestLatency(t *testing.T) {
+	metricstest.Reset(t)
+	metricstest.UseFakeClock(t)
+
+	l := NewLatency(metrics.NewHistogram("Latency", 1, 1000, 3), 250)
+	tr := l.Track(Objective{Target: 0.5, Window: time.Hour})
+	defer tr.Stop()
+
+	l.RecordValue(100)
+	l.RecordValue(250)
+	l.RecordValue(300)
+	l.RecordValue(5000)
+
+	metricstest.AssertCounter(t, "Latency.Good", 2)
+	metricstest.AssertCounter(t, "Latency.Bad", 2)
+	metricstest.AssertGauge(t, "Latency.BurnRate.1h", 1000)
+	metricstest.AssertGauge(t, "Latency.BudgetRemaining", 0)
+}
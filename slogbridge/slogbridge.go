@@ -0,0 +1,57 @@
+//go:build go1.21
+
+// Package slogbridge logs the events of the metrics package, such as exporter
+// failures and trigger threshold crossings, through log/slog with structured
+// attributes, rather than only counting them:
+//
+//	slogbridge.Install(slog.Default())
+package slogbridge
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/codahale/metrics"
+)
+
+// Install logs each event of the metrics package with the given logger,
+// replacing any event handler already set with metrics.SetEventHandler.
+// Errors are logged at the error level, name violations and thresholds being
+// crossed at the warning level, and values falling back below thresholds at
+// the info level.
+func Install(l *slog.Logger) {
+	metrics.SetEventHandler(Handler(l))
+}
+
+// Handler returns an event handler which logs events with the given logger,
+// for use with metrics.SetEventHandler.
+func Handler(l *slog.Logger) func(metrics.Event) {
+	return func(e metrics.Event) {
+		level, msg := slog.LevelError, "metrics error"
+		switch e.Kind {
+		case "name violation":
+			level, msg = slog.LevelWarn, "metric name violation"
+		case "threshold":
+			level, msg = slog.LevelInfo, "metric below threshold"
+			if e.Above {
+				level, msg = slog.LevelWarn, "metric above threshold"
+			}
+		}
+
+		attrs := make([]slog.Attr, 0, 4)
+		if e.Name != "" {
+			attrs = append(attrs, slog.String("metric", e.Name))
+		}
+		if e.Counter != "" {
+			attrs = append(attrs, slog.String("counter", e.Counter))
+		}
+		if e.Err != nil {
+			attrs = append(attrs, slog.Any("error", e.Err))
+		}
+		if e.Kind == "threshold" {
+			attrs = append(attrs, slog.Int64("value", e.Value))
+		}
+
+		l.LogAttrs(context.Background(), level, msg, attrs...)
+	}
+}
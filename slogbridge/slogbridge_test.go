@@ -0,0 +1,52 @@
+//go:build go1.21
+
+package slogbridge
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/codahale/metrics"
+)
+
+func TestInstall(t *testing.T) {
+	metrics.Reset()
+
+	var buf bytes.Buffer
+	Install(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+	defer metrics.SetEventHandler(nil)
+
+	tr := metrics.NewTrigger("Conns", 10, 5, func(bool) {})
+	defer tr.Stop()
+
+	metrics.Gauge("Conns").Set(12)
+	metrics.Gauge("Conns").Set(4)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	metrics.Webhook(srv.URL)(metrics.Alert{Name: "overloaded"})
+
+	expected := []string{
+		`level=WARN msg="metric above threshold" metric=Conns value=12`,
+		`level=INFO msg="metric below threshold" metric=Conns value=4`,
+		`level=ERROR msg="metrics error" metric=overloaded counter=Metrics.ExportErrors error="webhook responded with 502 Bad Gateway"`,
+	}
+
+	if v, want := strings.Split(strings.TrimSpace(buf.String()), "\n"), expected; strings.Join(v, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Log was\n%s\nbut expected\n%s", strings.Join(v, "\n"), strings.Join(want, "\n"))
+	}
+}
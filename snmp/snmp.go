@@ -0,0 +1,613 @@
+// Package snmp exposes selected metrics to SNMP managers via an AgentX (RFC
+// 2741) subagent, which registers with the host's SNMP master agent (e.g.,
+// net-snmp's snmpd with "master agentx" configured).
+//
+// Each metric is published as a scalar object under a base OID, in the order
+// the metrics are listed: the first is <base>.1.0, the second <base>.2.0, and
+// so on. Counters are published as Counter64 values, and gauges as Integer32
+// values, clamped to the type's range.
+//
+//	s := &snmp.Subagent{
+//		OID:      "1.3.6.1.4.1.99999.1",
+//		Counters: []string{"HTTP.Requests"},
+//		Gauges:   []string{"HTTP.InFlight"},
+//	}
+//	go func() {
+//		log.Println(s.Serve())
+//	}()
+//
+// WriteMIB generates a MIB module describing the objects, for use by managers.
+package snmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/codahale/metrics"
+)
+
+// A Subagent answers SNMP requests for a set of metrics on behalf of a master
+// agent.
+type Subagent struct {
+	Network     string        // the master agent's network; defaults to "unix"
+	Addr        string        // the master agent's address; defaults to "/var/agentx/master"
+	OID         string        // the base OID of the metrics (e.g., "1.3.6.1.4.1.99999.1")
+	Description string        // a description of the subagent
+	Counters    []string      // the names of the counters to publish
+	Gauges      []string      // the names of the gauges to publish
+	Timeout     time.Duration // the timeout for requests; defaults to 5s
+
+	m      sync.Mutex
+	conn   net.Conn
+	closed bool
+}
+
+// Serve connects to the master agent, registers the subagent's OID, and
+// answers requests until the connection fails or the subagent is closed.
+func (s *Subagent) Serve() error {
+	base, err := parseOID(s.OID)
+	if err != nil {
+		return err
+	}
+
+	network, addr := s.Network, s.Addr
+	if network == "" {
+		network = "unix"
+	}
+
+	if addr == "" {
+		addr = "/var/agentx/master"
+	}
+
+	conn, err := net.DialTimeout(network, addr, s.timeout())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	s.m.Lock()
+	if s.closed {
+		s.m.Unlock()
+		return nil
+	}
+	s.conn = conn
+	s.m.Unlock()
+
+	c := &session{conn: conn, objects: s.objects(base)}
+	if err := c.open(s.Description, s.timeout()); err != nil {
+		return err
+	}
+
+	if err := c.register(base, s.timeout()); err != nil {
+		return err
+	}
+
+	err = c.serve()
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	return err
+}
+
+// Close closes the subagent's session with the master agent.
+func (s *Subagent) Close() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.closed = true
+	if s.conn == nil {
+		return nil
+	}
+
+	var b bytes.Buffer
+	b.Write([]byte{reasonShutdown, 0, 0, 0})
+	_ = writePDU(s.conn, pdu{typ: pduClose, payload: b.Bytes()})
+	return s.conn.Close()
+}
+
+func (s *Subagent) timeout() time.Duration {
+	if s.Timeout == 0 {
+		return 5 * time.Second
+	}
+	return s.Timeout
+}
+
+type object struct {
+	oid     []uint32
+	name    string
+	counter bool
+}
+
+func (s *Subagent) objects(base []uint32) []object {
+	var objects []object
+	for i, n := range append(append([]string(nil), s.Counters...), s.Gauges...) {
+		oid := append(append([]uint32(nil), base...), uint32(i+1), 0)
+		objects = append(objects, object{oid: oid, name: n, counter: i < len(s.Counters)})
+	}
+	return objects
+}
+
+// WriteMIB writes an SMIv2 MIB module with the given name (e.g.,
+// "MY-SERVICE-MIB") describing the subagent's objects.
+func (s *Subagent) WriteMIB(w io.Writer, module string) error {
+	base, err := parseOID(s.OID)
+	if err != nil {
+		return err
+	}
+
+	root := identifier(strings.TrimSuffix(module, "-MIB"))
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s DEFINITIONS ::= BEGIN\n\n", module)
+	fmt.Fprint(&b, "IMPORTS\n    OBJECT-TYPE, Counter64, Integer32\n        FROM SNMPv2-SMI;\n\n")
+
+	parts := make([]string, len(base))
+	for i, n := range base {
+		parts[i] = strconv.FormatUint(uint64(n), 10)
+	}
+	if parts[0] == "1" {
+		parts[0] = "iso"
+	}
+	fmt.Fprintf(&b, "%s OBJECT IDENTIFIER ::= { %s }\n", root, strings.Join(parts, " "))
+
+	for _, o := range s.objects(base) {
+		syntax := "Integer32"
+		if o.counter {
+			syntax = "Counter64"
+		}
+
+		id := identifier(o.name)
+		fmt.Fprintf(&b, "\n%s%s%s OBJECT-TYPE\n", root, strings.ToUpper(id[:1]), id[1:])
+		fmt.Fprintf(&b, "    SYNTAX      %s\n", syntax)
+		fmt.Fprint(&b, "    MAX-ACCESS  read-only\n")
+		fmt.Fprint(&b, "    STATUS      current\n")
+		fmt.Fprintf(&b, "    DESCRIPTION \"%s\"\n", strings.ReplaceAll(o.name, `"`, `'`))
+		fmt.Fprintf(&b, "    ::= { %s %d }\n", root, o.oid[len(o.oid)-2])
+	}
+
+	fmt.Fprint(&b, "\nEND\n")
+
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// identifier converts a name (e.g., "HTTP.Requests" or "MY-SERVICE") to an
+// ASN.1 identifier (e.g., "hTTPRequests" or "myService").
+func identifier(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) || r > unicode.MaxASCII {
+			upper = true
+			continue
+		}
+
+		switch {
+		case b.Len() == 0:
+			if unicode.IsDigit(r) {
+				b.WriteByte('m')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		case upper:
+			b.WriteRune(unicode.ToUpper(r))
+		case allUpper(name):
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+		upper = false
+	}
+
+	if b.Len() == 0 {
+		return "m"
+	}
+	return b.String()
+}
+
+func allUpper(s string) bool {
+	return strings.ToUpper(s) == s
+}
+
+func parseOID(s string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimPrefix(s, "."), ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("snmp: invalid OID %q", s)
+	}
+
+	oid := make([]uint32, len(parts))
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("snmp: invalid OID %q", s)
+		}
+		oid[i] = uint32(n)
+	}
+	return oid, nil
+}
+
+// compareOIDs compares two OIDs lexicographically.
+func compareOIDs(a, b []uint32) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(a) - len(b)
+}
+
+const (
+	pduOpen     = 1
+	pduClose    = 2
+	pduRegister = 3
+	pduGet      = 5
+	pduGetNext  = 6
+	pduGetBulk  = 7
+	pduTestSet  = 8
+	pduCommit   = 9
+	pduUndo     = 10
+	pduCleanup  = 11
+	pduResponse = 18
+
+	flagNonDefaultContext = 0x08
+	flagNetworkByteOrder  = 0x10
+
+	typeInteger        = 2
+	typeCounter64      = 70
+	typeNoSuchObject   = 128
+	typeEndOfMIBView   = 130
+	errNotWritable     = 17
+	reasonShutdown     = 5
+	headerLen          = 20
+	internetPrefixSize = 4 // 1.3.6.1
+)
+
+var errMalformed = errors.New("snmp: malformed AgentX PDU")
+
+type pdu struct {
+	typ, flags                   byte
+	session, transaction, packet uint32
+	payload                      []byte
+}
+
+func readPDU(r io.Reader) (p pdu, order binary.ByteOrder, err error) {
+	var h [headerLen]byte
+	if _, err = io.ReadFull(r, h[:]); err != nil {
+		return
+	}
+
+	order = binary.LittleEndian
+	if h[2]&flagNetworkByteOrder != 0 {
+		order = binary.BigEndian
+	}
+
+	p = pdu{
+		typ:         h[1],
+		flags:       h[2],
+		session:     order.Uint32(h[4:]),
+		transaction: order.Uint32(h[8:]),
+		packet:      order.Uint32(h[12:]),
+		payload:     make([]byte, order.Uint32(h[16:])),
+	}
+	_, err = io.ReadFull(r, p.payload)
+	return
+}
+
+// writePDU writes a PDU in network byte order.
+func writePDU(w io.Writer, p pdu) error {
+	h := make([]byte, headerLen, headerLen+len(p.payload))
+	h[0] = 1
+	h[1] = p.typ
+	h[2] = p.flags | flagNetworkByteOrder
+	binary.BigEndian.PutUint32(h[4:], p.session)
+	binary.BigEndian.PutUint32(h[8:], p.transaction)
+	binary.BigEndian.PutUint32(h[12:], p.packet)
+	binary.BigEndian.PutUint32(h[16:], uint32(len(p.payload)))
+	_, err := w.Write(append(h, p.payload...))
+	return err
+}
+
+// writeOID appends an OID in network byte order, compressing the internet
+// prefix.
+func writeOID(b *bytes.Buffer, oid []uint32, include bool) {
+	prefix := byte(0)
+	if len(oid) > internetPrefixSize && oid[0] == 1 && oid[1] == 3 && oid[2] == 6 && oid[3] == 1 && oid[4] < 256 {
+		prefix = byte(oid[4])
+		oid = oid[internetPrefixSize+1:]
+	}
+
+	inc := byte(0)
+	if include {
+		inc = 1
+	}
+	b.Write([]byte{byte(len(oid)), prefix, inc, 0})
+
+	var buf [4]byte
+	for _, n := range oid {
+		binary.BigEndian.PutUint32(buf[:], n)
+		b.Write(buf[:])
+	}
+}
+
+func readOID(b []byte, order binary.ByteOrder) (oid []uint32, include bool, rest []byte, err error) {
+	if len(b) < 4 {
+		return nil, false, nil, errMalformed
+	}
+
+	n, prefix := int(b[0]), b[1]
+	include = b[2] != 0
+	b = b[4:]
+
+	if len(b) < 4*n {
+		return nil, false, nil, errMalformed
+	}
+
+	if prefix != 0 {
+		oid = append(oid, 1, 3, 6, 1, uint32(prefix))
+	}
+	for i := 0; i < n; i++ {
+		oid = append(oid, order.Uint32(b[4*i:]))
+	}
+	return oid, include, b[4*n:], nil
+}
+
+func writeString(b *bytes.Buffer, s string) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], uint32(len(s)))
+	b.Write(buf[:])
+	b.WriteString(s)
+	for i := len(s); i%4 != 0; i++ {
+		b.WriteByte(0)
+	}
+}
+
+func skipString(b []byte, order binary.ByteOrder) ([]byte, error) {
+	if len(b) < 4 {
+		return nil, errMalformed
+	}
+
+	n := int(order.Uint32(b))
+	n += (4 - n%4) % 4
+	if len(b) < 4+n {
+		return nil, errMalformed
+	}
+	return b[4+n:], nil
+}
+
+// A session is an open AgentX session with a master agent.
+type session struct {
+	conn    net.Conn
+	id      uint32
+	packet  uint32
+	objects []object
+}
+
+// request sends a PDU and waits for the master agent's response.
+func (c *session) request(typ byte, payload []byte, timeout time.Duration) error {
+	c.packet++
+	if err := c.conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	defer func() { _ = c.conn.SetDeadline(time.Time{}) }()
+
+	if err := writePDU(c.conn, pdu{typ: typ, session: c.id, packet: c.packet, payload: payload}); err != nil {
+		return err
+	}
+
+	for {
+		p, order, err := readPDU(c.conn)
+		if err != nil {
+			return err
+		}
+
+		if p.typ != pduResponse || p.packet != c.packet {
+			continue
+		}
+
+		if len(p.payload) < 8 {
+			return errMalformed
+		}
+
+		if code := order.Uint16(p.payload[4:]); code != 0 {
+			return fmt.Errorf("snmp: master agent returned error %d", code)
+		}
+
+		c.id = p.session
+		return nil
+	}
+}
+
+func (c *session) open(descr string, timeout time.Duration) error {
+	var b bytes.Buffer
+	b.Write([]byte{byte(timeout / time.Second), 0, 0, 0})
+	writeOID(&b, nil, false)
+	writeString(&b, descr)
+	return c.request(pduOpen, b.Bytes(), timeout)
+}
+
+func (c *session) register(base []uint32, timeout time.Duration) error {
+	var b bytes.Buffer
+	b.Write([]byte{byte(timeout / time.Second), 127, 0, 0})
+	writeOID(&b, base, false)
+	return c.request(pduRegister, b.Bytes(), timeout)
+}
+
+// serve answers requests from the master agent until the session is closed.
+func (c *session) serve() error {
+	for {
+		p, order, err := readPDU(c.conn)
+		if err != nil {
+			return err
+		}
+
+		var code, index uint16
+		var vbs []byte
+
+		switch p.typ {
+		case pduGet, pduGetNext, pduGetBulk:
+			vbs, err = c.answer(p, order)
+			if err != nil {
+				return err
+			}
+		case pduTestSet:
+			code, index = errNotWritable, 1
+		case pduCommit, pduUndo, pduCleanup:
+		case pduClose:
+			return nil
+		default:
+			continue
+		}
+
+		// sysUpTime, error, and index, followed by the variable bindings
+		var b bytes.Buffer
+		b.Write([]byte{0, 0, 0, 0, byte(code >> 8), byte(code), byte(index >> 8), byte(index)})
+		b.Write(vbs)
+
+		if err := writePDU(c.conn, pdu{
+			typ:         pduResponse,
+			session:     p.session,
+			transaction: p.transaction,
+			packet:      p.packet,
+			payload:     b.Bytes(),
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+// answer returns the variable bindings answering a Get, GetNext, or GetBulk
+// request.
+func (c *session) answer(p pdu, order binary.ByteOrder) ([]byte, error) {
+	b := p.payload
+	if p.flags&flagNonDefaultContext != 0 {
+		var err error
+		if b, err = skipString(b, order); err != nil {
+			return nil, err
+		}
+	}
+
+	nonRepeaters, maxRepetitions := 0, 1
+	if p.typ == pduGetBulk {
+		if len(b) < 4 {
+			return nil, errMalformed
+		}
+		nonRepeaters, maxRepetitions = int(order.Uint16(b)), int(order.Uint16(b[2:]))
+		b = b[4:]
+	}
+
+	type searchRange struct {
+		start, end []uint32
+		include    bool
+	}
+
+	var ranges []searchRange
+	for len(b) > 0 {
+		var r searchRange
+		var err error
+		if r.start, r.include, b, err = readOID(b, order); err != nil {
+			return nil, err
+		}
+
+		if r.end, _, b, err = readOID(b, order); err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+	}
+
+	counters, gauges := metrics.Snapshot()
+
+	var out bytes.Buffer
+	for i, r := range ranges {
+		if p.typ == pduGet {
+			c.writeExact(&out, r.start, counters, gauges)
+			continue
+		}
+
+		n := maxRepetitions
+		if i < nonRepeaters {
+			n = 1
+		}
+
+		start, include := r.start, r.include
+		for j := 0; j < n; j++ {
+			o, ok := c.next(start, r.end, include)
+			if !ok {
+				writeVarBind(&out, start, typeEndOfMIBView, nil)
+				break
+			}
+			writeObject(&out, o, counters, gauges)
+			start, include = o.oid, false
+		}
+	}
+
+	return out.Bytes(), nil
+}
+
+func (c *session) writeExact(b *bytes.Buffer, oid []uint32, counters map[string]uint64, gauges map[string]int64) {
+	for _, o := range c.objects {
+		if compareOIDs(o.oid, oid) == 0 {
+			writeObject(b, o, counters, gauges)
+			return
+		}
+	}
+	writeVarBind(b, oid, typeNoSuchObject, nil)
+}
+
+// next returns the first object after the start OID (or at it, if include is
+// true) and before the end OID, if it is not empty.
+func (c *session) next(start, end []uint32, include bool) (object, bool) {
+	for _, o := range c.objects {
+		cmp := compareOIDs(o.oid, start)
+		if cmp < 0 || cmp == 0 && !include {
+			continue
+		}
+
+		if len(end) > 0 && compareOIDs(o.oid, end) >= 0 {
+			break
+		}
+		return o, true
+	}
+	return object{}, false
+}
+
+func writeObject(b *bytes.Buffer, o object, counters map[string]uint64, gauges map[string]int64) {
+	if o.counter {
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], counters[o.name])
+		writeVarBind(b, o.oid, typeCounter64, buf[:])
+		return
+	}
+
+	v := gauges[o.name]
+	if v > math.MaxInt32 {
+		v = math.MaxInt32
+	} else if v < math.MinInt32 {
+		v = math.MinInt32
+	}
+
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], uint32(int32(v)))
+	writeVarBind(b, o.oid, typeInteger, buf[:])
+}
+
+func writeVarBind(b *bytes.Buffer, oid []uint32, typ uint16, data []byte) {
+	b.Write([]byte{byte(typ >> 8), byte(typ), 0, 0})
+	writeOID(b, oid, false)
+	b.Write(data)
+}
@@ -0,0 +1,168 @@
+package snmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestSubagent(t *testing.T) {
+	metricstest.Reset(t)
+
+	metrics.Counter("HTTP.Requests").AddN(7)
+	metrics.Gauge("HTTP.InFlight").Set(-3)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	s := &Subagent{
+		Network:  "tcp",
+		Addr:     l.Addr().String(),
+		OID:      "1.3.6.1.4.1.99999.1",
+		Counters: []string{"HTTP.Requests"},
+		Gauges:   []string{"HTTP.InFlight"},
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Serve()
+	}()
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// open and register
+	for _, typ := range []byte{pduOpen, pduRegister} {
+		p, _, err := readPDU(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if p.typ != typ {
+			t.Fatalf("PDU type was %d, but expected %d", p.typ, typ)
+		}
+
+		if err := writePDU(conn, pdu{typ: pduResponse, session: 42, packet: p.packet, payload: make([]byte, 8)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	base := []uint32{1, 3, 6, 1, 4, 1, 99999, 1}
+	request := func(typ byte, prefix []byte, oids ...[]uint32) []byte {
+		var b bytes.Buffer
+		b.Write(prefix)
+		for _, oid := range oids {
+			writeOID(&b, oid, false)
+			writeOID(&b, nil, false)
+		}
+
+		if err := writePDU(conn, pdu{typ: typ, session: 42, packet: 100, payload: b.Bytes()}); err != nil {
+			t.Fatal(err)
+		}
+
+		p, _, err := readPDU(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if p.typ != pduResponse || p.packet != 100 {
+			t.Fatalf("Response was %+v", p)
+		}
+		return p.payload[8:]
+	}
+
+	varBind := func(b []byte) (typ uint16, oid []uint32, rest []byte) {
+		typ = binary.BigEndian.Uint16(b)
+		oid, _, rest, err := readOID(b[4:], binary.BigEndian)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return typ, oid, rest
+	}
+
+	// get the counter
+	typ, oid, rest := varBind(request(pduGet, nil, append(base, 1, 0)))
+	if typ != typeCounter64 || compareOIDs(oid, append(base, 1, 0)) != 0 {
+		t.Errorf("Variable was %d %v, but expected a counter", typ, oid)
+	} else if v := binary.BigEndian.Uint64(rest); v != 7 {
+		t.Errorf("Counter was %v, but expected 7", v)
+	}
+
+	// get the next object after the counter, the gauge
+	typ, oid, rest = varBind(request(pduGetNext, nil, append(base, 1, 0)))
+	if typ != typeInteger || compareOIDs(oid, append(base, 2, 0)) != 0 {
+		t.Errorf("Variable was %d %v, but expected a gauge", typ, oid)
+	} else if v := int32(binary.BigEndian.Uint32(rest)); v != -3 {
+		t.Errorf("Gauge was %v, but expected -3", v)
+	}
+
+	// walk off the end of the objects
+	typ, _, _ = varBind(request(pduGetNext, nil, append(base, 2, 0)))
+	if typ != typeEndOfMIBView {
+		t.Errorf("Variable type was %d, but expected end of MIB view", typ)
+	}
+
+	// bulk walk from the base
+	b := request(pduGetBulk, []byte{0, 0, 0, 5}, base)
+	var types []uint16
+	for len(b) > 0 {
+		typ, _, b = varBind(b)
+		switch typ {
+		case typeCounter64:
+			b = b[8:]
+		case typeInteger:
+			b = b[4:]
+		}
+		types = append(types, typ)
+	}
+
+	if len(types) != 3 || types[0] != typeCounter64 || types[1] != typeInteger || types[2] != typeEndOfMIBView {
+		t.Errorf("Bulk types were %v", types)
+	}
+
+	// close the session
+	if err := writePDU(conn, pdu{typ: pduClose, session: 42, payload: []byte{reasonShutdown, 0, 0, 0}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteMIB(t *testing.T) {
+	s := &Subagent{
+		OID:      "1.3.6.1.4.1.99999.1",
+		Counters: []string{"HTTP.Requests"},
+		Gauges:   []string{"HTTP.InFlight"},
+	}
+
+	var b bytes.Buffer
+	if err := s.WriteMIB(&b, "MY-SERVICE-MIB"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"MY-SERVICE-MIB DEFINITIONS ::= BEGIN\n",
+		"myService OBJECT IDENTIFIER ::= { iso 3 6 1 4 1 99999 1 }\n",
+		"myServiceHTTPRequests OBJECT-TYPE\n    SYNTAX      Counter64\n",
+		"myServiceHTTPInFlight OBJECT-TYPE\n    SYNTAX      Integer32\n",
+		"    ::= { myService 2 }\n",
+		"\nEND\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("MIB was missing %q:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SetStaleAfter omits counters and gauges from snapshots if they have not been
+// written to (e.g., with Add or Set) within the given duration, so that
+// exporters do not forward the values of components which have stopped
+// running. Counters and gauges with functions are never stale. A zero duration
+// disables the omission of stale metrics.
+//
+// Writes are only tracked while SetStaleAfter is in effect, so it should be
+// called before metrics are written; metrics which have not been written since
+// it was called are never considered stale.
+func SetStaleAfter(d time.Duration) {
+	atomic.StoreInt64(&staleAfter, int64(d))
+	if d > 0 {
+		atomic.StoreInt32(&tracking, 1)
+	}
+}
+
+// LastUpdated returns the time the counter or gauge with the given name was
+// last written to, or false if the time is unknown. Writes are only tracked
+// while SetStaleAfter is in effect.
+func LastUpdated(name string) (time.Time, bool) {
+	p, ok := updated.Load(name)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(0, atomic.LoadInt64(p.(*int64))), true
+}
+
+// touch records a write to the metric with the given name, if writes are being
+// tracked.
+func touch(name string) {
+	if atomic.LoadInt32(&tracking) == 0 {
+		return
+	}
+
+	t := now().UnixNano()
+	p, ok := updated.Load(name)
+	if !ok {
+		p, _ = updated.LoadOrStore(name, new(int64))
+	}
+	atomic.StoreInt64(p.(*int64), t)
+}
+
+// untouch forgets the last write to the metric with the given name.
+func untouch(name string) {
+	if atomic.LoadInt32(&tracking) != 0 {
+		updated.Delete(name)
+	}
+}
+
+// isStale returns true if the metric with the given name has not been written
+// to within the stale duration as of the given time.
+func isStale(name string, t time.Time) bool {
+	d := atomic.LoadInt64(&staleAfter)
+	if d == 0 {
+		return false
+	}
+
+	p, ok := updated.Load(name)
+	if !ok {
+		return false
+	}
+	return t.UnixNano()-atomic.LoadInt64(p.(*int64)) > d
+}
+
+var (
+	updated    sync.Map // the times of metrics' last writes, by name, as *int64
+	tracking   int32    // non-zero if writes are being tracked
+	staleAfter int64
+)
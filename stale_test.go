@@ -0,0 +1,49 @@
+package metrics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestStaleAfter(t *testing.T) {
+	metricstest.Reset(t)
+	c := metricstest.UseFakeClock(t)
+
+	metrics.SetStaleAfter(time.Minute)
+	defer metrics.SetStaleAfter(0)
+
+	metrics.Counter("Worker.Jobs").Add()
+	metrics.Gauge("Worker.Queue").Set(3)
+	metrics.Gauge("Live").SetFunc(func() int64 { return 1 })
+
+	if v, ok := metrics.LastUpdated("Worker.Jobs"); !ok || !v.Equal(c.Now()) {
+		t.Errorf("Last update was %v/%v, but expected %v", v, ok, c.Now())
+	}
+
+	c.Advance(2 * time.Minute)
+	metrics.Counter("Other").Add()
+
+	counters, gauges := metrics.Snapshot()
+
+	if v, ok := counters["Worker.Jobs"]; ok {
+		t.Errorf("Counter was %v, but expected nothing", v)
+	}
+
+	if v, ok := gauges["Worker.Queue"]; ok {
+		t.Errorf("Gauge was %v, but expected nothing", v)
+	}
+
+	if v, want := gauges["Live"], int64(1); v != want {
+		t.Errorf("Gauge was %v, but expected %v", v, want)
+	}
+
+	if v, want := counters["Other"], uint64(1); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	metrics.Counter("Worker.Jobs").Add()
+	metricstest.AssertCounter(t, "Worker.Jobs", 2)
+}
@@ -0,0 +1,221 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A StatsDServer receives metrics in the StatsD protocol over UDP and records
+// them in the registry, so that scripts and cron jobs on the same host can
+// contribute metrics to a process's exports without a StatsD daemon.
+//
+// Counters (c) are added to counters, with negative values ignored and sampled
+// values scaled up by their sample rates. Gauges (g) are set, or adjusted if
+// their values are signed. Timers and histograms (ms, h, and d) are recorded,
+// rounded to integers, into histograms of values from 1 to 3,600,000 (an hour,
+// in milliseconds). Sets (s) are counted by a Uniques with a one-minute window.
+// DogStatsD tags are ignored.
+//
+// Since packets are unauthenticated, a server records at most 1,000 distinct
+// names, and rejects names beginning with "Metrics.", which are reserved for
+// the package's own metrics. Lines which cannot be parsed or recorded, or
+// which exceed the limit, increment the Metrics.StatsDErrors counter.
+type StatsDServer struct {
+	conn net.PacketConn
+
+	m          sync.Mutex
+	names      map[string]struct{}
+	histograms map[string]*Histogram
+	uniques    map[string]*Uniques
+}
+
+// statsDMaxNames is the number of distinct names a StatsDServer records.
+const statsDMaxNames = 1000
+
+// ListenStatsD listens for StatsD packets on the given UDP address (e.g.,
+// "127.0.0.1:8125") and records them until the server is closed.
+func ListenStatsD(addr string) (*StatsDServer, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &StatsDServer{
+		conn:       conn,
+		names:      make(map[string]struct{}),
+		histograms: make(map[string]*Histogram),
+		uniques:    make(map[string]*Uniques),
+	}
+	go s.serve()
+
+	return s, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *StatsDServer) Addr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// Close stops the server. The metrics it recorded remain registered.
+func (s *StatsDServer) Close() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	for _, u := range s.uniques {
+		u.Stop()
+	}
+	return s.conn.Close()
+}
+
+func (s *StatsDServer) serve() {
+	b := make([]byte, 65535)
+	for {
+		n, _, err := s.conn.ReadFrom(b)
+		if err != nil {
+			return
+		}
+
+		for _, line := range strings.Split(string(b[:n]), "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+
+			if err := s.handle(line); err != nil {
+				Counter("Metrics.StatsDErrors").Add()
+			}
+		}
+	}
+}
+
+var (
+	errStatsD      = errors.New("metrics: invalid statsd line")
+	errStatsDNames = errors.New("metrics: too many statsd names")
+)
+
+// handle records a single line of the form name:value|type[|@rate][|#tags].
+func (s *StatsDServer) handle(line string) (err error) {
+	i := strings.IndexByte(line, ':')
+	if i <= 0 {
+		return errStatsD
+	}
+	name, fields := line[:i], strings.Split(line[i+1:], "|")
+	if len(fields) < 2 || strings.HasPrefix(name, "Metrics.") {
+		return errStatsD
+	}
+	value, typ := fields[0], fields[1]
+
+	rate := 1.0
+	for _, f := range fields[2:] {
+		if strings.HasPrefix(f, "@") {
+			if rate, err = strconv.ParseFloat(f[1:], 64); err != nil || rate <= 0 || rate > 1 {
+				return errStatsD
+			}
+		}
+	}
+
+	if typ == "s" {
+		if !s.admit(name) {
+			return errStatsDNames
+		}
+		s.uniquesFor(name).Add(value)
+		return nil
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return err
+	}
+
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return errStatsD
+	}
+
+	switch typ {
+	case "c", "g", "ms", "h", "d":
+		if !s.admit(name) {
+			return errStatsDNames
+		}
+	default:
+		return errStatsD
+	}
+
+	switch typ {
+	case "c":
+		if v > 0 {
+			Counter(name).AddN(uint64(math.Round(v / rate)))
+		}
+	case "g":
+		if value[0] == '+' || value[0] == '-' {
+			cur, _ := Gauge(name).Value()
+			Gauge(name).Set(cur + int64(math.Round(v)))
+		} else {
+			Gauge(name).Set(int64(math.Round(v)))
+		}
+	case "ms", "h", "d":
+		h, err := s.histogramFor(name)
+		if err != nil {
+			return err
+		}
+		return h.RecordValue(int64(math.Round(v)))
+	}
+	return nil
+}
+
+// admit returns whether the server records the given name, which it does if
+// the name has been seen before or the limit of distinct names is not reached.
+func (s *StatsDServer) admit(name string) bool {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if _, ok := s.names[name]; ok {
+		return true
+	}
+
+	if len(s.names) >= statsDMaxNames {
+		return false
+	}
+	s.names[name] = struct{}{}
+	return true
+}
+
+// histogramFor returns the histogram with the given name, creating it if
+// necessary.
+func (s *StatsDServer) histogramFor(name string) (h *Histogram, err error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if h, ok := s.histograms[name]; ok {
+		return h, nil
+	}
+
+	// NewHistogram panics if another histogram already has the name
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("metrics: %v", e)
+		}
+	}()
+
+	h = NewHistogram(name, 1, int64(time.Hour/time.Millisecond), 3)
+	s.histograms[name] = h
+	return h, nil
+}
+
+// uniquesFor returns the unique counter with the given name, creating it if
+// necessary.
+func (s *StatsDServer) uniquesFor(name string) *Uniques {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	u, ok := s.uniques[name]
+	if !ok {
+		u = NewUniques(name, time.Minute)
+		s.uniques[name] = u
+	}
+	return u
+}
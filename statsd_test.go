@@ -0,0 +1,101 @@
+package metrics_test
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestStatsDServer(t *testing.T) {
+	metricstest.Reset(t)
+
+	s, err := metrics.ListenStatsD("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	conn, err := net.Dial("udp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, p := range []string{
+		"jobs.run:1|c\njobs.run:2|c|@0.5\njobs.queue:10|g",
+		"jobs.queue:-3|g\njobs.time:320.4|ms|#env:prod\njobs.user:alice|s",
+		"jobs.user:bob|s\njobs.user:alice|s\nbogus",
+		"jobs.bad:NaN|g\njobs.bad:+Inf|ms",
+	} {
+		if _, err := conn.Write([]byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for metrics.Counter("Metrics.StatsDErrors").Value() < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	metricstest.AssertCounter(t, "jobs.run", 5)
+	metricstest.AssertCounter(t, "Metrics.StatsDErrors", 3)
+	metricstest.AssertGauge(t, "jobs.queue", 7)
+	metricstest.AssertGauge(t, "jobs.time.P50", 320)
+	metricstest.AssertGauge(t, "jobs.user", 2)
+
+	_, gauges := metrics.Snapshot()
+	for _, n := range []string{"jobs.bad", "jobs.bad.P50"} {
+		if v, ok := gauges[n]; ok {
+			t.Errorf("Gauge %s was %v, but expected nothing", n, v)
+		}
+	}
+}
+
+func TestStatsDServerLimits(t *testing.T) {
+	metricstest.Reset(t)
+
+	s, err := metrics.ListenStatsD("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	conn, err := net.Dial("udp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("Metrics.StatsDErrors:5|c")); err != nil {
+		t.Fatal(err)
+	}
+
+	// one more distinct name than the limit, in packets small enough for UDP
+	for i := 0; i <= 1000; i += 100 {
+		var b strings.Builder
+		for j := i; j < i+100 && j <= 1000; j++ {
+			fmt.Fprintf(&b, "spam.%d:1|ms\n", j)
+		}
+		if _, err := conn.Write([]byte(b.String())); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for metrics.Counter("Metrics.StatsDErrors").Value() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	metricstest.AssertCounter(t, "Metrics.StatsDErrors", 2)
+	metricstest.AssertGauge(t, "spam.999.P50", 1)
+
+	_, gauges := metrics.Snapshot()
+	if _, ok := gauges["spam.1000.P50"]; ok {
+		t.Error("Name over the limit was recorded")
+	}
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// A Stream is an HTTP handler which streams reports of all metrics to clients
+// as Server-Sent Events, allowing lightweight dashboards to update live
+// without polling. Each report is sent as a "report" event whose data is the
+// report encoded as JSON, e.g.:
+//
+//	const source = new EventSource("/debug/metrics/stream");
+//	source.addEventListener("report", (e) => render(JSON.parse(e.data)));
+//
+// A report is sent when a client connects, once per interval thereafter, and
+// whenever Send is called. Clients may request a different interval with an
+// interval query parameter (e.g., "?interval=5s").
+type Stream struct {
+	// Pipeline is applied to each report before it is sent.
+	Pipeline Pipeline
+
+	interval time.Duration
+
+	m       sync.Mutex
+	clients map[chan struct{}]struct{}
+}
+
+// NewStream returns a stream which sends reports once per the given interval.
+func NewStream(interval time.Duration) *Stream {
+	return &Stream{
+		interval: interval,
+		clients:  make(map[chan struct{}]struct{}),
+	}
+}
+
+// Send sends a report to every connected client immediately.
+func (s *Stream) Send() {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	for c := range s.clients {
+		select {
+		case c <- struct{}{}:
+		default: // a report is already pending
+		}
+	}
+}
+
+// ServeHTTP streams reports until the client disconnects.
+func (s *Stream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	interval := s.interval
+	if v := r.URL.Query().Get("interval"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "invalid interval", http.StatusBadRequest)
+			return
+		}
+		interval = d
+	}
+
+	send := make(chan struct{}, 1)
+	s.m.Lock()
+	s.clients[send] = struct{}{}
+	s.m.Unlock()
+
+	defer func() {
+		s.m.Lock()
+		delete(s.clients, send)
+		s.m.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		b, err := json.Marshal(s.Pipeline.Apply(Capture()))
+		if err == nil {
+			_, err = fmt.Fprintf(w, "event: report\ndata: %s\n\n", b)
+		}
+
+		if err != nil {
+			countError("Metrics.ExportErrors", "", err)
+			return
+		}
+		f.Flush()
+
+		select {
+		case <-t.C:
+		case <-send:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package metrics_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestStream(t *testing.T) {
+	metricstest.Reset(t)
+	metrics.Counter("whee").Add()
+
+	s := metrics.NewStream(time.Hour)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if v, want := resp.Header.Get("Content-Type"), "text/event-stream"; v != want {
+		t.Errorf("Content-Type was %q, but expected %q", v, want)
+	}
+
+	events := bufio.NewReader(resp.Body)
+	next := func() metrics.Report {
+		var r metrics.Report
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if strings.HasPrefix(line, "data: ") {
+				if err := json.Unmarshal([]byte(line[6:]), &r); err != nil {
+					t.Fatal(err)
+				}
+				return r
+			}
+		}
+	}
+
+	if v, want := next().Counters["whee"], uint64(1); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	metrics.Counter("whee").Add()
+	s.Send()
+
+	if v, want := next().Counters["whee"], uint64(2); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+}
@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A SyslogReporter sends the values of metrics as RFC 5424 syslog messages, for
+// environments where syslog is the only approved path off a host. Each metric
+// is sent as an informational message with a metric@32473 structured data
+// element describing it, e.g.:
+//
+//	<134>1 2024-05-01T12:00:00.000000Z web1 app 1234 metrics [metric@32473 name="HTTP.Requests" type="counter" value="1042"] HTTP.Requests=1042
+//
+// Histograms are sent with their count and the values at each quantile (e.g.,
+// p99="12"), rather than a single value.
+type SyslogReporter struct {
+	// Network and Addr are the network ("udp", "tcp", or "unixgram") and
+	// address of the syslog server. If both are empty, the local syslog
+	// socket, /dev/log, is used. Messages sent over TCP are framed with octet
+	// counting, as in RFC 6587.
+	Network, Addr string
+
+	// Facility is the syslog facility of the messages. If zero, local0 (16)
+	// is used.
+	Facility int
+
+	// Hostname and AppName identify the source of the messages. If empty,
+	// the hostname and the base name of the executable are used.
+	Hostname, AppName string
+
+	// Pipeline is applied to each report before it is sent.
+	Pipeline Pipeline
+}
+
+// sdID is the ID of the structured data element of each message. 32473 is the
+// private enterprise number reserved for documentation and examples.
+const sdID = "metric@32473"
+
+// Report sends a message for each metric to the syslog server.
+func (sr SyslogReporter) Report() error {
+	r := sr.Pipeline.Apply(Capture())
+
+	network, addr := sr.Network, sr.Addr
+	if network == "" && addr == "" {
+		network, addr = "unixgram", "/dev/log"
+	}
+
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	facility := sr.Facility
+	if facility == 0 {
+		facility = 16
+	}
+
+	hostname := sr.Hostname
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+
+	app := sr.AppName
+	if app == "" {
+		app = filepath.Base(os.Args[0])
+	}
+
+	header := fmt.Sprintf("<%d>1 %s %s %s %d metrics ",
+		facility*8+6, r.Time.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogField(hostname), syslogField(app), os.Getpid())
+
+	send := func(name, typ string, params [][2]string, msg string) error {
+		var b strings.Builder
+		b.WriteString(header)
+		fmt.Fprintf(&b, "[%s name=\"%s\" type=\"%s\"", sdID, escapeSD(name), typ)
+		for _, p := range params {
+			fmt.Fprintf(&b, " %s=\"%s\"", p[0], escapeSD(p[1]))
+		}
+		b.WriteString("] ")
+		b.WriteString(msg)
+
+		m := b.String()
+		if network == "tcp" {
+			m = strconv.Itoa(len(m)) + " " + m
+		}
+		_, err := conn.Write([]byte(m))
+		return err
+	}
+
+	for _, n := range sortedKeys(r.Counters) {
+		v := strconv.FormatUint(r.Counters[n], 10)
+		if err := send(n, "counter", [][2]string{{"value", v}}, n+"="+v); err != nil {
+			return err
+		}
+	}
+
+	for _, n := range sortedKeys(r.FloatCounters) {
+		v := strconv.FormatFloat(r.FloatCounters[n], 'g', -1, 64)
+		if err := send(n, "counter", [][2]string{{"value", v}}, n+"="+v); err != nil {
+			return err
+		}
+	}
+
+	for _, n := range sortedKeys(r.Gauges) {
+		v := strconv.FormatInt(r.Gauges[n], 10)
+		if err := send(n, "gauge", [][2]string{{"value", v}}, n+"="+v); err != nil {
+			return err
+		}
+	}
+
+	for _, n := range sortedKeys(r.Histograms) {
+		s := r.Histograms[n]
+		params := [][2]string{{"count", strconv.FormatInt(s.Count, 10)}}
+		for _, q := range quantiles {
+			params = append(params, [2]string{
+				strings.ToLower(q.suffix[1:]),
+				strconv.FormatInt(s.Quantiles[q.q], 10),
+			})
+		}
+
+		msg := fmt.Sprintf("%s count=%d p99=%d", n, s.Count, s.Quantiles[99])
+		if err := send(n, "histogram", params, msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReportEvery sends the values of metrics once per the given interval until the
+// returned timer is stopped. Errors are counted by the Metrics.ExportErrors
+// counter.
+func (sr SyslogReporter) ReportEvery(d time.Duration) Timer {
+	return flushEvery(d, "Metrics.ExportErrors", sr.Report)
+}
+
+// syslogField returns the value as a header field: printable ASCII with no
+// spaces, or "-" if empty.
+func syslogField(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c <= ' ' || c > '~' {
+			b[i] = '_'
+		}
+	}
+
+	if len(b) == 0 {
+		return "-"
+	}
+	return string(b)
+}
+
+// escapeSD escapes a structured data parameter value.
+func escapeSD(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
@@ -0,0 +1,60 @@
+package metrics_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestSyslogReporter(t *testing.T) {
+	metricstest.Reset(t)
+	metricstest.UseFakeClock(t)
+
+	metrics.Counter("HTTP.Requests").AddN(3)
+	metrics.FloatCounter("HTTP.Bytes").Add(1.5)
+	h := metrics.NewHistogram("Latency", 1, 1000, 3)
+	h.RecordValue(10)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sr := metrics.SyslogReporter{
+		Network:  "udp",
+		Addr:     conn.LocalAddr().String(),
+		Hostname: "web1",
+		AppName:  "app",
+		Pipeline: metrics.Pipeline{
+			Filters: []metrics.Filter{metrics.Exclude("Metrics.*")},
+		},
+	}
+	if err := sr.Report(); err != nil {
+		t.Fatal(err)
+	}
+
+	var msgs []string
+	b := make([]byte, 1024)
+	for i := 0; i < 3; i++ {
+		n, _, err := conn.ReadFrom(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, string(b[:n]))
+	}
+
+	header := `<134>1 2000-01-01T00:00:00.000000Z web1 app `
+	for i, want := range []string{
+		` metrics [metric@32473 name="HTTP.Requests" type="counter" value="3"] HTTP.Requests=3`,
+		` metrics [metric@32473 name="HTTP.Bytes" type="counter" value="1.5"] HTTP.Bytes=1.5`,
+		` metrics [metric@32473 name="Latency" type="histogram" count="1" p50="10" p75="10" p90="10" p95="10" p99="10" p999="10"] Latency count=1 p99=10`,
+	} {
+		if !strings.HasPrefix(msgs[i], header) || !strings.HasSuffix(msgs[i], want) {
+			t.Errorf("Message was %q, but expected %q...%q", msgs[i], header, want)
+		}
+	}
+}
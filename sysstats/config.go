@@ -0,0 +1,37 @@
+package sysstats
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/codahale/metrics"
+)
+
+func init() {
+	metrics.RegisterConfig("sysstats", configure)
+}
+
+// configure validates the settings of the sysstats collector, which are the
+// mount points whose disk usage should be tracked:
+//
+//	sysstats: {Mounts: ["/", "/var/lib/data"]}
+//
+// It returns ErrUnsupported on platforms without system statistics.
+func configure(settings json.RawMessage) (func(), error) {
+	var c struct {
+		Mounts []string
+	}
+
+	if len(settings) > 0 {
+		d := json.NewDecoder(bytes.NewReader(settings))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&c); err != nil {
+			return nil, err
+		}
+	}
+
+	if !supported {
+		return nil, ErrUnsupported
+	}
+	return func() { _ = Register(c.Mounts...) }, nil
+}
@@ -0,0 +1,28 @@
+// Package sysstats registers gauges for host-level system statistics, for small
+// deployments which do not run a separate host monitoring agent.
+//
+// To use, call Register with the mount points whose disk usage should be
+// tracked:
+//
+//	sysstats.Register("/", "/var/lib/data")
+//
+// This registers the following gauges, with load averages in hundredths and
+// sizes in bytes:
+//
+//	System.Load1
+//	System.Load5
+//	System.Load15
+//	System.Memory.Total
+//	System.Memory.Free
+//	Disk.<mount>.Total
+//	Disk.<mount>.Free
+//
+// System statistics are currently only available on Linux; on other platforms
+// Register returns ErrUnsupported.
+package sysstats
+
+import "errors"
+
+// ErrUnsupported is returned on platforms where system statistics are not
+// available.
+var ErrUnsupported = errors.New("sysstats: unsupported platform")
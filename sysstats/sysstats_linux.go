@@ -0,0 +1,108 @@
+package sysstats
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/codahale/metrics"
+)
+
+const supported = true
+
+// Register registers the system gauges, and disk gauges for each of the given
+// mount points.
+func Register(mounts ...string) error {
+	s := &stats{}
+
+	metrics.Gauge("System.Load1").SetBatchFunc(key{}, s.init, func() int64 { return s.load[0] })
+	metrics.Gauge("System.Load5").SetBatchFunc(key{}, s.init, func() int64 { return s.load[1] })
+	metrics.Gauge("System.Load15").SetBatchFunc(key{}, s.init, func() int64 { return s.load[2] })
+	metrics.Gauge("System.Memory.Total").SetBatchFunc(key{}, s.init, func() int64 { return s.memTotal })
+	metrics.Gauge("System.Memory.Free").SetBatchFunc(key{}, s.init, func() int64 { return s.memFree })
+
+	for _, m := range mounts {
+		m := m
+		metrics.Gauge("Disk." + m + ".Total").SetFunc(func() int64 {
+			total, _ := diskUsage(m)
+			return total
+		})
+		metrics.Gauge("Disk." + m + ".Free").SetFunc(func() int64 {
+			_, free := diskUsage(m)
+			return free
+		})
+	}
+
+	return nil
+}
+
+type key struct{} // unexported to prevent collision
+
+type stats struct {
+	load              [3]int64
+	memTotal, memFree int64
+}
+
+func (s *stats) init() {
+	s.load = readLoadAvg("/proc/loadavg")
+	s.memTotal, s.memFree = readMemInfo("/proc/meminfo")
+}
+
+// readLoadAvg returns the 1, 5, and 15 minute load averages, in hundredths.
+func readLoadAvg(path string) (load [3]int64) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	f := strings.Fields(string(b))
+	for i := 0; i < 3 && i < len(f); i++ {
+		if v, err := strconv.ParseFloat(f[i], 64); err == nil {
+			load[i] = int64(v*100 + 0.5)
+		}
+	}
+	return
+}
+
+// readMemInfo returns the total and available memory, in bytes.
+func readMemInfo(path string) (total, free int64) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		v, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		switch fields[0] {
+		case "MemTotal:":
+			total = v * 1024
+		case "MemAvailable:":
+			free = v * 1024
+		}
+	}
+	return
+}
+
+// diskUsage returns the total and free bytes of the filesystem mounted at the
+// given path.
+func diskUsage(path string) (total, free int64) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return
+	}
+	return int64(fs.Blocks) * int64(fs.Bsize), int64(fs.Bavail) * int64(fs.Bsize)
+}
@@ -0,0 +1,59 @@
+package sysstats
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/codahale/metrics"
+)
+
+func TestRegister(t *testing.T) {
+	metrics.Reset()
+
+	if err := Register("/"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, gauges := metrics.Snapshot()
+
+	if v := gauges["System.Memory.Total"]; v <= 0 {
+		t.Errorf("Memory total was %v, but expected more than zero", v)
+	}
+
+	if v := gauges["Disk./.Total"]; v <= 0 {
+		t.Errorf("Disk total was %v, but expected more than zero", v)
+	}
+}
+
+func TestReadLoadAvg(t *testing.T) {
+	f, err := ioutil.TempFile("", "loadavg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	f.WriteString("0.52 1.07 2.00 1/234 5678\n")
+	f.Close()
+
+	if v, want := readLoadAvg(f.Name()), [3]int64{52, 107, 200}; v != want {
+		t.Errorf("Load was %v, but expected %v", v, want)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	metrics.Reset()
+
+	if err := metrics.Configure(metrics.Config{
+		Collectors: map[string]json.RawMessage{"sysstats": json.RawMessage(`{"Mounts": ["/"]}`)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, gauges := metrics.Snapshot()
+
+	if v := gauges["Disk./.Total"]; v <= 0 {
+		t.Errorf("Disk total was %v, but expected more than zero", v)
+	}
+}
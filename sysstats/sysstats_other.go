@@ -0,0 +1,10 @@
+// +build !linux
+
+package sysstats
+
+const supported = false
+
+// Register returns ErrUnsupported.
+func Register(mounts ...string) error {
+	return ErrUnsupported
+}
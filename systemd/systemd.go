@@ -0,0 +1,161 @@
+// Package systemd ties systemd's service watchdog to the metrics package, so
+// that a process which is alive but wedged (e.g., its work queue has stopped
+// draining) is restarted by systemd.
+//
+// To use, enable the watchdog in the service's unit file:
+//
+//	[Service]
+//	WatchdogSec=30s
+//
+// And in the process, register conditions which indicate it is unhealthy:
+//
+//	w, err := systemd.NewWatchdog()
+//	if err != nil {
+//		// not running under a systemd watchdog
+//	}
+//	w.Watch("queue", func(c map[string]uint64, g map[string]int64) bool {
+//		return g["Queue.OldestAge"] > 60
+//	})
+//	w.Start()
+package systemd
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/codahale/metrics"
+)
+
+// ErrNoWatchdog is returned when the process is not supervised by a systemd
+// watchdog.
+var ErrNoWatchdog = errors.New("systemd: watchdog is not enabled")
+
+// A Watchdog notifies systemd's service watchdog that the process is healthy
+// at half the watchdog's timeout, but only while none of its conditions hold
+// over a snapshot of all counters and gauges. If a condition holds for longer
+// than the timeout, systemd considers the service failed and restarts it
+// according to its Restart= setting.
+type Watchdog struct {
+	socket   string
+	interval time.Duration
+
+	m     sync.Mutex
+	conds map[string]metrics.Condition
+	stop  chan struct{}
+}
+
+// NewWatchdog returns a watchdog configured from the environment systemd
+// provides to supervised services. If the service has no watchdog, or the
+// watchdog is for a different process, it returns ErrNoWatchdog.
+func NewWatchdog() (*Watchdog, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil, ErrNoWatchdog
+	}
+
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return nil, ErrNoWatchdog
+	}
+
+	if s := os.Getenv("WATCHDOG_PID"); s != "" {
+		pid, err := strconv.Atoi(s)
+		if err != nil || pid != os.Getpid() {
+			return nil, ErrNoWatchdog
+		}
+	}
+
+	return &Watchdog{
+		socket:   socket,
+		interval: time.Duration(usec) * time.Microsecond / 2,
+		conds:    make(map[string]metrics.Condition),
+	}, nil
+}
+
+// Watch registers a condition under the given name, while which the watchdog
+// is not notified.
+func (w *Watchdog) Watch(name string, cond metrics.Condition) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	w.conds[name] = cond
+}
+
+// Check takes a snapshot and, if none of the conditions hold over it, notifies
+// the watchdog. It returns whether or not the watchdog was notified.
+func (w *Watchdog) Check() (bool, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	counters, gauges := metrics.Snapshot()
+	for _, cond := range w.conds {
+		if cond(counters, gauges) {
+			return false, nil
+		}
+	}
+
+	if err := notify(w.socket, "WATCHDOG=1"); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Start begins checking conditions and notifying the watchdog in a background
+// goroutine. Notification failures increment the Metrics.ExportErrors counter.
+func (w *Watchdog) Start() {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if w.stop != nil {
+		return
+	}
+	w.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		t := time.NewTicker(w.interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-t.C:
+				if _, err := w.Check(); err != nil {
+					metrics.Counter("Metrics.ExportErrors").Add()
+				}
+			case <-stop:
+				return
+			}
+		}
+	}(w.stop)
+}
+
+// Stop halts the checking of conditions. Unless the watchdog is disabled by
+// other means, systemd will restart the service once its timeout elapses.
+func (w *Watchdog) Stop() {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if w.stop != nil {
+		close(w.stop)
+		w.stop = nil
+	}
+}
+
+// notify sends the given state to the systemd notification socket.
+func notify(socket, state string) error {
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:] // abstract namespace
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
@@ -0,0 +1,77 @@
+package systemd
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/codahale/metrics"
+)
+
+func TestNewWatchdogDisabled(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+
+	if _, err := NewWatchdog(); err != ErrNoWatchdog {
+		t.Errorf("Error was %v, but expected %v", err, ErrNoWatchdog)
+	}
+
+	t.Setenv("NOTIFY_SOCKET", "/run/systemd/notify")
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+
+	if _, err := NewWatchdog(); err != ErrNoWatchdog {
+		t.Errorf("Error was %v, but expected %v", err, ErrNoWatchdog)
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	metrics.Reset()
+
+	path := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", path)
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+
+	w, err := NewWatchdog()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := w.interval.String(), "15s"; v != want {
+		t.Errorf("Interval was %v, but expected %v", v, want)
+	}
+
+	w.Watch("queue", func(c map[string]uint64, g map[string]int64) bool {
+		return g["Queue.Age"] > 60
+	})
+
+	metrics.Gauge("Queue.Age").Set(100)
+
+	if ok, err := w.Check(); ok || err != nil {
+		t.Errorf("Check was %v/%v, but expected false/nil", ok, err)
+	}
+
+	metrics.Gauge("Queue.Age").Set(10)
+
+	if ok, err := w.Check(); !ok || err != nil {
+		t.Errorf("Check was %v/%v, but expected true/nil", ok, err)
+	}
+
+	b := make([]byte, 64)
+	n, err := conn.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := string(b[:n]), "WATCHDOG=1"; v != want {
+		t.Errorf("State was %q, but expected %q", v, want)
+	}
+}
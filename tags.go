@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TagsEnv is the environment variable from which tags are read when the
+// package is initialized, as comma-separated key=value pairs (e.g.,
+// "env=production,region=us-east-1").
+const TagsEnv = "METRICS_TAGS"
+
+// SetTag adds a tag which is attached to every report captured by Capture, so
+// that reports from multiple sources can be distinguished when aggregated.
+func SetTag(key, value string) {
+	tgm.Lock()
+	defer tgm.Unlock()
+
+	tags[key] = value
+}
+
+// RemoveTag removes a tag added by SetTag.
+func RemoveTag(key string) {
+	tgm.Lock()
+	defer tgm.Unlock()
+
+	delete(tags, key)
+}
+
+// SetDefaultTags adds the host and pid tags, containing the machine's hostname
+// and the process's ID.
+func SetDefaultTags() {
+	if host, err := os.Hostname(); err == nil {
+		SetTag("host", host)
+	}
+	SetTag("pid", strconv.Itoa(os.Getpid()))
+}
+
+// copyTags returns the tags added by SetTag.
+func copyTags() map[string]string {
+	tgm.Lock()
+	defer tgm.Unlock()
+
+	m := make(map[string]string, len(tags))
+	for k, v := range tags {
+		m[k] = v
+	}
+	return m
+}
+
+// parseTags parses comma-separated key=value pairs, ignoring malformed pairs.
+func parseTags(s string) map[string]string {
+	m := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			continue
+		}
+		m[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return m
+}
+
+var (
+	tags = parseTags(os.Getenv(TagsEnv))
+	tgm  sync.Mutex
+)
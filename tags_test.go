@@ -0,0 +1,34 @@
+package metrics_test
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/codahale/metrics"
+)
+
+func TestSetTag(t *testing.T) {
+	metrics.SetTag("region", "us-east-1")
+	defer metrics.RemoveTag("region")
+
+	if v, want := metrics.Capture().Tags["region"], "us-east-1"; v != want {
+		t.Errorf("Region was %q, but expected %q", v, want)
+	}
+}
+
+func TestSetDefaultTags(t *testing.T) {
+	metrics.SetDefaultTags()
+	defer metrics.RemoveTag("host")
+	defer metrics.RemoveTag("pid")
+
+	r := metrics.Capture()
+
+	if v, want := r.Tags["pid"], strconv.Itoa(os.Getpid()); v != want {
+		t.Errorf("PID was %q, but expected %q", v, want)
+	}
+
+	if r.Tags["host"] == "" {
+		t.Error("No host tag")
+	}
+}
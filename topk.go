@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+)
+
+// A TopK tracks the most frequent items (e.g., the hottest endpoints or the
+// noisiest clients) in a stream, using the Space-Saving algorithm with bounded
+// memory. On each snapshot, the estimated count of each of the top K items is
+// published as a gauge named for the TopK and the item (e.g.,
+// Clients.10.0.0.1), and the gauges of items which have left the top K are
+// removed.
+//
+// Estimated counts may overstate an item's true count by up to the count of
+// the least frequent tracked item, but never understate it.
+type TopK struct {
+	name      string
+	k         int
+	capacity  int
+	counts    map[string]uint64
+	published map[string]bool
+	m         sync.Mutex
+}
+
+// A TopKItem is an item and its estimated count.
+type TopKItem struct {
+	Item  string
+	Count uint64
+}
+
+// NewTopK returns a TopK with the given name which publishes the k most
+// frequent items, tracking 10k items to improve the accuracy of their counts.
+func NewTopK(name string, k int) *TopK {
+	t := &TopK{
+		name:      name,
+		k:         k,
+		capacity:  10 * k,
+		counts:    make(map[string]uint64),
+		published: make(map[string]bool),
+	}
+	Gauge(name+".Tracked").SetBatchFunc(t, t.publish, t.tracked)
+	return t
+}
+
+// Add records an occurrence of the item.
+func (t *TopK) Add(item string) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if _, ok := t.counts[item]; ok || len(t.counts) < t.capacity {
+		t.counts[item]++
+		return
+	}
+
+	// replace the least frequent item, inheriting its count
+	var min string
+	var minCount uint64
+	for i, n := range t.counts {
+		if min == "" || n < minCount {
+			min, minCount = i, n
+		}
+	}
+	delete(t.counts, min)
+	t.counts[item] = minCount + 1
+}
+
+// Top returns the k most frequent items in descending order of count.
+func (t *TopK) Top() []TopKItem {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	return t.top()
+}
+
+// Reset forgets all items.
+func (t *TopK) Reset() {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	t.counts = make(map[string]uint64)
+}
+
+// top returns the top k items. It must be called with t.m held.
+func (t *TopK) top() []TopKItem {
+	items := make([]TopKItem, 0, len(t.counts))
+	for i, n := range t.counts {
+		items = append(items, TopKItem{Item: i, Count: n})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count != items[j].Count {
+			return items[i].Count > items[j].Count
+		}
+		return items[i].Item < items[j].Item
+	})
+
+	if len(items) > t.k {
+		items = items[:t.k]
+	}
+	return items
+}
+
+func (t *TopK) publish() {
+	t.m.Lock()
+	top := t.top()
+	t.m.Unlock()
+
+	current := make(map[string]bool, len(top))
+	for _, i := range top {
+		n := t.name + "." + i.Item
+		current[n] = true
+		Gauge(n).Set(int64(i.Count))
+	}
+
+	for n := range t.published {
+		if !current[n] {
+			Gauge(n).Remove()
+		}
+	}
+	t.published = current
+}
+
+func (t *TopK) tracked() int64 {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	return int64(len(t.counts))
+}
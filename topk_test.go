@@ -0,0 +1,52 @@
+package metrics_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/codahale/metrics"
+)
+
+func TestTopK(t *testing.T) {
+	metrics.Reset()
+
+	top := metrics.NewTopK("Clients", 2)
+	for i := 0; i < 100; i++ {
+		top.Add("a")
+		if i%2 == 0 {
+			top.Add("b")
+		}
+		top.Add(fmt.Sprintf("noise%d", i))
+	}
+
+	items := top.Top()
+	if v, want := fmt.Sprint(items), "[{a 100} {b 50}]"; v != want {
+		t.Errorf("Top was %v, but expected %v", v, want)
+	}
+
+	metrics.Snapshot()
+	_, gauges := metrics.Snapshot()
+
+	if v, want := gauges["Clients.a"], int64(100); v != want {
+		t.Errorf("Gauge was %v, but expected %v", v, want)
+	}
+
+	if v, want := gauges["Clients.Tracked"], int64(20); v != want {
+		t.Errorf("Tracked was %v, but expected %v", v, want)
+	}
+
+	for i := 0; i < 200; i++ {
+		top.Add("c")
+	}
+
+	metrics.Snapshot()
+	_, gauges = metrics.Snapshot()
+
+	if v, ok := gauges["Clients.b"]; ok {
+		t.Errorf("Gauge was %v, but expected nothing", v)
+	}
+
+	if v, want := gauges["Clients.c"], int64(200); v < want {
+		t.Errorf("Gauge was %v, but expected at least %v", v, want)
+	}
+}
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// A Trigger calls a function when the value of a counter or gauge rises to or
+// above a high threshold, and again when it then falls to or below a low
+// threshold. The gap between the thresholds keeps a value which hovers around
+// a limit from repeatedly firing the trigger.
+//
+// Triggers are evaluated as values are written, in the writer's goroutine:
+// when a counter is incremented, when a gauge is set with Set, and when the
+// <name>.Current gauge of an InFlight changes. Gauges whose values are
+// computed by functions are not evaluated. The function should return quickly.
+//
+// For example, to shed load while too many requests are in progress:
+//
+//	var shedding int32
+//	metrics.NewTrigger("Requests.Current", 1000, 800, func(above bool) {
+//		if above {
+//			atomic.StoreInt32(&shedding, 1)
+//		} else {
+//			atomic.StoreInt32(&shedding, 0)
+//		}
+//	})
+type Trigger struct {
+	name      string
+	high, low int64
+	f         func(above bool)
+	above     int32 // accessed atomically
+}
+
+// NewTrigger returns a trigger which calls f with true when the value of the
+// counter or gauge with the given name rises to or above high, and with false
+// when it then falls to or below low.
+func NewTrigger(name string, high, low int64, f func(above bool)) *Trigger {
+	t := &Trigger{high: high, low: low, f: f}
+
+	name, ok := resolve(name)
+	if !ok {
+		return t
+	}
+	t.name = name
+
+	trm.Lock()
+	defer trm.Unlock()
+
+	triggers[name] = append(triggers[name][:len(triggers[name]):len(triggers[name])], t)
+	atomic.AddInt32(&triggering, 1)
+
+	return t
+}
+
+// Above returns true if the value has crossed the high threshold and not yet
+// fallen back to the low threshold.
+func (t *Trigger) Above() bool {
+	return atomic.LoadInt32(&t.above) != 0
+}
+
+// Stop stops evaluating the trigger.
+func (t *Trigger) Stop() {
+	trm.Lock()
+	defer trm.Unlock()
+
+	var ts []*Trigger
+	for _, v := range triggers[t.name] {
+		if v != t {
+			ts = append(ts, v)
+		}
+	}
+
+	if len(ts) == len(triggers[t.name]) {
+		return
+	}
+
+	if len(ts) > 0 {
+		triggers[t.name] = ts
+	} else {
+		delete(triggers, t.name)
+	}
+	atomic.AddInt32(&triggering, -1)
+}
+
+func (t *Trigger) check(v int64) {
+	if v >= t.high {
+		if atomic.CompareAndSwapInt32(&t.above, 0, 1) {
+			emit(Event{Kind: "threshold", Name: t.name, Value: v, Above: true})
+			t.f(true)
+		}
+	} else if v <= t.low {
+		if atomic.CompareAndSwapInt32(&t.above, 1, 0) {
+			emit(Event{Kind: "threshold", Name: t.name, Value: v})
+			t.f(false)
+		}
+	}
+}
+
+// checkTriggers evaluates the triggers for the metric with the given resolved
+// name.
+func checkTriggers(name string, v int64) {
+	if atomic.LoadInt32(&triggering) == 0 {
+		return
+	}
+
+	trm.RLock()
+	ts := triggers[name]
+	trm.RUnlock()
+
+	for _, t := range ts {
+		t.check(v)
+	}
+}
+
+var (
+	triggers   = make(map[string][]*Trigger) // slices are replaced, never modified
+	triggering int32
+	trm        sync.RWMutex
+)
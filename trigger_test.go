@@ -0,0 +1,85 @@
+package metrics_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestTrigger(t *testing.T) {
+	metricstest.Reset(t)
+
+	var calls []bool
+	tr := metrics.NewTrigger("Queue", 10, 5, func(above bool) {
+		calls = append(calls, above)
+	})
+
+	for _, v := range []int64{3, 10, 12, 9, 6, 10, 5, 4, 11} {
+		metrics.Gauge("Queue").Set(v)
+	}
+
+	if want := []bool{true, false, true}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("Calls were %v, but expected %v", calls, want)
+	}
+
+	if !tr.Above() {
+		t.Error("Trigger was not above its threshold")
+	}
+
+	tr.Stop()
+	metrics.Gauge("Queue").Set(0)
+
+	if v, want := len(calls), 3; v != want {
+		t.Errorf("Trigger was called %v times after being stopped", v-want)
+	}
+}
+
+func TestTriggerCounter(t *testing.T) {
+	metricstest.Reset(t)
+
+	fired := false
+	metrics.NewTrigger("Errors", 100, 0, func(above bool) {
+		fired = above
+	}).Stop()
+
+	tr := metrics.NewTrigger("Errors", 100, 0, func(above bool) {
+		fired = above
+	})
+	defer tr.Stop()
+
+	metrics.Counter("Errors").AddN(99)
+	if fired {
+		t.Error("Trigger fired below its threshold")
+	}
+
+	metrics.Counter("Errors").Add()
+	if !fired {
+		t.Error("Trigger did not fire at its threshold")
+	}
+}
+
+func TestTriggerInFlight(t *testing.T) {
+	metricstest.Reset(t)
+	metricstest.UseFakeClock(t)
+
+	f := metrics.NewInFlight("Requests", time.Second)
+	defer f.Remove()
+
+	tr := metrics.NewTrigger("Requests.Current", 2, 0, func(bool) {})
+	defer tr.Stop()
+
+	a := f.Start()
+	b := f.Start()
+	if !tr.Above() {
+		t.Error("Trigger was not above its threshold")
+	}
+
+	a.Done()
+	b.Done()
+	if tr.Above() {
+		t.Error("Trigger was still above its threshold")
+	}
+}
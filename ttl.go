@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SetTTL removes the counter once it has not been written to for the given
+// duration. Use a TTL to keep metrics named for short-lived things (e.g.,
+// connections or sessions) from accumulating. Once the counter has been
+// removed, writing to it again re-creates it without a TTL.
+func (c Counter) SetTTL(ttl time.Duration) {
+	if name, ok := resolve(string(c)); ok {
+		setTTL(name, ttl, c.Remove)
+	}
+}
+
+// SetTTL removes the gauge once it has not been set for the given duration.
+// Once the gauge has been removed, setting it again re-creates it without a
+// TTL. Gauges with functions are never removed.
+func (g Gauge) SetTTL(ttl time.Duration) {
+	if name, ok := resolve(string(g)); ok {
+		setTTL(name, ttl, g.Remove)
+	}
+}
+
+type expiry struct {
+	ttl    time.Duration
+	remove func()
+}
+
+func setTTL(name string, ttl time.Duration, remove func()) {
+	atomic.StoreInt32(&tracking, 1)
+	if _, ok := LastUpdated(name); !ok {
+		touch(name)
+	}
+
+	ttlm.Lock()
+	defer ttlm.Unlock()
+
+	ttls[name] = expiry{ttl: ttl, remove: remove}
+	if sweeper == nil {
+		sweeper = repeat(1*time.Second, sweep)
+	}
+}
+
+// sweep removes the metrics whose TTLs have expired.
+func sweep() {
+	t := now()
+
+	var expired []func()
+
+	ttlm.Lock()
+	for name, e := range ttls {
+		last, ok := LastUpdated(name)
+		if !ok {
+			// removed or given a function
+			delete(ttls, name)
+		} else if t.Sub(last) > e.ttl {
+			delete(ttls, name)
+			expired = append(expired, e.remove)
+		}
+	}
+	ttlm.Unlock()
+
+	for _, remove := range expired {
+		remove()
+	}
+}
+
+var (
+	ttls    = make(map[string]expiry)
+	sweeper Timer
+	ttlm    sync.Mutex
+)
@@ -0,0 +1,41 @@
+package metrics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestTTL(t *testing.T) {
+	metricstest.Reset(t)
+	c := metricstest.UseFakeClock(t)
+
+	metrics.Counter("Conn.1.Bytes").SetTTL(time.Minute)
+	metrics.Gauge("Conn.1.Buffered").SetTTL(time.Minute)
+	metrics.Counter("Conn.1.Bytes").AddN(10)
+	metrics.Gauge("Conn.1.Buffered").Set(5)
+
+	c.Advance(30 * time.Second)
+	metrics.Counter("Conn.1.Bytes").AddN(10)
+
+	c.Advance(45 * time.Second)
+
+	counters, gauges := metrics.Snapshot()
+
+	if v, want := counters["Conn.1.Bytes"], uint64(20); v != want {
+		t.Errorf("Counter was %v, but expected %v", v, want)
+	}
+
+	if v, ok := gauges["Conn.1.Buffered"]; ok {
+		t.Errorf("Gauge was %v, but expected nothing", v)
+	}
+
+	c.Advance(1 * time.Minute)
+
+	counters, _ = metrics.Snapshot()
+	if v, ok := counters["Conn.1.Bytes"]; ok {
+		t.Errorf("Counter was %v, but expected nothing", v)
+	}
+}
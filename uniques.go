@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// Uniques estimates the number of distinct items (e.g., user IDs or IP
+// addresses) seen during each window of time, using a HyperLogLog sketch of
+// 4KB regardless of the number of items. Estimates have a standard error of
+// about 1.6%.
+//
+// The estimate for the most recently completed window is published as a gauge;
+// until the first window completes, the estimate for the current window is
+// published instead.
+type Uniques struct {
+	current  hll
+	previous int64
+	complete bool
+	timer    Timer
+	m        sync.Mutex
+}
+
+// NewUniques returns a unique counter published as a gauge with the given
+// name, which starts a new estimate every window.
+func NewUniques(name string, window time.Duration) *Uniques {
+	u := &Uniques{}
+	u.timer = repeat(window, u.rotate)
+
+	Gauge(name).SetFunc(func() int64 {
+		u.m.Lock()
+		defer u.m.Unlock()
+
+		if u.complete {
+			return u.previous
+		}
+		return u.current.estimate()
+	})
+
+	return u
+}
+
+// Add records an item.
+func (u *Uniques) Add(item string) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(item))
+	u.add(h.Sum64())
+}
+
+// AddBytes records an item.
+func (u *Uniques) AddBytes(item []byte) {
+	h := fnv.New64a()
+	_, _ = h.Write(item)
+	u.add(h.Sum64())
+}
+
+// Stop stops starting new windows.
+func (u *Uniques) Stop() {
+	u.timer.Stop()
+}
+
+func (u *Uniques) add(x uint64) {
+	u.m.Lock()
+	defer u.m.Unlock()
+
+	u.current.add(mix(x))
+}
+
+func (u *Uniques) rotate() {
+	u.m.Lock()
+	defer u.m.Unlock()
+
+	u.previous = u.current.estimate()
+	u.complete = true
+	u.current = hll{}
+}
+
+const hllPrecision = 12
+
+// hll is a HyperLogLog sketch with 2^hllPrecision registers.
+type hll struct {
+	registers [1 << hllPrecision]uint8
+}
+
+func (s *hll) add(x uint64) {
+	i := x >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	if rank > s.registers[i] {
+		s.registers[i] = rank
+	}
+}
+
+func (s *hll) estimate() int64 {
+	const m = float64(len(s.registers))
+
+	var sum float64
+	var zeros int
+	for _, r := range s.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	e := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if e <= 2.5*m && zeros > 0 {
+		// use linear counting for small cardinalities
+		e = m * math.Log(m/float64(zeros))
+	}
+	return int64(math.Round(e))
+}
+
+// mix improves the distribution of a hash's bits (MurmurHash3's finalizer).
+func mix(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
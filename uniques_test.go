@@ -0,0 +1,40 @@
+package metrics_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestUniques(t *testing.T) {
+	metricstest.Reset(t)
+	c := metricstest.UseFakeClock(t)
+
+	u := metrics.NewUniques("Users", time.Minute)
+	defer u.Stop()
+
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 10000; j++ {
+			u.Add(fmt.Sprintf("user%d", j))
+		}
+	}
+	u.AddBytes([]byte("user0"))
+
+	c.Advance(time.Minute)
+	u.Add("user0")
+
+	_, gauges := metrics.Snapshot()
+	if v := gauges["Users"]; v < 9500 || v > 10500 {
+		t.Errorf("Estimate was %v, but expected ~10000", v)
+	}
+
+	c.Advance(time.Minute)
+
+	_, gauges = metrics.Snapshot()
+	if v, want := gauges["Users"], int64(1); v != want {
+		t.Errorf("Estimate was %v, but expected %v", v, want)
+	}
+}
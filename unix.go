@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ListenUnix listens on a Unix domain socket at the given path with the given
+// permissions, so that handlers such as Handler and AdminHandler can be served
+// on hosts where opening additional TCP ports is not allowed, with access
+// controlled by file permissions:
+//
+//	l, err := metrics.ListenUnix("/run/myservice/metrics.sock", 0660)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	go http.Serve(l, mux)
+//
+// The socket is created with its permissions already set, and a stale socket
+// left at the path by a previous process is replaced. Closing the listener
+// removes the socket.
+func ListenUnix(path string, perm os.FileMode) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, &net.OpError{Op: "listen", Net: "unix", Err: errors.New("file exists and is not a socket")}
+		}
+
+		if c, err := net.Dial("unix", path); err == nil {
+			_ = c.Close()
+			return nil, &net.OpError{Op: "listen", Net: "unix", Err: errors.New("socket is in use")}
+		}
+	}
+
+	// create the socket in a private directory, set its permissions, and only
+	// then move it into place, so it is never accessible with the wrong ones
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".metrics")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "s")
+	l, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	if err := os.Chmod(tmp, perm); err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+
+	return &unixListener{Listener: l, path: path}, nil
+}
+
+type unixListener struct {
+	net.Listener
+	path string
+	once sync.Once
+}
+
+func (l *unixListener) Close() error {
+	err := l.Listener.Close()
+	l.once.Do(func() {
+		_ = os.Remove(l.path)
+	})
+	return err
+}
@@ -0,0 +1,74 @@
+package metrics_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestListenUnix(t *testing.T) {
+	metricstest.Reset(t)
+
+	metrics.Counter("whee").Add()
+
+	path := filepath.Join(t.TempDir(), "metrics.sock")
+
+	// a stale socket from a previous process
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	l, err := metrics.ListenUnix(path, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, want := fi.Mode().Perm(), os.FileMode(0600); v != want {
+		t.Errorf("Permissions were %v, but expected %v", v, want)
+	}
+
+	if _, err := metrics.ListenUnix(path, 0600); err == nil {
+		t.Error("Listened on a socket which is in use")
+	}
+
+	srv := &http.Server{Handler: metrics.Handler{}}
+	go func() { _ = srv.Serve(l) }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+
+	resp, err := client.Get("http://unix/debug/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || len(b) == 0 {
+		t.Errorf("Response was %d %s", resp.StatusCode, b)
+	}
+
+	_ = srv.Close()
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Socket was not removed: %v", err)
+	}
+}
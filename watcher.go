@@ -0,0 +1,186 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// A Condition reports whether a snapshot of counters and gauges is in an alert
+// state (e.g., the heap gauge exceeds 2GB).
+type Condition func(counters map[string]uint64, gauges map[string]int64) bool
+
+// An Alert describes a change in the state of a watched condition.
+type Alert struct {
+	Name     string            // the name the condition was watched under
+	Firing   bool              // true if the condition began holding, false if it cleared
+	Time     time.Time         // the time of the snapshot which changed the state
+	Counters map[string]uint64 // the counters in the snapshot
+	Gauges   map[string]int64  // the gauges in the snapshot
+}
+
+// A Watcher periodically evaluates conditions over snapshots of all counters
+// and gauges, notifying callbacks when a condition has held for a number of
+// consecutive intervals and again when it clears.
+//
+// Use a watcher to provide basic in-process alerting without a monitoring
+// stack.
+type Watcher struct {
+	interval time.Duration
+	watches  []*watch
+	m        sync.Mutex
+	stop     chan struct{}
+}
+
+type watch struct {
+	name      string
+	cond      Condition
+	intervals int
+	notify    []func(Alert)
+	streak    int
+	firing    bool
+}
+
+// NewWatcher returns a watcher which evaluates its conditions once per the
+// given interval. The watcher must be started with Start, unless the interval
+// is not positive, in which case conditions are only evaluated by Check.
+func NewWatcher(interval time.Duration) *Watcher {
+	return &Watcher{interval: interval}
+}
+
+// Watch registers a condition under the given name. Once the condition has held
+// for the given number of consecutive intervals, each notify function is called
+// with a firing alert; when the condition subsequently stops holding, each is
+// called with a cleared alert.
+func (w *Watcher) Watch(name string, cond Condition, intervals int, notify ...func(Alert)) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if intervals < 1 {
+		intervals = 1
+	}
+
+	w.watches = append(w.watches, &watch{
+		name:      name,
+		cond:      cond,
+		intervals: intervals,
+		notify:    notify,
+	})
+}
+
+// Check takes a snapshot and evaluates every condition against it once, as if
+// an interval had elapsed. Notify functions are called after the conditions
+// are evaluated, so they may call the watcher's methods.
+func (w *Watcher) Check() {
+	for _, n := range w.evaluate() {
+		n.f(n.a)
+	}
+}
+
+type notification struct {
+	f func(Alert)
+	a Alert
+}
+
+// evaluate takes a snapshot, evaluates every condition against it, and returns
+// the notifications to be sent.
+func (w *Watcher) evaluate() []notification {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	counters, gauges := Snapshot()
+	t := now()
+
+	var pending []notification
+	for _, wt := range w.watches {
+		if wt.cond(counters, gauges) {
+			wt.streak++
+		} else {
+			wt.streak = 0
+		}
+
+		firing := wt.streak >= wt.intervals
+		if firing == wt.firing {
+			continue
+		}
+		wt.firing = firing
+
+		a := Alert{
+			Name:     wt.name,
+			Firing:   firing,
+			Time:     t,
+			Counters: counters,
+			Gauges:   gauges,
+		}
+		for _, f := range wt.notify {
+			pending = append(pending, notification{f, a})
+		}
+	}
+	return pending
+}
+
+// Start begins evaluating conditions in a background goroutine. It does nothing
+// if the watcher's interval is not positive.
+func (w *Watcher) Start() {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if w.stop != nil || w.interval <= 0 {
+		return
+	}
+	w.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		t := time.NewTicker(w.interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-t.C:
+				w.Check()
+			case <-stop:
+				return
+			}
+		}
+	}(w.stop)
+}
+
+// Stop halts the evaluation of conditions.
+func (w *Watcher) Stop() {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if w.stop != nil {
+		close(w.stop)
+		w.stop = nil
+	}
+}
+
+// Webhook returns a notification function which POSTs each alert as a JSON
+// object to the given URL, waiting up to ten seconds for a response. Delivery
+// is best-effort; failures increment the Metrics.ExportErrors counter.
+func Webhook(url string) func(Alert) {
+	return func(a Alert) {
+		b, err := json.Marshal(a)
+		if err != nil {
+			countError("Metrics.ExportErrors", a.Name, err)
+			return
+		}
+
+		resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(b))
+		if err != nil {
+			countError("Metrics.ExportErrors", a.Name, err)
+			return
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			countError("Metrics.ExportErrors", a.Name, fmt.Errorf("webhook responded with %s", resp.Status))
+		}
+	}
+}
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
@@ -0,0 +1,109 @@
+package metrics_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+)
+
+func TestWatcher(t *testing.T) {
+	metrics.Reset()
+
+	var alerts []metrics.Alert
+
+	w := metrics.NewWatcher(0)
+	w.Watch("heap", func(c map[string]uint64, g map[string]int64) bool {
+		return g["heap"] > 100
+	}, 2, func(a metrics.Alert) {
+		alerts = append(alerts, a)
+	})
+
+	metrics.Gauge("heap").Set(200)
+
+	w.Check()
+	if v, want := len(alerts), 0; v != want {
+		t.Fatalf("Alert count was %v, but expected %v", v, want)
+	}
+
+	w.Check()
+	if v, want := len(alerts), 1; v != want {
+		t.Fatalf("Alert count was %v, but expected %v", v, want)
+	}
+
+	if !alerts[0].Firing {
+		t.Errorf("Alert was not firing")
+	}
+
+	if v, want := alerts[0].Gauges["heap"], int64(200); v != want {
+		t.Errorf("Gauge was %v, but expected %v", v, want)
+	}
+
+	w.Check()
+	if v, want := len(alerts), 1; v != want {
+		t.Fatalf("Alert count was %v, but expected %v", v, want)
+	}
+
+	metrics.Gauge("heap").Set(50)
+
+	w.Check()
+	if v, want := len(alerts), 2; v != want {
+		t.Fatalf("Alert count was %v, but expected %v", v, want)
+	}
+
+	if alerts[1].Firing {
+		t.Errorf("Alert was firing, but expected it to have cleared")
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	alerts := make(chan metrics.Alert, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a metrics.Alert
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			t.Error(err)
+		}
+		alerts <- a
+	}))
+	defer srv.Close()
+
+	metrics.Webhook(srv.URL)(metrics.Alert{Name: "heap", Firing: true})
+
+	a := <-alerts
+	if v, want := a.Name, "heap"; v != want {
+		t.Errorf("Alert name was %v, but expected %v", v, want)
+	}
+}
+
+func TestWatcherReentrantNotify(t *testing.T) {
+	metrics.Reset()
+
+	w := metrics.NewWatcher(0)
+	w.Start() // does nothing without an interval
+	defer w.Stop()
+
+	w.Watch("always", func(map[string]uint64, map[string]int64) bool {
+		return true
+	}, 1, func(metrics.Alert) {
+		// registering a watch from a notification must not deadlock
+		w.Watch("nested", func(map[string]uint64, map[string]int64) bool {
+			return false
+		}, 1)
+		w.Stop()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		w.Check()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Check deadlocked")
+	}
+}
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CounterWindows publishes the number of times a counter has been incremented
+// over recent windows of time (e.g., the last minute and the last five
+// minutes) as gauges named for the counter and the window (e.g.,
+// Requests.1m and Requests.5m), for consumers which cannot derive rates from
+// the counter's total.
+//
+// The counter is sampled once per resolution, and each window's count is the
+// difference between the counter's current value and its sample from the
+// start of the window. Until a window has elapsed, its count covers the time
+// since sampling began.
+type CounterWindows struct {
+	c       Counter
+	res     time.Duration
+	samples []uint64 // a ring of samples, one per resolution
+	n       int      // the number of samples taken, up to len(samples)
+	head    int      // the index of the next sample
+	timer   Timer
+	names   []string
+	m       sync.Mutex
+}
+
+// NewCounterWindows begins sampling the counter with the given name once per
+// resolution, publishing its count over each of the given windows. Each
+// window should be a multiple of the resolution.
+func NewCounterWindows(name string, resolution time.Duration, windows ...time.Duration) *CounterWindows {
+	var max time.Duration
+	for _, w := range windows {
+		if w > max {
+			max = w
+		}
+	}
+
+	cw := &CounterWindows{
+		c:       Counter(name),
+		res:     resolution,
+		samples: make([]uint64, int(max/resolution)+1),
+	}
+	cw.sample()
+	cw.timer = repeat(resolution, cw.sample)
+
+	for _, w := range windows {
+		n := name + "." + formatWindow(w)
+		cw.names = append(cw.names, n)
+
+		steps := int(w / resolution)
+		Gauge(n).SetFunc(func() int64 {
+			return int64(cw.since(steps))
+		})
+	}
+
+	return cw
+}
+
+// Stop stops sampling the counter and removes the windows' gauges.
+func (cw *CounterWindows) Stop() {
+	cw.timer.Stop()
+	for _, n := range cw.names {
+		Gauge(n).Remove()
+	}
+}
+
+// Count returns the number of times the counter has been incremented over the
+// given window, which should be a multiple of the resolution no longer than
+// the longest of the windows.
+func (cw *CounterWindows) Count(window time.Duration) uint64 {
+	return cw.since(int(window / cw.res))
+}
+
+func (cw *CounterWindows) sample() {
+	v := cw.c.Value()
+
+	cw.m.Lock()
+	defer cw.m.Unlock()
+
+	cw.samples[cw.head] = v
+	cw.head = (cw.head + 1) % len(cw.samples)
+	if cw.n < len(cw.samples) {
+		cw.n++
+	}
+}
+
+// since returns the amount the counter has increased since the sample taken
+// the given number of resolutions ago, or the oldest sample if there is none.
+func (cw *CounterWindows) since(steps int) uint64 {
+	v := cw.c.Value()
+
+	cw.m.Lock()
+	defer cw.m.Unlock()
+
+	if steps >= cw.n {
+		steps = cw.n - 1
+	}
+
+	i := (cw.head - 1 - steps + 2*len(cw.samples)) % len(cw.samples)
+	if v < cw.samples[i] {
+		return v // the counter was reset
+	}
+	return v - cw.samples[i]
+}
+
+// formatWindow formats a duration compactly (e.g., 5m or 30s).
+func formatWindow(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	case d%time.Second == 0:
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+	return d.String()
+}
@@ -0,0 +1,30 @@
+package metrics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+func TestCounterWindows(t *testing.T) {
+	metricstest.Reset(t)
+	c := metricstest.UseFakeClock(t)
+
+	cw := metrics.NewCounterWindows("Requests", 10*time.Second, time.Minute, 5*time.Minute)
+	defer cw.Stop()
+
+	metrics.Counter("Requests").AddN(10)
+	metricstest.AssertGauge(t, "Requests.1m", 10)
+	metricstest.AssertGauge(t, "Requests.5m", 10)
+
+	c.Advance(1 * time.Minute)
+	metrics.Counter("Requests").AddN(5)
+	metricstest.AssertGauge(t, "Requests.1m", 15)
+	metricstest.AssertGauge(t, "Requests.5m", 15)
+
+	c.Advance(5 * time.Minute)
+	metricstest.AssertGauge(t, "Requests.1m", 0)
+	metricstest.AssertGauge(t, "Requests.5m", 5)
+}
@@ -0,0 +1,332 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlToJSON converts the subset of YAML used by configuration files to JSON:
+// block mappings and sequences, flow mappings and sequences, comments, and
+// plain, single-quoted, and double-quoted scalars. Anchors, tags, multiple
+// documents, and block scalars are not supported.
+func yamlToJSON(b []byte) ([]byte, error) {
+	var lines []yamlLine
+	for i, s := range strings.Split(string(b), "\n") {
+		s = strings.TrimRight(yamlStripComment(s), " \t\r")
+		text := strings.TrimLeft(s, " ")
+		if text == "" || (len(lines) == 0 && text == "---") {
+			continue
+		}
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed in indentation", i+1)
+		}
+		lines = append(lines, yamlLine{n: i + 1, indent: len(s) - len(text), text: text})
+	}
+
+	p := &yamlParser{lines: lines}
+	var v interface{}
+	if len(lines) > 0 {
+		var err error
+		if v, err = p.node(lines[0].indent); err != nil {
+			return nil, err
+		}
+		if p.i < len(lines) {
+			return nil, fmt.Errorf("line %d: unexpected indentation", lines[p.i].n)
+		}
+	}
+	return json.Marshal(v)
+}
+
+type yamlLine struct {
+	n      int // the line number, for errors
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	i     int
+}
+
+// node parses the block node which starts at the current line.
+func (p *yamlParser) node(indent int) (interface{}, error) {
+	l := p.lines[p.i]
+	switch {
+	case yamlIsItem(l.text):
+		return p.sequence(indent)
+	case yamlKeyEnd(l.text) >= 0:
+		return p.mapping(indent)
+	default:
+		p.i++
+		return yamlScalar(l.n, l.text)
+	}
+}
+
+func (p *yamlParser) sequence(indent int) (interface{}, error) {
+	s := []interface{}{}
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent && yamlIsItem(p.lines[p.i].text) {
+		l := p.lines[p.i]
+		rest := strings.TrimLeft(l.text[1:], " ")
+		if rest == "" {
+			v, err := p.child(indent, false)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, v)
+			continue
+		}
+
+		// parse the rest of the line as a node indented past the dash, so
+		// that a mapping can continue on the following lines
+		l.indent += len(l.text) - len(rest)
+		l.text = rest
+		p.lines[p.i] = l
+		v, err := p.node(l.indent)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, v)
+	}
+	return s, nil
+}
+
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+	m := map[string]interface{}{}
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent && !yamlIsItem(p.lines[p.i].text) {
+		l := p.lines[p.i]
+		end := yamlKeyEnd(l.text)
+		if end < 0 {
+			return nil, fmt.Errorf("line %d: expected a key", l.n)
+		}
+
+		k, err := yamlScalar(l.n, strings.TrimSpace(l.text[:end]))
+		if err != nil {
+			return nil, err
+		}
+		key := fmt.Sprint(k)
+		if _, ok := m[key]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key %q", l.n, key)
+		}
+
+		var v interface{}
+		if rest := strings.TrimSpace(l.text[end+1:]); rest != "" {
+			p.i++
+			if v, err = yamlScalar(l.n, rest); err != nil {
+				return nil, err
+			}
+		} else if v, err = p.child(indent, true); err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+// child parses the block node on the lines following the current one, if they
+// are indented further (or, for the values of mappings, are sequence items at
+// the same indentation), and returns nil otherwise.
+func (p *yamlParser) child(indent int, sameIndentItems bool) (interface{}, error) {
+	p.i++
+	if p.i == len(p.lines) {
+		return nil, nil
+	}
+
+	l := p.lines[p.i]
+	if l.indent > indent || (sameIndentItems && l.indent == indent && yamlIsItem(l.text)) {
+		return p.node(l.indent)
+	}
+	return nil, nil
+}
+
+func yamlIsItem(s string) bool {
+	return s == "-" || strings.HasPrefix(s, "- ")
+}
+
+// yamlKeyEnd returns the index of the colon which ends the key at the start of
+// the given line, or -1 if the line is not a key.
+func yamlKeyEnd(s string) int {
+	if s == "" || strings.ContainsRune("[{", rune(s[0])) {
+		return -1
+	}
+
+	i := 0
+	if s[0] == '"' || s[0] == '\'' {
+		end, err := yamlQuoteEnd(s)
+		if err != nil {
+			return -1
+		}
+		i = end
+	}
+
+	for ; i < len(s); i++ {
+		if s[i] == ':' && (i+1 == len(s) || s[i+1] == ' ') {
+			return i
+		}
+	}
+	return -1
+}
+
+// yamlQuoteEnd returns the index after the quoted string at the start of s.
+func yamlQuoteEnd(s string) (int, error) {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case s[i] == q && q == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == q:
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated string %s", s)
+}
+
+// yamlStripComment removes a comment from the end of the given line.
+func yamlStripComment(s string) string {
+	var q byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case q != 0:
+			if c == '\\' && q == '"' {
+				i++
+			} else if c == q {
+				q = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || strings.ContainsRune(" \t[{,:-", rune(s[i-1])) {
+				q = c
+			}
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// yamlScalar parses the value on a single line: a flow collection or a scalar.
+func yamlScalar(n int, s string) (interface{}, error) {
+	f := &yamlFlow{s: s}
+	v, err := f.value("")
+	if err == nil && f.skipSpace() < len(s) {
+		err = fmt.Errorf("unexpected %q", s[f.i:])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", n, err)
+	}
+	return v, nil
+}
+
+type yamlFlow struct {
+	s string
+	i int
+}
+
+func (f *yamlFlow) skipSpace() int {
+	for f.i < len(f.s) && f.s[f.i] == ' ' {
+		f.i++
+	}
+	return f.i
+}
+
+// value parses a value, with plain scalars ended by any of the given bytes.
+func (f *yamlFlow) value(stop string) (interface{}, error) {
+	if f.skipSpace() == len(f.s) {
+		return nil, nil
+	}
+
+	switch f.s[f.i] {
+	case '[':
+		f.i++
+		s := []interface{}{}
+		for f.skipSpace(); f.i < len(f.s) && f.s[f.i] != ']'; {
+			v, err := f.value(",]")
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, v)
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+		return s, f.expect(']')
+	case '{':
+		f.i++
+		m := map[string]interface{}{}
+		for f.skipSpace(); f.i < len(f.s) && f.s[f.i] != '}'; {
+			k, err := f.value(":,}")
+			if err != nil {
+				return nil, err
+			}
+			if err := f.expect(':'); err != nil {
+				return nil, err
+			}
+			v, err := f.value(",}")
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(k)] = v
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+		return m, f.expect('}')
+	case '"', '\'':
+		end, err := yamlQuoteEnd(f.s[f.i:])
+		if err != nil {
+			return nil, err
+		}
+		q := f.s[f.i : f.i+end]
+		f.i += end
+		if q[0] == '\'' {
+			return strings.Replace(q[1:len(q)-1], "''", "'", -1), nil
+		}
+		return strconv.Unquote(q)
+	}
+
+	start := f.i
+	for f.i < len(f.s) && strings.IndexByte(stop, f.s[f.i]) < 0 {
+		f.i++
+	}
+	return yamlPlain(strings.TrimSpace(f.s[start:f.i])), nil
+}
+
+func (f *yamlFlow) separator(end byte) error {
+	if f.skipSpace() < len(f.s) && f.s[f.i] == ',' {
+		f.i++
+		f.skipSpace()
+		return nil
+	}
+	if f.i < len(f.s) && f.s[f.i] == end {
+		return nil
+	}
+	return fmt.Errorf("expected ',' or '%c' in %s", end, f.s)
+}
+
+func (f *yamlFlow) expect(c byte) error {
+	if f.skipSpace() == len(f.s) || f.s[f.i] != c {
+		return fmt.Errorf("expected '%c' in %s", c, f.s)
+	}
+	f.i++
+	return nil
+}
+
+// yamlPlain returns the value of a plain scalar: null, a boolean, a number, or
+// otherwise a string.
+func yamlPlain(s string) interface{} {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+
+	if (s[0] == '-' || (s[0] >= '0' && s[0] <= '9')) && json.Valid([]byte(s)) {
+		return json.Number(s)
+	}
+	return s
+}
@@ -0,0 +1,160 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A ZabbixSender sends the values of metrics to a Zabbix server or proxy using
+// the Zabbix sender protocol, as zabbix_sender does. Each metric's value is
+// sent to the trapper item whose key is the metric's name; histograms are sent
+// as their quantile values (e.g., Latency.P99). The items must exist on the
+// host in Zabbix.
+type ZabbixSender struct {
+	// Addr is the address of the Zabbix server or proxy (e.g.,
+	// "zabbix:10051").
+	Addr string
+
+	// Host is the name of the host in Zabbix the items belong to.
+	Host string
+
+	// Timeout limits the time taken to send the values. If zero, ten seconds
+	// is used.
+	Timeout time.Duration
+
+	// Pipeline is applied to each report before it is sent.
+	Pipeline Pipeline
+}
+
+type zabbixItem struct {
+	Host  string `json:"host"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Clock int64  `json:"clock"`
+}
+
+type zabbixRequest struct {
+	Request string       `json:"request"`
+	Data    []zabbixItem `json:"data"`
+	Clock   int64        `json:"clock"`
+}
+
+type zabbixResponse struct {
+	Response string `json:"response"`
+	Info     string `json:"info"`
+}
+
+// Push sends the current value of each metric to the server. It returns an
+// error if the server rejects any of the values (e.g., because an item does not
+// exist).
+func (zs ZabbixSender) Push() error {
+	r := zs.Pipeline.Apply(Capture())
+	clock := r.Time.Unix()
+
+	req := zabbixRequest{Request: "sender data", Clock: clock}
+	add := func(key, v string) {
+		req.Data = append(req.Data, zabbixItem{
+			Host:  zs.Host,
+			Key:   key,
+			Value: v,
+			Clock: clock,
+		})
+	}
+
+	for _, n := range sortedKeys(r.Counters) {
+		add(n, strconv.FormatUint(r.Counters[n], 10))
+	}
+
+	for _, n := range sortedKeys(r.FloatCounters) {
+		add(n, strconv.FormatFloat(r.FloatCounters[n], 'g', -1, 64))
+	}
+
+	for _, n := range sortedKeys(r.Gauges) {
+		add(n, strconv.FormatInt(r.Gauges[n], 10))
+	}
+
+	for _, n := range sortedKeys(r.Histograms) {
+		for _, q := range quantiles {
+			add(n+q.suffix, strconv.FormatInt(r.Histograms[n].Quantiles[q.q], 10))
+		}
+	}
+
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	timeout := zs.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	conn, err := net.DialTimeout("tcp", zs.Addr, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+
+	if _, err := conn.Write(zabbixPacket(b)); err != nil {
+		return err
+	}
+
+	b, err = readZabbixPacket(conn)
+	if err != nil {
+		return err
+	}
+
+	var resp zabbixResponse
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return err
+	}
+
+	if resp.Response != "success" || !strings.Contains(resp.Info, "failed: 0;") {
+		return fmt.Errorf("metrics: zabbix rejected values: %s %s", resp.Response, resp.Info)
+	}
+	return nil
+}
+
+// PushEvery sends the values of metrics once per the given interval until the
+// returned timer is stopped. Errors are counted by the Metrics.ExportErrors
+// counter.
+func (zs ZabbixSender) PushEvery(d time.Duration) Timer {
+	return flushEvery(d, "Metrics.ExportErrors", zs.Push)
+}
+
+// zabbixPacket frames a payload with the Zabbix protocol header: "ZBXD", a
+// flags byte, and the little-endian length of the payload.
+func zabbixPacket(payload []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString("ZBXD\x01")
+	_ = binary.Write(&b, binary.LittleEndian, uint64(len(payload)))
+	b.Write(payload)
+	return b.Bytes()
+}
+
+// readZabbixPacket reads a framed payload.
+func readZabbixPacket(r io.Reader) ([]byte, error) {
+	var header [13]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	if string(header[:4]) != "ZBXD" {
+		return nil, fmt.Errorf("metrics: invalid zabbix response header %q", header[:5])
+	}
+
+	n := binary.LittleEndian.Uint64(header[5:])
+	return ioutil.ReadAll(io.LimitReader(r, int64(n)))
+}
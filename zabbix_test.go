@@ -0,0 +1,101 @@
+package metrics_test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/codahale/metrics/metricstest"
+)
+
+// zabbixServer accepts a single connection, decodes its request, and responds
+// with the given info.
+func zabbixServer(t *testing.T, info string, requests chan<- map[string]interface{}) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		defer ln.Close()
+
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		header := make([]byte, 13)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+
+		body := make([]byte, binary.LittleEndian.Uint64(header[5:]))
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+
+		var req map[string]interface{}
+		_ = json.Unmarshal(body, &req)
+		requests <- req
+
+		resp, _ := json.Marshal(map[string]string{"response": "success", "info": info})
+		binary.LittleEndian.PutUint64(header[5:], uint64(len(resp)))
+		_, _ = conn.Write(append(header, resp...))
+	}()
+
+	return ln.Addr().String()
+}
+
+func TestZabbixSender(t *testing.T) {
+	metricstest.Reset(t)
+	metrics.Counter("Requests").AddN(3)
+	metrics.FloatCounter("Bytes").Add(1.5)
+
+	requests := make(chan map[string]interface{}, 1)
+	addr := zabbixServer(t, "processed: 1; failed: 0; total: 1; seconds spent: 0.000055", requests)
+
+	zs := metrics.ZabbixSender{
+		Addr: addr,
+		Host: "web1",
+		Pipeline: metrics.Pipeline{
+			Filters: []metrics.Filter{metrics.Include("Requests", "Bytes")},
+		},
+	}
+	if err := zs.Push(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := <-requests
+	if v, want := req["request"], "sender data"; v != want {
+		t.Errorf("Request was %v, but expected %v", v, want)
+	}
+
+	data := req["data"].([]interface{})
+	for i, items := range []map[string]string{
+		{"host": "web1", "key": "Requests", "value": "3"},
+		{"host": "web1", "key": "Bytes", "value": "1.5"},
+	} {
+		item := data[i].(map[string]interface{})
+		for k, want := range items {
+			if v := item[k]; v != want {
+				t.Errorf("%s was %v, but expected %v", k, v, want)
+			}
+		}
+	}
+}
+
+func TestZabbixSenderFailed(t *testing.T) {
+	metricstest.Reset(t)
+	metrics.Counter("Requests").AddN(3)
+
+	requests := make(chan map[string]interface{}, 1)
+	addr := zabbixServer(t, "processed: 0; failed: 1; total: 1; seconds spent: 0.000055", requests)
+
+	if err := (metrics.ZabbixSender{Addr: addr, Host: "web1"}).Push(); err == nil {
+		t.Error("No error was returned")
+	}
+}